@@ -5,22 +5,48 @@ import "gopkg.in/yaml.v2"
 type AggregateSpec struct {
 	APIVersion string    `yaml:"apiVersion"`
 	SpecType   string    `yaml:"specType"`
-	Namespace  string    `yaml:"namespace"`
+	Namespace  string    `yaml:"namespace,omitempty"`
 	Spec       Aggregate `yaml:"spec"`
 }
 
 type Aggregate struct {
-	Name          string    `yaml:"name"`
-	APIName       string    `yaml:"apiName"`
-	IsPublic      bool      `yaml:"isPublic"`
-	SchemaName    string    `yaml:"schemaName"`
-	SchemaVersion string    `yaml:"schemaVersion"`
-	Refs          []string  `yaml:"refs"`
-	Handlers      []Handler `yaml:"handlers"`
+	Name          string                 `yaml:"name"`
+	APIName       string                 `yaml:"apiName"`
+	Path          string                 `yaml:"path,omitempty"`
+	IsPublic      bool                   `yaml:"isPublic,omitempty"`
+	SchemaName    string                 `yaml:"schemaName,omitempty"`
+	SchemaVersion string                 `yaml:"schemaVersion,omitempty"`
+	Refs          []string               `yaml:"refs,omitempty"`
+	Body          *Body                  `yaml:"body,omitempty"`
+	Cache         *Cache                 `yaml:"cache,omitempty"`
+	Idempotency   *Idempotency           `yaml:"idempotency,omitempty"`
+	Authorization map[string]interface{} `yaml:"authorization,omitempty"`
+	Handlers      []Handler              `yaml:"handlers"`
+}
+
+type Body struct {
+	Streaming      bool     `yaml:"streaming"`
+	MaxBodySize    int64    `yaml:"maxBodySize,omitempty"`
+	SpillThreshold int64    `yaml:"spillThreshold,omitempty"`
+	TempDir        string   `yaml:"tempDir,omitempty"`
+	MediaTypes     []string `yaml:"mediaTypes,omitempty"`
+}
+
+type Cache struct {
+	Enabled     bool     `yaml:"enabled"`
+	TTL         string   `yaml:"ttl,omitempty"`
+	MaxEntries  int      `yaml:"maxEntries,omitempty"`
+	VaryHeaders []string `yaml:"varyHeaders,omitempty"`
+}
+
+type Idempotency struct {
+	Enabled bool   `yaml:"enabled"`
+	TTL     string `yaml:"ttl,omitempty"`
+	Path    string `yaml:"path,omitempty"`
 }
 
 type Target struct {
-	Name   string                 `yaml:"name"`
+	Name   string                 `yaml:"name,omitempty"`
 	Type   string                 `yaml:"type"`
 	Config map[string]interface{} `yaml:"config,omitempty"`
 }
@@ -1,6 +1,11 @@
 package model
 
-import "gopkg.in/yaml.v2"
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
 
 type HubSpec struct {
 	APIVersion string `yaml:"apiVersion"`
@@ -9,16 +14,165 @@ type HubSpec struct {
 }
 
 type Hub struct {
-	ApplicationName    string `yaml:"applicationName"`
-	ApplicationVersion string `yaml:"applicationVersion"`
-	PublicPort         int    `yaml:"publicPort"`
-	PrivatePort        int    `yaml:"privatePort"`
-	APIs               []API  `yaml:"apis"`
+	ApplicationName    string             `yaml:"applicationName"`
+	ApplicationVersion string             `yaml:"applicationVersion"`
+	PublicPort         int                `yaml:"publicPort"`
+	PrivatePort        int                `yaml:"privatePort"`
+	APIs               []API              `yaml:"apis"`
+	Tracing            *Tracing           `yaml:"tracing,omitempty"`
+	TLS                *TLS               `yaml:"tls,omitempty"`
+	APIKeys            *APIKeys           `yaml:"apiKeys,omitempty"`
+	CORS               *CORS              `yaml:"cors,omitempty"`
+	SecurityHeaders    *SecurityHeaders   `yaml:"securityHeaders,omitempty"`
+	Webhooks           *Webhooks          `yaml:"webhooks,omitempty"`
+	Audit              *Audit             `yaml:"audit,omitempty"`
+	HotReload          bool               `yaml:"hotReload,omitempty"`
+	Sidecars           map[string]Sidecar `yaml:"sidecars,omitempty"`
 }
 
+// API groups aggregates under a common base path, which defaults to
+// /{name}. Aggregates whose apiName names the API are served under the base
+// path, and the inbound and outbound tasks and the authorization policy of
+// the API apply to each of their handlers unless the aggregate overrides
+// them.
 type API struct {
-	Name       string            `yaml:"name"`
-	Aggregates map[string]string `yaml:"aggregates"`
+	Name          string                 `yaml:"name"`
+	BasePath      string                 `yaml:"basePath,omitempty"`
+	Aggregates    APIAggregates          `yaml:"aggregates,omitempty"`
+	Inbound       []Task                 `yaml:"inbound,omitempty"`
+	Outbound      []Task                 `yaml:"outbound,omitempty"`
+	Authorization map[string]interface{} `yaml:"authorization,omitempty"`
+}
+
+// APIAggregate selects the path segment an aggregate of the API is served
+// under, which defaults to its name. Namespace tells apart aggregates of the
+// same name defined by different teams.
+type APIAggregate struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Path      string `yaml:"path,omitempty"`
+}
+
+// APIAggregates are the aggregates of an API. They are listed as
+// APIAggregate entries, or in the older form of a map from the name of each
+// aggregate to the path it is served under, which may be empty:
+//
+//	aggregates:
+//	  chef: ""
+//	  recipe: recipes
+type APIAggregates []APIAggregate
+
+// UnmarshalYAML accepts both forms of APIAggregates.
+func (a *APIAggregates) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []APIAggregate
+	if err := unmarshal(&list); err == nil {
+		*a = list
+		return nil
+	}
+
+	var paths map[string]string
+	if err := unmarshal(&paths); err != nil {
+		return fmt.Errorf("aggregates must be a list of aggregates or a map of aggregate names to paths: %w", err)
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	*a = make(APIAggregates, len(names))
+	for i, name := range names {
+		(*a)[i] = APIAggregate{Name: name, Path: paths[name]}
+	}
+	return nil
+}
+
+// Sidecar is the process running the user-defined task and target types
+// written in the language it is keyed by, such as python. Each type is
+// called at {host}{pathPrefix}/tasks/{name} or
+// {host}{pathPrefix}/targets/{name}.
+type Sidecar struct {
+	Host       string      `yaml:"host"`
+	PathPrefix string      `yaml:"pathPrefix,omitempty"`
+	Timeout    string      `yaml:"timeout,omitempty"`
+	TLS        *SidecarTLS `yaml:"tls,omitempty"`
+}
+
+// SidecarTLS configures the client certificate presented to a sidecar and
+// the CA verifying it.
+type SidecarTLS struct {
+	CertFile string `yaml:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty"`
+	CAFile   string `yaml:"caFile,omitempty"`
+}
+
+type Tracing struct {
+	Enabled            bool              `yaml:"enabled"`
+	SamplerRatio       *float64          `yaml:"samplerRatio,omitempty"`
+	Exporter           string            `yaml:"exporter,omitempty"`
+	Endpoint           string            `yaml:"endpoint,omitempty"`
+	Insecure           bool              `yaml:"insecure,omitempty"`
+	Headers            map[string]string `yaml:"headers,omitempty"`
+	FilePath           string            `yaml:"filePath,omitempty"`
+	ResourceAttributes map[string]string `yaml:"resourceAttributes,omitempty"`
+}
+
+type TLS struct {
+	Public  *TLSListener `yaml:"public,omitempty"`
+	Private *TLSListener `yaml:"private,omitempty"`
+}
+
+type TLSListener struct {
+	CertFile     string   `yaml:"certFile"`
+	KeyFile      string   `yaml:"keyFile"`
+	ClientCAFile string   `yaml:"clientCAFile,omitempty"`
+	ClientAuth   string   `yaml:"clientAuth,omitempty"`
+	MinVersion   string   `yaml:"minVersion,omitempty"`
+	CipherPolicy string   `yaml:"cipherPolicy,omitempty"`
+	CipherSuites []string `yaml:"cipherSuites,omitempty"`
+}
+
+type APIKeys struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+// Webhooks enables the webhook dead letter admin API on the private port.
+// Path must match the deadLetterPath of the Webhook tasks and targets.
+type Webhooks struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+// Audit records every successful mutating request in an append-only log,
+// queried through the audit API on the private port.
+type Audit struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins"`
+	AllowedMethods   []string `yaml:"allowedMethods,omitempty"`
+	AllowedHeaders   []string `yaml:"allowedHeaders,omitempty"`
+	ExposedHeaders   []string `yaml:"exposedHeaders,omitempty"`
+	AllowCredentials bool     `yaml:"allowCredentials,omitempty"`
+	MaxAge           int      `yaml:"maxAge,omitempty"`
+}
+
+type SecurityHeaders struct {
+	HSTS                  *HSTS  `yaml:"hsts,omitempty"`
+	ContentSecurityPolicy string `yaml:"contentSecurityPolicy,omitempty"`
+	ContentTypeOptions    string `yaml:"contentTypeOptions,omitempty"`
+	FrameOptions          string `yaml:"frameOptions,omitempty"`
+	ReferrerPolicy        string `yaml:"referrerPolicy,omitempty"`
+}
+
+type HSTS struct {
+	MaxAge            int  `yaml:"maxAge"`
+	IncludeSubdomains bool `yaml:"includeSubdomains,omitempty"`
+	Preload           bool `yaml:"preload,omitempty"`
 }
 
 func UnmarshalHub(specYaml []byte) (*HubSpec, error) {
@@ -0,0 +1,50 @@
+package model
+
+import "gopkg.in/yaml.v2"
+
+// TypesSpec is a manifest of user-defined task or target types, such as
+// user-defined-task-types.yml:
+//
+//	apiVersion: v1
+//	specType: Tasks
+//	spec:
+//	  tasks:
+//	    - name: auditTask
+//	      language: go
+//	      config:
+//	        importPath: github.com/org/app/tasks
+//	        handlerName: AuditTask
+//	    - name: scoreRecipe
+//	      language: python
+//
+// Types written in Go name the handler registered with
+// api.RegisterTaskHandler or api.RegisterTargetHandler by its importPath and
+// handlerName. Types written in any other language are run by the sidecar
+// configured for that language in hub.yaml. The rest of config provides
+// defaults for the config of each task or target of the type.
+type TypesSpec struct {
+	APIVersion string `yaml:"apiVersion"`
+	SpecType   string `yaml:"specType"`
+	Spec       Types  `yaml:"spec"`
+}
+
+type Types struct {
+	Tasks   []TypeDefinition `yaml:"tasks,omitempty"`
+	Targets []TypeDefinition `yaml:"targets,omitempty"`
+}
+
+type TypeDefinition struct {
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description,omitempty"`
+	Language    string                 `yaml:"language"`
+	Config      map[string]interface{} `yaml:"config,omitempty"`
+}
+
+func UnmarshalTypes(specYaml []byte) (*TypesSpec, error) {
+	var typesSpec TypesSpec
+	if err := yaml.Unmarshal(specYaml, &typesSpec); err != nil {
+		return nil, err
+	}
+
+	return &typesSpec, nil
+}
@@ -0,0 +1,112 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Directory is a Source serving the files of a local directory. It also
+// serves directories with ConfigMaps mounted into them, as the files are
+// read through their links.
+type Directory struct {
+	path string
+}
+
+var (
+	_ Source   = (*Directory)(nil)
+	_ Notifier = (*Directory)(nil)
+)
+
+// NewDirectory creates a Source for the directory at path.
+func NewDirectory(path string) *Directory {
+	return &Directory{path: path}
+}
+
+// Open implements Source.
+func (d *Directory) Open(context.Context) (fs.FS, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", d.path)
+	}
+
+	return os.DirFS(d.path), nil
+}
+
+// Location implements Source.
+func (d *Directory) Location(name string) string {
+	return filepath.Join(d.path, filepath.FromSlash(name))
+}
+
+// Notify implements Notifier, watching the directory and every directory
+// under it.
+func (d *Directory) Notify(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := d.watchTree(watcher, d.path); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						d.watchTree(watcher, event.Name)
+					}
+				}
+				if IsSpecFile(event.Name) {
+					onChange()
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger := logging.GetLogger()
+				logger.Error().Err(err).Str("directory", d.path).Msg("configuration watcher error")
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (d *Directory) watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,182 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// DefaultPollInterval is used when no poll interval is configured.
+const DefaultPollInterval = 30 * time.Second
+
+// maxDocumentSize bounds the documents accepted from a config store.
+const maxDocumentSize = 16 << 20
+
+// HTTPConfig describes a remote config store.
+type HTTPConfig struct {
+	// URL serves a JSON object mapping the paths of the spec files to
+	// their contents, such as {"hub.yaml": "...", "aggregates/chef.yaml": "..."}.
+	URL string
+	// Header is sent with every request, for example to authenticate.
+	Header http.Header
+	// Interval is how often Notify polls for changes.
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// HTTP is a Source serving the spec files of a remote config store. ETags
+// are sent back with each request, so that unchanged files need not be
+// transferred again.
+type HTTP struct {
+	config HTTPConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	files  memoryFS
+	etag   string
+	digest [sha256.Size]byte
+}
+
+var (
+	_ Source   = (*HTTP)(nil)
+	_ Notifier = (*HTTP)(nil)
+)
+
+// NewHTTP creates a Source for the config store described by config.
+func NewHTTP(config HTTPConfig) *HTTP {
+	if config.Interval <= 0 {
+		config.Interval = DefaultPollInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &HTTP{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Open implements Source, fetching the files from the store.
+func (h *HTTP) Open(ctx context.Context) (fs.FS, error) {
+	files, _, err := h.fetch(ctx)
+	return files, err
+}
+
+// Location implements Source.
+func (h *HTTP) Location(name string) string {
+	return h.config.URL + "#" + name
+}
+
+// Notify implements Notifier, polling the store every interval.
+func (h *HTTP) Notify(ctx context.Context, onChange func()) error {
+	go func() {
+		ticker := time.NewTicker(h.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, changed, err := h.fetch(ctx)
+				if err != nil {
+					logger := logging.GetLogger()
+					logger.Error().Err(err).Str("url", h.config.URL).Msg("failed to poll config store")
+					continue
+				}
+				if changed {
+					onChange()
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// fetch returns the files of the store, and whether they changed since
+// they were last fetched.
+func (h *HTTP) fetch(ctx context.Context) (memoryFS, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.config.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	for name, values := range h.config.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if h.files != nil && h.etag != "" {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch configuration from %s: %w", h.config.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && h.files != nil {
+		return h.files, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to fetch configuration from %s: status %d", h.config.URL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > maxDocumentSize {
+		return nil, false, fmt.Errorf("configuration from %s is larger than %d bytes", h.config.URL, maxDocumentSize)
+	}
+
+	files, err := parseDocument(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid configuration from %s: %w", h.config.URL, err)
+	}
+
+	digest := sha256.Sum256(data)
+	changed := h.files != nil && digest != h.digest
+
+	h.files = files
+	h.etag = resp.Header.Get("ETag")
+	h.digest = digest
+
+	return files, changed, nil
+}
+
+// parseDocument builds a file system from a JSON object mapping paths to
+// file contents.
+func parseDocument(data []byte) (memoryFS, error) {
+	var document map[string]string
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	files := make(memoryFS, len(document))
+	for name, content := range document {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("invalid path %q", name)
+		}
+		files[path.Clean(name)] = []byte(content)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files")
+	}
+
+	return files, nil
+}
@@ -0,0 +1,70 @@
+// Package source provides the spec files read by the configurer: a local
+// directory, such as one with ConfigMaps mounted into it, an embedded
+// fs.FS for single binary deploys, or a remote config store polled over
+// HTTP. Paths are slash-separated and relative to the application root, as
+// in "hub.yaml" and "aggregates/recipe.yaml".
+package source
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Source provides the spec files of an application.
+type Source interface {
+	// Open returns the current files of the application.
+	Open(ctx context.Context) (fs.FS, error)
+
+	// Location describes where the named file comes from, for messages.
+	Location(name string) string
+}
+
+// Notifier is implemented by sources which can report changes to their
+// files.
+type Notifier interface {
+	// Notify calls onChange whenever the files change, until ctx is done.
+	// It returns once watching has started.
+	Notify(ctx context.Context, onChange func()) error
+}
+
+// FS is a Source serving the files of an fs.FS, such as an embed.FS.
+type FS struct {
+	name string
+	fsys fs.FS
+}
+
+var _ Source = (*FS)(nil)
+
+// NewFS creates a Source for fsys. name identifies it in messages.
+func NewFS(name string, fsys fs.FS) *FS {
+	return &FS{name: name, fsys: fsys}
+}
+
+// Open implements Source.
+func (s *FS) Open(context.Context) (fs.FS, error) {
+	return s.fsys, nil
+}
+
+// Location implements Source.
+func (s *FS) Location(name string) string {
+	return path.Join(s.name, name)
+}
+
+// IsSpecFile reports whether a change to name may change the
+// configuration. Besides spec and schema files, it includes the ..data
+// links swapped by Kubernetes when a mounted ConfigMap is updated.
+func IsSpecFile(name string) bool {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if strings.HasPrefix(base, "..") {
+		return true
+	}
+
+	switch strings.ToLower(path.Ext(base)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
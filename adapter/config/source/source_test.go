@@ -0,0 +1,203 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configStore is a stand-in for a remote config store.
+type configStore struct {
+	mu       sync.Mutex
+	files    map[string]string
+	version  int
+	requests atomic.Int32
+	notMod   atomic.Int32
+}
+
+func (s *configStore) set(files map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = files
+	s.version++
+}
+
+func (s *configStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	etag := `"v` + strconv.Itoa(s.version) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		s.notMod.Add(1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.files)
+}
+
+func TestFS(t *testing.T) {
+	src := NewFS("embedded", fstest.MapFS{
+		"hub.yaml": {Data: []byte("apiVersion: v1\n")},
+	})
+
+	files, err := src.Open(context.Background())
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(files, "hub.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v1\n", string(data))
+	assert.Equal(t, "embedded/aggregates/recipe.yaml", src.Location("aggregates/recipe.yaml"))
+}
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hub.yaml"), []byte("apiVersion: v1\n"), 0644))
+
+	src := NewDirectory(dir)
+	files, err := src.Open(context.Background())
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(files, "hub.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v1\n", string(data))
+	assert.Equal(t, filepath.Join(dir, "aggregates", "recipe.yaml"), src.Location("aggregates/recipe.yaml"))
+
+	_, err = NewDirectory(filepath.Join(dir, "missing")).Open(context.Background())
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestDirectory_Notify(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "aggregates"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 16)
+	require.NoError(t, NewDirectory(dir).Notify(ctx, func() { changes <- struct{}{} }))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "aggregates", "recipe.yaml"), []byte("spec: {}\n"), 0644))
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	store := &configStore{}
+	store.set(map[string]string{
+		"hub.yaml":             "apiVersion: v1\n",
+		"aggregates/chef.yaml": "spec: {}\n",
+	})
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	src := NewHTTP(HTTPConfig{URL: server.URL, Header: http.Header{"Authorization": {"Bearer token"}}})
+
+	files, err := src.Open(context.Background())
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(files, "aggregates/chef.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "spec: {}\n", string(data))
+	assert.Equal(t, server.URL+"#hub.yaml", src.Location("hub.yaml"))
+	require.NoError(t, fstest.TestFS(files, "hub.yaml", "aggregates/chef.yaml"))
+
+	// unchanged files are not transferred again
+	files, err = src.Open(context.Background())
+	require.NoError(t, err)
+	_, err = fs.ReadFile(files, "hub.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), store.notMod.Load())
+}
+
+func TestHTTP_Notify(t *testing.T) {
+	store := &configStore{}
+	store.set(map[string]string{"hub.yaml": "apiVersion: v1\n"})
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	src := NewHTTP(HTTPConfig{URL: server.URL, Interval: 20 * time.Millisecond})
+	_, err := src.Open(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 16)
+	require.NoError(t, src.Notify(ctx, func() { changes <- struct{}{} }))
+
+	// polls of an unchanged store report nothing
+	require.Eventually(t, func() bool { return store.requests.Load() >= 4 }, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, changes)
+
+	store.set(map[string]string{"hub.yaml": "apiVersion: v2\n"})
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	files, err := src.Open(context.Background())
+	require.NoError(t, err)
+	data, err := fs.ReadFile(files, "hub.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v2\n", string(data))
+}
+
+func TestHTTP_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{name: "status", status: http.StatusInternalServerError, want: "status 500"},
+		{name: "not json", status: http.StatusOK, body: "hub.yaml: x", want: "invalid configuration"},
+		{name: "empty", status: http.StatusOK, body: "{}", want: "no files"},
+		{name: "escaping path", status: http.StatusOK, body: `{"../hub.yaml": "x"}`, want: `invalid path "../hub.yaml"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := NewHTTP(HTTPConfig{URL: server.URL}).Open(context.Background())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestIsSpecFile(t *testing.T) {
+	assert.True(t, IsSpecFile("aggregates/recipe.yaml"))
+	assert.True(t, IsSpecFile("schemas/recipe.JSON"))
+	assert.True(t, IsSpecFile("/etc/hub/..data"))
+	assert.False(t, IsSpecFile("notes.txt"))
+}
@@ -0,0 +1,198 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+)
+
+// Aggregates are told apart by their namespace, API and name, so that two
+// teams may each define an aggregate of the same name in their own
+// namespace. An API of hub.yaml decides the path each of its aggregates is
+// served under, unless the aggregate spec sets a path of its own:
+//
+//	apis:
+//	  - name: shop
+//	    basePath: /shop/v1
+//	    inbound:
+//	      - name: authenticate
+//	        type: ValidateJWT
+//	    aggregates:
+//	      - name: order
+//	        namespace: billing
+//	        path: invoices
+//	      - name: order
+//	        namespace: shipping
+//	        path: shipments
+//
+// Refs name the aggregates an aggregate refers to, as name in its own
+// namespace or as namespace/name in another.
+
+// qualifiedName returns name prefixed with namespace, if it has one.
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// aggregateKey identifies an aggregate by its namespace, API and name.
+func aggregateKey(namespace, apiName, name string) string {
+	return strings.ToLower(namespace + "/" + apiName + "/" + name)
+}
+
+// parseRef splits a ref into its namespace, defaulting to namespace, and
+// name.
+func parseRef(namespace, ref string) (string, string) {
+	if refNamespace, name, ok := strings.Cut(ref, "/"); ok {
+		return refNamespace, name
+	}
+	return namespace, ref
+}
+
+// findAPI returns the API of hub.yaml named name, or nil.
+func findAPI(apis []model.API, name string) *model.API {
+	for i := range apis {
+		if strings.EqualFold(apis[i].Name, name) {
+			return &apis[i]
+		}
+	}
+	return nil
+}
+
+// basePath returns the path the aggregates of api are served under.
+func basePath(api *model.API) string {
+	if api.BasePath == "" {
+		return "/" + api.Name
+	}
+	return "/" + strings.Trim(api.BasePath, "/")
+}
+
+// routePath returns the path an aggregate of api is served under.
+func routePath(api *model.API, namespace, name string) string {
+	segment := name
+	for _, aggregate := range api.Aggregates {
+		if strings.EqualFold(aggregate.Name, name) && strings.EqualFold(aggregate.Namespace, namespace) && aggregate.Path != "" {
+			segment = strings.Trim(aggregate.Path, "/")
+		}
+	}
+
+	base := basePath(api)
+	if base == "/" {
+		return "/" + segment
+	}
+	return base + "/" + segment
+}
+
+// specPath returns the path an aggregate spec sets, which it is served
+// under rather than the path its API gives it.
+func specPath(path string) string {
+	return "/" + strings.Trim(path, "/")
+}
+
+// withAPIDefaults adds the inbound and outbound tasks of api to each of
+// handlers.
+func withAPIDefaults(handlers []model.Handler, api *model.API) []model.Handler {
+	merged := make([]model.Handler, len(handlers))
+	for i, handler := range handlers {
+		handler.Inbound = withAPITasks(handler.Inbound, api.Inbound)
+		handler.Outbound = withAPITasks(handler.Outbound, api.Outbound)
+		merged[i] = handler
+	}
+	return merged
+}
+
+// withAPITasks adds the tasks of an API to the tasks of a handler, unless
+// the handler has a task of the same name.
+func withAPITasks(tasks, apiTasks []model.Task) []model.Task {
+	if len(apiTasks) == 0 {
+		return tasks
+	}
+
+	merged := make([]model.Task, 0, len(apiTasks)+len(tasks))
+	for _, apiTask := range apiTasks {
+		overridden := false
+		for _, task := range tasks {
+			overridden = overridden || task.Name == apiTask.Name
+		}
+		if !overridden {
+			merged = append(merged, apiTask)
+		}
+	}
+
+	return append(merged, tasks...)
+}
+
+// findUndefinedAggregates calls listed with the indices of each API of
+// apis and of each aggregate it lists which is not defined by specs, and
+// referred with the indices of each of specs and of each of its refs which
+// is not a defined aggregate, along with a message describing the problem.
+func findUndefinedAggregates(
+	apis []model.API,
+	specs []model.AggregateSpec,
+	listed func(api, aggregate int, message string),
+	referred func(spec, ref int, message string),
+) {
+	defined := make(map[string]bool)
+	namespaced := make(map[string]bool)
+	for _, spec := range specs {
+		defined[aggregateKey(spec.Namespace, spec.Spec.APIName, spec.Spec.Name)] = true
+		namespaced[strings.ToLower(qualifiedName(spec.Namespace, spec.Spec.Name))] = true
+	}
+
+	for i, api := range apis {
+		for j, aggregate := range api.Aggregates {
+			if !defined[aggregateKey(aggregate.Namespace, api.Name, aggregate.Name)] {
+				listed(i, j, fmt.Sprintf("api %s lists aggregate %s, which is not defined with apiName %s",
+					api.Name, qualifiedName(aggregate.Namespace, aggregate.Name), api.Name))
+			}
+		}
+	}
+
+	for i, spec := range specs {
+		for j, ref := range spec.Spec.Refs {
+			namespace, name := parseRef(spec.Namespace, ref)
+			if !namespaced[strings.ToLower(qualifiedName(namespace, name))] {
+				referred(i, j, fmt.Sprintf("ref %s is not a defined aggregate", qualifiedName(namespace, name)))
+			}
+		}
+	}
+}
+
+// checkAPIs checks that the aggregates listed by the APIs of hub.yaml and
+// the refs of each aggregate exist, returning the first problem found.
+func checkAPIs(hubSpec *model.HubSpec, specs map[string]*model.AggregateSpec) error {
+	// report problems in a stable order
+	files := make([]string, 0, len(specs))
+	for file := range specs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	aggregates := make([]model.AggregateSpec, len(files))
+	for i, file := range files {
+		aggregates[i] = *specs[file]
+	}
+
+	var apis []model.API
+	if hubSpec != nil {
+		apis = hubSpec.Spec.APIs
+	}
+
+	var problems []string
+	findUndefinedAggregates(apis, aggregates,
+		func(_, _ int, message string) {
+			problems = append(problems, message)
+		},
+		func(spec, _ int, message string) {
+			problems = append(problems, files[spec]+": "+message)
+		})
+
+	if len(problems) > 0 {
+		return errors.New(problems[0])
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package yaml
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/adapter/handler/requesthandler"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shopHubYAML = `
+spec:
+  applicationName: TestApp
+  apis:
+    - name: shop
+      basePath: /shop/v1
+      outbound:
+        - name: respond
+          type: MockTask
+      aggregates:
+        - name: order
+          namespace: billing
+          path: invoices
+        - name: order
+          namespace: shipping
+          path: shipments
+`
+
+// orderAggregateYAML is an aggregate whose handler leaves the response of
+// its target untouched, so that the tasks added by its API can be told
+// apart.
+const orderAggregateYAML = `
+namespace: %s
+spec:
+  name: order
+  apiName: shop
+  refs: %s
+  handlers:
+    - methods: ["GET"]
+      inbound: []
+      outbound: []
+      target:
+        type: MockTarget
+`
+
+func orderAggregate(namespace string, refs string) string {
+	return fmt.Sprintf(orderAggregateYAML, namespace, refs)
+}
+
+func TestWithAPIDefaults(t *testing.T) {
+	api := &model.API{
+		Inbound:  []model.Task{{Name: "authenticate", Type: "ValidateJWT"}, {Name: "log", Type: "LogWriter"}},
+		Outbound: []model.Task{{Name: "log", Type: "LogWriter"}},
+	}
+	handlers := []model.Handler{{
+		Inbound: []model.Task{{Name: "log", Type: "RequestLogger"}},
+	}}
+
+	merged := withAPIDefaults(handlers, api)
+
+	assert.Equal(t, []model.Task{{Name: "authenticate", Type: "ValidateJWT"}, {Name: "log", Type: "RequestLogger"}}, merged[0].Inbound)
+	assert.Equal(t, []model.Task{{Name: "log", Type: "LogWriter"}}, merged[0].Outbound)
+	assert.Len(t, handlers[0].Inbound, 1, "the handlers passed in are left unchanged")
+}
+
+func TestRoutePath(t *testing.T) {
+	api := &model.API{Name: "shop", Aggregates: []model.APIAggregate{{Name: "order", Namespace: "billing", Path: "invoices"}}}
+	assert.Equal(t, "/shop/invoices", routePath(api, "billing", "order"))
+	assert.Equal(t, "/shop/order", routePath(api, "shipping", "order"))
+
+	api.BasePath = "/shop/v1/"
+	assert.Equal(t, "/shop/v1/invoices", routePath(api, "billing", "order"))
+
+	api.BasePath = "/"
+	assert.Equal(t, "/order", routePath(api, "shipping", "order"))
+}
+
+func TestAPIAggregatesMapForm(t *testing.T) {
+	hubSpec, err := model.UnmarshalHub([]byte(`
+spec:
+  apis:
+    - name: shop
+      aggregates:
+        order: invoices
+        cart: ""
+`))
+	require.NoError(t, err)
+	assert.Equal(t, model.APIAggregates{{Name: "cart"}, {Name: "order", Path: "invoices"}}, hubSpec.Spec.APIs[0].Aggregates)
+
+	_, err = model.UnmarshalHub([]byte("spec:\n  apis:\n    - name: shop\n      aggregates: order\n"))
+	assert.Error(t, err)
+}
+
+func TestConfigureHub_APIs(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                 shopHubYAML,
+		"aggregates/billing.yaml":  orderAggregate("billing", "[shipping/order]"),
+		"aggregates/shipping.yaml": orderAggregate("shipping", "[]"),
+		"aggregates/test.yaml":     validAggregateYAML,
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	})
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+	require.NoError(t, NewConfigurer(dir).ConfigureHub(hub))
+
+	billing, ok := hub.GetNamespacedService("billing", "shop", "order")
+	require.True(t, ok)
+	assert.Equal(t, "/shop/v1/invoices", billing.RoutePath())
+	shipping, ok := hub.GetNamespacedService("shipping", "shop", "order")
+	require.True(t, ok)
+	assert.Equal(t, "/shop/v1/shipments", shipping.RoutePath())
+
+	handler := requesthandler.NewRequestHandler(8080, hub)
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	for _, path := range []string{"/shop/v1/invoices/42", "/shop/v1/shipments", "/internal/call/shop/v1/shipments/7"} {
+		rr := get(path)
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+		assert.Equal(t, "MockTask", rr.Body.String(), "%s runs the outbound tasks of the api", path)
+	}
+
+	// aggregates of apis which hub.yaml does not list keep their own path
+	rr := get("/testapp/testaggregate")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "MockTarget", rr.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/shop/order").Code)
+	assert.Equal(t, http.StatusNotFound, get("/shop/v1/order").Code)
+}
+
+func TestConfigureHub_APIErrors(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "unknown ref",
+			files: map[string]string{
+				"hub.yaml":                 shopHubYAML,
+				"aggregates/billing.yaml":  orderAggregate("billing", "[customer]"),
+				"aggregates/shipping.yaml": orderAggregate("shipping", "[]"),
+			},
+			want: "billing.yaml: ref billing/customer is not a defined aggregate",
+		},
+		{
+			name: "unknown aggregate",
+			files: map[string]string{
+				"hub.yaml":                shopHubYAML,
+				"aggregates/billing.yaml": orderAggregate("billing", "[]"),
+			},
+			want: "api shop lists aggregate shipping/order, which is not defined with apiName shop",
+		},
+		{
+			name: "same path",
+			files: map[string]string{
+				"hub.yaml":                 "spec:\n  applicationName: TestApp\n",
+				"aggregates/billing.yaml":  orderAggregate("billing", "[]"),
+				"aggregates/shipping.yaml": orderAggregate("shipping", "[]"),
+			},
+			want: "cannot be served under /shop/order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zerolog.Nop()
+			hub, err := entity.NewHub(&logger, "TestApp")
+			require.NoError(t, err)
+
+			tt.files["schemas/schemas.yaml"] = validSchemasYAML
+			tt.files["schemas/test.schema.json"] = `{"type": "object"}`
+
+			err = NewConfigurer(writeValidationDirectory(t, tt.files)).ConfigureHub(hub)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestValidate_APIs(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml": `spec:
+  applicationName: TestApp
+  apis:
+    - name: shop
+      basePath: shop
+      inbound:
+        - name: authenticate
+          type: MissingTask
+      aggregates:
+        - name: order
+          namespace: billing
+        - name: cart
+    - name: shop
+`,
+		"aggregates/billing.yaml":  orderAggregate("billing", "[shipping/order, customer]"),
+		"aggregates/shipping.yaml": orderAggregate("shipping", "[]"),
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	})
+
+	err := NewConfigurer(dir).Validate()
+	require.Error(t, err)
+
+	hubFile := dir + "/hub.yaml"
+	billingFile := dir + "/aggregates/billing.yaml"
+	shippingFile := dir + "/aggregates/shipping.yaml"
+	assert.Equal(t, strings.Join([]string{
+		billingFile + ":6:26: ref billing/customer is not a defined aggregate",
+		shippingFile + ":4:3: aggregate shipping/order is served under /shop/order, as is the aggregate at " + billingFile + ":4:3",
+		hubFile + ":5:17: basePath \"shop\" must start with /",
+		hubFile + ":8:17: unknown task type \"MissingTask\"",
+		hubFile + ":12:11: api shop lists aggregate cart, which is not defined with apiName shop",
+		hubFile + ":13:7: api shop is already defined at " + hubFile + ":4:7",
+	}, "\n"), err.Error())
+}
+
+func TestValidate_APIAggregatesServedUnderOneName(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml": `spec:
+  applicationName: TestApp
+  apis:
+    - name: shop
+      aggregates:
+        - name: order
+          namespace: billing
+          path: a/order
+        - name: order
+          namespace: shipping
+          path: b/order
+`,
+		"aggregates/billing.yaml":  orderAggregate("billing", "[]"),
+		"aggregates/shipping.yaml": orderAggregate("shipping", "[]"),
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	})
+
+	err := NewConfigurer(dir).Validate()
+	require.Error(t, err)
+
+	billingFile := dir + "/aggregates/billing.yaml"
+	shippingFile := dir + "/aggregates/shipping.yaml"
+	assert.Equal(t, shippingFile+":4:3: aggregate shipping/order is served under the name order in api shop, as is the aggregate at "+billingFile+":4:3", err.Error())
+}
@@ -1,47 +1,164 @@
 package yaml
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
+	"sync"
+	"time"
 
 	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
 
 	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
 
 	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/adapter/config/source"
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/cache"
+	"github.com/QueerGlobal/hub-framework/service/idempotency"
+	"github.com/QueerGlobal/hub-framework/service/logging"
 )
 
 type Configurer struct {
 	applicationDirectory string
+	profile              string
+	source               source.Source
+	skipGoHandlerChecks  bool             // Validate does not look up Go handlers
+	services             []ServiceBuilder // defined in code rather than by aggregate specs
+	files                fs.FS            // the files being applied
+	hubSpec              *model.HubSpec
+	stopWatching         context.CancelFunc
+	mu                   sync.Mutex // guards hubSpec and stopWatching once watching
 }
 
-func NewConfigurer(applicationDirectory string) *Configurer {
-	return &Configurer{
+// ConfigurerOption configures a Configurer.
+type ConfigurerOption func(*Configurer)
+
+// WithSource reads the specs from src rather than from the application
+// directory.
+func WithSource(src source.Source) ConfigurerOption {
+	return func(c *Configurer) {
+		c.source = src
+	}
+}
+
+// WithoutGoHandlerChecks stops Validate from requiring the Go handlers of
+// user-defined types to be registered, for tools such as the hub command
+// which are not linked with the application.
+func WithoutGoHandlerChecks() ConfigurerOption {
+	return func(c *Configurer) {
+		c.skipGoHandlerChecks = true
+	}
+}
+
+// ServiceBuilder builds a service defined in code, such as
+// api.AggregateBuilder.
+type ServiceBuilder interface {
+	Build() (*entity.Service, error)
+}
+
+// WithServices adds services defined in code to the hub alongside the
+// aggregates of the specs. They are built once the user-defined types are
+// registered, and rebuilt on every reload. The APIs of hub.yaml do not
+// apply to them.
+func WithServices(services ...ServiceBuilder) ConfigurerOption {
+	return func(c *Configurer) {
+		c.services = append(c.services, services...)
+	}
+}
+
+// NewConfigurer creates a Configurer for the specs in applicationDirectory.
+// The profile defaults to the value of the HUB_PROFILE environment variable.
+func NewConfigurer(applicationDirectory string, opts ...ConfigurerOption) *Configurer {
+	c := &Configurer{
 		applicationDirectory: applicationDirectory,
+		profile:              os.Getenv(ProfileEnv),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.source == nil {
+		c.source = source.NewDirectory(applicationDirectory)
+	}
+
+	return c
+}
+
+// open takes a snapshot of the files of the source, which the specs are
+// then read from.
+func (c *Configurer) open() error {
+	files, err := c.source.Open(context.Background())
+	if err != nil {
+		return err
 	}
+
+	c.files = files
+	return nil
+}
+
+// snapshot returns the files being applied, taking a snapshot if there is
+// none.
+func (c *Configurer) snapshot() (fs.FS, error) {
+	if c.files == nil {
+		if err := c.open(); err != nil {
+			return nil, err
+		}
+	}
+	return c.files, nil
 }
 
+// ConfigureHub configures hub from the spec files of the application
+// directory. Secrets interpolated into the specs are masked in the errors
+// it returns.
 func (c *Configurer) ConfigureHub(hub *entity.Hub) error {
+	return logging.RedactError(c.configureHub(hub))
+}
+
+func (c *Configurer) configureHub(hub *entity.Hub) error {
+	if err := c.open(); err != nil {
+		return err
+	}
+
+	if err := c.checkProfile(); err != nil {
+		return err
+	}
+
 	hubConfig, err := c.readHubSpec()
 	if err != nil {
 		return err
 	}
 
+	c.hubSpec = hubConfig
+
 	if err := c.applyHubSpec(hub, hubConfig); err != nil {
 		return err
 	}
 
+	if err := c.registerUserDefinedTypes(hubConfig); err != nil {
+		return err
+	}
+
 	aggregateSpecs, err := c.readAggregateSpecs()
 	if err != nil {
 		return err
 	}
 
+	if err := checkAPIs(hubConfig, *aggregateSpecs); err != nil {
+		return err
+	}
+
 	if err := c.applyAggregateSpecs(hub, aggregateSpecs); err != nil {
 		return err
 	}
 
+	if err := c.addServices(hub); err != nil {
+		return err
+	}
+
 	schemas, err := c.readSchemas()
 	if err != nil {
 		return err
@@ -54,33 +171,30 @@ func (c *Configurer) ConfigureHub(hub *entity.Hub) error {
 	return nil
 }
 
+// GetHubSpec returns the hub spec read by the last call to ConfigureHub,
+// or nil if the hub has not been configured yet.
+func (c *Configurer) GetHubSpec() *model.HubSpec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hubSpec
+}
+
 func (c *Configurer) readAggregateSpecs() (*map[string]*model.AggregateSpec, error) {
 	aggregateMap := make(map[string]*model.AggregateSpec)
-	aggregateDir := filepath.Join(c.applicationDirectory, "aggregates")
 
-	files, err := os.ReadDir(aggregateDir)
+	files, err := c.specFileNames("aggregates", true)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		filePath := filepath.Join(aggregateDir, file.Name())
-		aggregateData, err := os.ReadFile(filePath)
-		if err != nil {
-			return nil, err
-		}
-
 		var aggregateSpec model.AggregateSpec
-		err = yaml.Unmarshal(aggregateData, &aggregateSpec)
+		err = c.readSpec(path.Join("aggregates", file), &aggregateSpec)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal aggregate spec %s: %v", file.Name(), err)
+			return nil, fmt.Errorf("failed to unmarshal aggregate spec %s: %v", file, err)
 		}
 
-		aggregateMap[file.Name()] = &aggregateSpec
+		aggregateMap[file] = &aggregateSpec
 	}
 
 	return &aggregateMap, nil
@@ -88,51 +202,73 @@ func (c *Configurer) readAggregateSpecs() (*map[string]*model.AggregateSpec, err
 
 func (c *Configurer) readSchemas() (*map[string]*model.SchemasSpec, error) {
 	schemaMap := make(map[string]*model.SchemasSpec)
-	schemaDir := filepath.Join(c.applicationDirectory, "schemas")
-
-	files, err := os.ReadDir(schemaDir)
+	files, err := c.specFileNames("schemas", false)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		filePath := filepath.Join(schemaDir, file.Name())
-		schemaData, err := os.ReadFile(filePath)
-		if err != nil {
-			return nil, err
-		}
 
 		var schemaSpec model.SchemasSpec
-		err = yaml.Unmarshal(schemaData, &schemaSpec)
+		err = c.readSpecFile(path.Join("schemas", file), &schemaSpec)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal schema spec %s: %v", file.Name(), err)
+			return nil, fmt.Errorf("failed to unmarshal schema spec %s: %v", file, err)
 		}
 
-		schemaMap[file.Name()] = &schemaSpec
+		schemaMap[file] = &schemaSpec
 	}
 
 	return &schemaMap, nil
 }
 
 func (c *Configurer) readHubSpec() (*model.HubSpec, error) {
-	hubFile := filepath.Join(c.applicationDirectory, "hub.yaml")
+	var hub model.HubSpec
+	if err := c.readSpec("hub.yaml", &hub); err != nil {
+		return nil, err
+	}
+
+	return &hub, nil
+}
 
-	hubData, err := os.ReadFile(hubFile)
+// readSpec reads the spec at rel, relative to the application directory,
+// with the overlay of the selected profile merged onto it.
+func (c *Configurer) readSpec(rel string, spec interface{}) error {
+	document, err := c.readDocument(rel, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var hub model.HubSpec
-	err = yaml.Unmarshal(hubData, &hub)
+	return decodeSpec(document, spec)
+}
+
+// readSpecFile reads a spec file which has no overlay.
+func (c *Configurer) readSpecFile(name string, spec interface{}) error {
+	files, err := c.snapshot()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &hub, nil
+	document, err := readNode(files, name)
+	if err != nil {
+		return err
+	}
+
+	return decodeSpec(document, spec)
+}
+
+// decodeSpec resolves the environment and file references of a spec
+// document and decodes it into spec.
+func decodeSpec(document *yamlv3.Node, spec interface{}) error {
+	if err := newInterpolator().interpolateDocument(document); err != nil {
+		return err
+	}
+
+	data, err := yamlv3.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, spec)
 }
 
 func (c *Configurer) applyAggregateSpecs(hub *entity.Hub, specs *map[string]*model.AggregateSpec) error {
@@ -140,17 +276,40 @@ func (c *Configurer) applyAggregateSpecs(hub *entity.Hub, specs *map[string]*mod
 		return domainerr.ErrEmptyInput
 	}
 
+	var apis []model.API
+	if c.hubSpec != nil {
+		apis = c.hubSpec.Spec.APIs
+	}
+
 	for _, aggregateSpec := range *specs {
 		aggregate := &model.Aggregate{
 			Name:          aggregateSpec.Spec.Name,
 			APIName:       aggregateSpec.Spec.APIName,
+			Path:          aggregateSpec.Spec.Path,
 			SchemaName:    aggregateSpec.Spec.SchemaName,
 			SchemaVersion: aggregateSpec.Spec.SchemaVersion,
 			IsPublic:      aggregateSpec.Spec.IsPublic,
+			Refs:          aggregateSpec.Spec.Refs,
+			Body:          aggregateSpec.Spec.Body,
+			Cache:         aggregateSpec.Spec.Cache,
+			Idempotency:   aggregateSpec.Spec.Idempotency,
+			Authorization: aggregateSpec.Spec.Authorization,
 			Handlers:      aggregateSpec.Spec.Handlers,
 		}
 
-		err := c.addOrConfigureAggregate(hub, aggregate)
+		var routed string
+		if api := findAPI(apis, aggregate.APIName); api != nil {
+			routed = routePath(api, aggregateSpec.Namespace, aggregate.Name)
+			aggregate.Handlers = withAPIDefaults(aggregate.Handlers, api)
+			if aggregate.Authorization == nil {
+				aggregate.Authorization = api.Authorization
+			}
+		}
+		if aggregate.Path != "" {
+			routed = specPath(aggregate.Path)
+		}
+
+		err := c.addOrConfigureAggregate(hub, aggregateSpec.Namespace, routed, aggregate)
 		if err != nil {
 			return fmt.Errorf("failed to configure aggregate %s: %w", aggregateSpec.Spec.Name, err)
 		}
@@ -159,40 +318,116 @@ func (c *Configurer) applyAggregateSpecs(hub *entity.Hub, specs *map[string]*mod
 	return nil
 }
 
-func (c *Configurer) addOrConfigureAggregate(hub *entity.Hub, aggregate *model.Aggregate) error {
+// addServices adds the services defined in code to hub, which must not
+// already serve a service of the same namespace, API and name.
+func (c *Configurer) addServices(hub *entity.Hub) error {
+	for _, builder := range c.services {
+		svc, err := builder.Build()
+		if err != nil {
+			return err
+		}
+		if _, ok := hub.GetNamespacedService(svc.Namespace, svc.APIName, svc.Name); ok {
+			return fmt.Errorf("aggregate %s of api %s is defined both in code and by an aggregate spec", svc.QualifiedName(), svc.APIName)
+		}
+		if err := hub.AddService(svc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addOrConfigureAggregate adds the service of an aggregate in namespace to
+// hub, served under routePath, or under /{api}/{name} when it is "".
+func (c *Configurer) addOrConfigureAggregate(hub *entity.Hub, namespace, routePath string, aggregate *model.Aggregate) error {
 	if hub == nil || aggregate == nil {
 		return domainerr.ErrEmptyInput
 	}
 
 	var err error
 
-	aggregateSvc, ok := hub.GetService(aggregate.APIName, aggregate.Name)
+	aggregateSvc, ok := hub.GetNamespacedService(namespace, aggregate.APIName, aggregate.Name)
 	if !ok {
 		aggregateSvc, err = entity.NewService(aggregate.APIName, aggregate.Name, aggregate.SchemaName, aggregate.SchemaVersion, aggregate.IsPublic)
 		if err != nil {
 			return err
 		}
+		aggregateSvc.Namespace = namespace
 	}
 
+	aggregateSvc.Path = routePath
+	aggregateSvc.Refs = aggregate.Refs
 	aggregateSvc.IsPublic = aggregate.IsPublic
 	aggregateSvc.SchemaName = aggregate.SchemaName
 	aggregateSvc.SchemaVersion = aggregate.SchemaVersion
-	err = c.buildHandlers(aggregateSvc, aggregate.Handlers)
+
+	if aggregate.Body != nil {
+		aggregateSvc.BodyOptions = entity.BodyOptions{
+			Streaming:      aggregate.Body.Streaming,
+			MaxBodySize:    aggregate.Body.MaxBodySize,
+			SpillThreshold: aggregate.Body.SpillThreshold,
+			TempDir:        aggregate.Body.TempDir,
+			MediaTypes:     aggregate.Body.MediaTypes,
+		}
+	}
+
+	if aggregate.Cache != nil && aggregate.Cache.Enabled {
+		cacheConfig := cache.Config{
+			MaxEntries:  aggregate.Cache.MaxEntries,
+			VaryHeaders: aggregate.Cache.VaryHeaders,
+		}
+
+		if aggregate.Cache.TTL != "" {
+			cacheConfig.TTL, err = time.ParseDuration(aggregate.Cache.TTL)
+			if err != nil {
+				return fmt.Errorf("invalid cache ttl %s: %w", aggregate.Cache.TTL, err)
+			}
+		}
+
+		aggregateSvc.Cache = cache.New(cacheConfig)
+	}
+
+	if aggregate.Idempotency != nil && aggregate.Idempotency.Enabled {
+		idempotencyConfig := idempotency.Config{}
+
+		if aggregate.Idempotency.TTL != "" {
+			idempotencyConfig.TTL, err = time.ParseDuration(aggregate.Idempotency.TTL)
+			if err != nil {
+				return fmt.Errorf("invalid idempotency ttl %s: %w", aggregate.Idempotency.TTL, err)
+			}
+		}
+
+		path := idempotency.DefaultPath
+		if aggregate.Idempotency.Path != "" {
+			path = aggregate.Idempotency.Path
+		}
+
+		store, err := idempotency.OpenBadgerStore(path)
+		if err != nil {
+			return err
+		}
+
+		aggregateSvc.Idempotency = idempotency.New(idempotencyConfig, store)
+	}
+
+	err = c.buildHandlers(aggregateSvc, aggregate.Handlers, aggregate.Authorization)
 	if err != nil {
 		return err
 	}
 
-	hub.AddService(aggregateSvc)
-
-	return nil
+	return hub.AddService(aggregateSvc)
 }
 
-func (c *Configurer) buildHandlers(svc *entity.Service, handlers []model.Handler) error {
+func (c *Configurer) buildHandlers(svc *entity.Service, handlers []model.Handler, authorization map[string]interface{}) error {
 	if svc == nil || handlers == nil {
 		return domainerr.ErrEmptyInput
 	}
 
 	for _, hndlr := range handlers {
+		if authorization != nil {
+			hndlr.Inbound = withAuthorization(hndlr.Inbound, authorization)
+		}
+
 		handler, err := c.buildHandler(&hndlr)
 		if err != nil {
 			return err
@@ -236,17 +471,52 @@ func (c *Configurer) buildHandler(handler *model.Handler) (*entity.Handler, erro
 		InboundWorkflow:  inboundWorkflow,
 		OutboundWorkflow: outboundWorkflow,
 		Target:           handlerTarget,
+		TargetName:       handler.Target.Name,
+		TargetType:       handler.Target.Type,
+		TargetConfig:     normalizeConfig(handler.Target.Config),
 	}
 
 	return entityHandler, nil
 }
 
+// withAuthorization appends an Authorize step to an inbound workflow. Unless
+// the policy sets a precedence, the step runs after every other inbound task
+// so that the claims set by authentication tasks are available.
+func withAuthorization(inbound []model.Task, authorization map[string]interface{}) []model.Task {
+	precedence := 0
+	for _, task := range inbound {
+		if task.Precedence >= precedence {
+			precedence = task.Precedence + 1
+		}
+	}
+	// the precedence places the step, and is not a setting of the task
+	config := make(map[string]interface{}, len(authorization))
+	for key, value := range authorization {
+		config[key] = value
+	}
+	if p, ok := config["precedence"].(int); ok {
+		precedence = p
+	}
+	delete(config, "precedence")
+
+	tasks := make([]model.Task, len(inbound), len(inbound)+1)
+	copy(tasks, inbound)
+
+	return append(tasks, model.Task{
+		Name:        "authorize",
+		Type:        "Authorize",
+		Description: "Enforces the aggregate authorization policy",
+		Precedence:  precedence,
+		Config:      config,
+	})
+}
+
 func (c *Configurer) buildTarget(target *model.Target) (entity.Target, error) {
 	if target == nil {
 		return nil, domainerr.ErrEmptyInput
 	}
 
-	configuredTgt, err := entity.GetTarget(target.Type, target.Config)
+	configuredTgt, err := entity.GetTarget(target.Type, normalizeConfig(target.Config))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get target: %w", err)
 	}
@@ -261,7 +531,7 @@ func (c *Configurer) buildWorkflow(workflow []model.Task) (entity.Workflow, erro
 
 	var steps []*entity.WorkflowStep
 	for _, s := range workflow {
-		task, err := entity.GetTask(s.Type, s.Config)
+		task, err := entity.GetTask(s.Type, normalizeConfig(s.Config))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get task for step %s: %w", s.Name, err)
 		}
@@ -272,6 +542,7 @@ func (c *Configurer) buildWorkflow(workflow []model.Task) (entity.Workflow, erro
 			Description:   s.Description,
 			TaskType:      s.Type,
 			ExecutionType: s.ExecutionType,
+			Config:        normalizeConfig(s.Config),
 			Task:          task,
 		}
 		steps = append(steps, step)
@@ -281,29 +552,89 @@ func (c *Configurer) buildWorkflow(workflow []model.Task) (entity.Workflow, erro
 	return wkfl, nil
 }
 
+// normalizeConfig converts the nested map[interface{}]interface{} values
+// produced by yaml.v2 into map[string]interface{}, so that tasks and targets
+// can read nested config blocks.
+func normalizeConfig(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+
+	normalized := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		normalized[key] = normalizeConfigValue(value)
+	}
+	return normalized
+}
+
+func normalizeConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalizeConfigValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		return normalizeConfig(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, val := range v {
+			values[i] = normalizeConfigValue(val)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
 func (c *Configurer) applySchemasSpec(hub *entity.Hub, specs *map[string]*model.SchemasSpec) error {
 	if hub == nil || specs == nil {
 		return domainerr.ErrEmptyInput
 	}
 
+	type loadedSchema struct {
+		name    string
+		version string
+		data    []byte
+	}
+
+	// every schema is read before any is registered, so that a reload with
+	// a broken schema leaves the registered schemas untouched
+	files, err := c.snapshot()
+	if err != nil {
+		return err
+	}
+
+	var schemas []loadedSchema
 	for _, schemaSpec := range *specs {
 		for _, schema := range schemaSpec.Spec.Schemas {
-			filePath := filepath.Join(c.applicationDirectory, "schemas", schema.FileName)
-			schemaData, err := os.ReadFile(filePath)
+			schemaData, err := fs.ReadFile(files, path.Join("schemas", schema.FileName))
 			if err != nil {
 				return fmt.Errorf("failed to read schema file %s: %w", schema.FileName, err)
 			}
 
-			entity.RegisterSchema(schema.Name, schema.Version, schemaData)
+			if !json.Valid(schemaData) {
+				return fmt.Errorf("schema file %s is not valid JSON", schema.FileName)
+			}
+
+			schemas = append(schemas, loadedSchema{schema.Name, schema.Version, schemaData})
 		}
 	}
 
+	for _, schema := range schemas {
+		entity.RegisterSchema(schema.name, schema.version, schema.data)
+	}
+
 	return nil
 }
 
 func (c *Configurer) applyHubSpec(hub *entity.Hub, s *model.HubSpec) error {
 	hub.APIVersion = s.APIVersion
 	hub.ApplicationName = s.Spec.ApplicationName
+	if s.Spec.ApplicationVersion != "" {
+		hub.Version = s.Spec.ApplicationVersion
+	}
 
 	return nil
 }
@@ -9,8 +9,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
 	"github.com/QueerGlobal/hub-framework/adapter/handler/requesthandler"
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/task/builtin"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -251,3 +253,26 @@ spec:
 
 	return testDir
 }
+
+func TestWithAuthorization(t *testing.T) {
+	authorization := map[string]interface{}{
+		"default":    "deny",
+		"precedence": 5,
+		"rules":      []interface{}{map[string]interface{}{"methods": []interface{}{"GET"}, "anonymous": true}},
+	}
+	inbound := []model.Task{{Name: "jwt", Type: "ValidateJWT", Precedence: 1}}
+
+	tasks := withAuthorization(inbound, authorization)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, 5, tasks[1].Precedence)
+	assert.NotContains(t, tasks[1].Config, "precedence")
+	assert.Contains(t, authorization, "precedence")
+
+	// the rest of the policy is the config of the Authorize task
+	_, err := entity.TypedTaskConstructor(builtin.NewAuthorize).New(tasks[1].Config)
+	assert.NoError(t, err)
+
+	// without a precedence the step runs after the other inbound tasks
+	delete(authorization, "precedence")
+	assert.Equal(t, 2, withAuthorization(inbound, authorization)[1].Precedence)
+}
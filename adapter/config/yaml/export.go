@@ -0,0 +1,280 @@
+package yaml
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/cache"
+	"github.com/QueerGlobal/hub-framework/service/idempotency"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// methodOrder is the order the methods of an exported handler are listed
+// in, and its handlers are sorted by.
+var methodOrder = []entity.HTTPMethod{
+	entity.HTTPMethodGET,
+	entity.HTTPMethodHEAD,
+	entity.HTTPMethodPOST,
+	entity.HTTPMethodPUT,
+	entity.HTTPMethodPATCH,
+	entity.HTTPMethodDELETE,
+	entity.HTTPMethodOPTIONS,
+	entity.HTTPMethodTRACE,
+	entity.HTTPMethodCONNECT,
+}
+
+// ExportAggregates returns an aggregate spec for each service of hub,
+// keyed by a file name such as aggregates/recipe.yaml, describing the
+// services as they are configured: the tasks added by the APIs of hub.yaml
+// and by authorization policies are listed with the tasks of each handler,
+// and the path each service is served under, whether set by its API or
+// itself, is the path of its spec. Methods sharing a handler are listed
+// together, and everything is sorted, so that exports of the same
+// configuration are identical. Services defined in code list the Go types
+// of their own tasks and targets as their types. Services with caches or
+// idempotency stores which cannot be described by a spec are not exported.
+func ExportAggregates(hub *entity.Hub) (map[string]*model.AggregateSpec, error) {
+	services := make([]*entity.Service, 0, len(hub.GetServices()))
+	for _, svc := range hub.GetServices() {
+		services = append(services, svc)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return exportKey(services[i]) < exportKey(services[j])
+	})
+
+	names := make(map[string]int)
+	for _, svc := range services {
+		names[exportFileName(svc, false)]++
+	}
+
+	specs := make(map[string]*model.AggregateSpec, len(services))
+	for _, svc := range services {
+		spec, err := exportAggregate(svc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export aggregate %s of api %s: %w", svc.QualifiedName(), svc.APIName, err)
+		}
+		// aggregates of the same name in different APIs are told apart by
+		// their API
+		specs[exportFileName(svc, names[exportFileName(svc, false)] > 1)] = spec
+	}
+
+	return specs, nil
+}
+
+// WriteAggregates writes the aggregate specs of the services of hub as a
+// stream of YAML documents, each headed by its file name. Secrets are
+// masked.
+func WriteAggregates(w io.Writer, hub *entity.Hub) error {
+	specs, err := ExportAggregates(hub)
+	if err != nil {
+		return err
+	}
+
+	files := make([]string, 0, len(specs))
+	for file := range specs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var out strings.Builder
+	for i, file := range files {
+		data, err := yaml.Marshal(specs[file])
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", file, err)
+		}
+
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		fmt.Fprintf(&out, "# %s\n", file)
+		out.Write(data)
+	}
+
+	_, err = io.WriteString(w, logging.Redact(out.String()))
+	return err
+}
+
+func exportKey(svc *entity.Service) string {
+	return strings.ToLower(svc.Namespace + "/" + svc.APIName + "/" + svc.Name)
+}
+
+// exportFileName returns the file an aggregate is exported to, named after
+// its namespace and name, and after its API when withAPI is set.
+func exportFileName(svc *entity.Service, withAPI bool) string {
+	parts := []string{svc.Name}
+	if withAPI {
+		parts = append([]string{svc.APIName}, parts...)
+	}
+	if svc.Namespace != "" {
+		parts = append([]string{svc.Namespace}, parts...)
+	}
+	return path.Join("aggregates", strings.ToLower(strings.Join(parts, "-"))+".yaml")
+}
+
+func exportAggregate(svc *entity.Service) (*model.AggregateSpec, error) {
+	aggregate := model.Aggregate{
+		Name:          svc.Name,
+		APIName:       svc.APIName,
+		Path:          svc.Path,
+		IsPublic:      svc.IsPublic,
+		SchemaName:    svc.SchemaName,
+		SchemaVersion: svc.SchemaVersion,
+		Refs:          svc.Refs,
+		Handlers:      []model.Handler{},
+	}
+
+	body := &model.Body{
+		Streaming:      svc.BodyOptions.Streaming,
+		MaxBodySize:    svc.BodyOptions.MaxBodySize,
+		SpillThreshold: svc.BodyOptions.SpillThreshold,
+		TempDir:        svc.BodyOptions.TempDir,
+		MediaTypes:     svc.BodyOptions.MediaTypes,
+	}
+	if !reflect.DeepEqual(body, &model.Body{}) {
+		aggregate.Body = body
+	}
+
+	if svc.Cache != nil {
+		exported, err := exportCache(svc.Cache)
+		if err != nil {
+			return nil, err
+		}
+		aggregate.Cache = exported
+	}
+
+	if svc.Idempotency != nil {
+		exported, err := exportIdempotency(svc.Idempotency)
+		if err != nil {
+			return nil, err
+		}
+		aggregate.Idempotency = exported
+	}
+
+	// methods sharing a handler are listed together, in the order of their
+	// first method
+	var handlers []*entity.Handler
+	methods := make(map[*entity.Handler][]string)
+	for _, method := range methodOrder {
+		handler, ok := svc.Methods[method]
+		if !ok || handler == nil {
+			continue
+		}
+		if _, seen := methods[handler]; !seen {
+			handlers = append(handlers, handler)
+		}
+		methods[handler] = append(methods[handler], method.String())
+	}
+
+	for _, handler := range handlers {
+		exported, err := exportHandler(handler)
+		if err != nil {
+			return nil, err
+		}
+		exported.Methods = methods[handler]
+		aggregate.Handlers = append(aggregate.Handlers, exported)
+	}
+
+	return &model.AggregateSpec{
+		APIVersion: "v1",
+		SpecType:   "Aggregate",
+		Namespace:  svc.Namespace,
+		Spec:       aggregate,
+	}, nil
+}
+
+func exportCache(responseCache entity.ResponseCache) (*model.Cache, error) {
+	cached, ok := responseCache.(*cache.ResponseCache)
+	if !ok {
+		return nil, fmt.Errorf("cache of type %T cannot be exported", responseCache)
+	}
+
+	config := cached.Config()
+	return &model.Cache{
+		Enabled:     true,
+		TTL:         config.TTL.String(),
+		MaxEntries:  config.MaxEntries,
+		VaryHeaders: config.VaryHeaders,
+	}, nil
+}
+
+func exportIdempotency(replays entity.Idempotency) (*model.Idempotency, error) {
+	keyed, ok := replays.(*idempotency.Idempotency)
+	if !ok {
+		return nil, fmt.Errorf("idempotency of type %T cannot be exported", replays)
+	}
+
+	store, ok := keyed.Store().(*idempotency.BadgerStore)
+	if !ok {
+		return nil, fmt.Errorf("idempotency store of type %T cannot be exported", keyed.Store())
+	}
+
+	return &model.Idempotency{
+		Enabled: true,
+		TTL:     keyed.Config().TTL.String(),
+		Path:    store.Path(),
+	}, nil
+}
+
+func exportHandler(handler *entity.Handler) (model.Handler, error) {
+	inbound, err := exportWorkflow(handler.InboundWorkflow)
+	if err != nil {
+		return model.Handler{}, fmt.Errorf("inbound workflow: %w", err)
+	}
+
+	outbound, err := exportWorkflow(handler.OutboundWorkflow)
+	if err != nil {
+		return model.Handler{}, fmt.Errorf("outbound workflow: %w", err)
+	}
+
+	return model.Handler{
+		Inbound:  inbound,
+		Outbound: outbound,
+		Target: model.Target{
+			Name:   handler.TargetName,
+			Type:   handler.TargetType,
+			Config: handler.TargetConfig,
+		},
+	}, nil
+}
+
+// exportWorkflow lists the steps of workflow in the order they run.
+func exportWorkflow(workflow entity.Workflow) ([]model.Task, error) {
+	tasks := []model.Task{}
+	if workflow == nil {
+		return tasks, nil
+	}
+
+	chain, ok := workflow.(*entity.WorkflowTasks)
+	if !ok {
+		return nil, fmt.Errorf("workflow of type %T cannot be exported", workflow)
+	}
+
+	precedences := make([]int, 0, len(chain.Steps))
+	for precedence := range chain.Steps {
+		precedences = append(precedences, precedence)
+	}
+	sort.Ints(precedences)
+
+	for _, precedence := range precedences {
+		for _, step := range chain.Steps[precedence] {
+			tasks = append(tasks, model.Task{
+				Name:          step.Name,
+				Type:          step.TaskType,
+				Description:   step.Description,
+				Precedence:    step.Precedence,
+				ExecutionType: step.ExecutionType,
+				Config:        step.Config,
+			})
+		}
+	}
+
+	return tasks, nil
+}
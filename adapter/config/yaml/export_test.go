@@ -0,0 +1,143 @@
+package yaml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/cache"
+	"github.com/QueerGlobal/hub-framework/service/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const exportedAggregateYAML = `
+spec:
+  name: testAggregate
+  apiName: testApp
+  schemaName: TestSchema
+  schemaVersion: v0.0.1
+  body:
+    streaming: true
+  cache:
+    enabled: true
+    ttl: 1m0s
+    varyHeaders: ["Accept-Language"]
+  idempotency:
+    enabled: true
+    path: %s
+  handlers:
+    - methods: ["POST", "GET"]
+      inbound:
+        - name: second
+          type: MockTask
+          precedence: 2
+          config:
+            limits:
+              burst: 5
+        - name: first
+          type: MockTask
+      outbound: []
+      target:
+        type: MockTarget
+        config:
+          table: tests
+    - methods: ["DELETE"]
+      inbound: []
+      outbound: []
+      target:
+        type: MockTarget
+`
+
+func TestExportAggregates(t *testing.T) {
+	files := map[string]string{
+		"hub.yaml":                 shopHubYAML,
+		"aggregates/billing.yaml":  orderAggregate("billing", "[shipping/order]"),
+		"aggregates/shipping.yaml": orderAggregate("shipping", "[]"),
+		"aggregates/test.yaml":     fmt.Sprintf(exportedAggregateYAML, t.TempDir()),
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	}
+	_, hub := configuredHub(t, writeValidationDirectory(t, files))
+
+	specs, err := ExportAggregates(hub)
+	require.NoError(t, err)
+	require.Len(t, specs, 3)
+
+	// methods sharing a handler are listed together, and steps in the order
+	// they run
+	test := specs["aggregates/testaggregate.yaml"]
+	require.NotNil(t, test)
+	assert.Equal(t, &model.Body{Streaming: true}, test.Spec.Body)
+	assert.Equal(t, &model.Cache{Enabled: true, TTL: "1m0s", MaxEntries: cache.DefaultMaxEntries, VaryHeaders: []string{"Accept-Language"}}, test.Spec.Cache)
+	require.NotNil(t, test.Spec.Idempotency)
+	assert.Equal(t, idempotency.DefaultTTL.String(), test.Spec.Idempotency.TTL)
+	assert.NotEmpty(t, test.Spec.Idempotency.Path)
+	require.Len(t, test.Spec.Handlers, 2)
+	assert.Equal(t, []string{"GET", "POST"}, test.Spec.Handlers[0].Methods)
+	assert.Equal(t, []model.Task{
+		{Name: "first", Type: "MockTask"},
+		{Name: "second", Type: "MockTask", Precedence: 2, Config: map[string]interface{}{
+			"limits": map[string]interface{}{"burst": 5},
+		}},
+	}, test.Spec.Handlers[0].Inbound)
+	assert.Equal(t, model.Target{Type: "MockTarget", Config: map[string]interface{}{"table": "tests"}}, test.Spec.Handlers[0].Target)
+	assert.Equal(t, []string{"DELETE"}, test.Spec.Handlers[1].Methods)
+
+	// the tasks of the api are listed with those of the handler
+	billing := specs["aggregates/billing-order.yaml"]
+	require.NotNil(t, billing)
+	assert.Equal(t, "billing", billing.Namespace)
+	assert.Equal(t, "/shop/v1/invoices", billing.Spec.Path)
+	assert.Equal(t, []string{"shipping/order"}, billing.Spec.Refs)
+	assert.Equal(t, []model.Task{{Name: "respond", Type: "MockTask"}}, billing.Spec.Handlers[0].Outbound)
+
+	// configuring a hub from the export exports the same specs
+	var exported strings.Builder
+	require.NoError(t, WriteAggregates(&exported, hub))
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                 shopHubYAML,
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	})
+	for file, spec := range specs {
+		data, err := yaml.Marshal(spec)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "aggregates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), data, 0644))
+	}
+
+	// the paths of the specs are used, whatever the apis of hub.yaml say
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hub.yaml"), []byte("spec:\n  applicationName: TestApp\n"), 0644))
+
+	_, reconfigured := configuredHub(t, dir)
+	var reexported strings.Builder
+	require.NoError(t, WriteAggregates(&reexported, reconfigured))
+	assert.Equal(t, exported.String(), reexported.String())
+}
+
+// customCache is a cache the export cannot describe.
+type customCache struct {
+	entity.ResponseCache
+}
+
+func TestExportAggregates_UnknownCache(t *testing.T) {
+	_, hub := configuredHub(t, writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                 "spec:\n  applicationName: TestApp\n",
+		"aggregates/test.yaml":     validAggregateYAML,
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	}))
+	for _, svc := range hub.GetServices() {
+		svc.Cache = customCache{}
+	}
+
+	_, err := ExportAggregates(hub)
+	assert.ErrorContains(t, err, "cache of type yaml.customCache cannot be exported")
+}
@@ -0,0 +1,193 @@
+package yaml
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// Spec files may refer to environment variables and files in any value:
+//
+//	${NAME}                  the environment variable NAME, which must be set
+//	${NAME:-default}         NAME, or default when it is unset or empty
+//	${file:/run/secrets/x}   the contents of a file, without trailing newlines
+//	${secret:NAME}           NAME, masked wherever it would be logged
+//	$${NAME}                 the literal text ${NAME}
+//
+// Values read from files are always treated as secrets. A default may be
+// given for any reference, and is used when the variable is unset or empty
+// or the file does not exist.
+const (
+	filePrefix   = "file:"
+	secretPrefix = "secret:"
+)
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolator resolves the references in spec files. Its lookups are
+// replaceable for tests.
+type interpolator struct {
+	lookupEnv func(string) (string, bool)
+	readFile  func(string) ([]byte, error)
+}
+
+func newInterpolator() interpolator {
+	return interpolator{lookupEnv: os.LookupEnv, readFile: os.ReadFile}
+}
+
+// interpolateYAML resolves the references in a YAML document, returning the
+// document re-encoded with the resolved values.
+func (i interpolator) interpolateYAML(data []byte) ([]byte, error) {
+	if !strings.Contains(string(data), "${") {
+		return data, nil
+	}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	if err := i.interpolateDocument(&document); err != nil {
+		return nil, err
+	}
+
+	return yamlv3.Marshal(&document)
+}
+
+// interpolateDocument resolves the references in a YAML document in place,
+// returning the first which cannot be resolved.
+func (i interpolator) interpolateDocument(document *yamlv3.Node) error {
+	var firstErr error
+	i.interpolateNode(document, func(node *yamlv3.Node, err error) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("line %d, column %d: %w", node.Line, node.Column, err)
+		}
+	})
+
+	return firstErr
+}
+
+// interpolateNode resolves the references in the scalar values under node,
+// calling report for each one which cannot be resolved.
+func (i interpolator) interpolateNode(node *yamlv3.Node, report func(*yamlv3.Node, error)) {
+	switch node.Kind {
+	case yamlv3.ScalarNode:
+		value, err := i.expand(node.Value)
+		if err != nil {
+			report(node, err)
+			return
+		}
+		if value == node.Value {
+			return
+		}
+
+		node.Value = value
+		if node.Style&(yamlv3.DoubleQuotedStyle|yamlv3.SingleQuotedStyle) == 0 {
+			// resolve the type of a plain value from what it expanded to,
+			// so that ports and flags may come from the environment
+			node.Tag = ""
+			node.Style = 0
+		}
+
+	case yamlv3.MappingNode:
+		// keys are left as they are
+		for j := 1; j < len(node.Content); j += 2 {
+			i.interpolateNode(node.Content[j], report)
+		}
+
+	case yamlv3.DocumentNode, yamlv3.SequenceNode:
+		for _, child := range node.Content {
+			i.interpolateNode(child, report)
+		}
+	}
+}
+
+// expand resolves every reference in s.
+func (i interpolator) expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+
+		if start > 0 && s[start-1] == '$' {
+			out.WriteString(s[:start-1])
+			out.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s[start:])
+		}
+		end += start
+
+		value, err := i.resolve(s[start+2 : end])
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteString(s[:start])
+		out.WriteString(value)
+		s = s[end+1:]
+	}
+}
+
+// resolve returns the value of a single reference, without its ${ and }.
+func (i interpolator) resolve(reference string) (string, error) {
+	secret := false
+	if strings.HasPrefix(reference, secretPrefix) {
+		secret = true
+		reference = strings.TrimPrefix(reference, secretPrefix)
+	}
+
+	name, defaultValue, hasDefault := strings.Cut(reference, ":-")
+
+	var value string
+	if strings.HasPrefix(name, filePrefix) {
+		secret = true
+		path := strings.TrimPrefix(name, filePrefix)
+
+		data, err := i.readFile(path)
+		switch {
+		case os.IsNotExist(err) && hasDefault:
+			value = defaultValue
+		case err != nil:
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		default:
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+	} else {
+		if !envName.MatchString(name) {
+			return "", fmt.Errorf("invalid reference ${%s}", reference)
+		}
+
+		env, ok := i.lookupEnv(name)
+		switch {
+		case env != "":
+			value = env
+		case hasDefault:
+			value = defaultValue
+		case !ok:
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+	}
+
+	if secret {
+		logging.RegisterSecret(value)
+	}
+
+	return value, nil
+}
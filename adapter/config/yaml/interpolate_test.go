@@ -0,0 +1,144 @@
+package yaml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func testInterpolator(env map[string]string, files map[string]string) interpolator {
+	return interpolator{
+		lookupEnv: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+		readFile: func(path string) ([]byte, error) {
+			content, ok := files[path]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return []byte(content), nil
+		},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	i := testInterpolator(
+		map[string]string{"HOST": "db.internal", "EMPTY": ""},
+		map[string]string{"/run/secrets/db": "s3cr3t-db-password\n"},
+	)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${HOST}", "db.internal"},
+		{"http://${HOST}:5432/app", "http://db.internal:5432/app"},
+		{"${MISSING:-fallback}", "fallback"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${HOST:-fallback}", "db.internal"},
+		{"${file:/run/secrets/db}", "s3cr3t-db-password"},
+		{"${file:/run/secrets/none:-none}", "none"},
+		{"$${HOST}", "${HOST}"},
+		{"${secret:HOST}", "db.internal"},
+	}
+
+	for _, test := range tests {
+		got, err := i.expand(test.in)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	i := testInterpolator(nil, nil)
+
+	for _, in := range []string{"${MISSING}", "${file:/missing}", "${NOT-A-NAME}", "${HOST"} {
+		_, err := i.expand(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestInterpolateYAML(t *testing.T) {
+	i := testInterpolator(map[string]string{
+		"PORT":     "9090",
+		"NAME":     "app: with colon",
+		"QUOTED":   "8080",
+		"PASSWORD": "hunter2-long-password",
+	}, nil)
+
+	data, err := i.interpolateYAML([]byte(`
+spec:
+  applicationName: ${NAME}
+  applicationVersion: "${QUOTED}"
+  publicPort: ${PORT}
+  privatePort: ${PRIVATE_PORT:-9091}
+  tracing:
+    headers:
+      authorization: ${secret:PASSWORD}
+`))
+	require.NoError(t, err)
+
+	var spec model.HubSpec
+	require.NoError(t, yaml.Unmarshal(data, &spec))
+
+	assert.Equal(t, "app: with colon", spec.Spec.ApplicationName)
+	assert.Equal(t, "8080", spec.Spec.ApplicationVersion)
+	assert.Equal(t, 9090, spec.Spec.PublicPort)
+	assert.Equal(t, 9091, spec.Spec.PrivatePort)
+	assert.Equal(t, "hunter2-long-password", spec.Spec.Tracing.Headers["authorization"])
+
+	assert.Equal(t, "token "+logging.SecretMask, logging.Redact("token hunter2-long-password"))
+}
+
+func TestInterpolateYAML_ReportsLocation(t *testing.T) {
+	i := testInterpolator(nil, nil)
+
+	_, err := i.interpolateYAML([]byte("spec:\n  applicationName: ${MISSING}\n"))
+	require.Error(t, err)
+	assert.Equal(t, "line 2, column 20: environment variable MISSING is not set", err.Error())
+}
+
+func TestConfigureHub_RedactsSecretsInErrors(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	t.Setenv("HUB_TEST_TARGET", "unregistered-target-type")
+	aggregate := `
+spec:
+  name: secretAggregate
+  apiName: testApp
+  handlers:
+    - methods: ["GET"]
+      inbound: []
+      outbound: []
+      target:
+        type: ${secret:HUB_TEST_TARGET}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "aggregates", "secret.yaml"), []byte(aggregate), 0644))
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+
+	err = NewConfigurer(testDir).ConfigureHub(hub)
+	require.Error(t, err)
+
+	assert.NotContains(t, err.Error(), "unregistered-target-type")
+	assert.Contains(t, err.Error(), logging.SecretMask)
+	assert.True(t, errors.Is(err, domainerr.ErrTargetNotRegistered))
+}
@@ -0,0 +1,358 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// ProfileEnv names the environment variable selecting the profile when
+// none is given with WithProfile.
+const ProfileEnv = "HUB_PROFILE"
+
+// A profile is a directory under overlays holding a hub.yaml and aggregate
+// specs, each deep-merged onto the base spec of the same name:
+//
+//	hub.yaml
+//	aggregates/recipe.yaml
+//	overlays/prod/hub.yaml
+//	overlays/prod/aggregates/recipe.yaml
+//
+// Mappings are merged key by key, and a null value removes a key. Lists of
+// tasks, APIs and other items with a name are merged item by item, as are
+// handlers with the same methods; items which do not match are appended.
+// Empty lists, other lists and scalars replace the base value. Aggregates
+// only found in the overlay are added.
+const overlaysDirectory = "overlays"
+
+// WithProfile selects the overlay merged onto the base specs.
+func WithProfile(profile string) ConfigurerOption {
+	return func(c *Configurer) {
+		c.profile = profile
+	}
+}
+
+// Profile returns the selected profile, or "" when only the base specs
+// are used.
+func (c *Configurer) Profile() string {
+	return c.profile
+}
+
+// overlayDirectory returns the directory of the selected profile, or ""
+// when there is none.
+func (c *Configurer) overlayDirectory() string {
+	if c.profile == "" {
+		return ""
+	}
+	return path.Join(overlaysDirectory, c.profile)
+}
+
+func (c *Configurer) checkProfile() error {
+	dir := c.overlayDirectory()
+	if dir == "" {
+		return nil
+	}
+
+	files, err := c.snapshot()
+	if err != nil {
+		return err
+	}
+
+	info, err := fs.Stat(files, dir)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("profile %s has no overlay directory %s", c.profile, c.source.Location(dir))
+	}
+	return nil
+}
+
+// specFileNames lists the files of a spec directory, such as aggregates,
+// in the base and, when overlay is set, in the overlay of the selected
+// profile. Hidden files, such as the links of a mounted ConfigMap, are
+// skipped.
+func (c *Configurer) specFileNames(dir string, overlay bool) ([]string, error) {
+	files, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []string{"."}
+	if overlay && c.overlayDirectory() != "" {
+		roots = append(roots, c.overlayDirectory())
+	}
+
+	seen := make(map[string]bool)
+	for i, root := range roots {
+		entries, err := fs.ReadDir(files, path.Join(root, dir))
+		if i > 0 && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				seen[entry.Name()] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// readDocument reads the spec named rel, such as "aggregates/recipe.yaml",
+// and merges the overlay of the selected profile onto it. Either may be
+// missing, but not both. Nodes taken from the overlay are recorded in
+// origins, when it is not nil, with the location they came from.
+func (c *Configurer) readDocument(rel string, origins map[*yamlv3.Node]string) (*yamlv3.Node, error) {
+	files, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := readNode(files, rel)
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && c.overlayDirectory() != "") {
+		return nil, err
+	}
+
+	if c.overlayDirectory() == "" {
+		return base, nil
+	}
+
+	overlayFile := path.Join(c.overlayDirectory(), rel)
+	overlay, overlayErr := readNode(files, overlayFile)
+	switch {
+	case errors.Is(overlayErr, fs.ErrNotExist) && base != nil:
+		return base, nil
+	case errors.Is(overlayErr, fs.ErrNotExist):
+		return nil, err
+	case overlayErr != nil:
+		return nil, overlayErr
+	}
+
+	if origins != nil {
+		recordOrigin(overlay, c.source.Location(overlayFile), origins)
+	}
+
+	if base == nil {
+		return overlay, nil
+	}
+
+	return mergeNodes(base, overlay), nil
+}
+
+// readNode parses a YAML file into a document node.
+func readNode(files fs.FS, name string) (*yamlv3.Node, error) {
+	data, err := fs.ReadFile(files, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &document); err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: name, Err: err}
+	}
+	if document.Kind == 0 {
+		document = yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{{Kind: yamlv3.MappingNode}}}
+	}
+
+	return &document, nil
+}
+
+func recordOrigin(node *yamlv3.Node, file string, origins map[*yamlv3.Node]string) {
+	origins[node] = file
+	for _, child := range node.Content {
+		recordOrigin(child, file, origins)
+	}
+}
+
+// mergeNodes deep-merges overlay onto base, returning the merged node.
+// base is modified in place.
+func mergeNodes(base, overlay *yamlv3.Node) *yamlv3.Node {
+	if base.Kind != overlay.Kind {
+		return overlay
+	}
+
+	switch base.Kind {
+	case yamlv3.DocumentNode:
+		if len(base.Content) == 0 || len(overlay.Content) == 0 {
+			return overlay
+		}
+		base.Content[0] = mergeNodes(base.Content[0], overlay.Content[0])
+		return base
+
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(overlay.Content); i += 2 {
+			key, value := overlay.Content[i], overlay.Content[i+1]
+			index := mappingIndex(base, key.Value)
+
+			switch {
+			case isNull(value) && index >= 0:
+				base.Content = append(base.Content[:index], base.Content[index+2:]...)
+			case isNull(value):
+			case index >= 0:
+				base.Content[index+1] = mergeNodes(base.Content[index+1], value)
+			default:
+				base.Content = append(base.Content, key, value)
+			}
+		}
+		return base
+
+	case yamlv3.SequenceNode:
+		if len(overlay.Content) == 0 || !keyedItems(base) || !keyedItems(overlay) {
+			return overlay
+		}
+
+		for _, item := range overlay.Content {
+			matched := false
+			for j, baseItem := range base.Content {
+				if itemKey(baseItem) == itemKey(item) {
+					base.Content[j] = mergeNodes(baseItem, item)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				base.Content = append(base.Content, item)
+			}
+		}
+		return base
+
+	default:
+		return overlay
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node, or -1.
+func mappingIndex(node *yamlv3.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func isNull(node *yamlv3.Node) bool {
+	return node.Kind == yamlv3.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// keyedItems reports whether every item of a sequence can be matched by
+// itemKey.
+func keyedItems(node *yamlv3.Node) bool {
+	for _, item := range node.Content {
+		if itemKey(item) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// itemKey identifies a list item by its name and namespace, or a handler
+// by its methods.
+func itemKey(node *yamlv3.Node) string {
+	if node.Kind != yamlv3.MappingNode {
+		return ""
+	}
+
+	if index := mappingIndex(node, "name"); index >= 0 && node.Content[index+1].Kind == yamlv3.ScalarNode {
+		key := "name=" + node.Content[index+1].Value
+		if index := mappingIndex(node, "namespace"); index >= 0 {
+			key += ",namespace=" + node.Content[index+1].Value
+		}
+		return key
+	}
+
+	if index := mappingIndex(node, "methods"); index >= 0 && node.Content[index+1].Kind == yamlv3.SequenceNode {
+		var methods []string
+		for _, method := range node.Content[index+1].Content {
+			methods = append(methods, strings.ToUpper(method.Value))
+		}
+		sort.Strings(methods)
+		return "methods=" + strings.Join(methods, ",")
+	}
+
+	return ""
+}
+
+// WriteEffectiveConfig writes the hub, aggregate and schema specs as they
+// are applied, with the overlay of the selected profile merged and the
+// environment and file references resolved, as a stream of YAML documents.
+// Secrets are masked.
+func (c *Configurer) WriteEffectiveConfig(w io.Writer) error {
+	if err := c.open(); err != nil {
+		return err
+	}
+
+	if err := c.checkProfile(); err != nil {
+		return err
+	}
+
+	specs := []string{"hub.yaml"}
+
+	aggregates, err := c.specFileNames("aggregates", true)
+	if err != nil {
+		return err
+	}
+	for _, name := range aggregates {
+		specs = append(specs, path.Join("aggregates", name))
+	}
+
+	schemas, err := c.specFileNames("schemas", false)
+	if err != nil {
+		return err
+	}
+	for _, name := range schemas {
+		if isSpecFile(name) {
+			specs = append(specs, path.Join("schemas", name))
+		}
+	}
+
+	var out strings.Builder
+	for i, rel := range specs {
+		var document *yamlv3.Node
+		if strings.HasPrefix(rel, "schemas/") {
+			document, err = readNode(c.files, rel)
+		} else {
+			document, err = c.readDocument(rel, nil)
+		}
+		if err != nil {
+			return logging.RedactError(err)
+		}
+
+		if err := newInterpolator().interpolateDocument(document); err != nil {
+			return logging.RedactError(fmt.Errorf("%s: %w", c.source.Location(rel), err))
+		}
+
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		fmt.Fprintf(&out, "# %s\n", rel)
+
+		encoder := yamlv3.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, logging.Redact(out.String()))
+	return err
+}
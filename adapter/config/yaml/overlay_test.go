@@ -0,0 +1,198 @@
+package yaml
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func mergeYAML(t *testing.T, base, overlay string) string {
+	var baseNode, overlayNode yamlv3.Node
+	require.NoError(t, yamlv3.Unmarshal([]byte(base), &baseNode))
+	require.NoError(t, yamlv3.Unmarshal([]byte(overlay), &overlayNode))
+
+	data, err := yamlv3.Marshal(mergeNodes(&baseNode, &overlayNode))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMergeNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{
+			name:    "mappings are merged",
+			base:    "a: 1\nb:\n    c: 2\n    d: 3\n",
+			overlay: "b:\n    c: 4\ne: 5\n",
+			want:    "a: 1\nb:\n    c: 4\n    d: 3\ne: 5\n",
+		},
+		{
+			name:    "null removes a key",
+			base:    "a: 1\nb: 2\n",
+			overlay: "b: null\n",
+			want:    "a: 1\n",
+		},
+		{
+			name:    "named items are merged",
+			base:    "tasks:\n    - name: x\n      level: INFO\n    - name: y\n",
+			overlay: "tasks:\n    - name: x\n      level: DEBUG\n    - name: z\n",
+			want:    "tasks:\n    - name: x\n      level: DEBUG\n    - name: y\n    - name: z\n",
+		},
+		{
+			name:    "namespaced items are merged",
+			base:    "aggregates:\n    - name: order\n      namespace: billing\n    - name: order\n      namespace: shipping\n",
+			overlay: "aggregates:\n    - name: order\n      namespace: shipping\n      path: shipments\n",
+			want:    "aggregates:\n    - name: order\n      namespace: billing\n    - name: order\n      namespace: shipping\n      path: shipments\n",
+		},
+		{
+			name:    "handlers are merged by methods",
+			base:    "handlers:\n    - methods: [GET]\n      target: {type: Noop}\n    - methods: [PUT, POST]\n      target: {type: Noop}\n",
+			overlay: "handlers:\n    - methods: [post, put]\n      target: {type: Badger}\n",
+			want:    "handlers:\n    - methods: [GET]\n      target: {type: Noop}\n    - methods: [post, put]\n      target: {type: Badger}\n",
+		},
+		{
+			name:    "other lists are replaced",
+			base:    "origins: [a, b]\n",
+			overlay: "origins: [c]\n",
+			want:    "origins: [c]\n",
+		},
+		{
+			name:    "empty lists are replaced",
+			base:    "tasks:\n    - name: x\n",
+			overlay: "tasks: []\n",
+			want:    "tasks: []\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, mergeYAML(t, test.base, test.overlay))
+		})
+	}
+}
+
+func writeOverlay(t *testing.T, testDir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(testDir, "overlays", "prod", name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestConfigureHub_Profile(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	writeOverlay(t, testDir, map[string]string{
+		"hub.yaml": "spec:\n  applicationVersion: v9.9.9\n",
+		"aggregates/test_aggregate.yaml": `
+spec:
+  isPublic: false
+`,
+		"aggregates/extra.yaml": secondAggregateYAML,
+	})
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+
+	c := NewConfigurer(testDir, WithProfile("prod"))
+	require.NoError(t, c.ConfigureHub(hub))
+
+	assert.Equal(t, "v9.9.9", c.GetHubSpec().Spec.ApplicationVersion)
+	assert.Equal(t, "TestApp", c.GetHubSpec().Spec.ApplicationName)
+
+	svc, ok := hub.GetService("testapp", "testaggregate")
+	require.True(t, ok)
+	assert.False(t, svc.IsPublic)
+
+	_, ok = hub.GetService("testapp", "secondaggregate")
+	assert.True(t, ok, "aggregates only in the overlay should be added")
+}
+
+func TestConfigureHub_ProfileFromEnvironment(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	t.Setenv(ProfileEnv, "staging")
+
+	c := NewConfigurer(testDir)
+	assert.Equal(t, "staging", c.Profile())
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+
+	err = c.ConfigureHub(hub)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile staging has no overlay directory")
+}
+
+func TestValidate_ReportsOverlayLocation(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                 "spec:\n  applicationName: TestApp\n",
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+		"aggregates/test.yaml":     validAggregateYAML,
+		"overlays/prod/aggregates/test.yaml": `
+spec:
+  handlers:
+    - methods: ["GET"]
+      target:
+        type: NoSuchTarget
+`,
+	})
+
+	err := NewConfigurer(dir, WithProfile("prod")).Validate()
+
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 1)
+	assert.Equal(t, filepath.Join(dir, "overlays", "prod", "aggregates", "test.yaml"), errs[0].File)
+	assert.Equal(t, 6, errs[0].Line)
+	assert.Equal(t, 15, errs[0].Column)
+}
+
+func TestWriteEffectiveConfig(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	t.Setenv("HUB_TEST_VERSION", "v2.0.0")
+	t.Setenv("HUB_TEST_TOKEN", "effective-config-token")
+	writeOverlay(t, testDir, map[string]string{
+		"hub.yaml": `
+spec:
+  applicationVersion: ${HUB_TEST_VERSION}
+  tracing:
+    headers:
+      authorization: ${secret:HUB_TEST_TOKEN}
+`,
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, NewConfigurer(testDir, WithProfile("prod")).WriteEffectiveConfig(&out))
+
+	assert.Contains(t, out.String(), "# hub.yaml\n")
+	assert.Contains(t, out.String(), "applicationName: TestApp")
+	assert.Contains(t, out.String(), "applicationVersion: v2.0.0")
+	assert.Contains(t, out.String(), "# aggregates/test_aggregate.yaml\n")
+	assert.Contains(t, out.String(), "authorization: "+logging.SecretMask)
+	assert.NotContains(t, out.String(), "effective-config-token")
+}
@@ -0,0 +1,112 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/source"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// reloadDelay groups the bursts of events produced by editors and
+// deployment tools into a single reload.
+const reloadDelay = 250 * time.Millisecond
+
+// Reload builds a fresh set of services from the spec files and
+// swaps them into hub. The hub keeps its current services when the new
+// configuration does not load. Requests in flight finish on the services
+// they started with. Listener settings in hub.yaml, such as ports and TLS,
+// only take effect on restart.
+func (c *Configurer) Reload(hub *entity.Hub) error {
+	staging, err := entity.NewHub(hub.GetLogger(), hub.Version)
+	if err != nil {
+		return err
+	}
+
+	reloaded := NewConfigurer(c.applicationDirectory, WithProfile(c.profile), WithSource(c.source), WithServices(c.services...))
+	if err := reloaded.ConfigureHub(staging); err != nil {
+		return fmt.Errorf("invalid configuration, keeping the previous version: %w", err)
+	}
+
+	services := make([]*entity.Service, 0, len(staging.GetServices()))
+	for _, svc := range staging.GetServices() {
+		services = append(services, svc)
+	}
+	if err := hub.ReplaceServices(services); err != nil {
+		return fmt.Errorf("invalid configuration, keeping the previous version: %w", err)
+	}
+
+	c.mu.Lock()
+	c.hubSpec = reloaded.hubSpec
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the configuration of hub whenever the spec files change.
+// Failed reloads are logged and the previous configuration is kept. The
+// source must implement source.Notifier, as local directories and config
+// stores polled over HTTP do.
+func (c *Configurer) Watch(hub *entity.Hub) error {
+	notifier, ok := c.source.(source.Notifier)
+	if !ok {
+		return fmt.Errorf("configuration source %s cannot be watched", c.source.Location("."))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 1)
+
+	err := notifier.Notify(ctx, func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	c.mu.Lock()
+	c.stopWatching = cancel
+	c.mu.Unlock()
+
+	go func() {
+		var pending <-chan time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-changes:
+				pending = time.After(reloadDelay)
+
+			case <-pending:
+				pending = nil
+				logger := hub.GetLogger()
+				location := c.source.Location(".")
+				if err := c.Reload(hub); err != nil {
+					logger.Error().Err(err).Str("source", location).Msg("failed to reload configuration")
+					continue
+				}
+				logger.Info().Str("source", location).Msg("reloaded configuration")
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops watching for configuration changes.
+func (c *Configurer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopWatching != nil {
+		c.stopWatching()
+		c.stopWatching = nil
+	}
+	return nil
+}
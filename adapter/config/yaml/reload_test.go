@@ -0,0 +1,115 @@
+package yaml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secondAggregateYAML = `
+spec:
+  name: secondAggregate
+  apiName: testApp
+  isPublic: true
+  handlers:
+    - methods: ["GET"]
+      inbound:
+        - name: mocktask
+          type: MockTask
+          precedence: 1
+          executionType: sync
+          onError: LogAndFail
+          enabled: True
+      outbound:
+        - name: mockResponseTask
+          type: MockTask
+          precedence: 1
+          executionType: sync
+          onError: LogAndFail
+          enabled: True
+      target:
+        name: persistSecond
+        type: MockTarget
+`
+
+func configuredHub(t *testing.T, dir string) (*Configurer, *entity.Hub) {
+	registerMockTasks()
+	registerMockTargets()
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+
+	c := NewConfigurer(dir)
+	require.NoError(t, c.ConfigureHub(hub))
+
+	return c, hub
+}
+
+func TestReload(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	c, hub := configuredHub(t, testDir)
+	require.Len(t, hub.GetServices(), 1)
+	before, ok := hub.GetService("testapp", "testaggregate")
+	require.True(t, ok)
+
+	err := os.WriteFile(filepath.Join(testDir, "aggregates", "second_aggregate.yaml"), []byte(secondAggregateYAML), 0644)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Reload(hub))
+
+	assert.Len(t, hub.GetServices(), 2)
+	_, ok = hub.GetService("testapp", "secondaggregate")
+	assert.True(t, ok)
+
+	after, ok := hub.GetService("testapp", "testaggregate")
+	require.True(t, ok)
+	assert.NotSame(t, before, after, "services should be rebuilt")
+}
+
+func TestReload_InvalidConfigKeepsPrevious(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	c, hub := configuredHub(t, testDir)
+	before, ok := hub.GetService("testapp", "testaggregate")
+	require.True(t, ok)
+
+	invalid := strings.Replace(secondAggregateYAML, "MockTarget", "NoSuchTarget", 1)
+	err := os.WriteFile(filepath.Join(testDir, "aggregates", "second_aggregate.yaml"), []byte(invalid), 0644)
+	require.NoError(t, err)
+
+	err = c.Reload(hub)
+	assert.Error(t, err)
+
+	assert.Len(t, hub.GetServices(), 1)
+	after, ok := hub.GetService("testapp", "testaggregate")
+	require.True(t, ok)
+	assert.Same(t, before, after)
+}
+
+func TestWatch(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	c, hub := configuredHub(t, testDir)
+	require.NoError(t, c.Watch(hub))
+	defer c.Close()
+
+	err := os.WriteFile(filepath.Join(testDir, "aggregates", "second_aggregate.yaml"), []byte(secondAggregateYAML), 0644)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, ok := hub.GetService("testapp", "secondaggregate")
+		return ok
+	}, 5*time.Second, 50*time.Millisecond)
+}
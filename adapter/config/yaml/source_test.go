@@ -0,0 +1,126 @@
+package yaml
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/source"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readFiles returns the files under dir, keyed by their slash-separated
+// paths.
+func readFiles(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := fs.WalkDir(os.DirFS(dir), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(os.DirFS(dir), name)
+		files[name] = string(data)
+		return err
+	})
+	require.NoError(t, err)
+
+	return files
+}
+
+// configStore is a stand-in for a remote config store serving files.
+type configStore struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func (s *configStore) set(name, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = content
+}
+
+func (s *configStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.files)
+}
+
+func configuredHubFrom(t *testing.T, src source.Source) (*Configurer, *entity.Hub) {
+	registerMockTasks()
+	registerMockTargets()
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+
+	c := NewConfigurer("", WithSource(src))
+	require.NoError(t, c.ConfigureHub(hub))
+
+	return c, hub
+}
+
+func TestConfigureHub_FSSource(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	files := make(fstest.MapFS)
+	for name, content := range readFiles(t, testDir) {
+		files[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	_, hub := configuredHubFrom(t, source.NewFS("embedded", files))
+
+	_, ok := hub.GetService("testapp", "testaggregate")
+	assert.True(t, ok)
+}
+
+func TestConfigureHub_HTTPSource(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	store := &configStore{files: readFiles(t, testDir)}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	c, hub := configuredHubFrom(t, source.NewHTTP(source.HTTPConfig{URL: server.URL, Interval: 20 * time.Millisecond}))
+
+	_, ok := hub.GetService("testapp", "testaggregate")
+	assert.True(t, ok)
+
+	require.NoError(t, c.Watch(hub))
+	defer c.Close()
+
+	store.set("aggregates/second_aggregate.yaml", secondAggregateYAML)
+
+	assert.Eventually(t, func() bool {
+		_, ok := hub.GetService("testapp", "secondaggregate")
+		return ok
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestValidate_ReportsSourceLocation(t *testing.T) {
+	testDir := setupTestDirectory(t)
+	defer os.RemoveAll(testDir)
+
+	store := &configStore{files: readFiles(t, testDir)}
+	store.set("aggregates/broken.yaml", "spec:\n  name: broken\n  colour: red\n")
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	registerMockTasks()
+	registerMockTargets()
+
+	err := NewConfigurer("", WithSource(source.NewHTTP(source.HTTPConfig{URL: server.URL}))).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), server.URL+"#aggregates/broken.yaml:3:3: ")
+}
@@ -0,0 +1,246 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/task/remote"
+)
+
+// The manifests of user-defined task and target types, read from the
+// application directory if present.
+const (
+	taskTypesFile   = "user-defined-task-types.yml"
+	targetTypesFile = "user-defined-target-types.yml"
+)
+
+// The config keys naming the handler of a type written in Go, which are not
+// passed on to its tasks and targets.
+const (
+	importPathKey  = "importPath"
+	handlerNameKey = "handlerName"
+)
+
+// readTypesSpec reads the manifest named rel, returning nil if there is
+// none.
+func (c *Configurer) readTypesSpec(rel string) (*model.TypesSpec, error) {
+	var spec model.TypesSpec
+	err := c.readSpecFile(rel, &spec)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to unmarshal type manifest %s: %w", rel, err)
+	}
+
+	return &spec, nil
+}
+
+// registerUserDefinedTypes registers the task and target types of the
+// manifests. Every type is resolved before any is registered, so that a
+// type which cannot be resolved leaves the registries untouched.
+func (c *Configurer) registerUserDefinedTypes(hubSpec *model.HubSpec) error {
+	var sidecars map[string]model.Sidecar
+	if hubSpec != nil {
+		sidecars = hubSpec.Spec.Sidecars
+	}
+
+	taskTypes, err := c.readTypesSpec(taskTypesFile)
+	if err != nil {
+		return err
+	}
+	targetTypes, err := c.readTypesSpec(targetTypesFile)
+	if err != nil {
+		return err
+	}
+
+	tasks := make(map[string]entity.TaskConstructor)
+	if taskTypes != nil {
+		for _, definition := range taskTypes.Spec.Tasks {
+			constructor, err := resolveTaskType(definition, sidecars)
+			if err != nil {
+				return err
+			}
+			tasks[definition.Name] = constructor
+		}
+	}
+
+	targets := make(map[string]entity.TargetConstructor)
+	if targetTypes != nil {
+		for _, definition := range targetTypes.Spec.Targets {
+			constructor, err := resolveTargetType(definition, sidecars)
+			if err != nil {
+				return err
+			}
+			targets[definition.Name] = constructor
+		}
+	}
+
+	for name, constructor := range tasks {
+		entity.RegisterTaskType(name, constructor)
+	}
+	for name, constructor := range targets {
+		entity.RegisterTargetType(name, constructor)
+	}
+
+	return nil
+}
+
+func resolveTaskType(definition model.TypeDefinition, sidecars map[string]model.Sidecar) (entity.TaskConstructor, error) {
+	if err := checkTypeDefinition("task", definition); err != nil {
+		return nil, err
+	}
+	defaults, importPath, handlerName := splitTypeConfig(definition.Config)
+
+	var constructor entity.TaskConstructor
+	if isGo(definition.Language) {
+		if importPath == "" || handlerName == "" {
+			return nil, fmt.Errorf("task type %s: Go types require importPath and handlerName", definition.Name)
+		}
+		handler, ok := entity.TaskHandlerRegistry()[entity.HandlerKey(importPath, handlerName)]
+		if !ok {
+			return nil, fmt.Errorf("task type %s: no Go handler is registered for %s", definition.Name, entity.HandlerKey(importPath, handlerName))
+		}
+		constructor = handler
+	} else {
+		sidecar, err := sidecarConfig("task", definition, sidecars)
+		if err != nil {
+			return nil, err
+		}
+		constructor = remote.NewSidecarTaskConstructor(sidecar, definition.Name)
+	}
+
+	return entity.TaskConstructorFunc(func(config map[string]any) (entity.Task, error) {
+		return constructor.New(withDefaults(defaults, config))
+	}), nil
+}
+
+func resolveTargetType(definition model.TypeDefinition, sidecars map[string]model.Sidecar) (entity.TargetConstructor, error) {
+	if err := checkTypeDefinition("target", definition); err != nil {
+		return nil, err
+	}
+	defaults, importPath, handlerName := splitTypeConfig(definition.Config)
+
+	var constructor entity.TargetConstructor
+	if isGo(definition.Language) {
+		if importPath == "" || handlerName == "" {
+			return nil, fmt.Errorf("target type %s: Go types require importPath and handlerName", definition.Name)
+		}
+		handler, ok := entity.TargetHandlerRegistry()[entity.HandlerKey(importPath, handlerName)]
+		if !ok {
+			return nil, fmt.Errorf("target type %s: no Go handler is registered for %s", definition.Name, entity.HandlerKey(importPath, handlerName))
+		}
+		constructor = handler
+	} else {
+		sidecar, err := sidecarConfig("target", definition, sidecars)
+		if err != nil {
+			return nil, err
+		}
+		constructor = remote.NewSidecarTargetConstructor(sidecar, definition.Name)
+	}
+
+	return entity.TargetConstructorFunc(func(config map[string]any) (entity.Target, error) {
+		return constructor.New(withDefaults(defaults, config))
+	}), nil
+}
+
+func checkTypeDefinition(kind string, definition model.TypeDefinition) error {
+	if definition.Name == "" {
+		return fmt.Errorf("%s type has no name", kind)
+	}
+	if definition.Language == "" {
+		return fmt.Errorf("%s type %s has no language", kind, definition.Name)
+	}
+	return nil
+}
+
+// isGo reports whether language names Go, whose types are linked into the
+// application rather than run by a sidecar.
+func isGo(language string) bool {
+	switch strings.ToLower(language) {
+	case "go", "golang":
+		return true
+	default:
+		return false
+	}
+}
+
+// splitTypeConfig separates the import path and handler name of a type
+// from the defaults it provides for the config of its tasks and targets.
+func splitTypeConfig(config map[string]interface{}) (map[string]interface{}, string, string) {
+	defaults := make(map[string]interface{}, len(config))
+	for key, value := range normalizeConfig(config) {
+		defaults[key] = value
+	}
+
+	importPath, _ := defaults[importPathKey].(string)
+	handlerName, _ := defaults[handlerNameKey].(string)
+	delete(defaults, importPathKey)
+	delete(defaults, handlerNameKey)
+
+	return defaults, importPath, handlerName
+}
+
+// hasSidecar reports whether sidecars has one for language.
+func hasSidecar(sidecars map[string]model.Sidecar, language string) bool {
+	for sidecarLanguage := range sidecars {
+		if strings.EqualFold(sidecarLanguage, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// sidecarConfig returns the sidecar running the types written in the
+// language of definition.
+func sidecarConfig(kind string, definition model.TypeDefinition, sidecars map[string]model.Sidecar) (remote.SidecarConfig, error) {
+	var spec *model.Sidecar
+	for language, sidecar := range sidecars {
+		if strings.EqualFold(language, definition.Language) {
+			spec = &sidecar
+		}
+	}
+	if spec == nil {
+		return remote.SidecarConfig{}, fmt.Errorf("%s type %s: no sidecar is configured for language %s", kind, definition.Name, definition.Language)
+	}
+	if spec.Host == "" {
+		return remote.SidecarConfig{}, fmt.Errorf("%s type %s: the sidecar for language %s has no host", kind, definition.Name, definition.Language)
+	}
+
+	config := remote.SidecarConfig{Host: spec.Host, PathPrefix: spec.PathPrefix}
+	if spec.Timeout != "" {
+		timeout, err := time.ParseDuration(spec.Timeout)
+		if err != nil {
+			return remote.SidecarConfig{}, fmt.Errorf("invalid timeout for the sidecar for language %s: %w", definition.Language, err)
+		}
+		config.Timeout = timeout
+	}
+	if spec.TLS != nil {
+		config.CertFile = spec.TLS.CertFile
+		config.KeyFile = spec.TLS.KeyFile
+		config.CAFile = spec.TLS.CAFile
+	}
+
+	return config, nil
+}
+
+// withDefaults returns defaults overridden by config.
+func withDefaults(defaults, config map[string]interface{}) map[string]interface{} {
+	if len(defaults) == 0 {
+		return config
+	}
+
+	merged := make(map[string]interface{}, len(defaults)+len(config))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range config {
+		merged[key] = value
+	}
+	return merged
+}
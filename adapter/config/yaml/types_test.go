@@ -0,0 +1,306 @@
+package yaml
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/adapter/handler/requesthandler"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/task/remote"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const greetingImportPath = "example.com/hub/targets"
+
+// GreetingTarget answers with its greeting followed by its punctuation.
+type GreetingTarget struct {
+	Config map[string]any
+}
+
+func (t *GreetingTarget) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK},
+		Body:         []byte(t.Config["greeting"].(string) + t.Config["punctuation"].(string)),
+	}, nil
+}
+
+func (t *GreetingTarget) Name() string {
+	return "GreetingTarget"
+}
+
+func registerGreetingHandler() {
+	entity.RegisterTargetHandler(greetingImportPath, "GreetingTarget", entity.TargetConstructorFunc(
+		func(config map[string]any) (entity.Target, error) {
+			return &GreetingTarget{Config: config}, nil
+		}))
+}
+
+const typesHubYAML = `
+spec:
+  applicationName: TestApp
+  sidecars:
+    Python:
+      host: %s
+      pathPrefix: /hub
+`
+
+const userDefinedTaskTypesYAML = `
+apiVersion: v1
+specType: Tasks
+spec:
+  tasks:
+    - name: shout
+      language: python
+      config:
+        volume: loud
+`
+
+const userDefinedTargetTypesYAML = `
+apiVersion: v1
+specType: Targets
+spec:
+  targets:
+    - name: greeting
+      language: go
+      config:
+        importPath: example.com/hub/targets
+        handlerName: GreetingTarget
+        greeting: hello
+        punctuation: "!"
+    - name: store
+      language: python
+`
+
+const greetingAggregateYAML = `
+spec:
+  name: greeting
+  apiName: testApp
+  handlers:
+    - methods: ["GET"]
+      inbound: []
+      outbound:
+        - name: shout
+          type: shout
+      target:
+        type: greeting
+        config:
+          greeting: hi
+    - methods: ["POST"]
+      inbound: []
+      outbound: []
+      target:
+        type: store
+`
+
+// pythonSidecar stands in for a sidecar running the shout task and the
+// store target, recording the exchanges it receives by path.
+func pythonSidecar(t *testing.T) (*httptest.Server, map[string]remote.Exchange) {
+	received := make(map[string]remote.Exchange)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var exchange remote.Exchange
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&exchange))
+		received[r.URL.Path] = exchange
+
+		switch r.URL.Path {
+		case "/hub/tasks/shout":
+			exchange.Response.Body = bytes.ToUpper(exchange.Response.Body)
+		case "/hub/targets/store":
+			exchange.Response = &remote.ExchangeResponse{StatusCode: http.StatusCreated, Body: append([]byte("stored "), exchange.Body...)}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(exchange))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, received
+}
+
+func TestConfigureHub_UserDefinedTypes(t *testing.T) {
+	registerGreetingHandler()
+	sidecar, received := pythonSidecar(t)
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                      fmt.Sprintf(typesHubYAML, sidecar.URL),
+		"user-defined-task-types.yml":   userDefinedTaskTypesYAML,
+		"user-defined-target-types.yml": userDefinedTargetTypesYAML,
+		"aggregates/greeting.yaml":      greetingAggregateYAML,
+		"schemas/schemas.yaml":          validSchemasYAML,
+		"schemas/test.schema.json":      `{"type": "object"}`,
+	})
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "TestApp")
+	require.NoError(t, err)
+	require.NoError(t, NewConfigurer(dir).ConfigureHub(hub))
+
+	handler := requesthandler.NewRequestHandler(8080, hub)
+
+	// the Go target takes its punctuation from the manifest, and the shout
+	// task run by the sidecar changes its response
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/testapp/greeting", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "HI!", rr.Body.String())
+
+	shout := received["/hub/tasks/shout"]
+	assert.Equal(t, "GET", shout.Method)
+	assert.Equal(t, "greeting", shout.ServiceName)
+	assert.Equal(t, map[string]any{"volume": "loud"}, shout.Config)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/testapp/greeting", strings.NewReader(`{"name": "hi"}`)))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, `stored {"name": "hi"}`, rr.Body.String())
+}
+
+// whisperTaskTypeYAML declares a task type which resolves, ahead of one
+// which does not.
+const whisperTaskTypeYAML = `
+spec:
+  tasks:
+    - name: whisper
+      language: python
+`
+
+func TestConfigureHub_UnresolvedTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string // task types following whisper
+		want     string
+	}{
+		{
+			name: "Go handler not registered",
+			manifest: `    - name: unlinkedTask
+      language: golang
+      config:
+        importPath: example.com/hub/tasks
+        handlerName: Unlinked
+`,
+			want: "task type unlinkedTask: no Go handler is registered for example.com/hub/tasks.Unlinked",
+		},
+		{
+			name: "Go handler not named",
+			manifest: `    - name: unnamedTask
+      language: go
+`,
+			want: "task type unnamedTask: Go types require importPath and handlerName",
+		},
+		{
+			name: "no sidecar",
+			manifest: `    - name: rubyTask
+      language: ruby
+`,
+			want: "task type rubyTask: no sidecar is configured for language ruby",
+		},
+		{
+			name: "no language",
+			manifest: `    - name: someTask
+`,
+			want: "task type someTask has no language",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeValidationDirectory(t, map[string]string{
+				"hub.yaml":                    fmt.Sprintf(typesHubYAML, "http://localhost:8090"),
+				"user-defined-task-types.yml": whisperTaskTypeYAML + tt.manifest,
+				"schemas/schemas.yaml":        validSchemasYAML,
+				"schemas/test.schema.json":    `{"type": "object"}`,
+			})
+
+			logger := zerolog.Nop()
+			hub, err := entity.NewHub(&logger, "TestApp")
+			require.NoError(t, err)
+
+			err = NewConfigurer(dir).ConfigureHub(hub)
+			require.EqualError(t, err, tt.want)
+
+			// no type of the manifest is registered, not even those resolved
+			_, ok := entity.TaskRegistry()["whisper"]
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestValidate_UserDefinedTypes(t *testing.T) {
+	registerMockTargets()
+	registerGreetingHandler()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml": `spec:
+  applicationName: TestApp
+  sidecars:
+    python:
+      timeout: soon
+`,
+		"user-defined-task-types.yml": `spec:
+  tasks:
+    - name: scoreRecipe
+      language: python
+    - name: scoreRecipe
+      language: ruby
+    - name: linkedTask
+      language: go
+      config:
+        importPath: example.com/hub/tasks
+        handlerName: Unlinked
+    - language: go
+`,
+		"user-defined-target-types.yml": `spec:
+  targets:
+    - name: greeting
+      language: go
+      config:
+        importPath: example.com/hub/targets
+        handlerName: GreetingTarget
+`,
+		"aggregates/test.yaml": `spec:
+  name: testAggregate
+  apiName: testApp
+  handlers:
+    - methods: ["GET"]
+      inbound:
+        - name: score
+          type: scoreRecipe
+      outbound:
+        - name: link
+          type: linkedTask
+      target:
+        type: greeting
+`,
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+	})
+
+	hubFile := dir + "/hub.yaml"
+	tasksFile := dir + "/user-defined-task-types.yml"
+
+	// tasks and targets of declared types are not reported as unknown
+	err := NewConfigurer(dir).Validate()
+	require.Error(t, err)
+	assert.Equal(t, strings.Join([]string{
+		hubFile + ":5:7: the sidecar for language python has no host",
+		hubFile + ":5:16: invalid timeout \"soon\"",
+		tasksFile + ":5:7: task type scoreRecipe is already defined at " + tasksFile + ":3:7",
+		tasksFile + ":6:17: no sidecar is configured for language ruby",
+		tasksFile + ":11:22: no Go handler is registered for example.com/hub/tasks.Unlinked",
+		tasksFile + ":12:7: task type has no name",
+		tasksFile + ":12:7: Go types require importPath and handlerName",
+	}, "\n"), err.Error())
+
+	err = NewConfigurer(dir, WithoutGoHandlerChecks()).Validate()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "no Go handler")
+}
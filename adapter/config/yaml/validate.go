@@ -0,0 +1,731 @@
+package yaml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// ValidationError is a problem found in a configuration file. Line and
+// Column locate the offending node, and are zero when it is not known.
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	case e.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+}
+
+// ValidationErrors lists every problem found by Validate, one per line.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the hub, aggregate and schema specs of the application
+// directory without configuring anything, and returns ValidationErrors
+// listing every problem found rather than stopping at the first. It reports
+// syntax errors, unknown fields, task and target types which are not
+// registered, methods handled more than once, missing or malformed schema
+// files, unresolved environment and file references, references to
+// schemas which are not declared, APIs listing aggregates which are not
+// defined, aggregates served under the same path, refs to aggregates which
+// are not defined, and user-defined types which cannot be resolved. Task and
+// target types, and the Go handlers of user-defined types, must be
+// registered before calling Validate.
+func (c *Configurer) Validate() error {
+	v := &validator{
+		c:           c,
+		origins:     make(map[*yamlv3.Node]string),
+		taskTypes:   make(map[string]bool),
+		targetTypes: make(map[string]bool),
+	}
+
+	if err := c.open(); err != nil {
+		return err
+	}
+
+	if err := c.checkProfile(); err != nil {
+		return err
+	}
+
+	hubFile, hubRoot, hubSpec := v.validateHub()
+	v.validateTypes(hubFile, hubRoot, hubSpec)
+	declared := v.validateSchemas()
+	aggregates := v.validateAggregates(declared)
+	v.validateAPIs(hubFile, hubRoot, hubSpec, aggregates)
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		a, b := v.errors[i], v.errors[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return logging.RedactError(v.errors)
+}
+
+type validator struct {
+	c           *Configurer
+	origins     map[*yamlv3.Node]string // nodes merged from an overlay
+	errors      ValidationErrors
+	taskTypes   map[string]bool // declared by user-defined-task-types.yml
+	targetTypes map[string]bool // declared by user-defined-target-types.yml
+}
+
+// knownTask reports whether name is a registered or user-defined task type.
+func (v *validator) knownTask(name string) bool {
+	_, ok := entity.TaskRegistry()[name]
+	return ok || v.taskTypes[name]
+}
+
+// knownTarget reports whether name is a registered or user-defined target
+// type.
+func (v *validator) knownTarget(name string) bool {
+	_, ok := entity.TargetRegistry()[name]
+	return ok || v.targetTypes[name]
+}
+
+// checkConfig reports the problems with the config of a task or target of
+// a registered type which decodes its config with entity.DecodeConfig, at
+// the keys at fault. Types declared by the manifests are not checked, since
+// their defaults are merged into their configs.
+func (v *validator) checkConfig(file string, node *yamlv3.Node, kind, typeName string, config map[string]interface{}) {
+	var err error
+	switch {
+	case kind == "task" && !v.taskTypes[typeName]:
+		err = entity.CheckTaskConfig(typeName, normalizeConfig(config))
+	case kind == "target" && !v.targetTypes[typeName]:
+		err = entity.CheckTargetConfig(typeName, normalizeConfig(config))
+	}
+
+	var configErr *entity.ConfigError
+	if !errors.As(err, &configErr) {
+		if err != nil {
+			v.addf(file, node, "invalid config: %v", err)
+		}
+		return
+	}
+
+	configNode := orNode(lookup(node, "config"), node)
+	for _, problem := range configErr.Problems {
+		key, _, _ := strings.Cut(problem.Key, ".")
+		key, _, _ = strings.Cut(key, "[")
+		v.addf(file, orNode(lookup(configNode, key), configNode), "invalid config of %s type %s: %s", kind, typeName, problem)
+	}
+}
+
+// addf records a problem at node, or at the start of file when node is nil.
+// Problems with nodes merged from an overlay are reported in the overlay.
+func (v *validator) addf(file string, node *yamlv3.Node, format string, args ...interface{}) {
+	err := ValidationError{File: v.fileOf(file, node), Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		err.Line, err.Column = node.Line, node.Column
+	}
+	v.errors = append(v.errors, err)
+}
+
+// fileOf returns the file node was read from.
+func (v *validator) fileOf(file string, node *yamlv3.Node) string {
+	if origin, ok := v.origins[node]; ok {
+		return origin
+	}
+	return file
+}
+
+// location formats the position of node for messages.
+func (v *validator) location(file string, node *yamlv3.Node) string {
+	return fmt.Sprintf("%s:%d:%d", v.fileOf(file, node), node.Line, node.Column)
+}
+
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// addYAMLError records the errors returned by the YAML decoder, which
+// carry a line number but no column.
+func (v *validator) addYAMLError(file string, err error) {
+	messages := []string{err.Error()}
+
+	var typeErr *yamlv3.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	for _, message := range messages {
+		validationErr := ValidationError{File: file, Message: message}
+		if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+			validationErr.Line, _ = strconv.Atoi(match[1])
+			validationErr.Message = match[2]
+		}
+		v.errors = append(v.errors, validationErr)
+	}
+}
+
+// parse reads the spec named rel, such as "aggregates/recipe.yaml", checks its fields against spec, and decodes it into spec. The overlay of
+// the selected profile is merged onto specs which have one. parse returns
+// the file reported for problems in the base spec and the root node, which
+// is nil if the spec could not be read or parsed.
+func (v *validator) parse(rel string, overlay bool, spec interface{}) (string, *yamlv3.Node) {
+	file := v.c.source.Location(rel)
+
+	var document *yamlv3.Node
+	var err error
+	if overlay {
+		document, err = v.c.readDocument(rel, v.origins)
+	} else {
+		document, err = readNode(v.c.files, rel)
+	}
+
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &pathErr) && pathErr.Op == "parse":
+		v.addYAMLError(v.c.source.Location(pathErr.Path), pathErr.Err)
+		return file, nil
+	case errors.As(err, &pathErr):
+		v.addf(v.c.source.Location(pathErr.Path), nil, "%v", pathErr.Err)
+		return file, nil
+	case err != nil:
+		v.addf(file, nil, "%v", err)
+		return file, nil
+	}
+
+	newInterpolator().interpolateNode(document, func(node *yamlv3.Node, err error) {
+		v.addf(file, node, "%v", err)
+	})
+
+	root := document.Content[0]
+	v.checkFields(file, root, reflect.TypeOf(spec))
+
+	if err := root.Decode(spec); err != nil {
+		v.addYAMLError(file, err)
+	}
+
+	return file, root
+}
+
+// checkFields reports the keys of node which do not match a field of t.
+// Free-form values, such as task config, are not checked.
+func (v *validator) checkFields(file string, node *yamlv3.Node, t reflect.Type) {
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yamlv3.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				v.addf(file, key, "unknown field %q", key.Value)
+				continue
+			}
+			v.checkFields(file, value, field)
+		}
+
+	case reflect.Map:
+		if node.Kind != yamlv3.MappingNode {
+			return
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			v.checkFields(file, node.Content[i], t.Elem())
+		}
+
+	case reflect.Slice:
+		if node.Kind != yamlv3.SequenceNode {
+			return
+		}
+		for _, item := range node.Content {
+			v.checkFields(file, item, t.Elem())
+		}
+	}
+}
+
+// yamlFields maps the YAML keys of a struct to the types of its fields.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		inline := false
+		for _, flag := range tag[1:] {
+			inline = inline || flag == "inline"
+		}
+		if inline && field.Type.Kind() == reflect.Struct {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// lookup follows a path of mapping keys and sequence indexes from node,
+// returning nil if any of them is missing.
+func lookup(node *yamlv3.Node, path ...interface{}) *yamlv3.Node {
+	for _, step := range path {
+		if node == nil {
+			return nil
+		}
+		if node.Kind == yamlv3.AliasNode {
+			node = node.Alias
+		}
+
+		switch step := step.(type) {
+		case string:
+			if node.Kind != yamlv3.MappingNode {
+				return nil
+			}
+			var next *yamlv3.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == step {
+					next = node.Content[i+1]
+				}
+			}
+			node = next
+		case int:
+			if node.Kind != yamlv3.SequenceNode || step >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[step]
+		}
+	}
+
+	return node
+}
+
+// orNode returns the first of nodes which is not nil, so that problems with
+// a missing key are reported at its parent.
+func orNode(nodes ...*yamlv3.Node) *yamlv3.Node {
+	for _, node := range nodes {
+		if node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateHub() (string, *yamlv3.Node, *model.HubSpec) {
+	var spec model.HubSpec
+	file, root := v.parse("hub.yaml", true, &spec)
+	return file, root, &spec
+}
+
+// validateTypes checks the sidecars of hub.yaml and the manifests of
+// user-defined task and target types, and records the types the manifests
+// declare.
+func (v *validator) validateTypes(hubFile string, hubRoot *yamlv3.Node, hubSpec *model.HubSpec) {
+	var sidecars map[string]model.Sidecar
+	if hubRoot != nil {
+		sidecars = hubSpec.Spec.Sidecars
+	}
+
+	for language, sidecar := range sidecars {
+		sidecarNode := orNode(lookup(hubRoot, "spec", "sidecars", language), hubRoot)
+		if sidecar.Host == "" {
+			v.addf(hubFile, sidecarNode, "the sidecar for language %s has no host", language)
+		}
+		if _, err := time.ParseDuration(sidecar.Timeout); sidecar.Timeout != "" && err != nil {
+			v.addf(hubFile, orNode(lookup(sidecarNode, "timeout"), sidecarNode), "invalid timeout %q", sidecar.Timeout)
+		}
+	}
+
+	for _, manifest := range []struct {
+		file     string
+		kind     string
+		key      string
+		declared map[string]bool
+		handlers func() map[string]bool
+	}{
+		{taskTypesFile, "task", "tasks", v.taskTypes, func() map[string]bool {
+			return keys(entity.TaskHandlerRegistry())
+		}},
+		{targetTypesFile, "target", "targets", v.targetTypes, func() map[string]bool {
+			return keys(entity.TargetHandlerRegistry())
+		}},
+	} {
+		if _, err := fs.Stat(v.c.files, manifest.file); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		var spec model.TypesSpec
+		file, root := v.parse(manifest.file, false, &spec)
+		if root == nil {
+			continue
+		}
+
+		definitions := spec.Spec.Tasks
+		if manifest.kind == "target" {
+			definitions = spec.Spec.Targets
+		}
+
+		locations := make(map[string]string)
+		for i, definition := range definitions {
+			definitionNode := orNode(lookup(root, "spec", manifest.key, i), root)
+			languageNode := orNode(lookup(definitionNode, "language"), definitionNode)
+
+			if definition.Name == "" {
+				v.addf(file, definitionNode, "%s type has no name", manifest.kind)
+			} else if previous, ok := locations[definition.Name]; ok {
+				v.addf(file, definitionNode, "%s type %s is already defined at %s", manifest.kind, definition.Name, previous)
+			}
+			locations[definition.Name] = v.location(file, definitionNode)
+			manifest.declared[definition.Name] = true
+
+			_, importPath, handlerName := splitTypeConfig(definition.Config)
+			switch {
+			case definition.Language == "":
+				v.addf(file, definitionNode, "%s type %s has no language", manifest.kind, definition.Name)
+			case isGo(definition.Language) && (importPath == "" || handlerName == ""):
+				v.addf(file, orNode(lookup(definitionNode, "config"), definitionNode), "Go types require importPath and handlerName")
+			case isGo(definition.Language):
+				if !v.c.skipGoHandlerChecks && !manifest.handlers()[entity.HandlerKey(importPath, handlerName)] {
+					v.addf(file, orNode(lookup(definitionNode, "config", handlerNameKey), definitionNode), "no Go handler is registered for %s", entity.HandlerKey(importPath, handlerName))
+				}
+			case !hasSidecar(sidecars, definition.Language):
+				v.addf(file, languageNode, "no sidecar is configured for language %s", definition.Language)
+			}
+		}
+	}
+}
+
+// keys returns the set of keys of registry.
+func keys[T any](registry map[string]T) map[string]bool {
+	set := make(map[string]bool, len(registry))
+	for key := range registry {
+		set[key] = true
+	}
+	return set
+}
+
+// validateSchemas checks the schema specs, and returns the declared
+// schemas keyed by name and version.
+func (v *validator) validateSchemas() map[string]bool {
+	declared := make(map[string]bool)
+	locations := make(map[string]string)
+
+	files, err := v.c.specFileNames("schemas", false)
+	if err != nil {
+		v.addf(v.c.source.Location("schemas"), nil, "%v", err)
+		return declared
+	}
+
+	for _, name := range files {
+		if !isSpecFile(name) {
+			continue
+		}
+
+		var spec model.SchemasSpec
+		file, root := v.parse(path.Join("schemas", name), false, &spec)
+		if root == nil {
+			continue
+		}
+
+		for i, schema := range spec.Spec.Schemas {
+			node := orNode(lookup(root, "spec", "schemas", i), root)
+
+			if schema.Name == "" {
+				v.addf(file, node, "schema has no name")
+			}
+
+			key := schema.Name + "@" + schema.Version
+			location := v.location(file, node)
+			if previous, ok := locations[key]; ok {
+				v.addf(file, node, "schema %s version %s is already declared at %s", schema.Name, schema.Version, previous)
+			}
+			locations[key] = location
+			declared[key] = true
+
+			fileNode := orNode(lookup(node, "fileName"), node)
+			if schema.FileName == "" {
+				v.addf(file, fileNode, "schema %s has no fileName", schema.Name)
+				continue
+			}
+
+			data, err := fs.ReadFile(v.c.files, path.Join("schemas", schema.FileName))
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				v.addf(file, fileNode, "schema file %s does not exist", schema.FileName)
+			case err != nil:
+				v.addf(file, fileNode, "failed to read schema file %s: %v", schema.FileName, err)
+			case !json.Valid(data):
+				v.addf(file, fileNode, "schema file %s is not valid JSON", schema.FileName)
+			}
+		}
+	}
+
+	return declared
+}
+
+// validatedAggregate is an aggregate spec which could be parsed, kept for
+// the checks which span several specs.
+type validatedAggregate struct {
+	file     string
+	specNode *yamlv3.Node
+	spec     model.AggregateSpec
+}
+
+func (v *validator) validateAggregates(declaredSchemas map[string]bool) []validatedAggregate {
+	files, err := v.c.specFileNames("aggregates", true)
+	if err != nil {
+		v.addf(v.c.source.Location("aggregates"), nil, "%v", err)
+		return nil
+	}
+
+	aggregates := make(map[string]string)
+	var validated []validatedAggregate
+
+	for _, name := range files {
+		var spec model.AggregateSpec
+		file, root := v.parse(path.Join("aggregates", name), true, &spec)
+		if root == nil {
+			continue
+		}
+
+		aggregate := spec.Spec
+		specNode := orNode(lookup(root, "spec"), root)
+
+		if aggregate.Name == "" {
+			v.addf(file, specNode, "aggregate has no name")
+		}
+
+		key := aggregateKey(spec.Namespace, aggregate.APIName, aggregate.Name)
+		location := v.location(file, specNode)
+		if previous, ok := aggregates[key]; ok {
+			v.addf(file, specNode, "aggregate %s of api %s is already defined at %s", qualifiedName(spec.Namespace, aggregate.Name), aggregate.APIName, previous)
+		}
+		aggregates[key] = location
+		validated = append(validated, validatedAggregate{file: file, specNode: specNode, spec: spec})
+
+		if aggregate.SchemaName != "" && !declaredSchemas[aggregate.SchemaName+"@"+aggregate.SchemaVersion] {
+			node := orNode(lookup(specNode, "schemaVersion"), lookup(specNode, "schemaName"))
+			v.addf(file, node, "schema %s version %s is not declared in schemas", aggregate.SchemaName, aggregate.SchemaVersion)
+		}
+
+		if aggregate.Path != "" && !strings.HasPrefix(aggregate.Path, "/") {
+			v.addf(file, orNode(lookup(specNode, "path"), specNode), "path %q must start with /", aggregate.Path)
+		}
+
+		if len(aggregate.Handlers) == 0 {
+			v.addf(file, specNode, "aggregate %s has no handlers", aggregate.Name)
+		}
+
+		v.validateHandlers(file, lookup(specNode, "handlers"), aggregate)
+	}
+
+	return validated
+}
+
+// validateAPIs checks the APIs of hub.yaml against the aggregates, that no
+// two aggregates are served under the same path, and that the refs of each
+// aggregate exist.
+func (v *validator) validateAPIs(hubFile string, hubRoot *yamlv3.Node, hubSpec *model.HubSpec, aggregates []validatedAggregate) {
+	var apis []model.API
+	if hubRoot != nil {
+		apis = hubSpec.Spec.APIs
+	}
+
+	apiLocations := make(map[string]string)
+	for i, api := range apis {
+		apiNode := orNode(lookup(hubRoot, "spec", "apis", i), hubRoot)
+
+		if api.Name == "" {
+			v.addf(hubFile, apiNode, "api has no name")
+		}
+		if previous, ok := apiLocations[strings.ToLower(api.Name)]; ok {
+			v.addf(hubFile, apiNode, "api %s is already defined at %s", api.Name, previous)
+		}
+		apiLocations[strings.ToLower(api.Name)] = v.location(hubFile, apiNode)
+
+		if api.BasePath != "" && !strings.HasPrefix(api.BasePath, "/") {
+			v.addf(hubFile, orNode(lookup(apiNode, "basePath"), apiNode), "basePath %q must start with /", api.BasePath)
+		}
+
+		for _, workflow := range []struct {
+			key   string
+			tasks []model.Task
+		}{{"inbound", api.Inbound}, {"outbound", api.Outbound}} {
+			for k, task := range workflow.tasks {
+				taskNode := lookup(apiNode, workflow.key, k)
+				if !v.knownTask(task.Type) {
+					v.addf(hubFile, orNode(lookup(taskNode, "type"), taskNode, apiNode), "unknown task type %q", task.Type)
+				}
+				v.checkConfig(hubFile, taskNode, "task", task.Type, task.Config)
+			}
+		}
+
+		if len(api.Authorization) > 0 {
+			if !v.knownTask("Authorize") {
+				v.addf(hubFile, orNode(lookup(apiNode, "authorization"), apiNode), "authorization requires the Authorize task type")
+			}
+		}
+	}
+
+	routes := make(map[string]string)
+	names := make(map[string]string) // by API and the last segment of the route
+	for _, aggregate := range aggregates {
+		spec := aggregate.spec
+
+		route := "/" + spec.Spec.APIName + "/" + spec.Spec.Name
+		if api := findAPI(apis, spec.Spec.APIName); api != nil {
+			route = routePath(api, spec.Namespace, spec.Spec.Name)
+		}
+		if spec.Spec.Path != "" {
+			route = specPath(spec.Spec.Path)
+		}
+		name := strings.ToLower(spec.Spec.APIName + "/" + path.Base(route))
+		if previous, ok := routes[strings.ToLower(route)]; ok {
+			v.addf(aggregate.file, aggregate.specNode, "aggregate %s is served under %s, as is the aggregate at %s", qualifiedName(spec.Namespace, spec.Spec.Name), route, previous)
+		} else if previous, ok := names[name]; ok {
+			v.addf(aggregate.file, aggregate.specNode, "aggregate %s is served under the name %s in api %s, as is the aggregate at %s", qualifiedName(spec.Namespace, spec.Spec.Name), path.Base(route), spec.Spec.APIName, previous)
+		} else {
+			routes[strings.ToLower(route)] = v.location(aggregate.file, aggregate.specNode)
+			names[name] = v.location(aggregate.file, aggregate.specNode)
+		}
+	}
+
+	specs := make([]model.AggregateSpec, len(aggregates))
+	for i, aggregate := range aggregates {
+		specs[i] = aggregate.spec
+	}
+
+	findUndefinedAggregates(apis, specs,
+		func(i, j int, message string) {
+			apiNode := orNode(lookup(hubRoot, "spec", "apis", i), hubRoot)
+			v.addf(hubFile, orNode(lookup(apiNode, "aggregates", j), apiNode), "%s", message)
+		},
+		func(i, j int, message string) {
+			aggregate := aggregates[i]
+			v.addf(aggregate.file, orNode(lookup(aggregate.specNode, "refs", j), aggregate.specNode), "%s", message)
+		})
+}
+
+func (v *validator) validateHandlers(file string, handlersNode *yamlv3.Node, aggregate model.Aggregate) {
+	handled := make(map[entity.HTTPMethod]*yamlv3.Node)
+
+	for i, handler := range aggregate.Handlers {
+		handlerNode := lookup(handlersNode, i)
+
+		if len(handler.Methods) == 0 {
+			v.addf(file, handlerNode, "handler has no methods")
+		}
+
+		for j, method := range handler.Methods {
+			methodNode := orNode(lookup(handlerNode, "methods", j), handlerNode)
+
+			httpMethod, err := entity.StringToHTTPMethod(method)
+			if err != nil {
+				v.addf(file, methodNode, "unknown method %q", method)
+				continue
+			}
+
+			if previous, ok := handled[httpMethod]; ok {
+				v.addf(file, methodNode, "method %s is already handled at line %d", method, previous.Line)
+				continue
+			}
+			handled[httpMethod] = methodNode
+		}
+
+		for _, workflow := range []struct {
+			key   string
+			tasks []model.Task
+		}{{"inbound", handler.Inbound}, {"outbound", handler.Outbound}} {
+			workflowNode := lookup(handlerNode, workflow.key)
+			if workflowNode == nil {
+				v.addf(file, handlerNode, "handler has no %s tasks", workflow.key)
+				continue
+			}
+
+			for k, task := range workflow.tasks {
+				taskNode := lookup(workflowNode, k)
+				if !v.knownTask(task.Type) {
+					v.addf(file, orNode(lookup(taskNode, "type"), taskNode), "unknown task type %q", task.Type)
+				}
+				v.checkConfig(file, taskNode, "task", task.Type, task.Config)
+			}
+		}
+
+		if len(aggregate.Authorization) > 0 {
+			if !v.knownTask("Authorize") {
+				v.addf(file, handlerNode, "authorization requires the Authorize task type")
+			}
+		}
+
+		targetNode := lookup(handlerNode, "target")
+		if !v.knownTarget(handler.Target.Type) {
+			v.addf(file, orNode(lookup(targetNode, "type"), targetNode, handlerNode), "unknown target type %q", handler.Target.Type)
+		}
+		v.checkConfig(file, orNode(targetNode, handlerNode), "target", handler.Target.Type, handler.Target.Config)
+	}
+}
+
+// isSpecFile reports whether name is a YAML spec rather than, say, a JSON
+// schema kept alongside the specs.
+func isSpecFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
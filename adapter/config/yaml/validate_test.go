@@ -0,0 +1,187 @@
+package yaml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validSchemasYAML = `
+apiVersion: v1
+specType: Schemas
+spec:
+  schemas:
+    - name: TestSchema
+      version: v0.0.1
+      fileName: test.schema.json
+`
+
+const validAggregateYAML = `
+spec:
+  name: testAggregate
+  apiName: testApp
+  schemaName: TestSchema
+  schemaVersion: v0.0.1
+  handlers:
+    - methods: ["GET"]
+      inbound: []
+      outbound: []
+      target:
+        type: MockTarget
+`
+
+func writeValidationDirectory(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestValidate_Valid(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                 "spec:\n  applicationName: TestApp\n",
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+		"aggregates/test.yaml":     validAggregateYAML,
+	})
+
+	assert.NoError(t, NewConfigurer(dir).Validate())
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	registerMockTasks()
+	registerMockTargets()
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml": "spec:\n  applicationName: TestApp\n  publicPrt: 8080\n",
+		"schemas/schemas.yaml": `
+spec:
+  schemas:
+    - name: TestSchema
+      version: v0.0.1
+      fileName: missing.schema.json
+`,
+		"aggregates/test.yaml": `
+spec:
+  name: testAggregate
+  apiName: testApp
+  schemaName: TestSchema
+  schemaVersion: v0.0.2
+  handlers:
+    - methods: ["GET", "POST"]
+      inbound:
+        - name: first
+          type: NoSuchTask
+      outbound: []
+      target:
+        type: MockTarget
+    - methods: ["POST"]
+      inbound: []
+      outbound: []
+      target:
+        type: NoSuchTarget
+        timeout: 5s
+`,
+	})
+
+	err := NewConfigurer(dir).Validate()
+	require.Error(t, err)
+
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		rel, relErr := filepath.Rel(dir, e.File)
+		require.NoError(t, relErr)
+		e.File = rel
+		messages[i] = e.Error()
+	}
+
+	assert.Equal(t, []string{
+		`aggregates/test.yaml:6:18: schema TestSchema version v0.0.2 is not declared in schemas`,
+		`aggregates/test.yaml:11:17: unknown task type "NoSuchTask"`,
+		`aggregates/test.yaml:15:17: method POST is already handled at line 8`,
+		`aggregates/test.yaml:19:15: unknown target type "NoSuchTarget"`,
+		`aggregates/test.yaml:20:9: unknown field "timeout"`,
+		`hub.yaml:3:3: unknown field "publicPrt"`,
+		`schemas/schemas.yaml:6:17: schema file missing.schema.json does not exist`,
+	}, messages)
+}
+
+// typedTaskConfig is the config of TypedTask, decoded with DecodeConfig.
+type typedTaskConfig struct {
+	Endpoint string        `config:"endpoint,required"`
+	Timeout  time.Duration `config:"timeout"`
+}
+
+func TestValidate_TaskConfigs(t *testing.T) {
+	registerMockTargets()
+	entity.RegisterTaskType("TypedTask", entity.TypedTaskConstructor(func(config typedTaskConfig) (entity.Task, error) {
+		return &MockTask{}, nil
+	}))
+
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":                 "spec:\n  applicationName: TestApp\n",
+		"schemas/schemas.yaml":     validSchemasYAML,
+		"schemas/test.schema.json": `{"type": "object"}`,
+		"aggregates/test.yaml": `spec:
+  name: testAggregate
+  apiName: testApp
+  handlers:
+    - methods: ["GET"]
+      inbound:
+        - name: typed
+          type: TypedTask
+          config:
+            timeout: soon
+            retries: 3
+        - name: untyped
+          type: TypedTask
+      outbound: []
+      target:
+        type: MockTarget
+`,
+	})
+	file := dir + "/aggregates/test.yaml"
+
+	// problems are reported at the keys at fault, or at the config or task
+	// when the key is missing
+	err := NewConfigurer(dir).Validate()
+	require.Error(t, err)
+	assert.Equal(t, strings.Join([]string{
+		file + ":10:13: invalid config of task type TypedTask: endpoint: is required",
+		file + ":10:22: invalid config of task type TypedTask: timeout: invalid duration \"soon\"",
+		file + ":11:22: invalid config of task type TypedTask: retries: unknown key",
+		file + ":12:11: invalid config of task type TypedTask: endpoint: is required",
+	}, "\n"), err.Error())
+}
+
+func TestValidate_SyntaxError(t *testing.T) {
+	dir := writeValidationDirectory(t, map[string]string{
+		"hub.yaml":             "spec:\n  applicationName: [TestApp\n",
+		"schemas/schemas.yaml": "spec:\n  schemas: []\n",
+		"aggregates/test.yaml": "spec:\n  name: test\n  handlers:\n    - methods: GET\n",
+	})
+
+	var errs ValidationErrors
+	require.ErrorAs(t, NewConfigurer(dir).Validate(), &errs)
+
+	var lines []int
+	for _, e := range errs {
+		lines = append(lines, e.Line)
+	}
+	assert.Contains(t, lines, 4, "the type error in the aggregate should carry its line")
+}
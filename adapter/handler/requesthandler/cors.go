@@ -0,0 +1,149 @@
+package requesthandler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions describes the cross-origin requests allowed from browsers.
+// Origins may be exact, "*" for any origin, or contain a single wildcard
+// such as "https://*.example.com".
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int // seconds preflight responses may be cached for
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// WithCORS answers CORS preflight requests and adds CORS headers to the
+// responses of allowed origins.
+func WithCORS(options CORSOptions) HandlerOption {
+	return func(r *RequestHandler) {
+		methods := options.AllowedMethods
+		if len(methods) == 0 {
+			methods = defaultCORSMethods
+		}
+
+		options.AllowedMethods = make([]string, len(methods))
+		for i, method := range methods {
+			options.AllowedMethods[i] = strings.ToUpper(method)
+		}
+		r.cors = &options
+	}
+}
+
+// handle adds the CORS response headers for a request. It reports true when
+// the request was a preflight request and has been answered.
+func (c *CORSOptions) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if origin == "" {
+		return false
+	}
+
+	if !c.originAllowed(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+
+	if c.allowsAnyOrigin() && !c.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		// credentialed requests may not use the * wildcard
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(c.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+		return false
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !contains(c.AllowedMethods, method) {
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+
+	if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		for _, name := range strings.Split(requested, ",") {
+			if !c.headerAllowed(strings.TrimSpace(name)) {
+				w.WriteHeader(http.StatusForbidden)
+				return true
+			}
+		}
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func (c *CORSOptions) allowsAnyOrigin() bool {
+	return contains(c.AllowedOrigins, "*")
+}
+
+func (c *CORSOptions) originAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if ok && len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSOptions) headerAllowed(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, allowed := range c.AllowedHeaders {
+		if allowed == "*" || strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
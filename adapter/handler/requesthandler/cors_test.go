@@ -0,0 +1,104 @@
+package requesthandler
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubHub answers every request with 200 OK.
+type stubHub struct {
+	calls int
+}
+
+func (s *stubHub) HandleRequest(r *http.Request) (entity.ServiceResponse, error) {
+	s.calls++
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK, Header: http.Header{}},
+		Body:         []byte("ok"),
+	}, nil
+}
+
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestCORSPreflight(t *testing.T) {
+	hub := &stubHub{}
+	handler := NewRequestHandler(0, hub, WithCORS(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowedMethods: []string{"get", "put"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         600,
+	}))
+
+	preflight := func(origin string, method string, headers string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/recipeApp/recipe/1", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			r.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		return serve(handler, r)
+	}
+
+	resp := preflight("https://app.example.com", "PUT", "authorization, content-type")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT", resp.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "authorization, content-type", resp.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", resp.Header().Get("Access-Control-Max-Age"))
+
+	assert.Equal(t, http.StatusNoContent, preflight("https://pr-12.preview.example.com", "GET", "").Code)
+	assert.Equal(t, http.StatusForbidden, preflight("https://evil.example.com", "GET", "").Code)
+	assert.Equal(t, http.StatusForbidden, preflight("https://app.example.com", "DELETE", "").Code)
+	assert.Equal(t, http.StatusForbidden, preflight("https://app.example.com", "GET", "X-Secret").Code)
+
+	assert.Equal(t, 0, hub.calls, "preflight requests are not forwarded")
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	hub := &stubHub{}
+	handler := NewRequestHandler(0, hub, WithCORS(CORSOptions{
+		AllowedOrigins:   []string{"*"},
+		ExposedHeaders:   []string{"ETag"},
+		AllowCredentials: true,
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/recipeApp/recipe/1", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	resp := serve(handler, r)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "ETag", resp.Header().Get("Access-Control-Expose-Headers"))
+	assert.Contains(t, resp.Header().Values("Vary"), "Origin")
+	assert.Equal(t, 1, hub.calls)
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := NewRequestHandler(0, &stubHub{}, WithSecurityHeaders(SecurityHeaders{
+		HSTSMaxAge:            31536000,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "default-src 'none'",
+		ContentTypeOptions:    "nosniff",
+	}))
+
+	plain := serve(handler, httptest.NewRequest(http.MethodGet, "/recipeApp/recipe/1", nil))
+	assert.Equal(t, "nosniff", plain.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "default-src 'none'", plain.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, plain.Header().Get("Strict-Transport-Security"), "HSTS is only sent over TLS")
+	assert.Empty(t, plain.Header().Get("X-Frame-Options"))
+
+	r := httptest.NewRequest(http.MethodGet, "/recipeApp/recipe/1", nil)
+	r.TLS = &tls.ConnectionState{}
+	secure := serve(handler, r)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", secure.Header().Get("Strict-Transport-Security"))
+}
@@ -1,12 +1,17 @@
 package requesthandler
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
 	"github.com/labstack/echo/v4"
 )
 
@@ -18,32 +23,112 @@ type RequestHandler struct {
 	hub          RequestForwarder
 	echoInstance *echo.Echo
 	port         int
+	tlsOptions   *TLSOptions
+	reloader     *CertificateReloader
+	server       *http.Server
+	routes       []route
+
+	cors            *CORSOptions
+	securityHeaders *SecurityHeaders
+}
+
+// route is an http.Handler served by the RequestHandler itself rather than
+// forwarded to the hub, such as an admin API.
+type route struct {
+	prefix  string
+	handler http.Handler
+}
+
+// HandlerOption configures optional RequestHandler behaviour.
+type HandlerOption func(*RequestHandler)
+
+// WithTLS serves the handler over TLS using the given certificates and policy.
+func WithTLS(options TLSOptions) HandlerOption {
+	return func(r *RequestHandler) {
+		r.tlsOptions = &options
+	}
+}
+
+// WithRoute serves requests whose path starts with prefix using handler
+// instead of forwarding them to the hub.
+func WithRoute(prefix string, handler http.Handler) HandlerOption {
+	return func(r *RequestHandler) {
+		r.routes = append(r.routes, route{prefix: strings.TrimSuffix(prefix, "/"), handler: handler})
+	}
 }
 
 func NewRequestHandler(
 	port int,
-	hub RequestForwarder) *RequestHandler {
-	return &RequestHandler{
+	hub RequestForwarder,
+	opts ...HandlerOption) *RequestHandler {
+	handler := &RequestHandler{
 		echoInstance: echo.New(),
 		port:         port,
 		hub:          hub,
 	}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	return handler
 }
 
 func (r *RequestHandler) Start(wg *sync.WaitGroup) error {
+	portStr := ":" + strconv.Itoa(r.port)
+
+	r.server = &http.Server{
+		Addr:    portStr,
+		Handler: r,
+	}
+
+	if r.tlsOptions != nil {
+		reloader, err := NewCertificateReloader(*r.tlsOptions)
+		if err != nil {
+			return err
+		}
+
+		if err := reloader.Watch(); err != nil {
+			return err
+		}
+
+		r.reloader = reloader
+		r.server.TLSConfig = reloader.TLSConfig()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		portStr := ":" + strconv.Itoa(r.port)
 
-		log.Printf("Starting server on %s...\r\n", portStr)
-		if err := http.ListenAndServe(portStr, r); err != nil {
+		var err error
+		if r.server.TLSConfig != nil {
+			log.Printf("Starting TLS server on %s...\r\n", portStr)
+			err = r.server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting server on %s...\r\n", portStr)
+			err = r.server.ListenAndServe()
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 	return nil
 }
 
+// Stop gracefully shuts down the server and stops watching certificates.
+func (r *RequestHandler) Stop(ctx context.Context) error {
+	if r.reloader != nil {
+		r.reloader.Close()
+	}
+
+	if r.server == nil {
+		return nil
+	}
+
+	return r.server.Shutdown(ctx)
+}
+
 func (handlerInt *RequestHandler) GetPort() int {
 	return handlerInt.port
 }
@@ -63,10 +148,34 @@ func (handler *RequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if handler.securityHeaders != nil {
+		handler.securityHeaders.apply(w, r)
+	}
+
+	if handler.cors != nil && handler.cors.handle(w, r) {
+		return
+	}
+
+	for _, rt := range handler.routes {
+		if r.URL.Path == rt.prefix || strings.HasPrefix(r.URL.Path, rt.prefix+"/") {
+			rt.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
 	response, err := handler.GetHub().HandleRequest(r)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError)
+		var httpErr *domainerr.HTTPError
+		if errors.As(err, &httpErr) {
+			for header, values := range httpErr.Header {
+				for _, value := range values {
+					w.Header().Add(header, value)
+				}
+			}
+		}
+
+		status := statusForError(err)
+		http.Error(w, http.StatusText(status), status)
 		return
 	}
 
@@ -78,8 +187,48 @@ func (handler *RequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 	w.WriteHeader(response.GetResponseMeta().GetStatusCode())
 
-	if _, err := w.Write(response.GetBody()); err != nil {
+	body, err := response.GetBodyReader()
+	if err != nil {
+		log.Printf("Error reading response body: %v", err)
+		return
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(w, body); err != nil {
 		log.Printf("Error writing response body: %v", err)
 		return
 	}
 }
+
+// statusForError maps domain errors returned by the hub to HTTP status codes.
+func statusForError(err error) int {
+	var httpErr *domainerr.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+
+	switch {
+	case errors.Is(err, domainerr.ErrServiceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, entity.ErrMethodNotConfigured):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, domainerr.ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, domainerr.ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, domainerr.ErrNotAcceptable):
+		return http.StatusNotAcceptable
+	case errors.Is(err, domainerr.ErrMalformedBody):
+		return http.StatusBadRequest
+	case errors.Is(err, domainerr.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, domainerr.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domainerr.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, domainerr.ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
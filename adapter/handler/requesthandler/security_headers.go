@@ -0,0 +1,50 @@
+package requesthandler
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// SecurityHeaders are added to every response. Empty values are omitted.
+type SecurityHeaders struct {
+	HSTSMaxAge            int // seconds; only sent over TLS
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentSecurityPolicy string
+	ContentTypeOptions    string // e.g. nosniff
+	FrameOptions          string // e.g. DENY
+	ReferrerPolicy        string
+}
+
+// WithSecurityHeaders adds the given security headers to every response.
+func WithSecurityHeaders(headers SecurityHeaders) HandlerOption {
+	return func(r *RequestHandler) {
+		r.securityHeaders = &headers
+	}
+}
+
+func (s *SecurityHeaders) apply(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+
+	if s.HSTSMaxAge > 0 && r.TLS != nil {
+		value := fmt.Sprintf("max-age=%d", s.HSTSMaxAge)
+		if s.HSTSIncludeSubdomains {
+			value += "; includeSubDomains"
+		}
+		if s.HSTSPreload {
+			value += "; preload"
+		}
+		header.Set("Strict-Transport-Security", value)
+	}
+
+	for name, value := range map[string]string{
+		"Content-Security-Policy": s.ContentSecurityPolicy,
+		"X-Content-Type-Options":  s.ContentTypeOptions,
+		"X-Frame-Options":         s.FrameOptions,
+		"Referrer-Policy":         s.ReferrerPolicy,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+}
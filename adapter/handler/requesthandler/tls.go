@@ -0,0 +1,270 @@
+package requesthandler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrNoClientCAs is returned when client certificate verification is
+// requested without a CA file to verify against.
+var ErrNoClientCAs = errors.New("client certificate verification requires a client CA file")
+
+// TLSOptions describes the certificates and policy used by a TLS listener.
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	ClientAuth   string // none, request, verify-if-given or require
+	MinVersion   string // 1.0, 1.1, 1.2 or 1.3
+	CipherPolicy string // default, modern or an empty string
+	CipherSuites []string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                tls.NoClientCert,
+	"none":            tls.NoClientCert,
+	"request":         tls.RequestClientCert,
+	"verify-if-given": tls.VerifyClientCertIfGiven,
+	"require":         tls.RequireAndVerifyClientCert,
+}
+
+// modernCipherSuites are the TLS 1.2 suites offering forward secrecy and
+// authenticated encryption. TLS 1.3 suites are not configurable.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// CertificateReloader keeps the server certificate and client CA pool for a
+// listener up to date with the files on disk, so that rotated certificates
+// are picked up without a restart.
+type CertificateReloader struct {
+	options TLSOptions
+	base    *tls.Config
+	watcher *fsnotify.Watcher
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// NewCertificateReloader loads the configured certificate, key and client
+// CAs and validates the TLS policy.
+func NewCertificateReloader(options TLSOptions) (*CertificateReloader, error) {
+	base, err := baseTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader := &CertificateReloader{
+		options: options,
+		base:    base,
+	}
+
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// Reload re-reads the certificate, key and client CA files. The previous
+// certificates stay in use if any of the files cannot be loaded.
+func (r *CertificateReloader) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(r.options.CertFile, r.options.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", r.options.CertFile, err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.options.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.options.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file %s: %w", r.options.ClientCAFile, err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", r.options.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.mu.Unlock()
+
+	return nil
+}
+
+// TLSConfig returns a server TLS config which resolves the current
+// certificate and client CA pool for every handshake.
+func (r *CertificateReloader) TLSConfig() *tls.Config {
+	config := r.base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		handshakeConfig := r.base.Clone()
+		handshakeConfig.Certificates = []tls.Certificate{*r.certificate}
+		handshakeConfig.ClientCAs = r.clientCAs
+		return handshakeConfig, nil
+	}
+
+	return config
+}
+
+// Watch reloads the certificates whenever one of the watched files changes.
+// The containing directories are watched so that files replaced by rename,
+// as done by most secret managers, are also picked up.
+func (r *CertificateReloader) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	files := map[string]bool{}
+	for _, file := range []string{r.options.CertFile, r.options.KeyFile, r.options.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		files[abs] = true
+
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", filepath.Dir(abs), err)
+		}
+	}
+
+	r.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				abs, err := filepath.Abs(event.Name)
+				if err != nil || !files[abs] {
+					continue
+				}
+
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				if err := r.Reload(); err != nil {
+					log.Printf("Failed to reload TLS certificates: %v", err)
+					continue
+				}
+				log.Printf("Reloaded TLS certificates after change to %s", event.Name)
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("TLS certificate watcher error: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops watching for certificate changes.
+func (r *CertificateReloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
+
+func baseTLSConfig(options TLSOptions) (*tls.Config, error) {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, fmt.Errorf("TLS requires both a certificate and a key file")
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if options.MinVersion != "" {
+		version, ok := tlsVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %s", options.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	clientAuth, ok := clientAuthTypes[strings.ToLower(options.ClientAuth)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported client auth type %s", options.ClientAuth)
+	}
+	config.ClientAuth = clientAuth
+
+	if clientAuth >= tls.VerifyClientCertIfGiven && options.ClientCAFile == "" {
+		return nil, ErrNoClientCAs
+	}
+
+	switch {
+	case len(options.CipherSuites) > 0:
+		suites, err := cipherSuitesByName(options.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = suites
+	case strings.EqualFold(options.CipherPolicy, "modern"):
+		config.CipherSuites = modernCipherSuites
+	case options.CipherPolicy == "" || strings.EqualFold(options.CipherPolicy, "default"):
+	default:
+		return nil, fmt.Errorf("unsupported cipher policy %s", options.CipherPolicy)
+	}
+
+	return config, nil
+}
+
+func cipherSuitesByName(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %s", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
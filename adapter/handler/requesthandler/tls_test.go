@@ -0,0 +1,112 @@
+package requesthandler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert writes a self-signed certificate and key for the given
+// common name to dir, returning their paths.
+func writeSelfSignedCert(t *testing.T, dir string, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func currentCommonName(t *testing.T, reloader *CertificateReloader) string {
+	t.Helper()
+
+	cfg, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReloaderReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir, "first")
+
+	reloader, err := NewCertificateReloader(TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Equal(t, "first", currentCommonName(t, reloader))
+
+	writeSelfSignedCert(t, dir, "second")
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, "second", currentCommonName(t, reloader))
+
+	// a broken certificate keeps the previous one in place
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, "second", currentCommonName(t, reloader))
+}
+
+func TestTLSPolicy(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir, "hub")
+
+	reloader, err := NewCertificateReloader(TLSOptions{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: certFile,
+		ClientAuth:   "require",
+		MinVersion:   "1.3",
+		CipherPolicy: "modern",
+	})
+	require.NoError(t, err)
+
+	cfg, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	assert.NotNil(t, cfg.ClientCAs)
+	assert.Equal(t, modernCipherSuites, cfg.CipherSuites)
+
+	_, err = NewCertificateReloader(TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientAuth: "require"})
+	assert.ErrorIs(t, err, ErrNoClientCAs)
+
+	_, err = NewCertificateReloader(TLSOptions{CertFile: certFile, KeyFile: keyFile, MinVersion: "0.9"})
+	assert.Error(t, err)
+
+	_, err = NewCertificateReloader(TLSOptions{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	assert.Error(t, err)
+}
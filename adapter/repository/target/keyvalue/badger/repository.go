@@ -24,17 +24,47 @@ import (
 
 // Repository encapsulates the BadgerDB instance for managing stored values.
 type Repository[T any] struct {
-	db     *badger.DB
-	dbPath string
+	db        *badger.DB
+	dbPath    string
+	encryptor FieldEncryptor
+}
+
+// FieldEncryptor encrypts selected fields of an aggregate before it is
+// stored and decrypts them when it is read. The aggregate ID is given so
+// that encrypted values are bound to the aggregate they belong to.
+type FieldEncryptor interface {
+	Encrypt(aggregateID string, document map[string]any) error
+	Decrypt(aggregateID string, document map[string]any) error
+	NeedsReencryption(document map[string]any) bool
+}
+
+// Option configures optional Repository behaviour.
+type Option func(*options)
+
+type options struct {
+	encryptor FieldEncryptor
+}
+
+// WithFieldEncryption encrypts aggregate fields at rest using encryptor.
+func WithFieldEncryption(encryptor FieldEncryptor) Option {
+	return func(o *options) {
+		o.encryptor = encryptor
+	}
 }
 
 // NewRepository initializes a new BadgerDB instance and returns a Repository.
 // This function should only be used in testing and local development environments.
-func NewRepository[T any](config *map[string]any) (*Repository[T], error) {
+func NewRepository[T any](config *map[string]any, opts ...Option) (*Repository[T], error) {
 	repo := Repository[T]{
 		dbPath: "/tmp/badgerdb",
 	}
 
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	repo.encryptor = o.encryptor
+
 	if config != nil {
 		cfgpath, ok := (*config)["path"]
 		if ok {
@@ -42,7 +72,7 @@ func NewRepository[T any](config *map[string]any) (*Repository[T], error) {
 		}
 	}
 
-	db, err := badger.Open(badger.DefaultOptions("/tmp/badgerdb"))
+	db, err := badger.Open(badger.DefaultOptions(repo.dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open BadgerDB: %w", err)
 	}
@@ -64,7 +94,7 @@ func (r *Repository[T]) Update(in *entity.Aggregate) error {
 		return fmt.Errorf("failed to build StoredAggregate: %w", err)
 	}
 
-	body, err := json.Marshal(storedAggregate)
+	body, err := r.encode(in.ID, storedAggregate)
 	if err != nil {
 		return fmt.Errorf("failed to marshal StoredAggregate: %w", err)
 	}
@@ -93,7 +123,7 @@ func (r *Repository[T]) Create(in *entity.Aggregate) error {
 		return fmt.Errorf("failed to build StoredAggregate: %w", err)
 	}
 
-	body, err := json.Marshal(storedAggregate)
+	body, err := r.encode(in.ID, storedAggregate)
 	if err != nil {
 		return fmt.Errorf("failed to marshal StoredAggregate: %w", err)
 	}
@@ -125,7 +155,7 @@ func (r *Repository[T]) Read(id uuid.UUID) (*entity.Aggregate, error) {
 		}
 
 		return item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &result)
+			return r.decode(id, val, &result)
 		})
 	})
 	if err != nil {
@@ -153,6 +183,138 @@ func (r *Repository[T]) Delete(id uuid.UUID) error {
 	return nil
 }
 
+// Reencrypt rewrites every stored aggregate whose encrypted fields are in
+// plaintext or use a key other than the primary key, so that old keys can
+// be retired after a key rollover. It returns the number of rewritten entries.
+func (r *Repository[T]) Reencrypt() (int, error) {
+	if r.encryptor == nil {
+		return 0, nil
+	}
+
+	var stale [][]byte
+	err := r.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				document, aggregate, err := decodeDocument(val)
+				if err != nil || document == nil {
+					return err
+				}
+				if r.encryptor.NeedsReencryption(aggregate) {
+					stale = append(stale, it.Item().KeyCopy(nil))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan BadgerDB: %w", err)
+	}
+
+	for _, key := range stale {
+		err := r.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			id, err := uuid.FromBytes(key)
+			if err != nil {
+				return err
+			}
+
+			document, aggregate, err := decodeDocument(val)
+			if err != nil {
+				return err
+			}
+			if err := r.encryptor.Decrypt(id.String(), aggregate); err != nil {
+				return err
+			}
+			if err := r.encryptor.Encrypt(id.String(), aggregate); err != nil {
+				return err
+			}
+
+			body, err := json.Marshal(document)
+			if err != nil {
+				return err
+			}
+			return txn.Set(key, body)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt entry %x: %w", key, err)
+		}
+	}
+
+	return len(stale), nil
+}
+
+// encode marshals a stored aggregate, encrypting its configured fields.
+func (r *Repository[T]) encode(id uuid.UUID, stored *model.StoredAggregate[T]) ([]byte, error) {
+	body, err := json.Marshal(stored)
+	if err != nil || r.encryptor == nil {
+		return body, err
+	}
+
+	document, aggregate, err := decodeDocument(body)
+	if err != nil || aggregate == nil {
+		return body, err
+	}
+
+	if err := r.encryptor.Encrypt(id.String(), aggregate); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(document)
+}
+
+// decode unmarshals a stored aggregate, decrypting its configured fields.
+func (r *Repository[T]) decode(id uuid.UUID, val []byte, out *model.StoredAggregate[T]) error {
+	if r.encryptor == nil {
+		return json.Unmarshal(val, out)
+	}
+
+	document, aggregate, err := decodeDocument(val)
+	if err != nil {
+		return err
+	}
+
+	if aggregate != nil {
+		if err := r.encryptor.Decrypt(id.String(), aggregate); err != nil {
+			return err
+		}
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// decodeDocument decodes a stored aggregate generically, returning the whole
+// document and its Aggregate body when the body is a JSON object.
+func decodeDocument(val []byte) (map[string]any, map[string]any, error) {
+	var document map[string]any
+	if err := json.Unmarshal(val, &document); err != nil {
+		return nil, nil, err
+	}
+
+	aggregate, _ := document["Aggregate"].(map[string]any)
+	return document, aggregate, nil
+}
+
 // Close gracefully closes the BadgerDB instance.
 // This function should be called when the repository is no longer needed to prevent resource leaks.
 func (r *Repository[T]) Close() error {
@@ -1,13 +1,17 @@
 package badger_test
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/QueerGlobal/hub-framework/adapter/repository/target/keyvalue/badger"
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/encryption"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestType struct {
@@ -134,3 +138,63 @@ func TestRepository_UpdateInvalidEntry(t *testing.T) {
 	err = repo.Update(&entityAgg)
 	assert.Error(t, err)
 }
+
+type PersonType struct {
+	Name string
+	SSN  string
+}
+
+func TestRepository_FieldEncryption(t *testing.T) {
+	config := map[string]any{"path": t.TempDir()}
+
+	oldKeys, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	newKeys, err := encryption.NewKeyring("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	require.NoError(t, err)
+
+	stored := &entity.Aggregate{
+		ID:            uuid.New(),
+		AggregateName: "Person",
+		Body:          PersonType{Name: "Ada", SSN: "123-45-6789"},
+	}
+
+	repo, err := badger.NewRepository[PersonType](&config,
+		badger.WithFieldEncryption(encryption.NewFieldEncryptor(oldKeys, []string{"SSN"})))
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(stored))
+
+	read, err := repo.Read(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Body, read.Body)
+	require.NoError(t, repo.Close())
+
+	// without the encryptor the stored value is an encrypted envelope
+	raw, err := badger.NewRepository[PersonType](&config)
+	require.NoError(t, err)
+	read, err = raw.Read(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", read.Body.(PersonType).Name)
+	assert.True(t, strings.HasPrefix(read.Body.(PersonType).SSN, "hubenc:v1:k1:"))
+	require.NoError(t, raw.Close())
+
+	// rolling over to a new primary key re-encrypts existing entries
+	repo, err = badger.NewRepository[PersonType](&config,
+		badger.WithFieldEncryption(encryption.NewFieldEncryptor(newKeys, []string{"SSN"})))
+	require.NoError(t, err)
+
+	count, err := repo.Reencrypt()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = repo.Reencrypt()
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	read, err = repo.Read(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Body, read.Body)
+	require.NoError(t, repo.Close())
+}
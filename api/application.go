@@ -1,23 +1,41 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
 
+	"github.com/QueerGlobal/hub-framework/adapter/config/model"
+	"github.com/QueerGlobal/hub-framework/adapter/config/source"
 	"github.com/QueerGlobal/hub-framework/adapter/config/yaml"
 	"github.com/QueerGlobal/hub-framework/adapter/handler/requesthandler"
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/apikey"
+	"github.com/QueerGlobal/hub-framework/service/audit"
+	"github.com/QueerGlobal/hub-framework/service/codec"
 	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/QueerGlobal/hub-framework/service/shared"
 	"github.com/QueerGlobal/hub-framework/service/target"
+	"github.com/QueerGlobal/hub-framework/service/target/keyvalue"
 	"github.com/QueerGlobal/hub-framework/service/task/builtin"
 	"github.com/QueerGlobal/hub-framework/service/task/remote"
+	"github.com/QueerGlobal/hub-framework/service/telemetry"
+	"github.com/QueerGlobal/hub-framework/service/webhook"
 	"github.com/rs/zerolog"
 )
 
 type Application struct {
 	ApplicationName string
 	ApplicationHome string
+	Profile         string
+	ConfigSource    source.Source
+	Aggregates      []*AggregateBuilder
+	SkipGoHandlers  bool
 	PrivatePort     int
 	PublicPort      int
 	CustomTaskTypes []entity.TaskConstructor
@@ -27,6 +45,12 @@ type Application struct {
 	PublicHandler   *requesthandler.RequestHandler
 	PrivateHandler  *requesthandler.RequestHandler
 	Logger          *zerolog.Logger
+
+	// lifecycle is held by Start and Stop, so that an application stopped
+	// while starting is stopped once it has started.
+	lifecycle       sync.Mutex
+	shutdownTracing telemetry.ShutdownFunc
+	configurer      *yaml.Configurer
 }
 
 type Option func(*Application)
@@ -67,6 +91,39 @@ func WithApplicationHome(home string) Option {
 	}
 }
 
+// WithProfile selects the overlay under overlays/ merged onto the specs.
+// Without it the profile is taken from the HUB_PROFILE environment variable.
+func WithProfile(profile string) Option {
+	return func(app *Application) {
+		app.Profile = profile
+	}
+}
+
+// WithConfigSource reads the specs from src, such as an embedded file
+// system or a remote config store, rather than from the application home.
+func WithConfigSource(src source.Source) Option {
+	return func(app *Application) {
+		app.ConfigSource = src
+	}
+}
+
+// WithAggregates serves aggregates defined in code alongside those of the
+// aggregate specs. An aggregate may not be defined both ways.
+func WithAggregates(aggregates ...*AggregateBuilder) Option {
+	return func(app *Application) {
+		app.Aggregates = append(app.Aggregates, aggregates...)
+	}
+}
+
+// WithoutGoHandlerChecks validates user-defined task and target types written
+// in Go without requiring their handlers to be registered, for tools which
+// are not linked with the application.
+func WithoutGoHandlerChecks() Option {
+	return func(app *Application) {
+		app.SkipGoHandlers = true
+	}
+}
+
 func NewApplication(applicationName string, opts ...Option) *Application {
 	// Default settings
 	s := Application{
@@ -87,29 +144,57 @@ func NewApplication(applicationName string, opts ...Option) *Application {
 }
 
 func (a *Application) registerBuiltinTargets() error {
-	// Register the LogWriter task type
-	noopTargetConstructor := entity.TargetConstructorFromFunction(target.NewNoop)
+	// Register the Noop target type
+	noopTargetConstructor := entity.TypedTargetConstructor(target.NewNoopTarget)
 	entity.RegisterTargetType("Noop", noopTargetConstructor)
 
+	// Register the Webhook target type
+	webhookTargetConstructor := entity.TypedTargetConstructor(builtin.NewWebhookTargetFromConfig)
+	entity.RegisterTargetType("Webhook", webhookTargetConstructor)
+
+	// Register the Badger target type
+	badgerTargetConstructor := entity.TypedTargetConstructor(keyvalue.NewBadger)
+	entity.RegisterTargetType("Badger", badgerTargetConstructor)
+
 	// Register other built-in targets here if needed
 	return nil
 }
 
 func (a *Application) registerBuiltinTaskTypes() error {
 	// Register the LogWriter task type
-	logWriterTaskConstructor := entity.TaskConstructorFromFunction(builtin.NewLogWriterTask)
+	logWriterTaskConstructor := entity.TypedTaskConstructor(builtin.NewLogWriter)
 	entity.RegisterTaskType("LogWriter", logWriterTaskConstructor)
 
 	// Register the RequestLogger task type
-	requestLoggerTaskConstructor := entity.TaskConstructorFromFunction(builtin.NewRequestLoggerTask)
+	requestLoggerTaskConstructor := entity.TypedTaskConstructor(builtin.NewRequestLogger)
 	entity.RegisterTaskType("RequestLogger", requestLoggerTaskConstructor)
 
 	// Register the ResponseLogger task type
-	responseLoggerTaskConstructor := entity.TaskConstructorFromFunction(builtin.NewResponseLoggerTask)
+	responseLoggerTaskConstructor := entity.TypedTaskConstructor(builtin.NewResponseLogger)
 	entity.RegisterTaskType("ResponseLogger", responseLoggerTaskConstructor)
 
+	// Register the ValidateJWT task type
+	validateJWTTaskConstructor := entity.TypedTaskConstructor(builtin.NewValidateJWT)
+	entity.RegisterTaskType("ValidateJWT", validateJWTTaskConstructor)
+
+	// Register the Authorize task type
+	authorizeTaskConstructor := entity.TypedTaskConstructor(builtin.NewAuthorize)
+	entity.RegisterTaskType("Authorize", authorizeTaskConstructor)
+
+	// Register the RateLimit task type
+	rateLimitTaskConstructor := entity.TypedTaskConstructor(builtin.NewRateLimit)
+	entity.RegisterTaskType("RateLimit", rateLimitTaskConstructor)
+
+	// Register the ApiKey task type
+	apiKeyTaskConstructor := entity.TypedTaskConstructor(builtin.NewAPIKey)
+	entity.RegisterTaskType("ApiKey", apiKeyTaskConstructor)
+
+	// Register the Webhook task type
+	webhookTaskConstructor := entity.TypedTaskConstructor(builtin.NewWebhook)
+	entity.RegisterTaskType("Webhook", webhookTaskConstructor)
+
 	// Register the HttpForwardingService task type
-	remoteTaskConstructor := entity.TaskConstructorFromFunction(remote.NewForwardingService)
+	remoteTaskConstructor := entity.TypedTaskConstructor(remote.NewForwardingServiceTask)
 	entity.RegisterTaskType("HttpService", remoteTaskConstructor)
 
 	// Register other built-in tasks here if needed
@@ -117,6 +202,16 @@ func (a *Application) registerBuiltinTaskTypes() error {
 	return nil
 }
 
+func (a *Application) registerBuiltinCodecs() error {
+	// JSON is registered by default as the canonical body format
+	entity.RegisterCodec(codec.YAML{})
+	entity.RegisterCodec(codec.MessagePack{})
+	entity.RegisterCodec(codec.Form{})
+
+	// Register other built-in codecs here if needed
+	return nil
+}
+
 func (a *Application) createHub(applicationName string) (Hub, error) {
 	logging.SetLogLevel(a.LogLevel.ToZeroLogLevel())
 	logger := logging.GetLogger()
@@ -130,11 +225,123 @@ func (a *Application) createHub(applicationName string) (Hub, error) {
 	return hub, err
 }
 
-func (a *Application) startHub() error {
+// initAudit records the changes made by mutating requests to every service
+// when the audit section of hub.yaml is enabled.
+func (a *Application) initAudit(hubSpec *model.HubSpec) error {
+	if hubSpec == nil || hubSpec.Spec.Audit == nil || !hubSpec.Spec.Audit.Enabled {
+		return nil
+	}
+
+	store, err := audit.OpenBadgerStore(auditPath(hubSpec.Spec.Audit))
+	if err != nil {
+		return err
+	}
+
+	a.Hub.(*entity.Hub).SetAuditor(audit.NewLog(store))
+
+	return nil
+}
+
+func auditPath(config *model.Audit) string {
+	if config.Path != "" {
+		return config.Path
+	}
+	return audit.DefaultPath
+}
+
+// initTracing installs the global TracerProvider described by the tracing
+// section of hub.yaml. W3C tracecontext propagation is always registered so
+// that incoming trace headers are honoured even when export is disabled.
+func (a *Application) initTracing(hubSpec *model.HubSpec) error {
+	telemetry.RegisterPropagators()
+
+	if hubSpec == nil || hubSpec.Spec.Tracing == nil {
+		return nil
+	}
+
+	tracing := hubSpec.Spec.Tracing
+
+	cfg := telemetry.TracingConfig{
+		Enabled:            tracing.Enabled,
+		ServiceName:        a.ApplicationName,
+		ServiceVersion:     hubSpec.Spec.ApplicationVersion,
+		SamplerRatio:       1.0,
+		Exporter:           tracing.Exporter,
+		Endpoint:           tracing.Endpoint,
+		Insecure:           tracing.Insecure,
+		Headers:            tracing.Headers,
+		FilePath:           tracing.FilePath,
+		ResourceAttributes: tracing.ResourceAttributes,
+	}
+
+	if hubSpec.Spec.ApplicationName != "" {
+		cfg.ServiceName = hubSpec.Spec.ApplicationName
+	}
+
+	if tracing.SamplerRatio != nil {
+		cfg.SamplerRatio = *tracing.SamplerRatio
+	}
+
+	shutdown, err := telemetry.InitTracing(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	a.shutdownTracing = shutdown
+
+	return nil
+}
+
+func (a *Application) startHub(hubSpec *model.HubSpec) error {
 	hub := a.Hub.(*entity.Hub)
 
-	publicHandler := requesthandler.NewRequestHandler(a.PublicPort, hub)
-	//privateHandler := requesthandler.NewRequestHandler(a.PrivatePort, hub)
+	var publicOpts, privateOpts []requesthandler.HandlerOption
+	if hubSpec != nil && hubSpec.Spec.TLS != nil {
+		if listener := hubSpec.Spec.TLS.Public; listener != nil {
+			publicOpts = append(publicOpts, requesthandler.WithTLS(toTLSOptions(listener)))
+		}
+		if listener := hubSpec.Spec.TLS.Private; listener != nil {
+			privateOpts = append(privateOpts, requesthandler.WithTLS(toTLSOptions(listener)))
+		}
+	}
+
+	if hubSpec != nil && hubSpec.Spec.CORS != nil {
+		// browsers only call the public port
+		publicOpts = append(publicOpts, requesthandler.WithCORS(toCORSOptions(hubSpec.Spec.CORS)))
+	}
+
+	if hubSpec != nil && hubSpec.Spec.SecurityHeaders != nil {
+		headers := requesthandler.WithSecurityHeaders(toSecurityHeaders(hubSpec.Spec.SecurityHeaders))
+		publicOpts = append(publicOpts, headers)
+		privateOpts = append(privateOpts, headers)
+	}
+
+	if hubSpec != nil && hubSpec.Spec.APIKeys != nil && hubSpec.Spec.APIKeys.Enabled {
+		route, err := apiKeyAdminRoute(hubSpec.Spec.APIKeys)
+		if err != nil {
+			return err
+		}
+		privateOpts = append(privateOpts, route)
+	}
+
+	if hubSpec != nil && hubSpec.Spec.Audit != nil && hubSpec.Spec.Audit.Enabled {
+		store, err := audit.OpenBadgerStore(auditPath(hubSpec.Spec.Audit))
+		if err != nil {
+			return err
+		}
+		privateOpts = append(privateOpts, requesthandler.WithRoute(audit.AdminPath, audit.NewAdminHandler(store)))
+	}
+
+	if hubSpec != nil && hubSpec.Spec.Webhooks != nil && hubSpec.Spec.Webhooks.Enabled {
+		route, err := webhookAdminRoute(hubSpec.Spec.Webhooks)
+		if err != nil {
+			return err
+		}
+		privateOpts = append(privateOpts, route)
+	}
+
+	publicHandler := requesthandler.NewRequestHandler(a.PublicPort, hub, publicOpts...)
+	privateHandler := requesthandler.NewRequestHandler(a.PrivatePort, hub, privateOpts...)
 
 	handlerWG := sync.WaitGroup{}
 	if err := publicHandler.Start(&handlerWG); err != nil {
@@ -143,25 +350,96 @@ func (a *Application) startHub() error {
 		return err
 	}
 
-	/*
-		TODO:  Registering both the public and private handlers creates
-		a conlfict where the private handler is not able to start
-		as the public handler is already running.
-
-			if err := privateHandler.Start(&handlerWG); err != nil {
-				log.Print("error initializing private handler ")
-				log.Println(err)
-				return nil, nil, err
-			}
-	*/
+	if err := privateHandler.Start(&handlerWG); err != nil {
+		log.Print("error initializing private handler ")
+		log.Println(err)
+		publicHandler.Stop(context.Background())
+		return err
+	}
 
-	a.PrivateHandler = nil // temporary until we have a private handler
+	a.PrivateHandler = privateHandler
 	a.PublicHandler = publicHandler
 
 	return nil
 }
 
+// apiKeyAdminRoute serves the API key admin API, which is only ever
+// exposed on the private port.
+func apiKeyAdminRoute(config *model.APIKeys) (requesthandler.HandlerOption, error) {
+	path := apikey.DefaultPath
+	if config.Path != "" {
+		path = config.Path
+	}
+
+	store, err := apikey.OpenBadgerStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := apikey.NewAdminHandler(apikey.NewManager(store))
+	return requesthandler.WithRoute(apikey.AdminPath, handler), nil
+}
+
+// webhookAdminRoute serves the webhook dead letter admin API, which is only
+// ever exposed on the private port.
+func webhookAdminRoute(config *model.Webhooks) (requesthandler.HandlerOption, error) {
+	path := webhook.DefaultPath
+	if config.Path != "" {
+		path = config.Path
+	}
+
+	store, err := webhook.OpenBadgerStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return requesthandler.WithRoute(webhook.AdminPath, webhook.NewAdminHandler(store)), nil
+}
+
+func toCORSOptions(cors *model.CORS) requesthandler.CORSOptions {
+	return requesthandler.CORSOptions{
+		AllowedOrigins:   cors.AllowedOrigins,
+		AllowedMethods:   cors.AllowedMethods,
+		AllowedHeaders:   cors.AllowedHeaders,
+		ExposedHeaders:   cors.ExposedHeaders,
+		AllowCredentials: cors.AllowCredentials,
+		MaxAge:           cors.MaxAge,
+	}
+}
+
+func toSecurityHeaders(headers *model.SecurityHeaders) requesthandler.SecurityHeaders {
+	security := requesthandler.SecurityHeaders{
+		ContentSecurityPolicy: headers.ContentSecurityPolicy,
+		ContentTypeOptions:    headers.ContentTypeOptions,
+		FrameOptions:          headers.FrameOptions,
+		ReferrerPolicy:        headers.ReferrerPolicy,
+	}
+
+	if headers.HSTS != nil {
+		security.HSTSMaxAge = headers.HSTS.MaxAge
+		security.HSTSIncludeSubdomains = headers.HSTS.IncludeSubdomains
+		security.HSTSPreload = headers.HSTS.Preload
+	}
+
+	return security
+}
+
+func toTLSOptions(listener *model.TLSListener) requesthandler.TLSOptions {
+	return requesthandler.TLSOptions{
+		CertFile:     listener.CertFile,
+		KeyFile:      listener.KeyFile,
+		ClientCAFile: listener.ClientCAFile,
+		ClientAuth:   listener.ClientAuth,
+		MinVersion:   listener.MinVersion,
+		CipherPolicy: listener.CipherPolicy,
+		CipherSuites: listener.CipherSuites,
+	}
+}
+
 func (a *Application) Start() error {
+	a.lifecycle.Lock()
+	defer a.lifecycle.Unlock()
+
 	// create the hub
 	hub, err := a.createHub(a.ApplicationName)
 	if err != nil {
@@ -186,7 +464,14 @@ func (a *Application) Start() error {
 		return err
 	}
 
-	configurer := yaml.NewConfigurer(a.ApplicationHome)
+	err = a.registerBuiltinCodecs()
+	if err != nil {
+		err = fmt.Errorf("failed to register built-in codecs: %w", err)
+		log.Println(err)
+		return err
+	}
+
+	configurer := a.newConfigurer()
 
 	if hub == nil {
 		return fmt.Errorf("failed to create hub")
@@ -200,16 +485,164 @@ func (a *Application) Start() error {
 		return err
 	}
 
+	err = a.initAudit(configurer.GetHubSpec())
+	if err != nil {
+		err = fmt.Errorf("failed to initialize audit log: %w", err)
+		log.Println(err)
+		return err
+	}
+
+	err = a.initTracing(configurer.GetHubSpec())
+	if err != nil {
+		err = fmt.Errorf("failed to initialize tracing: %w", err)
+		log.Println(err)
+		return err
+	}
+
 	// start the hub
-	if err := a.startHub(); err != nil {
+	if err := a.startHub(configurer.GetHubSpec()); err != nil {
 		err = fmt.Errorf("failed to start hub service: %w", err)
 		log.Println(err)
 		return err
 	}
 
+	a.configurer = configurer
+	if hubSpec := configurer.GetHubSpec(); hubSpec != nil && hubSpec.Spec.HotReload {
+		if err := configurer.Watch(hub.(*entity.Hub)); err != nil {
+			err = fmt.Errorf("failed to watch configuration: %w", err)
+			log.Println(err)
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (a *Application) Stop() error {
+	a.lifecycle.Lock()
+	defer a.lifecycle.Unlock()
+
+	if a.configurer != nil {
+		if err := a.configurer.Close(); err != nil {
+			return fmt.Errorf("failed to stop configuration watcher: %w", err)
+		}
+	}
+
+	for _, handler := range []*requesthandler.RequestHandler{a.PublicHandler, a.PrivateHandler} {
+		if handler != nil {
+			if err := handler.Stop(context.Background()); err != nil {
+				return fmt.Errorf("failed to stop request handler: %w", err)
+			}
+		}
+	}
+
+	if err := webhook.CloseAll(); err != nil {
+		return fmt.Errorf("failed to stop webhooks: %w", err)
+	}
+
+	// the audit log, API keys, dead letters, idempotent responses, rate
+	// limits and Badger targets opened while starting and serving
+	if err := shared.CloseAll(); err != nil {
+		return fmt.Errorf("failed to close stores: %w", err)
+	}
+
+	if a.shutdownTracing != nil {
+		if err := a.shutdownTracing(context.Background()); err != nil {
+			return fmt.Errorf("failed to shut down tracing: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the configuration in the application home without
+// starting the hub, reporting every problem found with its file, line and
+// column. Task and target types registered by the application must be
+// registered before calling Validate.
+func (a *Application) Validate() error {
+	for _, register := range []func() error{
+		a.registerBuiltinTaskTypes,
+		a.registerBuiltinTargets,
+		a.registerBuiltinCodecs,
+	} {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+
+	return a.newConfigurer().Validate()
+}
+
+// WriteConfigSchemas writes the JSON Schema of the config of each
+// registered task and target type which publishes one, such as those
+// registered with TypedTaskConstructor, to dir as
+// tasks/{type}.schema.json and targets/{type}.schema.json, for editors to
+// complete and check the configs of aggregate specs with.
+func (a *Application) WriteConfigSchemas(dir string) error {
+	for _, register := range []func() error{
+		a.registerBuiltinTaskTypes,
+		a.registerBuiltinTargets,
+	} {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+
+	for kind, schemas := range map[string]map[string]map[string]any{
+		"tasks":   entity.TaskConfigSchemas(),
+		"targets": entity.TargetConfigSchemas(),
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, kind), 0755); err != nil {
+			return err
+		}
+
+		for name, schema := range schemas {
+			schema["title"] = name
+			data, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal the config schema of %s: %w", name, err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, kind, name+".schema.json"), append(data, '\n'), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
+
+// WriteEffectiveConfig writes the specs as they are applied, with the
+// overlay of the profile merged and references resolved.
+func (a *Application) WriteEffectiveConfig(w io.Writer) error {
+	return a.newConfigurer().WriteEffectiveConfig(w)
+}
+
+// ExportAggregates writes the services the running hub serves as canonical
+// aggregate specs, including those defined in code, so that they can be
+// inspected, diffed or committed. Secrets are masked.
+func (a *Application) ExportAggregates(w io.Writer) error {
+	hub, ok := a.Hub.(*entity.Hub)
+	if !ok || hub == nil {
+		return fmt.Errorf("the application has not been started")
+	}
+
+	return yaml.WriteAggregates(w, hub)
+}
+
+func (a *Application) newConfigurer() *yaml.Configurer {
+	var opts []yaml.ConfigurerOption
+	if a.Profile != "" {
+		opts = append(opts, yaml.WithProfile(a.Profile))
+	}
+	if a.ConfigSource != nil {
+		opts = append(opts, yaml.WithSource(a.ConfigSource))
+	}
+	if a.SkipGoHandlers {
+		opts = append(opts, yaml.WithoutGoHandlerChecks())
+	}
+	for _, aggregate := range a.Aggregates {
+		opts = append(opts, yaml.WithServices(aggregate))
+	}
+
+	return yaml.NewConfigurer(a.ApplicationHome, opts...)
+}
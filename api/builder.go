@@ -0,0 +1,287 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// HTTPMethod is the method of a request an aggregate handles.
+type HTTPMethod = entity.HTTPMethod
+
+// The methods handled by the handlers of an AggregateBuilder.
+const (
+	GET     = entity.HTTPMethodGET
+	POST    = entity.HTTPMethodPOST
+	PUT     = entity.HTTPMethodPUT
+	PATCH   = entity.HTTPMethodPATCH
+	DELETE  = entity.HTTPMethodDELETE
+	HEAD    = entity.HTTPMethodHEAD
+	OPTIONS = entity.HTTPMethodOPTIONS
+)
+
+// AggregateBuilder defines an aggregate in code, as an alternative to an
+// aggregate spec, and builds the same service an aggregate spec does:
+//
+//	api.Aggregate("recipe").
+//		API("recipeApp").
+//		Schema("Recipe", "v0.0.2").
+//		Handle(api.POST, api.PUT).
+//		Inbound(validateRecipe).
+//		Target(recipeStore).
+//		Handle(api.GET).
+//		Target(recipeStore)
+//
+// Handle starts a handler for the methods given, to which the following
+// calls to Inbound, Outbound and Target apply. Tasks run in the order
+// they are given. RegisteredTask and RegisteredTarget use registered types,
+// such as the builtin ones, in place of tasks and targets of your own.
+// Mistakes, such as a handler without a target, are reported by Build.
+// Tasks and targets of your own are recorded with their Go type, such as
+// *tasks.AuditTask, as their type.
+type AggregateBuilder struct {
+	name          string
+	apiName       string
+	namespace     string
+	path          string
+	schemaName    string
+	schemaVersion string
+	public        bool
+	body          entity.BodyOptions
+	handlers      []*handlerBuilder
+	err           error
+}
+
+type handlerBuilder struct {
+	methods  []HTTPMethod
+	inbound  []Task
+	outbound []Task
+	target   Target
+}
+
+// Aggregate starts the definition of the aggregate named name.
+func Aggregate(name string) *AggregateBuilder {
+	return &AggregateBuilder{name: name}
+}
+
+// API sets the API the aggregate belongs to, which it is served under by
+// default as /{api}/{name}.
+func (b *AggregateBuilder) API(name string) *AggregateBuilder {
+	b.apiName = name
+	return b
+}
+
+// Namespace tells the aggregate apart from aggregates of the same name
+// defined by other teams.
+func (b *AggregateBuilder) Namespace(namespace string) *AggregateBuilder {
+	b.namespace = namespace
+	return b
+}
+
+// Path serves the aggregate under path rather than /{api}/{name}.
+func (b *AggregateBuilder) Path(path string) *AggregateBuilder {
+	b.path = path
+	return b
+}
+
+// Schema sets the schema the bodies of the aggregate conform to.
+func (b *AggregateBuilder) Schema(name, version string) *AggregateBuilder {
+	b.schemaName = name
+	b.schemaVersion = version
+	return b
+}
+
+// Public serves the aggregate on the public port.
+func (b *AggregateBuilder) Public() *AggregateBuilder {
+	b.public = true
+	return b
+}
+
+// Body sets the body size and streaming options for incoming requests.
+func (b *AggregateBuilder) Body(options entity.BodyOptions) *AggregateBuilder {
+	b.body = options
+	return b
+}
+
+// Handle starts a handler for methods.
+func (b *AggregateBuilder) Handle(methods ...HTTPMethod) *AggregateBuilder {
+	b.handlers = append(b.handlers, &handlerBuilder{methods: methods})
+	return b
+}
+
+// Inbound adds tasks applied to incoming requests to the current handler.
+func (b *AggregateBuilder) Inbound(tasks ...Task) *AggregateBuilder {
+	if handler := b.current("Inbound"); handler != nil {
+		handler.inbound = append(handler.inbound, tasks...)
+	}
+	return b
+}
+
+// Outbound adds tasks applied to outgoing responses to the current
+// handler.
+func (b *AggregateBuilder) Outbound(tasks ...Task) *AggregateBuilder {
+	if handler := b.current("Outbound"); handler != nil {
+		handler.outbound = append(handler.outbound, tasks...)
+	}
+	return b
+}
+
+// Target sets the target of the current handler.
+func (b *AggregateBuilder) Target(target Target) *AggregateBuilder {
+	if handler := b.current("Target"); handler != nil {
+		handler.target = target
+	}
+	return b
+}
+
+// current returns the handler started by the last call to Handle, recording
+// an error if there is none.
+func (b *AggregateBuilder) current(call string) *handlerBuilder {
+	if len(b.handlers) == 0 {
+		if b.err == nil {
+			b.err = fmt.Errorf("aggregate %s: %s called before Handle", b.name, call)
+		}
+		return nil
+	}
+	return b.handlers[len(b.handlers)-1]
+}
+
+// Build returns the service of the aggregate.
+func (b *AggregateBuilder) Build() (*entity.Service, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.name == "" {
+		return nil, fmt.Errorf("aggregate has no name")
+	}
+	if b.apiName == "" {
+		return nil, fmt.Errorf("aggregate %s has no api", b.name)
+	}
+
+	service, err := entity.NewService(b.apiName, b.name, b.schemaName, b.schemaVersion, b.public)
+	if err != nil {
+		return nil, err
+	}
+	service.Namespace = b.namespace
+	service.Path = b.path
+	service.BodyOptions = b.body
+
+	for _, handler := range b.handlers {
+		if len(handler.methods) == 0 {
+			return nil, fmt.Errorf("aggregate %s has a handler with no methods", b.name)
+		}
+
+		built, err := handler.build()
+		if err != nil {
+			return nil, fmt.Errorf("aggregate %s: handler for %v: %w", b.name, handler.methods, err)
+		}
+
+		for _, method := range handler.methods {
+			if _, err := entity.StringToHTTPMethod(string(method)); err != nil {
+				return nil, fmt.Errorf("aggregate %s: unknown method %q", b.name, method)
+			}
+			if _, ok := service.Methods[method]; ok {
+				return nil, fmt.Errorf("aggregate %s: method %s is already handled", b.name, method)
+			}
+			service.SetHandler(method, built)
+		}
+	}
+
+	return service, nil
+}
+
+func (h *handlerBuilder) build() (*entity.Handler, error) {
+	if h.target == nil {
+		return nil, fmt.Errorf("no target")
+	}
+
+	inbound, err := buildWorkflow(h.inbound)
+	if err != nil {
+		return nil, fmt.Errorf("inbound: %w", err)
+	}
+	outbound, err := buildWorkflow(h.outbound)
+	if err != nil {
+		return nil, fmt.Errorf("outbound: %w", err)
+	}
+
+	handler := &entity.Handler{
+		InboundWorkflow:  inbound,
+		OutboundWorkflow: outbound,
+		Target:           NewTargetAdapter(h.target),
+		TargetType:       fmt.Sprintf("%T", h.target),
+	}
+	if registered, ok := h.target.(*registeredTarget); ok {
+		handler.Target, err = entity.GetTarget(registered.targetType, registered.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get target: %w", err)
+		}
+		handler.TargetType = registered.targetType
+		handler.TargetConfig = registered.config
+	}
+
+	return handler, nil
+}
+
+// buildWorkflow builds a workflow running tasks in order.
+func buildWorkflow(tasks []Task) (entity.Workflow, error) {
+	steps := make([]*entity.WorkflowStep, 0, len(tasks))
+	for _, task := range tasks {
+		if task == nil {
+			return nil, fmt.Errorf("nil task")
+		}
+
+		step := &entity.WorkflowStep{Name: task.Name(), Task: NewTaskAdapter(task), TaskType: fmt.Sprintf("%T", task)}
+		if registered, ok := task.(*registeredTask); ok {
+			built, err := entity.GetTask(registered.taskType, registered.config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get task for step %s: %w", registered.taskType, err)
+			}
+			step.Task = built
+			step.TaskType = registered.taskType
+			step.Config = registered.config
+		}
+		steps = append(steps, step)
+	}
+
+	return entity.NewWorkflowTasks(steps...), nil
+}
+
+// registeredTask is a task of a registered type, such as the builtin
+// ValidateJWT, used by an AggregateBuilder. It is constructed by Build, once
+// the application has registered its types.
+type registeredTask struct {
+	taskType string
+	config   map[string]any
+}
+
+// RegisteredTask returns a task of the registered task type taskType,
+// configured as a task of that type is in an aggregate spec.
+func RegisteredTask(taskType string, config map[string]any) Task {
+	return &registeredTask{taskType: taskType, config: config}
+}
+
+func (t *registeredTask) Name() string {
+	return t.taskType
+}
+
+func (t *registeredTask) Apply(ctx context.Context, request ServiceRequest) error {
+	return fmt.Errorf("task type %s is applied once built", t.taskType)
+}
+
+// registeredTarget is a target of a registered type used by an
+// AggregateBuilder, constructed by Build.
+type registeredTarget struct {
+	targetType string
+	config     map[string]any
+}
+
+// RegisteredTarget returns a target of the registered target type
+// targetType, configured as a target of that type is in an aggregate spec.
+func RegisteredTarget(targetType string, config map[string]any) Target {
+	return &registeredTarget{targetType: targetType, config: config}
+}
+
+func (t *registeredTarget) Apply(ctx context.Context, request ServiceRequest) (ServiceResponse, error) {
+	return nil, fmt.Errorf("target type %s is applied once built", t.targetType)
+}
@@ -0,0 +1,208 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/yaml"
+	"github.com/QueerGlobal/hub-framework/adapter/handler/requesthandler"
+	"github.com/QueerGlobal/hub-framework/api"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendTask appends its name to the body of the request.
+type appendTask struct {
+	name string
+}
+
+func (t *appendTask) Name() string {
+	return t.name
+}
+
+func (t *appendTask) Apply(ctx context.Context, request api.ServiceRequest) error {
+	request.SetBody(append(request.GetBody(), []byte(t.name)...))
+	return nil
+}
+
+// echoTarget answers with the body of the request.
+type echoTarget struct{}
+
+func (echoTarget) Apply(ctx context.Context, request api.ServiceRequest) (api.ServiceResponse, error) {
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK},
+		Body:         request.GetBody(),
+	}, nil
+}
+
+func registerTestTypes() {
+	api.RegisterTaskType("AppendTask", api.TaskConstructorFunc(func(config map[string]any) (api.Task, error) {
+		return &appendTask{name: config["text"].(string)}, nil
+	}))
+	api.RegisterTargetType("EchoTarget", api.TargetConstructorFunc(func(config map[string]any) (api.Target, error) {
+		return echoTarget{}, nil
+	}))
+}
+
+func serve(t *testing.T, hub *entity.Hub, method, path, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	requesthandler.NewRequestHandler(8080, hub).ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rr
+}
+
+func TestAggregateBuilder_Build(t *testing.T) {
+	registerTestTypes()
+
+	service, err := api.Aggregate("recipe").
+		API("recipeApp").
+		Namespace("kitchen").
+		Schema("Recipe", "v0.0.2").
+		Public().
+		Handle(api.POST, api.PUT).
+		Inbound(&appendTask{name: "-a"}, api.RegisteredTask("AppendTask", map[string]any{"text": "-b"})).
+		Inbound(&appendTask{name: "-c"}).
+		Target(echoTarget{}).
+		Handle(api.GET).
+		Target(api.RegisteredTarget("EchoTarget", nil)).
+		Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, "recipe", service.Name)
+	assert.Equal(t, "recipeApp", service.APIName)
+	assert.Equal(t, "kitchen", service.Namespace)
+	assert.Equal(t, "Recipe", service.SchemaName)
+	assert.Equal(t, "v0.0.2", service.SchemaVersion)
+	assert.True(t, service.IsPublic)
+	assert.Equal(t, "/recipeApp/recipe", service.RoutePath())
+	assert.Len(t, service.Methods, 3)
+	assert.Same(t, service.Methods[entity.HTTPMethodPOST], service.Methods[entity.HTTPMethodPUT])
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+	require.NoError(t, hub.AddService(service))
+
+	// inbound tasks run in the order they were given
+	rr := serve(t, hub, http.MethodPost, "/recipeapp/recipe", "body")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "body-a-b-c", rr.Body.String())
+
+	rr = serve(t, hub, http.MethodGet, "/recipeapp/recipe", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAggregateBuilder_Errors(t *testing.T) {
+	registerTestTypes()
+
+	tests := []struct {
+		name      string
+		aggregate *api.AggregateBuilder
+		want      string
+	}{
+		{
+			name:      "no api",
+			aggregate: api.Aggregate("recipe").Handle(api.GET).Target(echoTarget{}),
+			want:      "aggregate recipe has no api",
+		},
+		{
+			name:      "target before handle",
+			aggregate: api.Aggregate("recipe").API("recipeApp").Target(echoTarget{}),
+			want:      "aggregate recipe: Target called before Handle",
+		},
+		{
+			name:      "no target",
+			aggregate: api.Aggregate("recipe").API("recipeApp").Handle(api.GET),
+			want:      "aggregate recipe: handler for [GET]: no target",
+		},
+		{
+			name:      "no methods",
+			aggregate: api.Aggregate("recipe").API("recipeApp").Handle().Target(echoTarget{}),
+			want:      "aggregate recipe has a handler with no methods",
+		},
+		{
+			name: "method handled twice",
+			aggregate: api.Aggregate("recipe").API("recipeApp").
+				Handle(api.GET).Target(echoTarget{}).
+				Handle(api.GET, api.POST).Target(echoTarget{}),
+			want: "aggregate recipe: method GET is already handled",
+		},
+		{
+			name: "unknown task type",
+			aggregate: api.Aggregate("recipe").API("recipeApp").
+				Handle(api.GET).Inbound(api.RegisteredTask("MissingTask", nil)).Target(echoTarget{}),
+			want: "aggregate recipe: handler for [GET]: inbound: failed to get task for step MissingTask",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.aggregate.Build()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+const chefAggregateYAML = `
+spec:
+  name: chef
+  apiName: recipeApp
+  handlers:
+    - methods: ["GET"]
+      inbound:
+        - name: fromSpec
+          type: AppendTask
+          config:
+            text: "-spec"
+      outbound: []
+      target:
+        type: EchoTarget
+`
+
+func writeApplicationDirectory(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestAggregateBuilder_MixedWithSpecs(t *testing.T) {
+	registerTestTypes()
+
+	dir := writeApplicationDirectory(t, map[string]string{
+		"hub.yaml":             "spec:\n  applicationName: recipeApp\n",
+		"aggregates/chef.yaml": chefAggregateYAML,
+		"schemas/schemas.yaml": "spec:\n  schemas: []\n",
+	})
+
+	recipe := api.Aggregate("recipe").
+		API("recipeApp").
+		Handle(api.GET).
+		Inbound(&appendTask{name: "-code"}).
+		Target(echoTarget{})
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+	require.NoError(t, yaml.NewConfigurer(dir, yaml.WithServices(recipe)).ConfigureHub(hub))
+
+	assert.Equal(t, "-spec", serve(t, hub, http.MethodGet, "/recipeapp/chef", "").Body.String())
+	assert.Equal(t, "-code", serve(t, hub, http.MethodGet, "/recipeapp/recipe", "").Body.String())
+
+	// an aggregate may not be defined both in code and by a spec
+	chef := api.Aggregate("chef").API("recipeApp").Handle(api.GET).Target(echoTarget{})
+	hub, err = entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+	err = yaml.NewConfigurer(dir, yaml.WithServices(chef)).ConfigureHub(hub)
+	assert.EqualError(t, err, "aggregate chef of api recipeApp is defined both in code and by an aggregate spec")
+}
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// Codec converts between a wire format and the canonical JSON body
+// representation used by tasks and targets.
+type Codec = entity.Codec
+
+// RegisterCodec registers a codec for each of the media types it handles.
+// Custom codecs should be registered before the application is started.
+func RegisterCodec(codec Codec) {
+	entity.RegisterCodec(codec)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// ConfigError lists every problem DecodeConfig found with a config.
+type ConfigError = entity.ConfigError
+
+// DecodeConfig decodes the config of a task or target into out, a pointer
+// to a struct whose fields are tagged with the keys they are read from,
+// whether they are required and their defaults:
+//
+//	type AuditConfig struct {
+//		Topic   string        `config:"topic,required" description:"topic audit events are sent to"`
+//		Timeout time.Duration `config:"timeout" default:"5s"`
+//	}
+//
+// See entity.DecodeConfig for the details.
+func DecodeConfig(config map[string]any, out any) error {
+	return entity.DecodeConfig(config, out)
+}
+
+// TypedTaskConstructor returns a TaskConstructor which decodes its config
+// into a C with DecodeConfig before passing it to fn. Task types registered
+// with it have their configs checked by Validate and publish the JSON
+// Schema of C.
+func TypedTaskConstructor[C any](fn func(config C) (Task, error)) TaskConstructor {
+	return typedTaskConstructor[C]{fn: fn}
+}
+
+type typedTaskConstructor[C any] struct {
+	typedConfig[C]
+	fn func(config C) (Task, error)
+}
+
+func (c typedTaskConstructor[C]) New(config map[string]any) (Task, error) {
+	var decoded C
+	if err := entity.DecodeConfig(config, &decoded); err != nil {
+		return nil, err
+	}
+	return c.fn(decoded)
+}
+
+// TypedTargetConstructor returns a TargetConstructor which decodes its
+// config into a C with DecodeConfig before passing it to fn, as
+// TypedTaskConstructor does.
+func TypedTargetConstructor[C any](fn func(config C) (Target, error)) TargetConstructor {
+	return typedTargetConstructor[C]{fn: fn}
+}
+
+type typedTargetConstructor[C any] struct {
+	typedConfig[C]
+	fn func(config C) (Target, error)
+}
+
+func (c typedTargetConstructor[C]) New(config map[string]any) (Target, error) {
+	var decoded C
+	if err := entity.DecodeConfig(config, &decoded); err != nil {
+		return nil, err
+	}
+	return c.fn(decoded)
+}
+
+// typedConfig implements entity.ConfigDecoder for configs decoded into a C.
+type typedConfig[C any] struct{}
+
+func (typedConfig[C]) CheckConfig(config map[string]any) error {
+	var decoded C
+	return entity.DecodeConfig(config, &decoded)
+}
+
+func (typedConfig[C]) ConfigSchema() map[string]any {
+	return entity.ConfigSchema(new(C))
+}
+
+// decodingTaskConstructor is an adapted task constructor which keeps the
+// config checks and schema of the constructor it adapts.
+type decodingTaskConstructor struct {
+	entity.TaskConstructorFunc
+	entity.ConfigDecoder
+}
+
+// decodingTargetConstructor is the target counterpart of
+// decodingTaskConstructor.
+type decodingTargetConstructor struct {
+	entity.TargetConstructorFunc
+	entity.ConfigDecoder
+}
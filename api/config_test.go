@@ -0,0 +1,49 @@
+package api_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/api"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type suffixConfig struct {
+	Suffix string `config:"suffix,required"`
+}
+
+func TestTypedTaskConstructor(t *testing.T) {
+	api.RegisterTaskType("SuffixTask", api.TypedTaskConstructor(func(config suffixConfig) (api.Task, error) {
+		return &appendTask{name: config.Suffix}, nil
+	}))
+
+	task, err := entity.GetTask("SuffixTask", map[string]any{"suffix": "-s"})
+	require.NoError(t, err)
+	assert.Equal(t, "-s", task.Name())
+
+	// registered types keep the config checks and schema of their constructor
+	_, err = entity.GetTask("SuffixTask", map[string]any{})
+	assert.EqualError(t, err, "invalid config: suffix: is required")
+	assert.EqualError(t, entity.CheckTaskConfig("SuffixTask", map[string]any{"suffix": 1}), "invalid config: suffix: expected a string, got int")
+	assert.Equal(t, []string{"suffix"}, entity.TaskConfigSchemas()["SuffixTask"]["required"])
+}
+
+func TestWriteConfigSchemas(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, api.NewApplication("schemas").WriteConfigSchemas(dir))
+
+	// every builtin type publishes the schema of its config
+	for kind, types := range map[string][]string{
+		"tasks": {
+			"LogWriter", "RequestLogger", "ResponseLogger", "ValidateJWT",
+			"Authorize", "RateLimit", "ApiKey", "Webhook", "HttpService",
+		},
+		"targets": {"Noop", "Webhook", "Badger"},
+	} {
+		for _, name := range types {
+			assert.FileExists(t, filepath.Join(dir, kind, name+".schema.json"))
+		}
+	}
+}
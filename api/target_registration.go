@@ -22,8 +22,20 @@ func (f TargetConstructorFunc) New(config map[string]any) (Target, error) {
 type RegisteredTargets map[string]TargetConstructor
 
 func RegisterTargetType(name string, targetConstructor TargetConstructor) {
-	var entityTargetConstructor entity.TargetConstructorFunc
-	entityTargetConstructor = func(config map[string]any) (entity.Target, error) {
+	entity.RegisterTargetType(name, adaptTargetConstructor(targetConstructor))
+}
+
+// RegisterTargetHandler registers a target constructor under the import
+// path and handler name which target type manifests, such as
+// user-defined-target-types.yml, refer to it by.
+func RegisterTargetHandler(importPath, handlerName string, targetConstructor TargetConstructor) {
+	entity.RegisterTargetHandler(importPath, handlerName, adaptTargetConstructor(targetConstructor))
+}
+
+// adaptTargetConstructor converts a TargetConstructor to an
+// entity.TargetConstructor.
+func adaptTargetConstructor(targetConstructor TargetConstructor) entity.TargetConstructor {
+	adapted := entity.TargetConstructorFunc(func(config map[string]any) (entity.Target, error) {
 		target, err := targetConstructor.New(config)
 		if err != nil {
 			return nil, err
@@ -31,9 +43,12 @@ func RegisterTargetType(name string, targetConstructor TargetConstructor) {
 
 		tgt := NewTargetAdapter(target)
 		return tgt, nil
-	}
+	})
 
-	entity.RegisterTargetType(name, entityTargetConstructor)
+	if decoder, ok := targetConstructor.(entity.ConfigDecoder); ok {
+		return decodingTargetConstructor{adapted, decoder}
+	}
+	return adapted
 }
 
 func GetTarget(targetName string, config map[string]interface{}) (entity.Target, error) {
@@ -17,9 +17,21 @@ func (f TaskConstructorFunc) New(config map[string]any) (Task, error) {
 }
 
 func RegisterTaskType(name string, taskConstructor TaskConstructor) {
-	var entityTaskConstructor entity.TaskConstructorFunc
-	// Create an adapter function to convert TaskConstructor to entity.TaskConstructor
-	entityTaskConstructor = func(config map[string]any) (entity.Task, error) {
+	entity.RegisterTaskType(name, adaptTaskConstructor(taskConstructor))
+}
+
+// RegisterTaskHandler registers a task constructor under the import path
+// and handler name which task type manifests, such as
+// user-defined-task-types.yml, refer to it by:
+//
+//	api.RegisterTaskHandler("github.com/org/app/tasks", "AuditTask", constructor)
+func RegisterTaskHandler(importPath, handlerName string, taskConstructor TaskConstructor) {
+	entity.RegisterTaskHandler(importPath, handlerName, adaptTaskConstructor(taskConstructor))
+}
+
+// adaptTaskConstructor converts a TaskConstructor to an entity.TaskConstructor.
+func adaptTaskConstructor(taskConstructor TaskConstructor) entity.TaskConstructor {
+	adapted := entity.TaskConstructorFunc(func(config map[string]any) (entity.Task, error) {
 		task, err := taskConstructor.New(config)
 		if err != nil {
 			return nil, err
@@ -28,9 +40,12 @@ func RegisterTaskType(name string, taskConstructor TaskConstructor) {
 		taskAdapter := NewTaskAdapter(task)
 
 		return taskAdapter, nil
-	}
+	})
 
-	entity.RegisterTaskType(name, entityTaskConstructor)
+	if decoder, ok := taskConstructor.(entity.ConfigDecoder); ok {
+		return decodingTaskConstructor{adapted, decoder}
+	}
+	return adapted
 }
 
 func GetTask(taskName string, config map[string]interface{}) (entity.Task, error) {
@@ -0,0 +1,204 @@
+// Command hub works with hub application directories.
+//
+//	hub validate [-profile name] [directory | url]
+//	hub config [-profile name] [directory | url]
+//	hub schemas [directory]
+//	hub reencrypt -keyring file -schema file database
+//
+// validate checks the hub, aggregate and schema specs of a directory,
+// defaulting to the current one, or of a remote config store given by its
+// http or https URL, and prints every problem found as
+// file:line:column. Only the builtin task and target types, and those
+// declared by the user-defined type manifests, are known to it; the Go
+// handlers of user-defined types are not checked, since they are not linked
+// into it. Applications can call Application.Validate from their own binary
+// to check them too.
+//
+// config prints the effective configuration: the specs with the overlay of
+// the profile merged and environment and file references resolved, with
+// secrets masked.
+//
+// schemas writes the JSON Schema of the config of each builtin task and
+// target type to a directory, defaulting to config-schemas, for editors to
+// complete and check the configs of aggregate specs with.
+//
+// reencrypt rewrites the aggregates of the database of a Badger target whose
+// fields marked x-hub-encrypted in the given JSON schema are in plaintext or
+// encrypted with a key other than the primary key of the keyring, so that
+// old keys can be retired after a key rollover. Badger databases can only be
+// opened by one process, so the hub using the database must be stopped.
+//
+// The profile defaults to the HUB_PROFILE environment variable.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/QueerGlobal/hub-framework/adapter/config/source"
+	"github.com/QueerGlobal/hub-framework/adapter/repository/target/keyvalue/badger"
+	"github.com/QueerGlobal/hub-framework/api"
+	"github.com/QueerGlobal/hub-framework/service/encryption"
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "validate":
+		os.Exit(validate(flag.Args()[1:]))
+	case "config":
+		os.Exit(config(flag.Args()[1:]))
+	case "schemas":
+		os.Exit(schemas(flag.Args()[1:]))
+	case "reencrypt":
+		os.Exit(reencrypt(flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: hub validate [-profile name] [directory | url]")
+	fmt.Fprintln(os.Stderr, "       hub config [-profile name] [directory | url]")
+	fmt.Fprintln(os.Stderr, "       hub schemas [directory]")
+	fmt.Fprintln(os.Stderr, "       hub reencrypt -keyring file -schema file database")
+}
+
+// application parses the flags and directory shared by the commands.
+func application(name string, args []string) (*api.Application, string, bool) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = usage
+	profile := flags.String("profile", "", "overlay merged onto the specs")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		if err == nil {
+			usage()
+		}
+		return nil, "", false
+	}
+
+	directory := "."
+	if flags.NArg() == 1 {
+		directory = flags.Arg(0)
+	}
+
+	opts := []api.Option{api.WithApplicationHome(directory), api.WithoutGoHandlerChecks()}
+	if strings.HasPrefix(directory, "http://") || strings.HasPrefix(directory, "https://") {
+		opts = append(opts, api.WithConfigSource(source.NewHTTP(source.HTTPConfig{URL: directory})))
+	}
+	if *profile != "" {
+		opts = append(opts, api.WithProfile(*profile))
+	}
+
+	return api.NewApplication("hub", opts...), directory, true
+}
+
+func validate(args []string) int {
+	app, directory, ok := application("validate", args)
+	if !ok {
+		return 2
+	}
+
+	if err := app.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s: configuration is valid\n", directory)
+	return 0
+}
+
+func config(args []string) int {
+	app, _, ok := application("config", args)
+	if !ok {
+		return 2
+	}
+
+	if err := app.WriteEffectiveConfig(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func schemas(args []string) int {
+	if len(args) > 1 {
+		usage()
+		return 2
+	}
+
+	directory := "config-schemas"
+	if len(args) == 1 {
+		directory = args[0]
+	}
+
+	if err := api.NewApplication("hub").WriteConfigSchemas(directory); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s: config schemas written\n", directory)
+	return 0
+}
+
+func reencrypt(args []string) int {
+	flags := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	flags.Usage = usage
+	keyringFile := flags.String("keyring", "", "keyring whose primary key values are encrypted with")
+	schemaFile := flags.String("schema", "", "JSON schema marking the encrypted fields")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *keyringFile == "" || *schemaFile == "" {
+		if err == nil {
+			usage()
+		}
+		return 2
+	}
+
+	keyring, err := encryption.LoadKeyring(*keyringFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	schema, err := os.ReadFile(*schemaFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fields, err := encryption.EncryptedFields(schema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *schemaFile, err)
+		return 1
+	}
+
+	database := flags.Arg(0)
+	repo, err := badger.NewRepository[map[string]any](&map[string]any{"path": database},
+		badger.WithFieldEncryption(encryption.NewFieldEncryptor(keyring, fields)))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repo.Close()
+
+	count, err := repo.Reencrypt()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s: %d aggregates re-encrypted\n", database, count)
+	return 0
+}
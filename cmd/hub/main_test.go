@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestValidateExample(t *testing.T) {
+	for _, args := range [][]string{
+		{"../../example/recipe-app"},
+		{"-profile", "prod", "../../example/recipe-app"},
+	} {
+		if code := validate(args); code != 0 {
+			t.Errorf("hub validate %v exited with %d", args, code)
+		}
+	}
+}
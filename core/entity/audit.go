@@ -0,0 +1,184 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Auditor records the changes made by successful mutating requests.
+type Auditor interface {
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// AuditEvent describes a change to an aggregate. Before and After hold the
+// JSON state of the aggregate around the change, and are nil when the
+// aggregate did not exist before or no longer exists after it.
+type AuditEvent struct {
+	RequestID   uuid.UUID
+	Principal   string
+	APIName     string
+	ServiceName string
+	AggregateID string
+	Method      HTTPMethod
+	Before      []byte
+	After       []byte
+	Time        time.Time
+}
+
+// SetAuditor sets the auditor of every service of the hub, including
+// services added later.
+func (hub *Hub) SetAuditor(auditor Auditor) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.auditor = auditor
+	for _, service := range hub.services {
+		service.Auditor = auditor
+	}
+}
+
+// doAuditedRequest runs the target of a mutating request whose inbound
+// workflow has run, and records its change with the service auditor when
+// the target answers with a 2xx status. The state of the aggregate is read
+// through the GET target of the service, when there is one, as the
+// principal the request was authenticated as, before and after the target
+// runs. A change which cannot be recorded is logged rather than failing a
+// request whose change has already been made.
+func (service *Service) doAuditedRequest(ctx context.Context, handler *Handler, request ServiceRequest) error {
+	aggregateID := aggregateIDOf(request)
+	before := service.snapshot(ctx, request, aggregateID)
+
+	if err := handler.applyTarget(ctx, request); err != nil {
+		return err
+	}
+
+	if !succeeded(request) {
+		return nil
+	}
+
+	if aggregateID == "" {
+		// a created aggregate is identified by the response or the request
+		aggregateID = idFromBody(responseBody(request))
+		if aggregateID == "" {
+			aggregateID = idFromBody(request.GetBody())
+		}
+	}
+
+	var after []byte
+	if request.GetMethod() != HTTPMethodDELETE {
+		after = service.snapshot(ctx, request, aggregateID)
+		if after == nil && json.Valid(request.GetBody()) {
+			after = request.GetBody()
+		}
+	}
+
+	err := service.Auditor.Record(ctx, AuditEvent{
+		RequestID:   request.GetID(),
+		Principal:   request.GetClaims().Subject(),
+		APIName:     request.GetAPIName(),
+		ServiceName: request.GetServiceName(),
+		AggregateID: aggregateID,
+		Method:      request.GetMethod(),
+		Before:      before,
+		After:       after,
+		Time:        time.Now().UTC(),
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).
+			Str("apiName", request.GetAPIName()).
+			Str("serviceName", request.GetServiceName()).
+			Str("aggregateId", aggregateID).
+			Str("requestId", request.GetID().String()).
+			Msg("failed to record audit event")
+	}
+
+	return nil
+}
+
+// succeeded reports whether the response to a request has a 2xx status.
+// Responses without a status are taken to be 200 OK.
+func succeeded(request ServiceRequest) bool {
+	response := request.GetResponse()
+	if response == nil || response.GetResponseMeta() == nil {
+		return true
+	}
+
+	status := response.GetResponseMeta().GetStatusCode()
+	return status == 0 || (status >= 200 && status < 300)
+}
+
+// snapshot returns the JSON state of an aggregate as returned by the GET
+// target of the service, or nil if it cannot be read.
+func (service *Service) snapshot(ctx context.Context, request ServiceRequest, aggregateID string) []byte {
+	handler, ok := service.Methods[HTTPMethodGET]
+	if !ok || handler.Target == nil || aggregateID == "" {
+		return nil
+	}
+
+	path := "/" + request.GetAPIName() + "/" + request.GetServiceName() + "/" + aggregateID
+	get := &HTTPServiceRequest{
+		ID:           request.GetID(),
+		ApiName:      request.GetAPIName(),
+		ServiceName:  request.GetServiceName(),
+		Method:       HTTPMethodGET,
+		URL:          &url.URL{Path: path},
+		InternalPath: path,
+		Header:       http.Header{},
+		Claims:       request.GetClaims(),
+	}
+
+	response, err := handler.Target.Apply(ctx, get)
+	if err != nil || response == nil {
+		return nil
+	}
+	if meta := response.GetResponseMeta(); meta != nil && meta.GetStatusCode() >= 300 {
+		return nil
+	}
+
+	body := response.GetBody()
+	if !json.Valid(body) {
+		return nil
+	}
+	return body
+}
+
+// aggregateIDOf returns the aggregate ID from a request path of the form
+// /{api}/{service}/{id}.
+func aggregateIDOf(request ServiceRequest) string {
+	segments := strings.Split(strings.Trim(request.GetInternalPath(), "/"), "/")
+	if len(segments) < 3 {
+		return ""
+	}
+	return segments[2]
+}
+
+func responseBody(request ServiceRequest) []byte {
+	if response := request.GetResponse(); response != nil {
+		return response.GetBody()
+	}
+	return nil
+}
+
+func idFromBody(body []byte) string {
+	var document struct {
+		ID any `json:"id"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil || document.ID == nil {
+		return ""
+	}
+
+	switch id := document.ID.(type) {
+	case string:
+		return id
+	default:
+		encoded, _ := json.Marshal(id)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,223 @@
+package entity
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+)
+
+// DefaultSpillThreshold is the number of body bytes held in memory before a
+// streaming body is spilled to a temporary file.
+const DefaultSpillThreshold int64 = 1 << 20
+
+// ErrBodyConsumed is returned when an unbuffered streaming body is read more than once.
+var ErrBodyConsumed = errors.New("request body has already been consumed")
+
+// BodyOptions controls how request bodies are read for a service.
+type BodyOptions struct {
+	Streaming      bool   // Read the body lazily instead of buffering it up front
+	MaxBodySize    int64  // Maximum accepted body size in bytes, 0 for no limit
+	SpillThreshold int64  // Bytes kept in memory before spilling to disk
+	TempDir        string // Directory for spill files, os.TempDir() if empty
+	// MediaTypes are the request content types without a codec which are
+	// passed through as they are, such as text/plain or image/*, rather than
+	// answered with 415 Unsupported Media Type
+	MediaTypes []string
+}
+
+func (o BodyOptions) spillThreshold() int64 {
+	if o.SpillThreshold > 0 {
+		return o.SpillThreshold
+	}
+	return DefaultSpillThreshold
+}
+
+// RequestBody is a lazily read request body. Until it is buffered, the
+// underlying source can be handed out exactly once so that targets can pipe
+// data through without holding it in memory. Once buffered, the content is
+// kept in memory up to the spill threshold and in a temporary file beyond it,
+// and can be re-read any number of times.
+type RequestBody struct {
+	mu       sync.Mutex
+	source   io.ReadCloser
+	options  BodyOptions
+	consumed bool
+	buffered bool
+	data     []byte
+	spill    string
+	size     int64
+}
+
+// NewRequestBody wraps source as a lazily read body, enforcing the
+// configured maximum size.
+func NewRequestBody(source io.ReadCloser, options BodyOptions) *RequestBody {
+	if source == nil {
+		source = io.NopCloser(bytes.NewReader(nil))
+	}
+	if options.MaxBodySize > 0 {
+		source = &limitedBody{ReadCloser: source, remaining: options.MaxBodySize}
+	}
+
+	return &RequestBody{
+		source:  source,
+		options: options,
+	}
+}
+
+// Reader returns a reader over the body. If the body has been buffered a new
+// reader over the buffered content is returned on every call; otherwise the
+// underlying source is returned and subsequent calls fail with ErrBodyConsumed.
+func (b *RequestBody) Reader() (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.buffered {
+		return b.openBuffered()
+	}
+
+	if b.consumed {
+		return nil, ErrBodyConsumed
+	}
+
+	b.consumed = true
+	return b.source, nil
+}
+
+// Buffer reads the remainder of the source, spilling to a temporary file
+// once the spill threshold is exceeded.
+func (b *RequestBody) Buffer() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer()
+}
+
+func (b *RequestBody) buffer() error {
+	if b.buffered {
+		return nil
+	}
+
+	if b.consumed {
+		return ErrBodyConsumed
+	}
+	b.consumed = true
+
+	threshold := b.options.spillThreshold()
+
+	var mem bytes.Buffer
+	n, err := io.CopyN(&mem, b.source, threshold+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if n <= threshold {
+		b.data = mem.Bytes()
+		b.size = n
+		b.buffered = true
+		return nil
+	}
+
+	file, err := os.CreateTemp(b.options.TempDir, "hub-body-*")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	written, err := io.Copy(file, io.MultiReader(&mem, b.source))
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	b.spill = file.Name()
+	b.size = written
+	b.buffered = true
+
+	return nil
+}
+
+// Bytes buffers the body if needed and returns its full content. For bodies
+// that have spilled to disk this reads the spill file into memory.
+func (b *RequestBody) Bytes() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.buffer(); err != nil {
+		return nil, err
+	}
+
+	if b.spill != "" {
+		return os.ReadFile(b.spill)
+	}
+
+	return b.data, nil
+}
+
+// Size returns the number of bytes buffered so far.
+func (b *RequestBody) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.size
+}
+
+// IsSpilled reports whether the buffered body has been written to disk.
+func (b *RequestBody) IsSpilled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.spill != ""
+}
+
+// Close removes any spill file. The underlying source is owned by the HTTP
+// server and is not closed here.
+func (b *RequestBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.spill == "" {
+		return nil
+	}
+
+	err := os.Remove(b.spill)
+	b.spill = ""
+	b.buffered = false
+	return err
+}
+
+func (b *RequestBody) openBuffered() (io.ReadCloser, error) {
+	if b.spill != "" {
+		return os.Open(b.spill)
+	}
+	return io.NopCloser(bytes.NewReader(b.data)), nil
+}
+
+// limitedBody fails with ErrBodyTooLarge once more than remaining bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// probe for any data past the limit
+		var probe [1]byte
+		n, err := l.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, domainerr.ErrBodyTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,198 @@
+package entity_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/target"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBodyBuffersInMemory(t *testing.T) {
+	body := entity.NewRequestBody(io.NopCloser(strings.NewReader("small")), entity.BodyOptions{SpillThreshold: 16})
+	defer body.Close()
+
+	data, err := body.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(data))
+	assert.False(t, body.IsSpilled())
+
+	// buffered bodies can be read repeatedly
+	reader, err := body.Reader()
+	require.NoError(t, err)
+	again, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(again))
+}
+
+func TestRequestBodySpillsToDisk(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 64)
+	body := entity.NewRequestBody(io.NopCloser(bytes.NewReader(content)), entity.BodyOptions{
+		SpillThreshold: 16,
+		TempDir:        t.TempDir(),
+	})
+
+	require.NoError(t, body.Buffer())
+	assert.True(t, body.IsSpilled())
+	assert.Equal(t, int64(64), body.Size())
+
+	reader, err := body.Reader()
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	require.NoError(t, body.Close())
+	assert.False(t, body.IsSpilled())
+}
+
+func TestRequestBodyStreamsOnce(t *testing.T) {
+	body := entity.NewRequestBody(io.NopCloser(strings.NewReader("stream")), entity.BodyOptions{})
+
+	reader, err := body.Reader()
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "stream", string(data))
+
+	_, err = body.Reader()
+	assert.ErrorIs(t, err, entity.ErrBodyConsumed)
+}
+
+func TestRequestBodyMaxSize(t *testing.T) {
+	body := entity.NewRequestBody(io.NopCloser(strings.NewReader("too large")), entity.BodyOptions{MaxBodySize: 3})
+
+	_, err := body.Bytes()
+	assert.ErrorIs(t, err, domainerr.ErrBodyTooLarge)
+}
+
+func TestGetRequestFromHttpWithOptions(t *testing.T) {
+	t.Run("streaming leaves the body unread", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/service", strings.NewReader(`{"key":"value"}`))
+
+		req, err := entity.GetRequestFromHttpWithOptions(r, entity.BodyOptions{Streaming: true})
+		require.NoError(t, err)
+		assert.Nil(t, req.Body)
+		require.NotNil(t, req.BodyStream)
+
+		reader, err := req.GetBodyReader()
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, `{"key":"value"}`, string(data))
+	})
+
+	t.Run("content length above maximum is rejected", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/service", strings.NewReader("0123456789"))
+
+		_, err := entity.GetRequestFromHttpWithOptions(r, entity.BodyOptions{MaxBodySize: 5})
+		assert.ErrorIs(t, err, domainerr.ErrBodyTooLarge)
+	})
+
+	t.Run("chunked body above maximum is rejected", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/service", strings.NewReader("0123456789"))
+		r.ContentLength = -1
+
+		_, err := entity.GetRequestFromHttpWithOptions(r, entity.BodyOptions{MaxBodySize: 5})
+		assert.ErrorIs(t, err, domainerr.ErrBodyTooLarge)
+	})
+}
+
+// connectionBody stands in for a request body owned by the server, which
+// can no longer be read once the server discards it.
+type connectionBody struct {
+	io.Reader
+	discarded bool
+}
+
+func (b *connectionBody) Read(p []byte) (int, error) {
+	if b.discarded {
+		return 0, io.ErrClosedPipe
+	}
+	return b.Reader.Read(p)
+}
+
+func (b *connectionBody) Close() error {
+	return nil
+}
+
+func TestHub_EchoedBodyOutlivesRequest(t *testing.T) {
+	spillDir := t.TempDir()
+
+	service, err := entity.NewService("api", "echo", "", "", false)
+	require.NoError(t, err)
+	service.BodyOptions = entity.BodyOptions{Streaming: true, SpillThreshold: 16, TempDir: spillDir}
+	service.SetHandler(entity.HTTPMethodPOST, &entity.Handler{
+		InboundWorkflow:  entity.NewWorkflowTasks(),
+		OutboundWorkflow: entity.NewWorkflowTasks(),
+		Target:           &target.Noop{},
+	})
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+	require.NoError(t, hub.AddService(service))
+
+	payload := strings.Repeat("0123456789", 100)
+	body := &connectionBody{Reader: strings.NewReader(payload)}
+	r := httptest.NewRequest(http.MethodPost, "/api/echo", nil)
+	r.Body = body
+
+	response, err := hub.HandleRequest(r)
+	require.NoError(t, err)
+
+	// the server discards the request body once the headers are written,
+	// but the echo reads the spilled copy, which is kept until it is closed
+	body.discarded = true
+	reader, err := response.GetBodyReader()
+	require.NoError(t, err)
+	echoed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(echoed))
+
+	require.NoError(t, reader.Close())
+	spills, err := os.ReadDir(spillDir)
+	require.NoError(t, err)
+	assert.Empty(t, spills)
+}
+
+func TestHub_PassesThroughBodiesWithoutCodec(t *testing.T) {
+	service, err := entity.NewService("api", "echo", "", "", false)
+	require.NoError(t, err)
+	service.SetHandler(entity.HTTPMethodPOST, &entity.Handler{
+		InboundWorkflow:  entity.NewWorkflowTasks(),
+		OutboundWorkflow: entity.NewWorkflowTasks(),
+		Target:           &target.Noop{},
+	})
+
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+	require.NoError(t, hub.AddService(service))
+
+	// other media types must be allowed by the service
+	r := httptest.NewRequest(http.MethodPost, "/api/echo", strings.NewReader("plain body"))
+	r.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = hub.HandleRequest(r)
+	assert.ErrorIs(t, err, domainerr.ErrUnsupportedMediaType)
+
+	service.BodyOptions.MediaTypes = []string{"text/*"}
+	for _, contentType := range []string{"text/plain; charset=utf-8", "multipart/form-data; boundary=xyz"} {
+		r := httptest.NewRequest(http.MethodPost, "/api/echo", strings.NewReader("plain body"))
+		r.Header.Set("Content-Type", contentType)
+
+		response, err := hub.HandleRequest(r)
+		require.NoError(t, err, contentType)
+		assert.Equal(t, "plain body", string(response.GetBody()), contentType)
+	}
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "context"
+
+// ResponseCache caches responses to safe requests for a service. It is
+// optional; services without a cache run the full workflow for every request.
+type ResponseCache interface {
+	// Fetch returns a cached response for a safe request, or calls next to
+	// produce one. Identical concurrent requests share a single call to next.
+	Fetch(ctx context.Context, request ServiceRequest, next func(ctx context.Context) (ServiceResponse, error)) (ServiceResponse, error)
+
+	// Invalidate drops cached responses affected by a successful write.
+	Invalidate(request ServiceRequest)
+}
+
+// IsSafeMethod reports whether a method is safe (read-only) as defined by
+// RFC 9110, and so eligible for response caching.
+func IsSafeMethod(method HTTPMethod) bool {
+	switch method {
+	case HTTPMethodGET, HTTPMethodHEAD:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,43 @@
+package entity
+
+import "strings"
+
+// Claims holds the attributes of the authenticated principal of a request,
+// as established by an authentication task such as ValidateJWT.
+type Claims map[string]any
+
+// Subject returns the "sub" claim, identifying the principal.
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Scopes returns the scopes granted to the principal, read from either a
+// space separated "scope" claim or a "scp" list.
+func (c Claims) Scopes() []string {
+	if scope, ok := c["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return c.Strings("scp")
+}
+
+// Strings returns a claim as a list of strings. Single string claims are
+// returned as a one element list.
+func (c Claims) Strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
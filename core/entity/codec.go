@@ -0,0 +1,328 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+)
+
+// MediaTypeJSON is the canonical representation of request and response
+// bodies seen by tasks and targets.
+const MediaTypeJSON = "application/json"
+
+// Codec converts between a wire format and the generic values produced by
+// encoding/json (maps, slices, strings, float64s, bools and nil), which the
+// hub uses as its canonical body representation.
+type Codec interface {
+	// MediaTypes returns the media types handled by this codec. The first
+	// entry is used as the Content-Type of encoded responses.
+	MediaTypes() []string
+	Unmarshal(data []byte) (any, error)
+	Marshal(v any) ([]byte, error)
+}
+
+// RegisteredCodecs maps lower-case media types to the codec handling them.
+type RegisteredCodecs map[string]Codec
+
+var (
+	onceRegisteredCodecs sync.Once
+	registeredCodecs     RegisteredCodecs
+	codecsMu             sync.RWMutex
+)
+
+func codecs() RegisteredCodecs {
+	onceRegisteredCodecs.Do(func() {
+		registeredCodecs = make(RegisteredCodecs)
+		jsonCodec := JSONCodec{}
+		for _, mediaType := range jsonCodec.MediaTypes() {
+			registeredCodecs[mediaType] = jsonCodec
+		}
+	})
+
+	return registeredCodecs
+}
+
+// CodecRegistry returns a copy of the registered codecs. The JSON codec is
+// always registered.
+func CodecRegistry() RegisteredCodecs {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	registry := make(RegisteredCodecs, len(codecs()))
+	for mediaType, codec := range codecs() {
+		registry[mediaType] = codec
+	}
+	return registry
+}
+
+// RegisterCodec registers a codec for each of its media types, replacing any
+// codec previously registered for them. Codecs may be registered while
+// requests are being served.
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	for _, mediaType := range codec.MediaTypes() {
+		codecs()[strings.ToLower(mediaType)] = codec
+	}
+}
+
+// lookupCodec returns the codec registered for a lower-case media type.
+func lookupCodec(mediaType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs()[mediaType]
+	return codec, ok
+}
+
+// GetCodec returns the codec registered for the media type of the given
+// Content-Type header value, ignoring any parameters such as charset.
+func GetCodec(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	return lookupCodec(strings.ToLower(mediaType))
+}
+
+// NegotiateCodec selects the codec for a response from an Accept header value.
+// It returns a nil codec when the client accepts any media type, and
+// ErrNotAcceptable when none of the accepted media types have a codec.
+//
+// Parameters:
+//   - accept: The value of the Accept header.
+//
+// Returns:
+//   - Codec: The codec with the highest quality value, or nil for any.
+//   - error: ErrNotAcceptable if no acceptable codec is registered.
+func NegotiateCodec(accept string) (Codec, error) {
+	if strings.TrimSpace(accept) == "" {
+		return nil, nil
+	}
+
+	type acceptRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: strings.ToLower(mediaType), quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if r.mediaType == "*/*" || r.mediaType == "application/*" {
+			return nil, nil
+		}
+
+		if codec, ok := lookupCodec(r.mediaType); ok {
+			return codec, nil
+		}
+	}
+
+	return nil, domainerr.ErrNotAcceptable
+}
+
+// DecodeBody converts a body in the given content type to its canonical
+// JSON representation. Bodies without a content type are assumed to be JSON
+// and returned unchanged.
+//
+// Parameters:
+//   - contentType: The Content-Type header of the body.
+//   - body: The encoded body.
+//
+// Returns:
+//   - []byte: The body encoded as JSON.
+//   - error: ErrUnsupportedMediaType if no codec is registered for the content type.
+func DecodeBody(contentType string, body []byte) ([]byte, error) {
+	if contentType == "" || len(body) == 0 {
+		return body, nil
+	}
+
+	codec, ok := GetCodec(contentType)
+	if !ok {
+		return nil, domainerr.ErrUnsupportedMediaType
+	}
+
+	if _, isJSON := codec.(JSONCodec); isJSON {
+		return body, nil
+	}
+
+	value, err := codec.Unmarshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domainerr.ErrMalformedBody, err)
+	}
+
+	return json.Marshal(value)
+}
+
+// EncodeBody converts a canonical JSON body to the wire format of the codec.
+func EncodeBody(codec Codec, body []byte) ([]byte, error) {
+	if _, isJSON := codec.(JSONCodec); isJSON || len(body) == 0 {
+		return body, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, err
+	}
+
+	return codec.Marshal(value)
+}
+
+// normalizeRequestBody re-encodes a request body as JSON according to its
+// Content-Type, so that tasks and targets only ever see the canonical form.
+// Bodies of content types without a codec are answered with
+// ErrUnsupportedMediaType, except for multipart bodies and those of the
+// allowed media types, which are passed through as they are.
+func normalizeRequestBody(request ServiceRequest, allowed []string) error {
+	header := request.GetHeader()
+	if header == nil {
+		return nil
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	codec, ok := GetCodec(contentType)
+	if !ok {
+		if passesThrough(contentType, allowed) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", domainerr.ErrUnsupportedMediaType, contentType)
+	}
+	if _, isJSON := codec.(JSONCodec); isJSON {
+		return nil
+	}
+
+	raw := request.GetBody()
+	if err := requestBodyError(request); err != nil {
+		return err
+	}
+
+	body, err := DecodeBody(contentType, raw)
+	if err != nil {
+		return err
+	}
+
+	request.SetBody(body)
+	header.Set("Content-Type", MediaTypeJSON)
+	header.Del("Content-Length")
+
+	return nil
+}
+
+// passesThrough reports whether bodies of contentType, which has no codec,
+// are passed through: multipart bodies, and those of a media type matching
+// one of allowed, which may name a range such as image/*.
+func passesThrough(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return true
+	}
+
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeResponseBody re-encodes a JSON response body with the negotiated
+// codec. Responses with a non-JSON content type are left untouched.
+func encodeResponseBody(response ServiceResponse, codec Codec) error {
+	if response == nil || codec == nil || response.GetResponseMeta() == nil {
+		return nil
+	}
+
+	meta := response.GetResponseMeta()
+	header := meta.GetHeader()
+	if header == nil {
+		header = make(http.Header)
+		meta.SetHeader(header)
+	}
+
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		current, ok := GetCodec(contentType)
+		if !ok {
+			return nil
+		}
+		if _, isJSON := current.(JSONCodec); !isJSON {
+			return nil
+		}
+	}
+
+	body := response.GetBody()
+	if err := bodyError(response); err != nil {
+		return fmt.Errorf("failed to read the response body: %w", err)
+	}
+	if len(body) == 0 || !json.Valid(body) {
+		return nil
+	}
+
+	encoded, err := EncodeBody(codec, body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domainerr.ErrNotAcceptable, err)
+	}
+
+	response.SetBody(encoded)
+	header.Set("Content-Type", codec.MediaTypes()[0])
+	header.Del("Content-Length")
+
+	return nil
+}
+
+// JSONCodec is the built-in codec for JSON bodies.
+type JSONCodec struct{}
+
+func (JSONCodec) MediaTypes() []string {
+	return []string{MediaTypeJSON, "text/json"}
+}
+
+func (JSONCodec) Unmarshal(data []byte) (any, error) {
+	var value any
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
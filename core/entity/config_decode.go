@@ -0,0 +1,580 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ConfigProblem is a problem with the value of one key of a config, such
+// as fields[0].name, or with the config as a whole when Key is empty.
+type ConfigProblem struct {
+	Key     string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	if p.Key == "" {
+		return p.Message
+	}
+	return p.Key + ": " + p.Message
+}
+
+// ConfigError lists every problem DecodeConfig found with a config, sorted
+// by key.
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		problems[i] = problem.String()
+	}
+	return "invalid config: " + strings.Join(problems, "; ")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// DecodeConfig decodes the config of a task or target into out, a pointer
+// to a struct whose fields are tagged with the keys they are read from:
+//
+//	type WebhookConfig struct {
+//		URL     string        `config:"url,required" description:"where events are posted"`
+//		Timeout time.Duration `config:"timeout" default:"5s"`
+//		Headers []Header      `config:"headers"`
+//	}
+//
+// Keys match case-insensitively. Fields without a tag are read from their
+// name with its first letter lowercased, and fields tagged "-" are not read.
+// List fields with the single option, as in `config:"urls,single"`, also
+// accept a single value, read as a list of one.
+// Defaults are given as JSON, except for strings and durations, which are
+// given as is. Durations are read from strings such as "5s", or from
+// numbers of seconds. Numbers and booleans may be given as strings, as
+// they are when they come from the environment. Unknown keys, missing
+// required keys and values of the wrong type are all reported in a
+// ConfigError.
+func DecodeConfig(config map[string]any, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be decoded into a pointer to a struct, not %T", out)
+	}
+
+	d := &configDecoder{}
+	d.decodeStruct("", config, v.Elem())
+	if len(d.problems) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(d.problems, func(i, j int) bool {
+		return d.problems[i].Key < d.problems[j].Key
+	})
+	return &ConfigError{Problems: d.problems}
+}
+
+type configDecoder struct {
+	problems []ConfigProblem
+}
+
+func (d *configDecoder) addf(key, format string, args ...any) {
+	d.problems = append(d.problems, ConfigProblem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// configField is a field of a config struct and the key it is read from.
+type configField struct {
+	key         string
+	index       int
+	required    bool
+	single      bool
+	def         string
+	hasDefault  bool
+	description string
+}
+
+func configFields(t reflect.Type) []configField {
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("config")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		required, single := false, false
+		for _, option := range strings.Split(options, ",") {
+			required = required || option == "required"
+			single = single || option == "single"
+		}
+		if name == "" {
+			first, size := utf8.DecodeRuneInString(field.Name)
+			name = string(unicode.ToLower(first)) + field.Name[size:]
+		}
+
+		def, hasDefault := field.Tag.Lookup("default")
+		fields = append(fields, configField{
+			key:         name,
+			index:       i,
+			required:    required,
+			single:      single,
+			def:         def,
+			hasDefault:  hasDefault,
+			description: field.Tag.Get("description"),
+		})
+	}
+	return fields
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func (d *configDecoder) decodeStruct(prefix string, config map[string]any, v reflect.Value) {
+	fields := configFields(v.Type())
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	set := make(map[int]string)
+	for _, key := range keys {
+		field := -1
+		for i, f := range fields {
+			if strings.EqualFold(f.key, key) {
+				field = i
+				break
+			}
+		}
+		if field < 0 {
+			d.addf(join(prefix, key), "unknown key")
+			continue
+		}
+		if previous, ok := set[field]; ok {
+			d.addf(join(prefix, key), "is also given as %s", previous)
+			continue
+		}
+		set[field] = key
+
+		value := config[key]
+		if fields[field].single {
+			value = asList(value)
+		}
+		d.decodeValue(join(prefix, key), value, v.Field(fields[field].index))
+	}
+
+	for i, f := range fields {
+		if _, ok := set[i]; ok {
+			continue
+		}
+		switch {
+		case f.required:
+			d.addf(join(prefix, f.key), "is required")
+		case f.hasDefault:
+			value, err := parseDefault(f.def, v.Field(f.index).Type())
+			if err != nil {
+				d.addf(join(prefix, f.key), "invalid default %q: %v", f.def, err)
+				continue
+			}
+			d.decodeValue(join(prefix, f.key), value, v.Field(f.index))
+		}
+	}
+}
+
+// asList returns value as a list of one, unless it is a list already.
+func asList(value any) any {
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Slice {
+		return value
+	}
+	return []any{value}
+}
+
+// parseDefault parses the default of a field of type t.
+func parseDefault(def string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType || t.Kind() == reflect.String {
+		return def, nil
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(def), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (d *configDecoder) decodeValue(key string, in any, v reflect.Value) {
+	if in == nil {
+		return
+	}
+
+	if v.Type() == durationType {
+		switch value := in.(type) {
+		case string:
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				d.addf(key, "invalid duration %q", value)
+				return
+			}
+			v.SetInt(int64(duration))
+		default:
+			seconds, ok := toFloat(in)
+			if !ok {
+				d.addf(key, "expected a duration, got %T", in)
+				return
+			}
+			v.SetInt(int64(seconds * float64(time.Second)))
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		value, ok := in.(string)
+		if !ok {
+			d.addf(key, "expected a string, got %T", in)
+			return
+		}
+		v.SetString(value)
+
+	case reflect.Bool:
+		switch value := in.(type) {
+		case bool:
+			v.SetBool(value)
+		case string:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				d.addf(key, "expected a boolean, got %q", value)
+				return
+			}
+			v.SetBool(parsed)
+		default:
+			d.addf(key, "expected a boolean, got %T", in)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, ok := toFloat(in)
+		if !ok || value != float64(int64(value)) {
+			d.addf(key, "expected an integer, got %v", in)
+			return
+		}
+		if v.OverflowInt(int64(value)) {
+			d.addf(key, "%v is out of range", in)
+			return
+		}
+		v.SetInt(int64(value))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, ok := toFloat(in)
+		if !ok || value < 0 || value != float64(uint64(value)) {
+			d.addf(key, "expected a positive integer, got %v", in)
+			return
+		}
+		if v.OverflowUint(uint64(value)) {
+			d.addf(key, "%v is out of range", in)
+			return
+		}
+		v.SetUint(uint64(value))
+
+	case reflect.Float32, reflect.Float64:
+		value, ok := toFloat(in)
+		if !ok {
+			d.addf(key, "expected a number, got %v", in)
+			return
+		}
+		v.SetFloat(value)
+
+	case reflect.Slice:
+		items := reflect.ValueOf(in)
+		if items.Kind() != reflect.Slice {
+			d.addf(key, "expected a list, got %T", in)
+			return
+		}
+		slice := reflect.MakeSlice(v.Type(), items.Len(), items.Len())
+		for i := 0; i < items.Len(); i++ {
+			d.decodeValue(fmt.Sprintf("%s[%d]", key, i), items.Index(i).Interface(), slice.Index(i))
+		}
+		v.Set(slice)
+
+	case reflect.Map:
+		config, ok := toStringMap(in)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			d.addf(key, "expected a map, got %T", in)
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(config))
+		for k, item := range config {
+			value := reflect.New(v.Type().Elem()).Elem()
+			d.decodeValue(join(key, k), item, value)
+			m.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), value)
+		}
+		v.Set(m)
+
+	case reflect.Struct:
+		config, ok := toStringMap(in)
+		if !ok {
+			d.addf(key, "expected a map, got %T", in)
+			return
+		}
+		d.decodeStruct(key, config, v)
+
+	case reflect.Ptr:
+		value := reflect.New(v.Type().Elem())
+		d.decodeValue(key, in, value.Elem())
+		v.Set(value)
+
+	case reflect.Interface:
+		value := reflect.ValueOf(in)
+		if !value.Type().AssignableTo(v.Type()) {
+			d.addf(key, "expected a %s, got %T", v.Type(), in)
+			return
+		}
+		v.Set(value)
+
+	default:
+		d.addf(key, "fields of type %s cannot be configured", v.Type())
+	}
+}
+
+// toFloat converts numbers, and strings holding them, to a float64.
+func toFloat(in any) (float64, bool) {
+	switch value := in.(type) {
+	case string:
+		parsed, err := strconv.ParseFloat(value, 64)
+		return parsed, err == nil
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	}
+
+	v := reflect.ValueOf(in)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// toStringMap converts the maps of a config, including the
+// map[interface{}]interface{} maps of YAML, to a map[string]any.
+func toStringMap(in any) (map[string]any, bool) {
+	if config, ok := in.(map[string]any); ok {
+		return config, true
+	}
+
+	v := reflect.ValueOf(in)
+	if v.Kind() != reflect.Map {
+		return nil, false
+	}
+
+	config := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, ok := iter.Key().Interface().(string)
+		if !ok {
+			return nil, false
+		}
+		config[key] = iter.Value().Interface()
+	}
+	return config, true
+}
+
+// durationPattern matches the durations time.ParseDuration accepts.
+const durationPattern = `^[-+]?([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$`
+
+// ConfigSchema returns the JSON Schema of the config DecodeConfig decodes
+// into out, a pointer to a struct, for editors to complete and check
+// configs with.
+func ConfigSchema(out any) map[string]any {
+	schema := configSchema(reflect.TypeOf(out))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return schema
+}
+
+func configSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return map[string]any{"type": []string{"string", "number"}, "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": configSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": configSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		for _, f := range configFields(t) {
+			property := configSchema(t.Field(f.index).Type)
+			if f.single {
+				property = map[string]any{"anyOf": []any{property["items"], property}}
+			}
+			if f.description != "" {
+				property["description"] = f.description
+			}
+			if f.hasDefault {
+				if def, err := parseDefault(f.def, t.Field(f.index).Type); err == nil {
+					property["default"] = def
+				}
+			}
+			properties[f.key] = property
+			if f.required {
+				required = append(required, f.key)
+			}
+		}
+
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	// interfaces hold any value
+	return map[string]any{}
+}
+
+// ConfigDecoder is implemented by the task and target constructors which
+// decode their config with DecodeConfig, such as those returned by
+// TypedTaskConstructor, so that configs can be checked without
+// constructing anything and their schema published.
+type ConfigDecoder interface {
+	CheckConfig(config map[string]any) error
+	ConfigSchema() map[string]any
+}
+
+// typedConstructor decodes configs into a C.
+type typedConstructor[C any] struct{}
+
+func (typedConstructor[C]) decode(config map[string]any) (C, error) {
+	var decoded C
+	err := DecodeConfig(config, &decoded)
+	return decoded, err
+}
+
+func (c typedConstructor[C]) CheckConfig(config map[string]any) error {
+	_, err := c.decode(config)
+	return err
+}
+
+func (typedConstructor[C]) ConfigSchema() map[string]any {
+	return ConfigSchema(new(C))
+}
+
+type typedTaskConstructor[C any] struct {
+	typedConstructor[C]
+	fn func(config C) (Task, error)
+}
+
+// TypedTaskConstructor returns a TaskConstructor which decodes its config
+// into a C, a struct, with DecodeConfig before passing it to fn.
+func TypedTaskConstructor[C any](fn func(config C) (Task, error)) TaskConstructor {
+	return typedTaskConstructor[C]{fn: fn}
+}
+
+func (c typedTaskConstructor[C]) New(config map[string]any) (Task, error) {
+	decoded, err := c.decode(config)
+	if err != nil {
+		return nil, err
+	}
+	return c.fn(decoded)
+}
+
+type typedTargetConstructor[C any] struct {
+	typedConstructor[C]
+	fn func(config C) (Target, error)
+}
+
+// TypedTargetConstructor returns a TargetConstructor which decodes its
+// config into a C, a struct, with DecodeConfig before passing it to fn.
+func TypedTargetConstructor[C any](fn func(config C) (Target, error)) TargetConstructor {
+	return typedTargetConstructor[C]{fn: fn}
+}
+
+func (c typedTargetConstructor[C]) New(config map[string]any) (Target, error) {
+	decoded, err := c.decode(config)
+	if err != nil {
+		return nil, err
+	}
+	return c.fn(decoded)
+}
+
+// TaskConfigSchemas returns the JSON Schema of the config of each
+// registered task type whose constructor publishes one.
+func TaskConfigSchemas() map[string]map[string]any {
+	schemas := make(map[string]map[string]any)
+	for name, constructor := range TaskRegistry() {
+		if decoder, ok := constructor.(ConfigDecoder); ok {
+			schemas[name] = decoder.ConfigSchema()
+		}
+	}
+	return schemas
+}
+
+// TargetConfigSchemas returns the JSON Schema of the config of each
+// registered target type whose constructor publishes one.
+func TargetConfigSchemas() map[string]map[string]any {
+	schemas := make(map[string]map[string]any)
+	for name, constructor := range TargetRegistry() {
+		if decoder, ok := constructor.(ConfigDecoder); ok {
+			schemas[name] = decoder.ConfigSchema()
+		}
+	}
+	return schemas
+}
+
+// CheckTaskConfig checks config against the registered task type taskType
+// when its constructor decodes it with DecodeConfig, and accepts it
+// otherwise.
+func CheckTaskConfig(taskType string, config map[string]any) error {
+	if decoder, ok := TaskRegistry()[taskType].(ConfigDecoder); ok {
+		return decoder.CheckConfig(config)
+	}
+	return nil
+}
+
+// CheckTargetConfig checks config against the registered target type
+// targetType when its constructor decodes it with DecodeConfig, and accepts
+// it otherwise.
+func CheckTargetConfig(targetType string, config map[string]any) error {
+	if decoder, ok := TargetRegistry()[targetType].(ConfigDecoder); ok {
+		return decoder.CheckConfig(config)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package entity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type retryConfig struct {
+	Attempts int           `config:"attempts" default:"3"`
+	Delay    time.Duration `config:"delay" default:"500ms"`
+}
+
+type header struct {
+	Name  string `config:"name,required"`
+	Value string `config:"value"`
+}
+
+type webhookConfig struct {
+	URL      string            `config:"url,required" description:"where events are posted"`
+	Timeout  time.Duration     `config:"timeout" default:"5s"`
+	Enabled  bool              `config:"enabled" default:"true"`
+	Ratio    float64           `config:"ratio"`
+	Headers  []header          `config:"headers"`
+	Labels   map[string]string `config:"labels"`
+	Retry    retryConfig       `config:"retry"`
+	TLS      *retryConfig      `config:"tls"`
+	Internal string            `config:"-"`
+}
+
+func TestDecodeConfig(t *testing.T) {
+	var config webhookConfig
+	err := entity.DecodeConfig(map[string]any{
+		"URL":     "https://example.com/events",
+		"timeout": 2.5,
+		"enabled": "false",
+		"ratio":   1,
+		"headers": []any{map[string]any{"name": "X-Source", "value": "hub"}},
+		"labels":  map[interface{}]interface{}{"team": "kitchen"},
+		"retry":   map[string]any{"delay": "1s"},
+	}, &config)
+	require.NoError(t, err)
+
+	assert.Equal(t, webhookConfig{
+		URL:     "https://example.com/events",
+		Timeout: 2500 * time.Millisecond,
+		Enabled: false,
+		Ratio:   1,
+		Headers: []header{{Name: "X-Source", Value: "hub"}},
+		Labels:  map[string]string{"team": "kitchen"},
+		Retry:   retryConfig{Attempts: 3, Delay: time.Second},
+	}, config)
+
+	// defaults apply to keys which are not given
+	config = webhookConfig{}
+	require.NoError(t, entity.DecodeConfig(map[string]any{"url": "https://example.com"}, &config))
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.True(t, config.Enabled)
+}
+
+func TestDecodeConfig_Errors(t *testing.T) {
+	var config webhookConfig
+	err := entity.DecodeConfig(map[string]any{
+		"timeout":  "soon",
+		"enabled":  3,
+		"headers":  []any{map[string]any{"value": 1}},
+		"retry":    map[string]any{"attempts": 1.5},
+		"internal": "x",
+		"tls":      "on",
+	}, &config)
+	require.Error(t, err)
+
+	var configErr *entity.ConfigError
+	require.ErrorAs(t, err, &configErr)
+	assert.Equal(t, []entity.ConfigProblem{
+		{Key: "enabled", Message: "expected a boolean, got int"},
+		{Key: "headers[0].name", Message: "is required"},
+		{Key: "headers[0].value", Message: "expected a string, got int"},
+		{Key: "internal", Message: "unknown key"},
+		{Key: "retry.attempts", Message: "expected an integer, got 1.5"},
+		{Key: "timeout", Message: "invalid duration \"soon\""},
+		{Key: "tls", Message: "expected a map, got string"},
+		{Key: "url", Message: "is required"},
+	}, configErr.Problems)
+
+	assert.Error(t, entity.DecodeConfig(map[string]any{}, config))
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := entity.ConfigSchema(&webhookConfig{})
+
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, false, schema["additionalProperties"])
+	assert.Equal(t, []string{"url"}, schema["required"])
+
+	properties := schema["properties"].(map[string]any)
+	assert.NotContains(t, properties, "Internal")
+	assert.Equal(t, map[string]any{"type": "string", "description": "where events are posted"}, properties["url"])
+	assert.Equal(t, "5s", properties["timeout"].(map[string]any)["default"])
+	assert.Equal(t, true, properties["enabled"].(map[string]any)["default"])
+	assert.Equal(t, "array", properties["headers"].(map[string]any)["type"])
+	assert.Equal(t, map[string]any{"type": "string"}, properties["labels"].(map[string]any)["additionalProperties"])
+}
+
+type greeter struct {
+	greeting string
+}
+
+func (g *greeter) Name() string {
+	return g.greeting
+}
+
+func (g *greeter) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	return nil
+}
+
+func TestTypedTaskConstructor(t *testing.T) {
+	type greeterConfig struct {
+		Greeting string `config:"greeting" default:"hello"`
+	}
+	entity.RegisterTaskType("TypedGreeter", entity.TypedTaskConstructor(func(config greeterConfig) (entity.Task, error) {
+		return &greeter{greeting: config.Greeting}, nil
+	}))
+
+	task, err := entity.GetTask("TypedGreeter", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", task.Name())
+
+	_, err = entity.GetTask("TypedGreeter", map[string]any{"greting": "hi"})
+	assert.EqualError(t, err, "invalid config: greting: unknown key")
+	assert.EqualError(t, entity.CheckTaskConfig("TypedGreeter", map[string]any{"greting": "hi"}), "invalid config: greting: unknown key")
+
+	assert.Contains(t, entity.TaskConfigSchemas(), "TypedGreeter")
+}
+
+type fanoutConfig struct {
+	URLs []string `config:"urls,single"`
+}
+
+func TestDecodeConfig_Single(t *testing.T) {
+	var config fanoutConfig
+	require.NoError(t, entity.DecodeConfig(map[string]any{"urls": "https://example.com"}, &config))
+	assert.Equal(t, []string{"https://example.com"}, config.URLs)
+
+	config = fanoutConfig{}
+	require.NoError(t, entity.DecodeConfig(map[string]any{"urls": []any{"https://a", "https://b"}}, &config))
+	assert.Equal(t, []string{"https://a", "https://b"}, config.URLs)
+
+	urls := entity.ConfigSchema(&fanoutConfig{})["properties"].(map[string]any)["urls"].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"type": "string"}, map[string]any{"type": "array", "items": map[string]any{"type": "string"}}}, urls["anyOf"])
+}
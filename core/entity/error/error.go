@@ -25,3 +25,19 @@ var ErrSerializationTypeMismatch = errors.New(
 	"serialization version mismatch")
 
 var ErrTargetTypeNotSupported = errors.New("target type not supported")
+
+var ErrBodyTooLarge = errors.New("request body exceeds the configured maximum size")
+
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+var ErrNotAcceptable = errors.New("no acceptable media type available")
+
+var ErrMalformedBody = errors.New("request body could not be decoded")
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+var ErrForbidden = errors.New("forbidden")
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
@@ -0,0 +1,41 @@
+package error
+
+import "net/http"
+
+// HTTPError is returned by tasks and targets that need to stop processing
+// a request with a specific HTTP status, such as 401 or 429. Any headers
+// are added to the response sent to the client.
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Err        error
+}
+
+// NewHTTPError creates an HTTPError for the given status wrapping err.
+func NewHTTPError(statusCode int, err error) *HTTPError {
+	return &HTTPError{
+		StatusCode: statusCode,
+		Header:     make(http.Header),
+		Err:        err,
+	}
+}
+
+// WithHeader sets a header to be returned with the error response.
+func (e *HTTPError) WithHeader(key string, value string) *HTTPError {
+	if e.Header == nil {
+		e.Header = make(http.Header)
+	}
+	e.Header.Set(key, value)
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Err.Error()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
@@ -3,8 +3,10 @@ package entity
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
 	"github.com/google/uuid"
@@ -22,7 +24,11 @@ type Hub struct {
 	APIVersion      string
 	Version         string
 	ApplicationName string
-	services        map[string]*Service
+	services        map[string]*Service // keyed by namespace, API and name
+	routes          map[string]*Service // keyed by the path each service is served under
+	names           map[string]*Service // keyed by API and the name each service is served under
+	auditor         Auditor
+	mu              sync.RWMutex // guards services, routes, names and auditor
 	logger          *zerolog.Logger
 }
 
@@ -39,39 +45,183 @@ func NewHub(logger *zerolog.Logger, applicationVersion string) (*Hub, error) {
 	hub := &Hub{
 		Version:  applicationVersion,
 		services: make(map[string]*Service),
+		routes:   make(map[string]*Service),
+		names:    make(map[string]*Service),
 		logger:   logger,
 	}
 	return hub, nil
 }
 
-// AddService registers a new service with the Hub.
+// AddService registers a new service with the Hub, replacing any service
+// with the same namespace, API and name.
 //
 // Parameter:
 //   - svc: A pointer to the Service to be added.
 //
 // Returns:
-//   - An error if the service couldn't be added, nil otherwise.
+//   - An error if another service is already served under the same path,
+//     or under the same name in the same API, nil otherwise.
 func (hub *Hub) AddService(svc *Service) error {
-	serviceKey := strings.ToLower(svc.APIName + "/" + svc.Name)
-	hub.services[serviceKey] = svc
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	key := serviceKey(svc)
+	if err := checkRoute(hub.routes, hub.names, svc); err != nil {
+		return err
+	}
+
+	if hub.auditor != nil {
+		svc.Auditor = hub.auditor
+	}
+
+	if previous, ok := hub.services[key]; ok {
+		delete(hub.routes, strings.ToLower(previous.RoutePath()))
+		delete(hub.names, nameKey(previous.APIName, previous.RouteName()))
+	}
+
+	hub.services[key] = svc
+	hub.routes[strings.ToLower(svc.RoutePath())] = svc
+	hub.names[nameKey(svc.APIName, svc.RouteName())] = svc
 	return nil
 }
 
-// GetService retrieves a service from the Hub by its API name and service name.
+// ReplaceServices atomically swaps the services of the Hub for a new set,
+// such as one built from reloaded configuration. Requests already being
+// handled finish on the services they started with.
+//
+// Parameter:
+//   - services: The services to serve from now on.
+//
+// Returns:
+//   - An error if two of the services would be served under the same path,
+//     or under the same name in the same API, in which case the Hub keeps
+//     its current services, nil otherwise.
+func (hub *Hub) ReplaceServices(services []*Service) error {
+	replacement := make(map[string]*Service, len(services))
+	routes := make(map[string]*Service, len(services))
+	names := make(map[string]*Service, len(services))
+
+	for _, svc := range services {
+		if err := checkRoute(routes, names, svc); err != nil {
+			return err
+		}
+		replacement[serviceKey(svc)] = svc
+		routes[strings.ToLower(svc.RoutePath())] = svc
+		names[nameKey(svc.APIName, svc.RouteName())] = svc
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.auditor != nil {
+		for _, svc := range services {
+			svc.Auditor = hub.auditor
+		}
+	}
+
+	hub.services = replacement
+	hub.routes = routes
+	hub.names = names
+	return nil
+}
+
+// checkRoute returns an error if routes serves a service other than svc
+// under the path of svc, or names serves one under the name of svc in its
+// API. Services of an API are told apart by the last segment of their path
+// alone, in the internal paths of their requests, so services served under
+// /a/order and /b/order cannot both be part of one API.
+func checkRoute(routes, names map[string]*Service, svc *Service) error {
+	if other, ok := routes[strings.ToLower(svc.RoutePath())]; ok && serviceKey(other) != serviceKey(svc) {
+		return fmt.Errorf("service %s of api %s cannot be served under %s, which already serves %s of api %s",
+			svc.QualifiedName(), svc.APIName, svc.RoutePath(), other.QualifiedName(), other.APIName)
+	}
+	if other, ok := names[nameKey(svc.APIName, svc.RouteName())]; ok && serviceKey(other) != serviceKey(svc) {
+		return fmt.Errorf("service %s of api %s cannot be served under %s, since %s of the api is served under the name %s at %s",
+			svc.QualifiedName(), svc.APIName, svc.RoutePath(), other.QualifiedName(), svc.RouteName(), other.RoutePath())
+	}
+	return nil
+}
+
+func serviceKey(svc *Service) string {
+	return strings.ToLower(svc.Namespace + "/" + svc.APIName + "/" + svc.Name)
+}
+
+func nameKey(apiName, serviceName string) string {
+	return strings.ToLower(apiName + "/" + serviceName)
+}
+
+// GetService retrieves a service from the Hub by its API name and the name
+// it is served under, which is the service name unless the API serves it
+// under another.
 //
 // Parameters:
 //   - apiName: The name of the API.
-//   - serviceName: The name of the service.
+//   - serviceName: The name the service is served under.
 //
 // Returns:
 //   - A pointer to the Service and true if found.
 //   - nil and false if the service is not found.
 func (hub *Hub) GetService(apiName string, serviceName string) (*Service, bool) {
-	serviceKey := strings.ToLower(apiName + "/" + serviceName)
-	svc, ok := hub.services[serviceKey]
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	svc, ok := hub.names[nameKey(apiName, serviceName)]
 	return svc, ok
 }
 
+// GetNamespacedService retrieves a service from the Hub by its namespace,
+// API name and service name.
+//
+// Parameters:
+//   - namespace: The namespace of the service, or "" if it has none.
+//   - apiName: The name of the API.
+//   - serviceName: The name of the service.
+//
+// Returns:
+//   - A pointer to the Service and true if found.
+//   - nil and false if the service is not found.
+func (hub *Hub) GetNamespacedService(namespace, apiName, serviceName string) (*Service, bool) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	svc, ok := hub.services[strings.ToLower(namespace+"/"+apiName+"/"+serviceName)]
+	return svc, ok
+}
+
+// Route finds the service serving a request path, matching the longest path
+// a service is served under.
+//
+// Parameter:
+//   - requestPath: The URL path of the request, with or without the
+//     /internal/call prefix.
+//
+// Returns:
+//   - The Service, the request path rewritten to the /{api}/{service}/...
+//     form used by tasks and targets, and true if found.
+//   - nil, "" and false if no service serves the path.
+func (hub *Hub) Route(requestPath string) (*Service, string, bool) {
+	requestPath = strings.TrimPrefix(requestPath, "/internal/call")
+	segments := strings.Split(strings.Trim(requestPath, "/"), "/")
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	for i := len(segments); i > 0; i-- {
+		svc, ok := hub.routes[strings.ToLower("/"+strings.Join(segments[:i], "/"))]
+		if !ok {
+			continue
+		}
+
+		internalPath := "/" + svc.APIName + "/" + svc.RouteName()
+		if rest := segments[i:]; len(rest) > 0 {
+			internalPath += "/" + strings.Join(rest, "/")
+		}
+		return svc, internalPath, true
+	}
+
+	return nil, "", false
+}
+
 // HandleRequest is the main entry point for processing HTTP requests.
 //
 // Parameter:
@@ -91,17 +241,38 @@ func (hub *Hub) HandleRequest(r *http.Request) (ServiceResponse, error) {
 	ctx, span := tracer.Start(ctx, "HandleRequest")
 	defer span.End()
 
-	request, err := GetRequestFromHttp(r)
+	apiName, serviceName, _ := ParseServicePath(r.URL.Path)
+
+	var bodyOptions BodyOptions
+	service, internalPath, found := hub.Route(r.URL.Path)
+	if found {
+		bodyOptions = service.BodyOptions
+	}
+
+	request, err := GetRequestFromHttpWithOptions(r, bodyOptions)
 	if err != nil {
-		hub.logger.Err(err).Str("apiName", request.ApiName).
-			Str("serviceName", request.ServiceName).
+		hub.logger.Err(err).Str("apiName", apiName).
+			Str("serviceName", serviceName).
 			Msg("failed to build service request")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to build service request")
 		return nil, err
 	}
+	// the request is released once its response has been written, since
+	// targets may stream the response out of the request body
+	release := true
+	defer func() {
+		if release {
+			request.Close()
+		}
+	}()
 
 	request.ID = uuid.New()
+	if found {
+		request.ApiName = service.APIName
+		request.ServiceName = service.RouteName()
+		request.InternalPath = internalPath
+	}
 
 	// Set span attributes
 	span.SetAttributes(
@@ -112,7 +283,7 @@ func (hub *Hub) HandleRequest(r *http.Request) (ServiceResponse, error) {
 		attribute.String("http.url", r.URL.String()),
 	)
 
-	response, err := hub.executeServiceRequest(ctx, request)
+	response, err := hub.executeServiceRequest(ctx, request, service)
 	if err != nil {
 		hub.logger.Err(err).Str("apiName", request.ApiName).
 			Str("serviceName", request.ServiceName).
@@ -122,21 +293,41 @@ func (hub *Hub) HandleRequest(r *http.Request) (ServiceResponse, error) {
 		return nil, err
 	}
 
+	if sr, ok := response.(*HttpServiceResponse); ok && sr.BodyStream != nil {
+		sr.BodyStream = &closeAfter{ReadCloser: sr.BodyStream, after: request.Close}
+		release = false
+	}
+
 	span.SetStatus(codes.Ok, "request handled successfully")
 	return response, nil
 }
 
-// executeServiceRequest processes a ServiceRequest by fetching the
-// appropriate service and delegating the request handling to that service.
+// closeAfter runs after once the body it wraps is closed.
+type closeAfter struct {
+	io.ReadCloser
+	after func() error
+}
+
+func (c *closeAfter) Close() error {
+	err := c.ReadCloser.Close()
+	if afterErr := c.after(); err == nil {
+		err = afterErr
+	}
+	return err
+}
+
+// executeServiceRequest processes a ServiceRequest by delegating the
+// request handling to the service it was routed to.
 //
 // Parameters:
 //   - ctx: The context for the request.
 //   - request: A pointer to the ServiceRequest to be executed.
+//   - service: The service routed to, or nil if none serves the request.
 //
 // Returns:
 //   - A pointer to ServiceResponse and nil error on success.
 //   - A pointer to ServiceResponse with error details and an error on failure.
-func (hub *Hub) executeServiceRequest(ctx context.Context, request ServiceRequest) (ServiceResponse, error) {
+func (hub *Hub) executeServiceRequest(ctx context.Context, request ServiceRequest, service *Service) (ServiceResponse, error) {
 	span := trace.SpanFromContext(ctx)
 
 	ctx, localSpan := span.TracerProvider().Tracer(hub.ApplicationName).Start(ctx, request.GetServiceName())
@@ -145,8 +336,7 @@ func (hub *Hub) executeServiceRequest(ctx context.Context, request ServiceReques
 	response := &HttpServiceResponse{}
 	response.ResponseMeta = &HttpResponseMeta{}
 
-	service, ok := hub.GetService(request.GetAPIName(), request.GetServiceName())
-	if !ok {
+	if service == nil {
 		err := fmt.Errorf("service %s not found %w", request.GetServiceName(), domainerr.ErrServiceNotFound)
 		hub.logger.Err(err).Str("apiName", request.GetAPIName()).Str("serviceName", request.GetServiceName()).Msg("service not found")
 		response.ResponseMeta.SetStatusCode(http.StatusNotFound)
@@ -157,6 +347,30 @@ func (hub *Hub) executeServiceRequest(ctx context.Context, request ServiceReques
 		return response, err
 	}
 
+	// Streaming services pass bodies through untouched, so content
+	// negotiation only applies to buffered bodies
+	var responseCodec Codec
+	if !service.BodyOptions.Streaming {
+		var err error
+		responseCodec, err = NegotiateCodec(request.GetHeader().Get("Accept"))
+		if err == nil {
+			err = normalizeRequestBody(request, service.BodyOptions.MediaTypes)
+		}
+		if err != nil {
+			hub.logger.Err(err).Str("apiName", request.GetAPIName()).Str("serviceName", request.GetServiceName()).Msg("content negotiation failed")
+
+			localSpan.RecordError(err)
+			localSpan.SetStatus(codes.Error, "content negotiation failed")
+
+			return response, err
+		}
+	}
+
+	if hub.logger != nil {
+		// for services to log with
+		ctx = hub.logger.WithContext(ctx)
+	}
+
 	if err := service.DoRequest(ctx, request); err != nil {
 		hub.logger.Err(err).Str("apiName", request.GetAPIName()).Str("serviceName", request.GetServiceName()).Msg("failed to execute service request")
 		response.ResponseMeta.SetStatusCode(http.StatusInternalServerError)
@@ -167,6 +381,15 @@ func (hub *Hub) executeServiceRequest(ctx context.Context, request ServiceReques
 		return response, err
 	}
 
+	if err := encodeResponseBody(request.GetResponse(), responseCodec); err != nil {
+		hub.logger.Err(err).Str("apiName", request.GetAPIName()).Str("serviceName", request.GetServiceName()).Msg("failed to encode response")
+
+		localSpan.RecordError(err)
+		localSpan.SetStatus(codes.Error, "failed to encode response")
+
+		return response, err
+	}
+
 	return request.GetResponse(), nil
 }
 
@@ -189,7 +412,15 @@ func (hub *Hub) GetLogger() *zerolog.Logger {
 // GetServices returns a map of all registered services in the Hub.
 //
 // Returns:
-//   - A map with service keys as strings and Service pointers as values.
+//   - A map with keys of the form namespace/api/name, lower cased, and
+//     Service pointers as values.
 func (hub *Hub) GetServices() map[string]*Service {
-	return hub.services
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	services := make(map[string]*Service, len(hub.services))
+	for key, svc := range hub.services {
+		services[key] = svc
+	}
+	return services
 }
@@ -0,0 +1,140 @@
+package entity_test
+
+import (
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, namespace, apiName, name, path string) *entity.Service {
+	service, err := entity.NewService(apiName, name, "", "", true)
+	require.NoError(t, err)
+	service.Namespace = namespace
+	service.Path = path
+	return service
+}
+
+func TestHub_Route(t *testing.T) {
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+
+	invoices := newTestService(t, "billing", "shop", "order", "/shop/v1/invoices")
+	shipments := newTestService(t, "shipping", "shop", "order", "/shop/v1/shipments")
+	recipes := newTestService(t, "", "recipeApp", "recipe", "")
+	require.NoError(t, hub.AddService(invoices))
+	require.NoError(t, hub.AddService(shipments))
+	require.NoError(t, hub.AddService(recipes))
+
+	tests := []struct {
+		path         string
+		service      *entity.Service
+		internalPath string
+	}{
+		{"/shop/v1/invoices", invoices, "/shop/invoices"},
+		{"/shop/v1/invoices/42", invoices, "/shop/invoices/42"},
+		{"/SHOP/v1/Shipments/7/items", shipments, "/shop/shipments/7/items"},
+		{"/internal/call/shop/v1/shipments/7", shipments, "/shop/shipments/7"},
+		{"/recipeapp/recipe/1", recipes, "/recipeApp/recipe/1"},
+	}
+
+	for _, tt := range tests {
+		service, internalPath, ok := hub.Route(tt.path)
+		require.True(t, ok, tt.path)
+		assert.Same(t, tt.service, service, tt.path)
+		assert.Equal(t, tt.internalPath, internalPath, tt.path)
+	}
+
+	for _, path := range []string{"/", "/shop", "/shop/v1", "/shop/order", "/recipeapp/chef"} {
+		_, _, ok := hub.Route(path)
+		assert.False(t, ok, path)
+	}
+
+	service, ok := hub.GetService("shop", "invoices")
+	require.True(t, ok)
+	assert.Same(t, invoices, service)
+
+	service, ok = hub.GetNamespacedService("shipping", "shop", "order")
+	require.True(t, ok)
+	assert.Same(t, shipments, service)
+}
+
+func TestHub_AddService(t *testing.T) {
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+
+	require.NoError(t, hub.AddService(newTestService(t, "billing", "shop", "order", "")))
+
+	err = hub.AddService(newTestService(t, "shipping", "shop", "order", ""))
+	assert.EqualError(t, err, "service shipping/order of api shop cannot be served under /shop/order, which already serves billing/order of api shop")
+
+	// a service replaces the one with the same namespace, API and name,
+	// along with the path it was served under
+	moved := newTestService(t, "billing", "shop", "order", "/shop/invoices")
+	require.NoError(t, hub.AddService(moved))
+	assert.Len(t, hub.GetServices(), 1)
+
+	_, _, ok := hub.Route("/shop/order")
+	assert.False(t, ok)
+	service, _, ok := hub.Route("/shop/invoices")
+	require.True(t, ok)
+	assert.Same(t, moved, service)
+}
+
+func TestHub_RejectsServicesOfOneAPIServedUnderOneName(t *testing.T) {
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+
+	invoices := newTestService(t, "billing", "shop", "order", "/a/order")
+	require.NoError(t, hub.AddService(invoices))
+
+	const want = "service shipping/order of api shop cannot be served under /b/order, since billing/order of the api is served under the name order at /a/order"
+	err = hub.AddService(newTestService(t, "shipping", "shop", "order", "/b/order"))
+	assert.EqualError(t, err, want)
+
+	err = hub.ReplaceServices([]*entity.Service{
+		invoices,
+		newTestService(t, "shipping", "shop", "order", "/b/order"),
+	})
+	assert.EqualError(t, err, want)
+
+	service, ok := hub.GetService("shop", "order")
+	require.True(t, ok)
+	assert.Same(t, invoices, service)
+
+	// services of other APIs may share the name
+	require.NoError(t, hub.AddService(newTestService(t, "shipping", "warehouse", "order", "/b/order")))
+}
+
+func TestHub_ReplaceServices(t *testing.T) {
+	logger := zerolog.Nop()
+	hub, err := entity.NewHub(&logger, "v1")
+	require.NoError(t, err)
+
+	invoices := newTestService(t, "billing", "shop", "order", "/shop/invoices")
+	require.NoError(t, hub.AddService(invoices))
+
+	err = hub.ReplaceServices([]*entity.Service{
+		newTestService(t, "billing", "shop", "order", ""),
+		newTestService(t, "shipping", "shop", "order", ""),
+	})
+	assert.EqualError(t, err, "service shipping/order of api shop cannot be served under /shop/order, which already serves billing/order of api shop")
+
+	// the hub keeps its services
+	service, _, ok := hub.Route("/shop/invoices")
+	require.True(t, ok)
+	assert.Same(t, invoices, service)
+
+	shipments := newTestService(t, "shipping", "shop", "order", "/shop/shipments")
+	require.NoError(t, hub.ReplaceServices([]*entity.Service{shipments}))
+	_, _, ok = hub.Route("/shop/invoices")
+	assert.False(t, ok)
+	service, _, ok = hub.Route("/shop/shipments")
+	require.True(t, ok)
+	assert.Same(t, shipments, service)
+}
@@ -0,0 +1,27 @@
+package entity
+
+import "context"
+
+// IdempotencyKeyHeader carries the client chosen key which identifies
+// retries of the same request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency makes retried requests safe for a service. It is optional;
+// services without it run every request, including retries.
+type Idempotency interface {
+	// Do calls next for the first request with a given idempotency key and
+	// replays its response for later requests with the same key. Requests
+	// arriving while the first is in flight wait for its response.
+	Do(ctx context.Context, request ServiceRequest, next func(ctx context.Context) (ServiceResponse, error)) (ServiceResponse, error)
+}
+
+// IsIdempotencyKeyed reports whether a request carries an idempotency key
+// for a method which is not idempotent by itself (POST or PATCH).
+func IsIdempotencyKeyed(request ServiceRequest) bool {
+	switch request.GetMethod() {
+	case HTTPMethodPOST, HTTPMethodPATCH:
+		return request.GetHeader().Get(IdempotencyKeyHeader) != ""
+	default:
+		return false
+	}
+}
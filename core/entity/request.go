@@ -1,9 +1,12 @@
 package entity
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
@@ -22,6 +25,7 @@ type ServiceRequest interface {
 	GetInternalPath() string
 	GetBody() []byte
 	SetBody(body []byte)
+	GetBodyReader() (io.ReadCloser, error)
 	GetForm() *url.Values
 	SetForm(form *url.Values)
 	GetPostForm() *url.Values
@@ -36,6 +40,8 @@ type ServiceRequest interface {
 	SetHeader(header http.Header)
 	GetTrailer() http.Header
 	SetTrailer(trailer http.Header)
+	GetClaims() Claims
+	SetClaims(claims Claims)
 }
 
 // MultipartDataInterface represents the interface for multipart form data.
@@ -44,6 +50,7 @@ type MultipartDataInterface interface {
 	SetValue(value map[string][]string)
 	GetFileData() map[string][]byte
 	SetFileData(fileData map[string][]byte)
+	OpenFile(fieldName string) (io.ReadCloser, error)
 }
 
 // RequestMetaInterface represents the interface for request metadata.
@@ -79,6 +86,7 @@ type HTTPServiceRequest struct {
 	URL          *url.URL         // Full URL of the request
 	InternalPath string           // Internal path after removing prefixes
 	Body         []byte           // Raw body of the request
+	BodyStream   *RequestBody     `json:"-"` // Lazily read body, set for streaming services
 	Form         *url.Values      // URL-encoded form data
 	PostForm     *url.Values      // Posted form data
 	Multipart    *MultipartData   // Multipart form data, including file uploads
@@ -86,12 +94,16 @@ type HTTPServiceRequest struct {
 	RequestMeta  RequestMeta      // Additional metadata about the request
 	Header       http.Header      // HTTP headers
 	Trailer      http.Header      // HTTP trailers
+	Claims       Claims           // Claims of the authenticated principal, if any
+
+	bodyErr error // the error reading the body stream, if any
 }
 
 // MultipartData holds both regular form values and file data for multipart requests.
 type MultipartData struct {
-	Value    map[string][]string // Regular form values
-	FileData map[string][]byte   // File data, keyed by field name
+	Value       map[string][]string                // Regular form values
+	FileData    map[string][]byte                  // File data, keyed by field name
+	FileHeaders map[string][]*multipart.FileHeader `json:"-"` // Unread file parts, for streaming services
 }
 
 // RequestMeta contains additional metadata about the original HTTP request.
@@ -127,7 +139,8 @@ func (hc headerCarrier) Keys() []string {
 	return keys
 }
 
-// GetEntityFromRequest unmarshals the request body into a given entity type.
+// GetEntityFromRequest unmarshals the request body into a given entity type,
+// decoding it with the codec registered for its Content-Type.
 //
 // Parameters:
 //   - r: A pointer to a ServiceRequest containing the request data.
@@ -137,7 +150,11 @@ func (hc headerCarrier) Keys() []string {
 //   - error: An error if unmarshaling fails, nil otherwise.
 func GetEntityFromRequest[T any](r *HTTPServiceRequest) (T, error) {
 	var entity T
-	if err := json.Unmarshal(r.Body, &entity); err != nil {
+	body, err := DecodeBody(r.Header.Get("Content-Type"), r.GetBody())
+	if err != nil {
+		return entity, err
+	}
+	if err := json.Unmarshal(body, &entity); err != nil {
 		return entity, err
 	}
 	return entity, nil
@@ -152,59 +169,71 @@ func GetEntityFromRequest[T any](r *HTTPServiceRequest) (T, error) {
 //   - *ServiceRequest: A pointer to the converted ServiceRequest.
 //   - error: An error if conversion fails, nil otherwise.
 func GetRequestFromHttp(r *http.Request) (*HTTPServiceRequest, error) {
+	return GetRequestFromHttpWithOptions(r, BodyOptions{})
+}
+
+// GetRequestFromHttpWithOptions converts a standard http.Request to our custom
+// ServiceRequest, reading the body according to the given options. When
+// streaming is enabled the body is left unread and exposed through
+// GetBodyReader, and multipart file parts are spilled to disk rather than
+// read into memory.
+//
+// Parameters:
+//   - r: A pointer to an http.Request to be converted.
+//   - options: Body size and streaming options for the target service.
+//
+// Returns:
+//   - *ServiceRequest: A pointer to the converted ServiceRequest.
+//   - error: An error if conversion fails, nil otherwise.
+func GetRequestFromHttpWithOptions(r *http.Request, options BodyOptions) (*HTTPServiceRequest, error) {
 	if r == nil {
 		return nil, domainerr.ErrEmptyInput
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return nil, err
+	if options.MaxBodySize > 0 && r.ContentLength > options.MaxBodySize {
+		return nil, domainerr.ErrBodyTooLarge
 	}
 
-	multipartData := MultipartData{}
+	var body []byte
+	var bodyStream *RequestBody
+	var multipartData *MultipartData
+	var err error
 
-	if r.MultipartForm != nil {
-		multipartData.Value = r.MultipartForm.Value
-		multipartData.FileData = make(map[string][]byte)
-
-		for fieldName, fileHeaders := range r.MultipartForm.File {
-			for _, fileHeader := range fileHeaders {
-				file, err := fileHeader.Open()
-				if err != nil {
-					return nil, fmt.Errorf("error opening file %s: %w", fieldName, err)
-				}
-				defer file.Close()
-
-				fileBytes, err := io.ReadAll(file)
-				if err != nil {
-					return nil, fmt.Errorf("error reading file %s: %w", fieldName, err)
-				}
-
-				multipartData.FileData[fieldName] = fileBytes
+	source := r.Body
+	if options.MaxBodySize > 0 && source != nil {
+		source = &limitedBody{ReadCloser: source, remaining: options.MaxBodySize}
+	}
+
+	switch {
+	case options.Streaming && isMultipartForm(r) && r.MultipartForm == nil:
+		// the standard library keeps parts up to the threshold in memory
+		// and writes larger files to temporary files
+		r.Body = source
+		if err := r.ParseMultipartForm(options.spillThreshold()); err != nil {
+			return nil, fmt.Errorf("error parsing multipart form: %w", err)
+		}
+	case options.Streaming:
+		bodyStream = NewRequestBody(source, BodyOptions{
+			SpillThreshold: options.SpillThreshold,
+			TempDir:        options.TempDir,
+		})
+	default:
+		if source != nil {
+			body, err = io.ReadAll(source)
+			if err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	internalPath := r.URL.Path
-
-	// Remove the /internal/call prefix if it exists
-	if strings.HasPrefix(internalPath, "/internal/call") {
-		internalPath = strings.Replace(internalPath, "/internal/call", "", 1)
+	if r.MultipartForm != nil {
+		multipartData, err = getMultipartData(r.MultipartForm, options.Streaming)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Split the internal path
-	pathSegments := strings.Split(strings.Trim(internalPath, "/"), "/")
-
-	var apiName, serviceName string
-
-	if len(pathSegments) >= 2 {
-		apiName = pathSegments[0]
-		serviceName = pathSegments[1]
-	} else if len(pathSegments) == 1 {
-		// Handle case where only one segment is present
-		apiName = pathSegments[0]
-	}
-	// If no segments, both apiName and serviceName remain empty
+	apiName, serviceName, internalPath := ParseServicePath(r.URL.Path)
 
 	httpMethod, err := StringToHTTPMethod(r.Method)
 	if err != nil {
@@ -217,6 +246,8 @@ func GetRequestFromHttp(r *http.Request) (*HTTPServiceRequest, error) {
 		ServiceName:  serviceName,
 		URL:          r.URL,
 		Body:         body,
+		BodyStream:   bodyStream,
+		Multipart:    multipartData,
 		Form:         &r.Form,
 		PostForm:     &r.PostForm,
 		Header:       r.Header,
@@ -227,13 +258,94 @@ func GetRequestFromHttp(r *http.Request) (*HTTPServiceRequest, error) {
 			Proto:            r.Proto,
 			ProtoMajor:       r.ProtoMajor,
 			ProtoMinor:       r.ProtoMinor,
+			ContentLength:    r.ContentLength,
 			TransferEncoding: r.TransferEncoding,
+			Host:             r.Host,
+			RemoteAddr:       r.RemoteAddr,
+			RequestURI:       r.RequestURI,
 		},
 	}
 
 	return &response, nil
 }
 
+// ParseServicePath extracts the API name, service name and internal path
+// from a request path of the form [/internal/call]/{api}/{service}/...
+//
+// Parameters:
+//   - path: The URL path of the incoming request.
+//
+// Returns:
+//   - apiName: The first path segment, or empty if there is none.
+//   - serviceName: The second path segment, or empty if there is none.
+//   - internalPath: The path with any /internal/call prefix removed.
+func ParseServicePath(path string) (apiName string, serviceName string, internalPath string) {
+	internalPath = path
+
+	// Remove the /internal/call prefix if it exists
+	if strings.HasPrefix(internalPath, "/internal/call") {
+		internalPath = strings.Replace(internalPath, "/internal/call", "", 1)
+	}
+
+	// Split the internal path
+	pathSegments := strings.Split(strings.Trim(internalPath, "/"), "/")
+
+	if len(pathSegments) >= 2 {
+		apiName = pathSegments[0]
+		serviceName = pathSegments[1]
+	} else if len(pathSegments) == 1 {
+		// Handle case where only one segment is present
+		apiName = pathSegments[0]
+	}
+	// If no segments, both apiName and serviceName remain empty
+
+	return apiName, serviceName, internalPath
+}
+
+func isMultipartForm(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// getMultipartData copies form values and files out of a parsed multipart
+// form. In streaming mode file parts are left unread and can be opened with
+// MultipartData.OpenFile.
+func getMultipartData(form *multipart.Form, streaming bool) (*MultipartData, error) {
+	multipartData := &MultipartData{
+		Value: form.Value,
+	}
+
+	if streaming {
+		multipartData.FileHeaders = form.File
+		return multipartData, nil
+	}
+
+	multipartData.FileData = make(map[string][]byte)
+
+	for fieldName, fileHeaders := range form.File {
+		for _, fileHeader := range fileHeaders {
+			fileBytes, err := readFileHeader(fileHeader)
+			if err != nil {
+				return nil, fmt.Errorf("error reading file %s: %w", fieldName, err)
+			}
+
+			multipartData.FileData[fieldName] = fileBytes
+		}
+	}
+
+	return multipartData, nil
+}
+
+func readFileHeader(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
 // ServiceRequest methods
 
 func (sr *HTTPServiceRequest) GetAPIName() string {
@@ -276,14 +388,64 @@ func (sr *HTTPServiceRequest) SetInternalPath(path string) {
 	sr.InternalPath = path
 }
 
+// GetBody returns the request body. For streaming requests this buffers the
+// whole body, so tasks that can work on a stream should prefer GetBodyReader.
+// A body which cannot be read, such as one over the maximum body size, is
+// returned as nil, with the error kept for BodyError.
 func (sr *HTTPServiceRequest) GetBody() []byte {
+	if sr.Body == nil && sr.BodyStream != nil && sr.bodyErr == nil {
+		body, err := sr.BodyStream.Bytes()
+		if err != nil {
+			sr.bodyErr = err
+			return nil
+		}
+		sr.Body = body
+	}
 	return sr.Body
 }
 
+// BodyError returns the error GetBody met reading the body stream, if any.
+func (sr *HTTPServiceRequest) BodyError() error {
+	return sr.bodyErr
+}
+
+// SetBody replaces the request body, discarding any unread body stream.
 func (sr *HTTPServiceRequest) SetBody(body []byte) {
+	if sr.BodyStream != nil {
+		sr.BodyStream.Close()
+		sr.BodyStream = nil
+	}
+	sr.bodyErr = nil
 	sr.Body = body
 }
 
+// GetBodyReader returns a reader over the request body without buffering it
+// when the request is streaming.
+func (sr *HTTPServiceRequest) GetBodyReader() (io.ReadCloser, error) {
+	if sr.Body == nil && sr.BodyStream != nil {
+		return sr.BodyStream.Reader()
+	}
+	return io.NopCloser(bytes.NewReader(sr.Body)), nil
+}
+
+// BufferBody reads the rest of a streaming body, spilling it to disk past
+// the spill threshold, so that it no longer depends on the connection it
+// arrived on. Readers handed out afterwards read the buffered content.
+func (sr *HTTPServiceRequest) BufferBody() error {
+	if sr.BodyStream != nil {
+		return sr.BodyStream.Buffer()
+	}
+	return nil
+}
+
+// Close releases any resources, such as spill files, held by the request body.
+func (sr *HTTPServiceRequest) Close() error {
+	if sr.BodyStream != nil {
+		return sr.BodyStream.Close()
+	}
+	return nil
+}
+
 func (sr *HTTPServiceRequest) GetForm() *url.Values {
 	return sr.Form
 }
@@ -311,6 +473,9 @@ func (sr *HTTPServiceRequest) SetMultipart(data MultipartDataInterface) {
 }
 
 func (sr *HTTPServiceRequest) GetResponse() ServiceResponse {
+	if sr.Response == nil {
+		return nil
+	}
 	return *sr.Response
 }
 
@@ -344,6 +509,14 @@ func (sr *HTTPServiceRequest) SetTrailer(trailer http.Header) {
 	sr.Trailer = trailer
 }
 
+func (sr *HTTPServiceRequest) GetClaims() Claims {
+	return sr.Claims
+}
+
+func (sr *HTTPServiceRequest) SetClaims(claims Claims) {
+	sr.Claims = claims
+}
+
 func (sr *HTTPServiceRequest) GetID() uuid.UUID {
 	return sr.ID
 }
@@ -370,6 +543,20 @@ func (md *MultipartData) SetFileData(fileData map[string][]byte) {
 	md.FileData = fileData
 }
 
+// OpenFile returns a reader over the file uploaded in the given field,
+// reading it from disk for streaming requests.
+func (md *MultipartData) OpenFile(fieldName string) (io.ReadCloser, error) {
+	if fileHeaders, ok := md.FileHeaders[fieldName]; ok && len(fileHeaders) > 0 {
+		return fileHeaders[len(fileHeaders)-1].Open()
+	}
+
+	if data, ok := md.FileData[fieldName]; ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	return nil, fmt.Errorf("no file uploaded for field %s", fieldName)
+}
+
 // RequestMeta methods
 
 func (rm *RequestMeta) GetOriginalRequest() *http.Request {
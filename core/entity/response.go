@@ -13,6 +13,8 @@ type ServiceResponse interface {
 	SetResponseMeta(meta ResponseMeta)
 	GetBody() []byte
 	SetBody(body []byte)
+	GetBodyReader() (io.ReadCloser, error)
+	SetBodyReader(body io.ReadCloser)
 }
 
 // ResponseMetaInterface represents the interface for response metadata.
@@ -38,8 +40,11 @@ type ResponseMeta interface {
 
 // ServiceResponse represents a standardized response structure used within the service.
 type HttpServiceResponse struct {
-	ResponseMeta ResponseMeta // Metadata about the response
-	Body         []byte       // Raw body of the response
+	ResponseMeta ResponseMeta  // Metadata about the response
+	Body         []byte        // Raw body of the response
+	BodyStream   io.ReadCloser `json:"-"` // Unread body, streamed to the client when set
+
+	bodyErr error // the error reading the body stream, if any
 }
 
 // ResponseMeta contains metadata about the HTTP response.
@@ -100,6 +105,35 @@ func GetResponseFromHttp(r *http.Response) (*HttpServiceResponse, error) {
 	return &response, nil
 }
 
+// GetStreamingResponseFromHttp converts a standard http.Response to our custom
+// ServiceResponse without reading its body. The body is streamed to the client
+// when the response is written, and must be closed by the caller otherwise.
+//
+// Parameters:
+//   - r: A pointer to an http.Response to be converted.
+//
+// Returns:
+//   - *ServiceResponse: A pointer to the converted ServiceResponse.
+//   - error: An error if conversion fails, nil otherwise.
+func GetStreamingResponseFromHttp(r *http.Response) (*HttpServiceResponse, error) {
+	response := HttpServiceResponse{
+		ResponseMeta: &HttpResponseMeta{
+			OriginalResponse: r,
+			Status:           r.Status,
+			StatusCode:       r.StatusCode,
+			Proto:            r.Proto,
+			ProtoMajor:       r.ProtoMajor,
+			ProtoMinor:       r.ProtoMinor,
+			TransferEncoding: r.TransferEncoding,
+			Header:           r.Header,
+			Trailer:          r.Trailer,
+		},
+		BodyStream: r.Body,
+	}
+
+	return &response, nil
+}
+
 // GetHttpFromResponse converts our custom ServiceResponse back to a standard http.Response.
 //
 // Parameters:
@@ -109,8 +143,15 @@ func GetResponseFromHttp(r *http.Response) (*HttpServiceResponse, error) {
 //   - *http.Response: A pointer to the converted http.Response.
 //   - error: An error if conversion fails, nil otherwise.
 func GetHttpFromResponse(r ServiceResponse) (*http.Response, error) {
-	responseBody := io.NopCloser(bytes.NewReader(r.GetBody()))
-	contentLength := int64(len(r.GetBody()))
+	responseBody, err := r.GetBodyReader()
+	if err != nil {
+		return nil, err
+	}
+
+	contentLength := int64(-1)
+	if sr, ok := r.(*HttpServiceResponse); !ok || sr.BodyStream == nil {
+		contentLength = int64(len(r.GetBody()))
+	}
 
 	response := http.Response{
 		Status:           r.GetResponseMeta().GetStatus(),
@@ -139,14 +180,56 @@ func (sr *HttpServiceResponse) SetResponseMeta(meta ResponseMeta) {
 	sr.ResponseMeta = meta
 }
 
+// GetBody returns the response body, reading and closing any body stream.
+// When the stream fails part way, the part read is returned, with the error
+// kept for BodyError.
 func (sr *HttpServiceResponse) GetBody() []byte {
+	if sr.BodyStream != nil {
+		body, err := io.ReadAll(sr.BodyStream)
+		sr.BodyStream.Close()
+		sr.BodyStream = nil
+		sr.bodyErr = err
+		sr.Body = body
+	}
 	return sr.Body
 }
 
+// BodyError returns the error GetBody met reading the body stream, if any.
+func (sr *HttpServiceResponse) BodyError() error {
+	return sr.bodyErr
+}
+
+// SetBody replaces the response body, closing any unread body stream.
 func (sr *HttpServiceResponse) SetBody(body []byte) {
+	if sr.BodyStream != nil {
+		sr.BodyStream.Close()
+		sr.BodyStream = nil
+	}
+	sr.bodyErr = nil
 	sr.Body = body
 }
 
+// GetBodyReader returns a reader over the response body. A body stream is
+// handed out as-is and can only be read once.
+func (sr *HttpServiceResponse) GetBodyReader() (io.ReadCloser, error) {
+	if sr.BodyStream != nil {
+		body := sr.BodyStream
+		sr.BodyStream = nil
+		return body, nil
+	}
+	return io.NopCloser(bytes.NewReader(sr.Body)), nil
+}
+
+// SetBodyReader sets a body stream to be written to the client in place of Body.
+func (sr *HttpServiceResponse) SetBodyReader(body io.ReadCloser) {
+	if sr.BodyStream != nil {
+		sr.BodyStream.Close()
+	}
+	sr.bodyErr = nil
+	sr.Body = nil
+	sr.BodyStream = body
+}
+
 // ResponseMeta methods
 
 func (rm *HttpResponseMeta) GetOriginalResponse() *http.Response {
@@ -1,7 +1,10 @@
 package entity
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -37,3 +40,39 @@ func GetSchema(name string, version string) (Schema, bool) {
 	schema, ok := SchemaRegistry()[key]
 	return schema, ok
 }
+
+// SchemaFieldsWithKeyword returns the paths of the properties of a JSON
+// schema marked with the given extension keyword set to true, such as
+// x-hub-encrypted. Paths are dotted property names, with "*" standing for
+// every item of an array. Properties defined through $ref are not followed.
+func SchemaFieldsWithKeyword(schema []byte, keyword string) ([]string, error) {
+	var root map[string]any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	var paths []string
+	collectSchemaFields(root, keyword, nil, &paths)
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+func collectSchemaFields(schema map[string]any, keyword string, path []string, paths *[]string) {
+	if marked, _ := schema[keyword].(bool); marked && len(path) > 0 {
+		*paths = append(*paths, strings.Join(path, "."))
+		return
+	}
+
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		for name, property := range properties {
+			if child, ok := property.(map[string]any); ok {
+				collectSchemaFields(child, keyword, append(path[:len(path):len(path)], name), paths)
+			}
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		collectSchemaFields(items, keyword, append(path[:len(path):len(path)], "*"), paths)
+	}
+}
@@ -2,7 +2,9 @@ package entity
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
 	"time"
 
 	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
@@ -18,16 +20,26 @@ type Service struct {
 	SchemaName     string                  // Name of the schema used by the service
 	SchemaVersion  string                  // Version of the schema
 	APIName        string                  // Name of the API this service belongs to
+	Namespace      string                  // Namespace telling apart services of the same name, if any
+	Path           string                  // Path the service is served under, by default /{api}/{name}
+	Refs           []string                // Aggregates the service refers to, as name or namespace/name
 	IsPublic       bool                    // Indicates if the service is publicly accessible
 	ServiceTimeout *time.Duration          // Timeout for service operations
+	BodyOptions    BodyOptions             // Body size and streaming options for incoming requests
+	Cache          ResponseCache           // Optional cache for responses to safe requests
+	Auditor        Auditor                 // Optional auditor recording successful mutating requests
+	Idempotency    Idempotency             // Optional replay of responses to retried requests
 	Methods        map[HTTPMethod]*Handler // Map of HTTP methods to their respective handlers
 }
 
 // Handler defines the structure for handling a specific HTTP method within a service.
 type Handler struct {
-	InboundWorkflow  Workflow // Workflow to be applied to incoming requests
-	OutboundWorkflow Workflow // Workflow to be applied to outgoing responses
-	Target           Target   // The target operation to be executed
+	InboundWorkflow  Workflow               // Workflow to be applied to incoming requests
+	OutboundWorkflow Workflow               // Workflow to be applied to outgoing responses
+	Target           Target                 // The target operation to be executed
+	TargetName       string                 // Name of the target, if any
+	TargetType       string                 // Registered type the target was built from
+	TargetConfig     map[string]interface{} // Config the target was built with
 }
 
 // NewService creates and returns a new Service instance.
@@ -54,6 +66,29 @@ func NewService(apiName, name, schemaName, schemaVersion string, public bool) (*
 	return &service, nil
 }
 
+// RoutePath returns the path the service is served under.
+func (service *Service) RoutePath() string {
+	if service.Path != "" {
+		return service.Path
+	}
+	return "/" + service.APIName + "/" + service.Name
+}
+
+// RouteName returns the last segment of the path the service is served
+// under, which is its name unless the API serves it under another.
+func (service *Service) RouteName() string {
+	return path.Base(service.RoutePath())
+}
+
+// QualifiedName returns the name of the service prefixed with its
+// namespace, if it has one.
+func (service *Service) QualifiedName() string {
+	if service.Namespace == "" {
+		return service.Name
+	}
+	return service.Namespace + "/" + service.Name
+}
+
 // DoRequest processes an incoming service request by applying the appropriate workflows and target operation.
 //
 // Parameters:
@@ -67,37 +102,165 @@ func (service *Service) DoRequest(ctx context.Context, request ServiceRequest) e
 		return domainerr.ErrEmptyInput
 	}
 
-	var cancel context.CancelFunc
+	ctx = context.WithValue(ctx, handlingServiceKey{}, service)
+
+	if service.Cache != nil && IsSafeMethod(request.GetMethod()) {
+		return service.doCached(ctx, request)
+	}
+
+	if err := service.doRequest(ctx, request); err != nil {
+		return err
+	}
+
+	if service.Cache != nil {
+		service.Cache.Invalidate(request)
+	}
+
+	return nil
+}
+
+// doIdempotent runs the target of a request, replaying the earlier response
+// when it is a retry of a request with the same idempotency key. It runs
+// after the inbound workflow, so that the key is scoped to the principal
+// the request was authenticated as and retries are authenticated too.
+func (service *Service) doIdempotent(ctx context.Context, handler *Handler, request ServiceRequest) error {
+	if service.Idempotency == nil || !IsIdempotencyKeyed(request) {
+		return service.doAndAudit(ctx, handler, request)
+	}
+
+	response, err := service.Idempotency.Do(ctx, request, func(ctx context.Context) (ServiceResponse, error) {
+		if err := service.doAndAudit(ctx, handler, request); err != nil {
+			return nil, err
+		}
+		return request.GetResponse(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	request.SetResponse(response)
+	return nil
+}
+
+// doAndAudit runs the target of a request, recording the change it makes
+// with the auditor when it is not safe and the service has one.
+func (service *Service) doAndAudit(ctx context.Context, handler *Handler, request ServiceRequest) error {
+	if service.Auditor == nil || IsSafeMethod(request.GetMethod()) {
+		return handler.applyTarget(ctx, request)
+	}
+	return service.doAuditedRequest(ctx, handler, request)
+}
+
+// doCached runs the inbound workflow of a safe request, and then answers
+// it from the cache or runs its target. Inbound tasks, such as
+// authentication and rate limits, run for cached responses too, so that
+// they are only served to callers the target would have answered.
+func (service *Service) doCached(ctx context.Context, request ServiceRequest) error {
+	ctx, cancel := service.withTimeout(ctx)
+	defer cancel()
+
+	handler, err := service.handlerFor(request)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.applyInbound(ctx, request); err != nil {
+		return err
+	}
+
+	response, err := service.Cache.Fetch(ctx, request, func(ctx context.Context) (ServiceResponse, error) {
+		if err := handler.applyTarget(ctx, request); err != nil {
+			return nil, err
+		}
+		return request.GetResponse(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	request.SetResponse(response)
+	return nil
+}
+
+// doRequest runs the inbound workflow, target and outbound workflow for a request.
+func (service *Service) doRequest(ctx context.Context, request ServiceRequest) error {
+	ctx, cancel := service.withTimeout(ctx)
+	defer cancel()
+
+	handler, err := service.handlerFor(request)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.applyInbound(ctx, request); err != nil {
+		return err
+	}
+
+	return service.doIdempotent(ctx, handler, request)
+}
+
+// handlingServiceKey is the context key of the service handling a request.
+type handlingServiceKey struct{}
+
+// StoredAggregate returns the JSON state of the aggregate a request refers
+// to, as read through the GET target of the service handling the request.
+// It returns false when the request names no aggregate, the service has no
+// GET target or the aggregate cannot be read. Tasks deciding on the state of
+// an aggregate use it rather than the request body, which the client controls.
+func StoredAggregate(ctx context.Context, request ServiceRequest) ([]byte, bool) {
+	service, ok := ctx.Value(handlingServiceKey{}).(*Service)
+	if !ok {
+		return nil, false
+	}
+
+	state := service.snapshot(ctx, request, aggregateIDOf(request))
+	return state, state != nil
+}
+
+// withTimeout bounds ctx by the timeout of the service, if it has one.
+func (service *Service) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
 	if service.ServiceTimeout != nil {
-		ctx, cancel = context.WithTimeout(ctx, *service.ServiceTimeout)
-		defer cancel()
+		return context.WithTimeout(ctx, *service.ServiceTimeout)
 	}
+	return ctx, func() {}
+}
 
+// handlerFor returns the handler of the method of a request.
+func (service *Service) handlerFor(request ServiceRequest) (*Handler, error) {
 	method := request.GetMethod()
 
 	handler, ok := service.Methods[method]
 	if !ok {
-		return fmt.Errorf("method %s not found for service %s, %w", method, request.GetServiceName(), ErrMethodNotConfigured)
+		return nil, fmt.Errorf("method %s not found for service %s, %w", method, request.GetServiceName(), ErrMethodNotConfigured)
 	}
+	return handler, nil
+}
 
+// applyInbound runs the inbound workflow of a request. A request whose body
+// a task failed to read is not passed on to the target.
+func (handler *Handler) applyInbound(ctx context.Context, request ServiceRequest) error {
 	if handler.InboundWorkflow != nil {
-		err := handler.InboundWorkflow.Apply(ctx, request)
-		if err != nil {
+		if err := handler.InboundWorkflow.Apply(ctx, request); err != nil {
 			return err
 		}
 	}
+	return requestBodyError(request)
+}
 
-	var response ServiceResponse
-	var err error
-
+// applyTarget runs the target of a request and the outbound workflow of its
+// response.
+func (handler *Handler) applyTarget(ctx context.Context, request ServiceRequest) error {
 	if handler.Target == nil {
 		return domainerr.ErrTargetNotConfigured
 	}
 
-	response, err = handler.Target.Apply(ctx, request)
+	response, err := handler.Target.Apply(ctx, request)
 	if err != nil {
 		return err
 	}
+	if err := requestBodyError(request); err != nil {
+		return err
+	}
 
 	if response == nil {
 		return domainerr.ErrEmptyResponse
@@ -112,9 +275,33 @@ func (service *Service) DoRequest(ctx context.Context, request ServiceRequest) e
 		}
 	}
 
+	if err := bodyError(request.GetResponse()); err != nil {
+		return fmt.Errorf("failed to read the response body: %w", err)
+	}
+
+	return nil
+}
+
+// bodyError returns the error met reading the body of a request or
+// response, for those which keep it.
+func bodyError(message any) error {
+	if body, ok := message.(interface{ BodyError() error }); ok {
+		return body.BodyError()
+	}
 	return nil
 }
 
+// requestBodyError returns the error met reading the body of a request.
+// Bodies over the maximum size are answered with 413, and bodies the client
+// failed to send with 400.
+func requestBodyError(request ServiceRequest) error {
+	err := bodyError(request)
+	if err == nil || errors.Is(err, domainerr.ErrBodyTooLarge) || errors.Is(err, ErrBodyConsumed) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", domainerr.ErrMalformedBody, err)
+}
+
 // SetHandler assigns a Handler to a specific HTTP method for the service.
 //
 // Parameters:
@@ -3,11 +3,19 @@ package entity_test
 import (
 	"context"
 	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/QueerGlobal/hub-framework/core/entity"
 	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/cache"
+	"github.com/QueerGlobal/hub-framework/service/idempotency"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Mock Handler for testing
@@ -133,3 +141,312 @@ func TestDoRequest_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, req.Response)
 }
+
+// memoryTarget stores one aggregate, answering GET, PUT and DELETE.
+type memoryTarget struct {
+	state []byte
+}
+
+func (m *memoryTarget) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	status := http.StatusOK
+	switch req.GetMethod() {
+	case entity.HTTPMethodGET:
+		if m.state == nil {
+			status = http.StatusNotFound
+		}
+	case entity.HTTPMethodPUT:
+		m.state = req.GetBody()
+	case entity.HTTPMethodDELETE:
+		m.state = nil
+	}
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: status},
+		Body:         m.state,
+	}, nil
+}
+
+type recordingAuditor struct {
+	events []entity.AuditEvent
+}
+
+func (r *recordingAuditor) Record(ctx context.Context, event entity.AuditEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestDoRequest_Audit(t *testing.T) {
+	target := &memoryTarget{}
+	auditor := &recordingAuditor{}
+
+	service, _ := entity.NewService("recipeApp", "recipe", "Recipe", "1.0", true)
+	service.Auditor = auditor
+	for _, method := range []entity.HTTPMethod{entity.HTTPMethodGET, entity.HTTPMethodPUT, entity.HTTPMethodDELETE} {
+		service.SetHandler(method, &entity.Handler{Target: target})
+	}
+
+	request := func(method entity.HTTPMethod, body string) *entity.HTTPServiceRequest {
+		return &entity.HTTPServiceRequest{
+			ID:           uuid.New(),
+			ApiName:      "recipeApp",
+			ServiceName:  "recipe",
+			Method:       method,
+			InternalPath: "/recipeApp/recipe/42",
+			Body:         []byte(body),
+			Claims:       entity.Claims{"sub": "ada"},
+		}
+	}
+
+	assert.NoError(t, service.DoRequest(context.Background(), request(entity.HTTPMethodPUT, `{"name":"soup"}`)))
+	assert.NoError(t, service.DoRequest(context.Background(), request(entity.HTTPMethodGET, "")))
+	assert.NoError(t, service.DoRequest(context.Background(), request(entity.HTTPMethodPUT, `{"name":"stew"}`)))
+	assert.NoError(t, service.DoRequest(context.Background(), request(entity.HTTPMethodDELETE, "")))
+
+	if assert.Len(t, auditor.events, 3, "safe requests are not audited") {
+		created := auditor.events[0]
+		assert.Equal(t, "ada", created.Principal)
+		assert.Equal(t, "42", created.AggregateID)
+		assert.Nil(t, created.Before)
+		assert.JSONEq(t, `{"name":"soup"}`, string(created.After))
+
+		updated := auditor.events[1]
+		assert.JSONEq(t, `{"name":"soup"}`, string(updated.Before))
+		assert.JSONEq(t, `{"name":"stew"}`, string(updated.After))
+
+		deleted := auditor.events[2]
+		assert.JSONEq(t, `{"name":"stew"}`, string(deleted.Before))
+		assert.Nil(t, deleted.After)
+	}
+}
+
+// privateTarget stores one aggregate which only ada may read, and refuses
+// updates to a locked aggregate.
+type privateTarget struct {
+	memoryTarget
+}
+
+func (p *privateTarget) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	switch {
+	case req.GetMethod() == entity.HTTPMethodGET && req.GetClaims().Subject() != "ada":
+		return &entity.HttpServiceResponse{ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusForbidden}}, nil
+	case req.GetMethod() == entity.HTTPMethodPUT && string(req.GetBody()) == `{"locked":true}`:
+		return &entity.HttpServiceResponse{ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusConflict}}, nil
+	}
+	return p.memoryTarget.Apply(ctx, req)
+}
+
+type failingAuditor struct{}
+
+func (failingAuditor) Record(ctx context.Context, event entity.AuditEvent) error {
+	return errors.New("audit log is unavailable")
+}
+
+func TestDoRequest_AuditAfterAuthentication(t *testing.T) {
+	target := &privateTarget{}
+	auditor := &recordingAuditor{}
+
+	service, _ := entity.NewService("recipeApp", "recipe", "Recipe", "1.0", true)
+	service.Auditor = auditor
+	for _, method := range []entity.HTTPMethod{entity.HTTPMethodGET, entity.HTTPMethodPUT} {
+		service.SetHandler(method, &entity.Handler{InboundWorkflow: authenticate, Target: target})
+	}
+
+	put := func(body string) *entity.HTTPServiceRequest {
+		return &entity.HTTPServiceRequest{
+			ID:           uuid.New(),
+			ApiName:      "recipeApp",
+			ServiceName:  "recipe",
+			Method:       entity.HTTPMethodPUT,
+			InternalPath: "/recipeApp/recipe/42",
+			Header:       http.Header{"X-User": []string{"ada"}},
+			Body:         []byte(body),
+		}
+	}
+
+	require.NoError(t, service.DoRequest(context.Background(), put(`{"name":"soup"}`)))
+	require.NoError(t, service.DoRequest(context.Background(), put(`{"name":"stew"}`)))
+
+	// the state before the change is read as the authenticated principal
+	require.Len(t, auditor.events, 2)
+	assert.JSONEq(t, `{"name":"soup"}`, string(auditor.events[1].Before))
+
+	// refused changes are not recorded
+	require.NoError(t, service.DoRequest(context.Background(), put(`{"locked":true}`)))
+	assert.Len(t, auditor.events, 2)
+
+	// and changes which were made are not failed by the audit log
+	service.Auditor = failingAuditor{}
+	assert.NoError(t, service.DoRequest(context.Background(), put(`{"name":"pie"}`)))
+	assert.JSONEq(t, `{"name":"pie"}`, string(target.state))
+}
+
+// authenticate stands in for an authentication task, taking the principal
+// from the X-User header and rejecting requests without one.
+var authenticate = &MockWorkflow{
+	applyFunc: func(ctx context.Context, req entity.ServiceRequest) error {
+		user := req.GetHeader().Get("X-User")
+		if user == "" {
+			return domainerr.ErrUnauthorized
+		}
+		req.SetClaims(entity.Claims{"sub": user})
+		return nil
+	},
+}
+
+// principalTarget answers with the principal of the request, counting its
+// calls.
+type principalTarget struct {
+	calls int
+}
+
+func (p *principalTarget) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	p.calls++
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK, Header: http.Header{}},
+		Body:         []byte(req.GetClaims().Subject()),
+	}, nil
+}
+
+func TestDoRequest_CacheAuthenticatesEveryRequest(t *testing.T) {
+	target := &principalTarget{}
+
+	service, _ := entity.NewService("recipeApp", "recipe", "Recipe", "1.0", true)
+	service.Cache = cache.New(cache.Config{})
+	service.SetHandler(entity.HTTPMethodGET, &entity.Handler{InboundWorkflow: authenticate, Target: target})
+
+	get := func(user string) (*entity.HTTPServiceRequest, error) {
+		request := &entity.HTTPServiceRequest{
+			Method:       entity.HTTPMethodGET,
+			URL:          &url.URL{Path: "/recipeApp/recipe/42"},
+			InternalPath: "/recipeApp/recipe/42",
+			Header:       http.Header{},
+		}
+		if user != "" {
+			// a credential the cache does not know about, unlike Authorization
+			request.Header.Set("X-User", user)
+		}
+		return request, service.DoRequest(context.Background(), request)
+	}
+
+	request, err := get("ada")
+	require.NoError(t, err)
+	assert.Equal(t, "ada", string(request.GetResponse().GetBody()))
+
+	request, err = get("ada")
+	require.NoError(t, err)
+	assert.Equal(t, "ada", string(request.GetResponse().GetBody()))
+	assert.Equal(t, 1, target.calls)
+
+	// anonymous callers are rejected rather than served the cached response
+	_, err = get("")
+	assert.ErrorIs(t, err, domainerr.ErrUnauthorized)
+
+	// and other principals do not share it
+	request, err = get("grace")
+	require.NoError(t, err)
+	assert.Equal(t, "grace", string(request.GetResponse().GetBody()))
+	assert.Equal(t, 2, target.calls)
+}
+
+func TestDoRequest_IdempotencyKeysArePerPrincipal(t *testing.T) {
+	target := &principalTarget{}
+
+	store, err := idempotency.OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+
+	service, _ := entity.NewService("recipeApp", "recipe", "Recipe", "1.0", true)
+	service.Idempotency = idempotency.New(idempotency.Config{}, store)
+	service.SetHandler(entity.HTTPMethodPOST, &entity.Handler{InboundWorkflow: authenticate, Target: target})
+
+	post := func(user string) (*entity.HTTPServiceRequest, error) {
+		request := &entity.HTTPServiceRequest{
+			Method:       entity.HTTPMethodPOST,
+			URL:          &url.URL{Path: "/recipeApp/recipe"},
+			InternalPath: "/recipeApp/recipe",
+			Header:       http.Header{entity.IdempotencyKeyHeader: {"key-1"}},
+			Body:         []byte(`{"name":"pie"}`),
+		}
+		if user != "" {
+			request.Header.Set("X-User", user)
+		}
+		return request, service.DoRequest(context.Background(), request)
+	}
+
+	request, err := post("ada")
+	require.NoError(t, err)
+	assert.Equal(t, "ada", string(request.GetResponse().GetBody()))
+
+	request, err = post("ada")
+	require.NoError(t, err)
+	assert.Equal(t, "ada", string(request.GetResponse().GetBody()))
+	assert.Equal(t, "true", request.GetResponse().GetResponseMeta().GetHeader().Get(idempotency.ReplayedHeader))
+	assert.Equal(t, 1, target.calls)
+
+	// anonymous retries are rejected rather than replayed
+	_, err = post("")
+	assert.ErrorIs(t, err, domainerr.ErrUnauthorized)
+
+	// and other principals using the same key are not replayed the response
+	request, err = post("grace")
+	require.NoError(t, err)
+	assert.Equal(t, "grace", string(request.GetResponse().GetBody()))
+	assert.Equal(t, 2, target.calls)
+}
+
+// countingTarget counts the requests it is applied to.
+type countingTarget struct {
+	calls int
+}
+
+func (c *countingTarget) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	c.calls++
+	return &entity.HttpServiceResponse{}, nil
+}
+
+func TestDoRequest_BodyTooLarge(t *testing.T) {
+	target := &countingTarget{}
+	service, _ := entity.NewService("test", "TestService", "TestSchema", "1.0", true)
+	service.Methods = map[entity.HTTPMethod]*entity.Handler{
+		entity.HTTPMethodPOST: {
+			InboundWorkflow: &MockWorkflow{
+				applyFunc: func(ctx context.Context, req entity.ServiceRequest) error {
+					assert.Nil(t, req.GetBody())
+					return nil
+				},
+			},
+			Target: target,
+		},
+	}
+
+	req := &entity.HTTPServiceRequest{
+		Method:     entity.HTTPMethodPOST,
+		BodyStream: entity.NewRequestBody(io.NopCloser(strings.NewReader("too large")), entity.BodyOptions{Streaming: true, MaxBodySize: 3}),
+	}
+
+	err := service.DoRequest(context.Background(), req)
+	assert.ErrorIs(t, err, domainerr.ErrBodyTooLarge)
+	assert.Zero(t, target.calls)
+}
+
+// brokenBody fails after returning its content.
+type brokenBody struct {
+	io.Reader
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *brokenBody) Close() error {
+	return nil
+}
+
+func TestHttpServiceResponse_GetBodyKeepsReadError(t *testing.T) {
+	response := &entity.HttpServiceResponse{BodyStream: &brokenBody{strings.NewReader("partial")}}
+
+	assert.Equal(t, "partial", string(response.GetBody()))
+	assert.ErrorIs(t, response.BodyError(), io.ErrUnexpectedEOF)
+}
@@ -45,6 +45,28 @@ func RegisterTargetType(name string,
 	TargetRegistry()[name] = targetConstructor
 }
 
+var (
+	onceRegisteredTargetHandlers sync.Once
+	registeredTargetHandlers     RegisteredTargets
+)
+
+// TargetHandlerRegistry exposes the target constructors registered with
+// RegisterTargetHandler, keyed by HandlerKey.
+func TargetHandlerRegistry() RegisteredTargets {
+	onceRegisteredTargetHandlers.Do(func() {
+		registeredTargetHandlers = make(RegisteredTargets)
+	})
+
+	return registeredTargetHandlers
+}
+
+// RegisterTargetHandler registers a target constructor written in Go under
+// the import path and handler name a target type manifest refers to it by.
+func RegisterTargetHandler(importPath, handlerName string,
+	targetConstructor TargetConstructor) {
+	TargetHandlerRegistry()[HandlerKey(importPath, handlerName)] = targetConstructor
+}
+
 // GetTarget retrieves a Target Constructor instance by its name,
 // passes in a config and returns a configured Target instance.
 // It returns an error if the target type is not registered.
@@ -47,6 +47,35 @@ func RegisterTaskType(name string,
 	TaskRegistry()[name] = taskConstructor
 }
 
+var (
+	onceRegisteredTaskHandlers sync.Once
+	registeredTaskHandlers     RegisteredTaskTypes
+)
+
+// TaskHandlerRegistry exposes the task constructors registered with
+// RegisterTaskHandler, keyed by HandlerKey.
+func TaskHandlerRegistry() RegisteredTaskTypes {
+	onceRegisteredTaskHandlers.Do(func() {
+		registeredTaskHandlers = make(RegisteredTaskTypes)
+	})
+
+	return registeredTaskHandlers
+}
+
+// RegisterTaskHandler registers a task constructor written in Go under the
+// import path and handler name a task type manifest refers to it by.
+func RegisterTaskHandler(importPath, handlerName string,
+	taskConstructor TaskConstructor) {
+
+	TaskHandlerRegistry()[HandlerKey(importPath, handlerName)] = taskConstructor
+}
+
+// HandlerKey identifies a handler written in Go by its import path and
+// name, as in github.com/org/app/tasks.AuditTask.
+func HandlerKey(importPath, handlerName string) string {
+	return importPath + "." + handlerName
+}
+
 // GetTask retrieves a Task Constructor instance by its name,
 // passes in a config and returns a configured Task instance.
 // It returns an error if the task type is not registered.
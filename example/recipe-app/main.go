@@ -25,7 +25,9 @@ func main() {
 		return tasks.NewExampleTaskGolang(config), nil
 	})
 
-	api.RegisterTaskType("exampleTaskGolang", exampleTaskConstructor)
+	// user-defined-task-types.yml declares the exampleTaskGolang task type,
+	// naming this handler by its import path and name
+	api.RegisterTaskHandler("github.com/QueerGlobal/hub-framework/example/recipe-app/golang/tasks", "ExampleTaskGolang", exampleTaskConstructor)
 
 	log.Printf("Starting {{.ApplicationName}} server on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -22,7 +22,9 @@ func TestApplication(t *testing.T) {
 	exampleTaskConstructor := api.TaskConstructorFunc(func(config map[string]any) (api.Task, error) {
 		return tasks.NewExampleTaskGolang(config), nil
 	})
-	api.RegisterTaskType("exampleTaskGolang", exampleTaskConstructor)
+	// user-defined-task-types.yml declares the exampleTaskGolang task type,
+	// naming this handler by its import path and name
+	api.RegisterTaskHandler("github.com/QueerGlobal/hub-framework/example/recipe-app/golang/tasks", "ExampleTaskGolang", exampleTaskConstructor)
 
 	// Create a test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
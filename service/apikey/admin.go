@@ -0,0 +1,122 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminPath is the path under which the admin API is served.
+const AdminPath = "/admin/apikeys"
+
+// AdminHandler serves the API key admin API. It performs no authentication
+// of its own and must only be exposed on the private port.
+//
+//	POST   /admin/apikeys             create a key: {"name", "scopes", "expiresAt" or "ttl"}
+//	GET    /admin/apikeys             list keys
+//	GET    /admin/apikeys/{id}        get a key
+//	POST   /admin/apikeys/{id}/rotate replace the secret of a key
+//	DELETE /admin/apikeys/{id}        revoke a key
+type AdminHandler struct {
+	manager *Manager
+}
+
+// NewAdminHandler creates an AdminHandler for the given manager.
+func NewAdminHandler(manager *Manager) *AdminHandler {
+	return &AdminHandler{manager: manager}
+}
+
+type createRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+}
+
+// issuedKey is returned when a key is created or rotated, and is the only
+// response which includes the token.
+type issuedKey struct {
+	*Key
+	Token string `json:"token"`
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, AdminPath), "/")
+	segments := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		keys, err := h.manager.List()
+		writeResult(w, http.StatusOK, keys, err)
+
+	case path == "" && r.Method == http.MethodPost:
+		h.create(w, r)
+
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		key, err := h.manager.Get(segments[0])
+		writeResult(w, http.StatusOK, key, err)
+
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		key, err := h.manager.Revoke(segments[0])
+		writeResult(w, http.StatusOK, key, err)
+
+	case len(segments) == 2 && segments[1] == "rotate" && r.Method == http.MethodPost:
+		key, token, err := h.manager.Rotate(segments[0])
+		writeResult(w, http.StatusOK, issuedKey{Key: key, Token: token}, err)
+
+	case len(segments) <= 2:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *AdminHandler) create(w http.ResponseWriter, r *http.Request) {
+	var request createRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt := request.ExpiresAt
+	if request.TTL != "" {
+		ttl, err := time.ParseDuration(request.TTL)
+		if err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("invalid ttl %q", request.TTL), http.StatusBadRequest)
+			return
+		}
+		expires := h.manager.now().Add(ttl).UTC()
+		expiresAt = &expires
+	}
+
+	key, token, err := h.manager.Create(request.Name, request.Scopes, expiresAt)
+	writeResult(w, http.StatusCreated, issuedKey{Key: key, Token: token}, err)
+}
+
+func writeResult(w http.ResponseWriter, status int, body any, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrRevoked):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
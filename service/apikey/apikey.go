@@ -0,0 +1,200 @@
+// Package apikey manages API keys for partner integrations. Only a hash of
+// each key's secret is stored; the secret itself is returned once, when the
+// key is created or rotated.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TokenPrefix starts every API key token, making leaked keys easy to find.
+const TokenPrefix = "hub_"
+
+var (
+	ErrNotFound     = errors.New("API key not found")
+	ErrInvalidToken = errors.New("invalid API key")
+	ErrRevoked      = errors.New("API key has been revoked")
+	ErrExpired      = errors.New("API key has expired")
+)
+
+// Key is a stored API key. The token presented by clients has the form
+// hub_<id>.<secret>, and only the SHA-256 hash of the secret is kept.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	SecretHash string     `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	RotatedAt  *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// storedKey is the persisted form of a Key, including its secret hash.
+type storedKey struct {
+	Key
+	SecretHash string `json:"secretHash"`
+}
+
+// Store persists API keys.
+type Store interface {
+	Get(id string) (*Key, error)
+	Put(key *Key) error
+	List() ([]*Key, error)
+}
+
+// Manager creates, rotates, revokes and authenticates API keys.
+type Manager struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewManager creates a Manager storing keys in the given store.
+func NewManager(store Store) *Manager {
+	return &Manager{store: store, now: time.Now}
+}
+
+// Create issues a new key and returns it along with its token, which is
+// not stored and cannot be recovered later.
+func (m *Manager) Create(name string, scopes []string, expiresAt *time.Time) (*Key, string, error) {
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &Key{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: m.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	token, err := m.issueSecret(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := m.store.Put(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, token, nil
+}
+
+// Get returns the key with the given ID.
+func (m *Manager) Get(id string) (*Key, error) {
+	return m.store.Get(id)
+}
+
+// List returns all keys, including revoked and expired ones.
+func (m *Manager) List() ([]*Key, error) {
+	return m.store.List()
+}
+
+// Rotate replaces the secret of a key, invalidating its previous token, and
+// returns the new token.
+func (m *Manager) Rotate(id string) (*Key, string, error) {
+	key, err := m.store.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if key.RevokedAt != nil {
+		return nil, "", ErrRevoked
+	}
+
+	token, err := m.issueSecret(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := m.now().UTC()
+	key.RotatedAt = &now
+
+	if err := m.store.Put(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, token, nil
+}
+
+// Revoke permanently disables a key.
+func (m *Manager) Revoke(id string) (*Key, error) {
+	key, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt == nil {
+		now := m.now().UTC()
+		key.RevokedAt = &now
+		if err := m.store.Put(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// Authenticate returns the key identified by a token if the token's secret
+// matches and the key is neither revoked nor expired.
+func (m *Manager) Authenticate(token string) (*Key, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, TokenPrefix), ".")
+	if !ok || !strings.HasPrefix(token, TokenPrefix) || id == "" || secret == "" {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := m.store.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	if key.ExpiresAt != nil && !m.now().Before(*key.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	return key, nil
+}
+
+func (m *Manager) issueSecret(key *Key) (string, error) {
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+
+	key.SecretHash = hashSecret(secret)
+
+	return fmt.Sprintf("%s%s.%s", TokenPrefix, key.ID, secret), nil
+}
+
+// hashSecret hashes a secret. A fast hash is sufficient since secrets are
+// long random strings rather than user chosen passwords.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,118 @@
+package apikey
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+	return NewManager(store)
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	m := newTestManager(t)
+
+	key, token, err := m.Create("partner", []string{"recipes:read"}, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, TokenPrefix+key.ID+"."))
+	assert.NotContains(t, key.SecretHash, strings.SplitN(token, ".", 2)[1])
+
+	authenticated, err := m.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"recipes:read"}, authenticated.Scopes)
+
+	_, err = m.Authenticate(token + "x")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = m.Authenticate("hub_unknown.secret")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, rotated, err := m.Rotate(key.ID)
+	require.NoError(t, err)
+	_, err = m.Authenticate(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "old token is invalid after rotation")
+	_, err = m.Authenticate(rotated)
+	require.NoError(t, err)
+
+	_, err = m.Revoke(key.ID)
+	require.NoError(t, err)
+	_, err = m.Authenticate(rotated)
+	assert.ErrorIs(t, err, ErrRevoked)
+	_, _, err = m.Rotate(key.ID)
+	assert.ErrorIs(t, err, ErrRevoked)
+}
+
+func TestManagerExpiry(t *testing.T) {
+	m := newTestManager(t)
+
+	expires := time.Now().Add(time.Hour)
+	_, token, err := m.Create("temporary", nil, &expires)
+	require.NoError(t, err)
+
+	_, err = m.Authenticate(token)
+	require.NoError(t, err)
+
+	m.now = func() time.Time { return expires }
+	_, err = m.Authenticate(token)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestAdminHandler(t *testing.T) {
+	m := newTestManager(t)
+	server := httptest.NewServer(NewAdminHandler(m))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+AdminPath, "application/json",
+		strings.NewReader(`{"name":"partner","scopes":["recipes:read"],"ttl":"24h"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created struct {
+		ID         string    `json:"id"`
+		Token      string    `json:"token"`
+		ExpiresAt  time.Time `json:"expiresAt"`
+		SecretHash string    `json:"secretHash"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.NotEmpty(t, created.Token)
+	assert.Empty(t, created.SecretHash, "the hash is never returned")
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), created.ExpiresAt, time.Minute)
+
+	resp, err = http.Get(server.URL + AdminPath)
+	require.NoError(t, err)
+	var listed []map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed, 1)
+	assert.NotContains(t, listed[0], "token")
+
+	resp, err = http.Post(server.URL+AdminPath+"/"+created.ID+"/rotate", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	request, _ := http.NewRequest(http.MethodDelete, server.URL+AdminPath+"/"+created.ID, nil)
+	resp, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + AdminPath + "/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+AdminPath, "application/json", strings.NewReader(`{"scopes":[]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
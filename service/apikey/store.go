@@ -0,0 +1,125 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/QueerGlobal/hub-framework/service/shared"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// DefaultPath is the Badger directory used when none is configured.
+const DefaultPath = "./data/apikeys"
+
+const keyPrefix = "apikey/"
+
+// BadgerStore keeps API keys in a Badger database.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+var badgerStores = shared.NewRegistry[*BadgerStore]()
+
+// OpenBadgerStore opens the Badger database at path. Stores are shared by
+// path, so that the admin API and authentication tasks see the same keys.
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	return badgerStores.Open(path, func() (*BadgerStore, error) {
+		db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open API key store %s: %w", path, err)
+		}
+
+		return &BadgerStore{db: db}, nil
+	})
+}
+
+// Close closes the database of the store.
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// Get implements Store.
+func (s *BadgerStore) Get(id string) (*Key, error) {
+	var key *Key
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(storageKey(id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(value []byte) error {
+			key, err = decodeKey(value)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Put implements Store.
+func (s *BadgerStore) Put(key *Key) error {
+	value, err := json.Marshal(storedKey{Key: *key, SecretHash: key.SecretHash})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(storageKey(key.ID), value)
+	})
+}
+
+// List implements Store.
+func (s *BadgerStore) List() ([]*Key, error) {
+	keys := []*Key{}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(keyPrefix)
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			err := it.Item().Value(func(value []byte) error {
+				key, err := decodeKey(value)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, key)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func storageKey(id string) []byte {
+	return []byte(keyPrefix + id)
+}
+
+func decodeKey(value []byte) (*Key, error) {
+	var stored storedKey
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return nil, fmt.Errorf("invalid stored API key: %w", err)
+	}
+
+	key := stored.Key
+	key.SecretHash = stored.SecretHash
+	return &key, nil
+}
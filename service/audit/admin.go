@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminPath is the path under which the query API is served.
+const AdminPath = "/admin/audit"
+
+// AdminHandler serves the audit query API. It performs no authentication of
+// its own and must only be exposed on the private port.
+//
+//	GET /admin/audit         query records: api, service, aggregateId,
+//	                         principal, since, until (RFC 3339), after, limit
+//	GET /admin/audit/verify  check the hash chain of the whole log
+type AdminHandler struct {
+	store *BadgerStore
+}
+
+// NewAdminHandler creates an AdminHandler for the given store.
+func NewAdminHandler(store *BadgerStore) *AdminHandler {
+	return &AdminHandler{store: store}
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, AdminPath), "/")
+
+	if path != "" && path != "verify" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if path == "verify" {
+		checked, err := h.store.Verify()
+		result := map[string]any{"valid": err == nil, "records": checked}
+		switch {
+		case errors.Is(err, ErrTampered):
+			result["error"] = err.Error()
+			writeJSON(w, http.StatusConflict, result)
+		case err != nil:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+		return
+	}
+
+	query, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.store.Find(query)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	values := r.URL.Query()
+	query := Query{
+		APIName:     values.Get("api"),
+		ServiceName: values.Get("service"),
+		AggregateID: values.Get("aggregateId"),
+		Principal:   values.Get("principal"),
+		Limit:       100,
+	}
+
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s %q, must be RFC 3339", name, value)
+			}
+			*target = t
+		}
+	}
+
+	if value := values.Get("after"); value != "" {
+		after, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return query, fmt.Errorf("invalid after %q", value)
+		}
+		query.After = after
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return query, fmt.Errorf("invalid limit %q", value)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,73 @@
+// Package audit keeps a tamper evident trail of the changes made to
+// aggregates. Every successful mutating request is recorded with its
+// principal and a diff of the aggregate, in an append-only Badger log where
+// each record includes the hash of the one before it.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// Record is an entry of the audit log.
+type Record struct {
+	Sequence    uint64    `json:"sequence"`
+	RequestID   string    `json:"requestId"`
+	Principal   string    `json:"principal,omitempty"`
+	APIName     string    `json:"apiName"`
+	ServiceName string    `json:"serviceName"`
+	AggregateID string    `json:"aggregateId,omitempty"`
+	Method      string    `json:"method"`
+	Changes     []Change  `json:"changes"`
+	Timestamp   time.Time `json:"timestamp"`
+	PrevHash    string    `json:"prevHash"`
+	Hash        string    `json:"hash"`
+}
+
+// computeHash returns the hash of a record, covering every field but Hash.
+func (r Record) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log records audit events in a BadgerStore. It implements entity.Auditor.
+type Log struct {
+	store *BadgerStore
+}
+
+// NewLog creates a Log appending to store.
+func NewLog(store *BadgerStore) *Log {
+	return &Log{store: store}
+}
+
+// Record implements entity.Auditor.
+func (l *Log) Record(_ context.Context, event entity.AuditEvent) error {
+	changes, err := Diff(event.Before, event.After)
+	if err != nil {
+		// states which are not JSON are recorded as replaced wholesale
+		changes = []Change{{Op: "replace", Path: "/", Old: string(event.Before), New: string(event.After)}}
+	}
+
+	_, err = l.store.Append(Record{
+		RequestID:   event.RequestID.String(),
+		Principal:   event.Principal,
+		APIName:     event.APIName,
+		ServiceName: event.ServiceName,
+		AggregateID: event.AggregateID,
+		Method:      event.Method.String(),
+		Changes:     changes,
+		Timestamp:   event.Time,
+	})
+	return err
+}
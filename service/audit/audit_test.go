@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	changes, err := Diff(
+		[]byte(`{"name":"soup","tags":["hot"],"chef":{"id":1,"name":"ada"}}`),
+		[]byte(`{"name":"stew","tags":["hot"],"chef":{"id":1},"servings":4}`),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Op: "remove", Path: "/chef/name", Old: "ada"},
+		{Op: "replace", Path: "/name", Old: "soup", New: "stew"},
+		{Op: "add", Path: "/servings", New: float64(4)},
+	}, changes)
+
+	created, err := Diff(nil, []byte(`{"a":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Op: "add", Path: "/", New: map[string]any{"a": float64(1)}}}, created)
+}
+
+func record(t *testing.T, log *Log, aggregateID string, principal string, before string, after string) {
+	event := entity.AuditEvent{
+		RequestID:   uuid.New(),
+		Principal:   principal,
+		APIName:     "recipeApp",
+		ServiceName: "recipe",
+		AggregateID: aggregateID,
+		Method:      entity.HTTPMethodPUT,
+		Time:        time.Now().UTC(),
+	}
+	if before != "" {
+		event.Before = []byte(before)
+	}
+	if after != "" {
+		event.After = []byte(after)
+	}
+	require.NoError(t, log.Record(context.Background(), event))
+}
+
+func TestLogChainAndQuery(t *testing.T) {
+	path := t.TempDir()
+	store, err := OpenBadgerStore(path)
+	require.NoError(t, err)
+	log := NewLog(store)
+
+	record(t, log, "1", "ada", "", `{"name":"soup"}`)
+	record(t, log, "2", "grace", "", `{"name":"pie"}`)
+	record(t, log, "1", "ada", `{"name":"soup"}`, `{"name":"stew"}`)
+
+	checked, err := store.Verify()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), checked)
+
+	records, err := store.Find(Query{AggregateID: "1"})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, uint64(1), records[0].Sequence)
+	assert.Equal(t, records[0].Hash, mustFind(t, store, 2).PrevHash)
+	assert.Equal(t, "/name", records[1].Changes[0].Path)
+
+	limited, err := store.Find(Query{Principal: "ada", After: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, limited, 1)
+	assert.Equal(t, uint64(3), limited[0].Sequence)
+
+	reopened := &BadgerStore{db: store.db}
+	require.NoError(t, reopened.loadHead())
+	assert.Equal(t, uint64(3), reopened.sequence)
+	assert.Equal(t, limited[0].Hash, reopened.lastHash)
+}
+
+func mustFind(t *testing.T, store *BadgerStore, sequence uint64) *Record {
+	records, err := store.Find(Query{After: sequence - 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	return records[0]
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+	log := NewLog(store)
+
+	record(t, log, "1", "ada", "", `{"name":"soup"}`)
+	record(t, log, "1", "ada", `{"name":"soup"}`, `{"name":"stew"}`)
+
+	tampered := mustFind(t, store, 1)
+	tampered.Principal = "mallory"
+	value, err := json.Marshal(tampered)
+	require.NoError(t, err)
+	require.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(storageKey(1), value)
+	}))
+
+	_, err = store.Verify()
+	assert.ErrorIs(t, err, ErrTampered)
+
+	admin := NewAdminHandler(store)
+	resp := httptest.NewRecorder()
+	admin.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, AdminPath+"/verify", nil))
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+func TestAdminQuery(t *testing.T) {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+	log := NewLog(store)
+
+	record(t, log, "1", "ada", "", `{"name":"soup"}`)
+	record(t, log, "2", "grace", "", `{"name":"pie"}`)
+
+	admin := NewAdminHandler(store)
+
+	resp := httptest.NewRecorder()
+	admin.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, AdminPath+"?principal=grace", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	var records []*Record
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, "2", records[0].AggregateID)
+
+	bad := httptest.NewRecorder()
+	admin.ServeHTTP(bad, httptest.NewRequest(http.MethodGet, AdminPath+"?since=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, bad.Code)
+
+	post := httptest.NewRecorder()
+	admin.ServeHTTP(post, httptest.NewRequest(http.MethodPost, AdminPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, post.Code)
+
+	verify := httptest.NewRecorder()
+	admin.ServeHTTP(verify, httptest.NewRequest(http.MethodGet, AdminPath+"/verify", nil))
+	assert.Equal(t, http.StatusOK, verify.Code)
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is one difference between the state of an aggregate before and
+// after a request. Path is a JSON pointer; arrays are compared as a whole.
+type Change struct {
+	Op   string `json:"op"` // add, remove or replace
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// Diff compares two JSON documents. A nil document stands for an aggregate
+// which does not exist.
+func Diff(before []byte, after []byte) ([]Change, error) {
+	var prev, next any
+	if before != nil {
+		if err := json.Unmarshal(before, &prev); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &next); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []Change{}
+	diff("", prev, next, before != nil, after != nil, &changes)
+	return changes, nil
+}
+
+func diff(path string, prev any, next any, hasPrev bool, hasNext bool, changes *[]Change) {
+	switch {
+	case !hasPrev && !hasNext:
+		return
+	case !hasPrev:
+		*changes = append(*changes, Change{Op: "add", Path: pointer(path), New: next})
+		return
+	case !hasNext:
+		*changes = append(*changes, Change{Op: "remove", Path: pointer(path), Old: prev})
+		return
+	}
+
+	prevObject, prevIsObject := prev.(map[string]any)
+	nextObject, nextIsObject := next.(map[string]any)
+	if !prevIsObject || !nextIsObject {
+		if !reflect.DeepEqual(prev, next) {
+			*changes = append(*changes, Change{Op: "replace", Path: pointer(path), Old: prev, New: next})
+		}
+		return
+	}
+
+	keys := make(map[string]bool, len(prevObject)+len(nextObject))
+	for key := range prevObject {
+		keys[key] = true
+	}
+	for key := range nextObject {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		prevValue, hasPrevValue := prevObject[key]
+		nextValue, hasNextValue := nextObject[key]
+		diff(path+"/"+escape(key), prevValue, nextValue, hasPrevValue, hasNextValue, changes)
+	}
+}
+
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// escape encodes a key as a JSON pointer reference token (RFC 6901).
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/service/shared"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// DefaultPath is the Badger directory used when none is configured.
+const DefaultPath = "./data/audit"
+
+const recordPrefix = "audit/"
+
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// Query filters the records returned by Find. Empty fields match every
+// record.
+type Query struct {
+	APIName     string
+	ServiceName string
+	AggregateID string
+	Principal   string
+	Since       time.Time
+	Until       time.Time
+	After       uint64 // only records with a higher sequence number
+	Limit       int
+}
+
+func (q Query) matches(r *Record) bool {
+	return (q.APIName == "" || strings.EqualFold(q.APIName, r.APIName)) &&
+		(q.ServiceName == "" || strings.EqualFold(q.ServiceName, r.ServiceName)) &&
+		(q.AggregateID == "" || q.AggregateID == r.AggregateID) &&
+		(q.Principal == "" || q.Principal == r.Principal) &&
+		(q.Since.IsZero() || !r.Timestamp.Before(q.Since)) &&
+		(q.Until.IsZero() || r.Timestamp.Before(q.Until))
+}
+
+// BadgerStore is an append-only log of audit records in a Badger database.
+// Records are keyed by sequence number and chained by hash, so that any
+// modified, removed or reordered record is detected by Verify.
+type BadgerStore struct {
+	db *badger.DB
+
+	mu       sync.Mutex
+	sequence uint64
+	lastHash string
+}
+
+var badgerStores = shared.NewRegistry[*BadgerStore]()
+
+// OpenBadgerStore opens the Badger database at path. Stores are shared by
+// path, so that a single writer appends to each log.
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	return badgerStores.Open(path, func() (*BadgerStore, error) {
+		db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit store %s: %w", path, err)
+		}
+
+		store := &BadgerStore{db: db}
+		if err := store.loadHead(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return store, nil
+	})
+}
+
+// Close closes the database of the store.
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// loadHead reads the last record, which the next record is chained to.
+func (s *BadgerStore) loadHead() error {
+	return s.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(recordPrefix)
+		options.Reverse = true
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		// reverse iteration starts from the last key with the prefix
+		it.Seek(append([]byte(recordPrefix), 0xff))
+		if !it.Valid() {
+			return nil
+		}
+
+		return it.Item().Value(func(value []byte) error {
+			record, err := decodeRecord(value)
+			if err != nil {
+				return err
+			}
+			s.sequence = record.Sequence
+			s.lastHash = record.Hash
+			return nil
+		})
+	})
+}
+
+// Append adds a record to the end of the log, setting its sequence number
+// and hashes.
+func (s *BadgerStore) Append(record Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.Sequence = s.sequence + 1
+	record.PrevHash = s.lastHash
+
+	hash, err := record.computeHash()
+	if err != nil {
+		return nil, err
+	}
+	record.Hash = hash
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		key := storageKey(record.Sequence)
+		if _, err := txn.Get(key); err == nil {
+			return fmt.Errorf("audit record %d already exists", record.Sequence)
+		}
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.sequence = record.Sequence
+	s.lastHash = record.Hash
+
+	return &record, nil
+}
+
+// Find returns the records matching query, oldest first.
+func (s *BadgerStore) Find(query Query) ([]*Record, error) {
+	records := []*Record{}
+
+	err := s.iterate(query.After, func(record *Record) bool {
+		if query.matches(record) {
+			records = append(records, record)
+		}
+		return query.Limit <= 0 || len(records) < query.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Verify walks the whole log and checks the hash chain. It returns the
+// number of records checked, and ErrTampered with the sequence number of
+// the first record which does not match.
+func (s *BadgerStore) Verify() (uint64, error) {
+	var checked uint64
+	var expected uint64 = 1
+	prevHash := ""
+	var verifyErr error
+
+	err := s.iterate(0, func(record *Record) bool {
+		hash, err := record.computeHash()
+		switch {
+		case err != nil:
+			verifyErr = err
+		case record.Sequence != expected:
+			verifyErr = fmt.Errorf("%w: record %d is missing", ErrTampered, expected)
+		case record.PrevHash != prevHash || record.Hash != hash:
+			verifyErr = fmt.Errorf("%w: record %d does not match its hash", ErrTampered, record.Sequence)
+		}
+		if verifyErr != nil {
+			return false
+		}
+
+		checked++
+		expected++
+		prevHash = record.Hash
+		return true
+	})
+	if err != nil {
+		return checked, err
+	}
+
+	return checked, verifyErr
+}
+
+// iterate calls fn for the records after the given sequence number, in
+// order, until fn returns false.
+func (s *BadgerStore) iterate(after uint64, fn func(*Record) bool) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(recordPrefix)
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Seek(storageKey(after + 1)); it.Valid(); it.Next() {
+			var record *Record
+			err := it.Item().Value(func(value []byte) error {
+				var err error
+				record, err = decodeRecord(value)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if !fn(record) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// storageKey encodes the sequence number big endian so that keys sort in
+// sequence order.
+func storageKey(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(recordPrefix), sequence)
+}
+
+func decodeRecord(value []byte) (*Record, error) {
+	var record Record
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("invalid audit record: %w", err)
+	}
+	return &record, nil
+}
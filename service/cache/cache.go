@@ -0,0 +1,326 @@
+// Package cache provides an in-memory response cache for safe requests,
+// with coalescing of identical concurrent requests, conditional requests
+// via ETag, and invalidation when an aggregate is written.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultTTL is used when no TTL is configured.
+const DefaultTTL = 30 * time.Second
+
+// DefaultMaxEntries is used when no entry limit is configured.
+const DefaultMaxEntries = 1000
+
+// Config describes a service response cache.
+type Config struct {
+	TTL         time.Duration
+	MaxEntries  int
+	VaryHeaders []string // request headers which select between cached responses
+}
+
+// ResponseCache implements entity.ResponseCache.
+type ResponseCache struct {
+	config      Config
+	ttl         time.Duration
+	varyHeaders []string
+	store       *store
+	group       singleflight.Group
+}
+
+var _ entity.ResponseCache = (*ResponseCache)(nil)
+
+// entry is a cached response along with the aggregate resource it belongs to.
+type entry struct {
+	statusCode int
+	status     string
+	header     http.Header
+	body       []byte
+	resource   string
+	expires    time.Time
+}
+
+// New creates a ResponseCache from the given config.
+func New(config Config) *ResponseCache {
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultMaxEntries
+	}
+
+	varyHeaders := make([]string, len(config.VaryHeaders))
+	for i, header := range config.VaryHeaders {
+		varyHeaders[i] = http.CanonicalHeaderKey(header)
+	}
+
+	return &ResponseCache{
+		config:      config,
+		ttl:         config.TTL,
+		varyHeaders: varyHeaders,
+		store:       newStore(config.MaxEntries),
+	}
+}
+
+// Config returns the config of the cache, with defaults applied.
+func (c *ResponseCache) Config() Config {
+	return c.config
+}
+
+// Fetch implements entity.ResponseCache.
+func (c *ResponseCache) Fetch(
+	ctx context.Context,
+	request entity.ServiceRequest,
+	next func(ctx context.Context) (entity.ServiceResponse, error)) (entity.ServiceResponse, error) {
+
+	if !c.isCacheable(request) {
+		response, err := next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return c.respond(request, c.newEntry(request, response)), nil
+	}
+
+	key := c.key(request)
+
+	if !hasDirective(request.GetHeader(), "no-cache") {
+		if cached, ok := c.store.get(key); ok {
+			return c.respond(request, cached), nil
+		}
+	}
+
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		response, err := next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		e := c.newEntry(request, response)
+		if isStorable(e) {
+			c.addValidators(e)
+			c.store.add(key, e)
+		}
+		return e, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.respond(request, result.(*entry)), nil
+}
+
+// Invalidate implements entity.ResponseCache. Successful writes drop cached
+// responses for the written aggregate and for its collection.
+func (c *ResponseCache) Invalidate(request entity.ServiceRequest) {
+	response := request.GetResponse()
+	if response != nil && response.GetResponseMeta() != nil {
+		status := response.GetResponseMeta().GetStatusCode()
+		if status != 0 && (status < 200 || status > 299) {
+			return
+		}
+	}
+
+	resource := resourceOf(request)
+	collection := collectionOf(request)
+
+	c.store.removeWhere(func(e *entry) bool {
+		return e.resource == resource || e.resource == collection
+	})
+}
+
+// credentialHeaders are the request headers which carry credentials the
+// target may answer differently for.
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// isCacheable reports whether the response to a request may be shared.
+// Requests carrying credentials are only cached when their header is one
+// of the vary headers, so that users never see each other's responses.
+// Callers authenticated by the inbound tasks are told apart by the key.
+func (c *ResponseCache) isCacheable(request entity.ServiceRequest) bool {
+	if !entity.IsSafeMethod(request.GetMethod()) {
+		return false
+	}
+
+	header := request.GetHeader()
+	if hasDirective(header, "no-store") {
+		return false
+	}
+
+	for _, credentials := range credentialHeaders {
+		if header.Get(credentials) != "" && !c.variesOn(credentials) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *ResponseCache) variesOn(header string) bool {
+	for _, vary := range c.varyHeaders {
+		if vary == header {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ResponseCache) key(request entity.ServiceRequest) string {
+	var key strings.Builder
+	key.WriteString(request.GetMethod().String())
+	key.WriteString(" ")
+	key.WriteString(strings.ToLower(request.GetInternalPath()))
+	if u := request.GetURL(); u != nil && u.RawQuery != "" {
+		key.WriteString("?")
+		key.WriteString(u.RawQuery)
+	}
+
+	// responses are never shared between the principals authenticated by
+	// the inbound tasks
+	key.WriteString("\nprincipal:")
+	key.WriteString(request.GetClaims().Subject())
+
+	header := request.GetHeader()
+	for _, vary := range c.varyHeaders {
+		key.WriteString("\n")
+		key.WriteString(vary)
+		key.WriteString(":")
+		key.WriteString(strings.Join(header.Values(vary), ","))
+	}
+
+	return key.String()
+}
+
+// newEntry snapshots a response.
+func (c *ResponseCache) newEntry(request entity.ServiceRequest, response entity.ServiceResponse) *entry {
+	e := &entry{
+		body:     response.GetBody(),
+		resource: resourceOf(request),
+		expires:  c.store.now().Add(c.ttl),
+		header:   make(http.Header),
+	}
+
+	if meta := response.GetResponseMeta(); meta != nil {
+		e.statusCode = meta.GetStatusCode()
+		e.status = meta.GetStatus()
+		if meta.GetHeader() != nil {
+			e.header = meta.GetHeader().Clone()
+		}
+	}
+
+	if e.statusCode == 0 {
+		e.statusCode = http.StatusOK
+	}
+
+	// the response header may be a copy of the request headers
+	e.header.Del("Content-Length")
+
+	return e
+}
+
+// addValidators adds an ETag and Cache-Control header to an entry which is
+// stored, when the target did not supply them. Responses which are not
+// stored are left as the target returned them.
+func (c *ResponseCache) addValidators(e *entry) {
+	if e.header.Get("ETag") == "" {
+		sum := sha256.Sum256(e.body)
+		// weak, since the body may be re-encoded per the Accept header
+		e.header.Set("ETag", fmt.Sprintf(`W/"%s"`, hex.EncodeToString(sum[:16])))
+	}
+	if e.header.Get("Cache-Control") == "" {
+		e.header.Set("Cache-Control", fmt.Sprintf("max-age=%d", int(c.ttl.Seconds())))
+	}
+}
+
+// respond builds a response for a request from an entry, answering with
+// 304 Not Modified when the client already holds the current version.
+func (c *ResponseCache) respond(request entity.ServiceRequest, e *entry) entity.ServiceResponse {
+	header := e.header.Clone()
+
+	if etag := header.Get("ETag"); etag != "" && matchesETag(request.GetHeader().Get("If-None-Match"), etag) {
+		return &entity.HttpServiceResponse{
+			ResponseMeta: &entity.HttpResponseMeta{
+				StatusCode: http.StatusNotModified,
+				Status:     http.StatusText(http.StatusNotModified),
+				Header:     header,
+			},
+		}
+	}
+
+	body := make([]byte, len(e.body))
+	copy(body, e.body)
+
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{
+			StatusCode: e.statusCode,
+			Status:     e.status,
+			Header:     header,
+		},
+		Body: body,
+	}
+}
+
+// isStorable reports whether an entry may be kept for later requests.
+func isStorable(e *entry) bool {
+	if e.statusCode != http.StatusOK {
+		return false
+	}
+	return !hasDirective(e.header, "no-store") &&
+		!hasDirective(e.header, "private") &&
+		!hasDirective(e.header, "no-cache")
+}
+
+func hasDirective(header http.Header, directive string) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if strings.EqualFold(name, directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesETag compares an If-None-Match header to an ETag using weak comparison.
+func matchesETag(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceOf returns the api/service/id prefix identifying the aggregate a
+// request refers to, or the collection when no ID is present.
+func resourceOf(request entity.ServiceRequest) string {
+	segments := strings.Split(strings.Trim(strings.ToLower(request.GetInternalPath()), "/"), "/")
+	if len(segments) > 3 {
+		segments = segments[:3]
+	}
+	return strings.Join(segments, "/")
+}
+
+func collectionOf(request entity.ServiceRequest) string {
+	segments := strings.Split(strings.Trim(strings.ToLower(request.GetInternalPath()), "/"), "/")
+	if len(segments) > 2 {
+		segments = segments[:2]
+	}
+	return strings.Join(segments, "/")
+}
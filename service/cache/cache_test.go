@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequest(method entity.HTTPMethod, path string, header http.Header) *entity.HTTPServiceRequest {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &entity.HTTPServiceRequest{
+		Method:       method,
+		URL:          &url.URL{Path: path},
+		InternalPath: path,
+		Header:       header,
+	}
+}
+
+func countingNext(calls *int32, body string) func(context.Context) (entity.ServiceResponse, error) {
+	return func(context.Context) (entity.ServiceResponse, error) {
+		atomic.AddInt32(calls, 1)
+		return &entity.HttpServiceResponse{
+			ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK, Header: http.Header{}},
+			Body:         []byte(body),
+		}, nil
+	}
+}
+
+func TestFetchCachesResponses(t *testing.T) {
+	c := New(Config{TTL: time.Minute})
+	var calls int32
+
+	first, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", nil), countingNext(&calls, "soup"))
+	require.NoError(t, err)
+	second, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", nil), countingNext(&calls, "stew"))
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), calls)
+	assert.Equal(t, "soup", string(second.GetBody()))
+	assert.Equal(t, first.GetResponseMeta().GetHeader().Get("ETag"), second.GetResponseMeta().GetHeader().Get("ETag"))
+	assert.Equal(t, "max-age=60", second.GetResponseMeta().GetHeader().Get("Cache-Control"))
+}
+
+func TestFetchCoalescesConcurrentRequests(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+	release := make(chan struct{})
+
+	next := func(context.Context) (entity.ServiceResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &entity.HttpServiceResponse{
+			ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK},
+			Body:         []byte("soup"),
+		}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", nil), next)
+			assert.NoError(t, err)
+			assert.Equal(t, "soup", string(response.GetBody()))
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestFetchNotModified(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+
+	first, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", nil), countingNext(&calls, "soup"))
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("If-None-Match", first.GetResponseMeta().GetHeader().Get("ETag"))
+
+	second, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", header), countingNext(&calls, "soup"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, second.GetResponseMeta().GetStatusCode())
+	assert.Empty(t, second.GetBody())
+}
+
+func TestInvalidateOnWrite(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+
+	for _, path := range []string{"/app/recipe/1", "/app/recipe/2", "/app/recipe"} {
+		_, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, path, nil), countingNext(&calls, "soup"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, c.store.len())
+
+	write := newRequest(entity.HTTPMethodPUT, "/app/recipe/1", nil)
+	write.SetResponse(&entity.HttpServiceResponse{ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK}})
+	c.Invalidate(write)
+
+	// the written recipe and the collection are dropped, other recipes are kept
+	assert.Equal(t, 1, c.store.len())
+	_, ok := c.store.get(c.key(newRequest(entity.HTTPMethodGET, "/app/recipe/2", nil)))
+	assert.True(t, ok)
+}
+
+func TestAuthorizedRequestsBypassCache(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", header), countingNext(&calls, "soup"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), calls)
+
+	varying := New(Config{VaryHeaders: []string{"authorization"}})
+	calls = 0
+	for i := 0; i < 2; i++ {
+		_, err := varying.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", header), countingNext(&calls, "soup"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestCookieRequestsBypassCache(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+
+	header := http.Header{}
+	header.Set("Cookie", "session=abc")
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", header), countingNext(&calls, "soup"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestPrincipalsDoNotShareResponses(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+
+	for _, subject := range []string{"ada", "grace", "ada"} {
+		request := newRequest(entity.HTTPMethodGET, "/app/recipe/1", nil)
+		request.SetClaims(entity.Claims{"sub": subject})
+		_, err := c.Fetch(context.Background(), request, countingNext(&calls, subject))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestUnstoredResponsesAreNotDecorated(t *testing.T) {
+	c := New(Config{})
+	var calls int32
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+	response, err := c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/1", header), countingNext(&calls, "soup"))
+	require.NoError(t, err)
+	assert.Empty(t, response.GetResponseMeta().GetHeader().Get("ETag"))
+	assert.Empty(t, response.GetResponseMeta().GetHeader().Get("Cache-Control"))
+
+	notFound := func(context.Context) (entity.ServiceResponse, error) {
+		return &entity.HttpServiceResponse{
+			ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusNotFound, Header: http.Header{}},
+		}, nil
+	}
+	response, err = c.Fetch(context.Background(), newRequest(entity.HTTPMethodGET, "/app/recipe/2", nil), notFound)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.GetResponseMeta().GetStatusCode())
+	assert.Empty(t, response.GetResponseMeta().GetHeader().Get("ETag"))
+	assert.Empty(t, response.GetResponseMeta().GetHeader().Get("Cache-Control"))
+}
+
+func TestStoreExpiryAndEviction(t *testing.T) {
+	s := newStore(2)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.add("a", &entry{expires: now.Add(time.Second)})
+	s.add("b", &entry{expires: now.Add(time.Minute)})
+	s.add("c", &entry{expires: now.Add(time.Minute)})
+
+	_, ok := s.get("a")
+	assert.False(t, ok, "least recently used entry should be evicted")
+
+	now = now.Add(2 * time.Minute)
+	_, ok = s.get("b")
+	assert.False(t, ok, "expired entry should not be returned")
+}
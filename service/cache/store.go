@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// store is a size-bounded LRU of cache entries with per-entry expiry.
+type store struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type storeItem struct {
+	key   string
+	entry *entry
+}
+
+func newStore(maxEntries int) *store {
+	return &store{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// get returns the entry for key if it exists and has not expired.
+func (s *store) get(key string) (*entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := element.Value.(*storeItem)
+	if !s.now().Before(item.entry.expires) {
+		s.removeElement(element)
+		return nil, false
+	}
+
+	s.order.MoveToFront(element)
+	return item.entry, true
+}
+
+// add stores an entry, evicting the least recently used entry when full.
+func (s *store) add(key string, e *entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.items[key]; ok {
+		element.Value.(*storeItem).entry = e
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.items[key] = s.order.PushFront(&storeItem{key: key, entry: e})
+
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.removeElement(s.order.Back())
+	}
+}
+
+// removeWhere drops every entry matching the predicate.
+func (s *store) removeWhere(match func(*entry) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for element := s.order.Front(); element != nil; {
+		next := element.Next()
+		if match(element.Value.(*storeItem).entry) {
+			s.removeElement(element)
+		}
+		element = next
+	}
+}
+
+func (s *store) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
+
+func (s *store) removeElement(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.items, element.Value.(*storeItem).key)
+}
@@ -0,0 +1,13 @@
+// Package codec provides the built-in wire formats supported by the hub in
+// addition to JSON. Bodies in these formats are converted to JSON before
+// they reach tasks and targets, and responses are converted back according
+// to the client's Accept header.
+package codec
+
+import "github.com/QueerGlobal/hub-framework/core/entity"
+
+var (
+	_ entity.Codec = YAML{}
+	_ entity.Codec = MessagePack{}
+	_ entity.Codec = Form{}
+)
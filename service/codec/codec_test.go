@@ -0,0 +1,99 @@
+package codec_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerCodecs() {
+	entity.RegisterCodec(codec.YAML{})
+	entity.RegisterCodec(codec.MessagePack{})
+	entity.RegisterCodec(codec.Form{})
+}
+
+func TestDecodeBodyToJSON(t *testing.T) {
+	registerCodecs()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		expected    string
+	}{
+		{"json is unchanged", "application/json; charset=utf-8", []byte(`{"name":"soup"}`), `{"name":"soup"}`},
+		{"yaml", "application/yaml", []byte("name: soup\nservings: 4\n"), `{"name":"soup","servings":4}`},
+		{"form", "application/x-www-form-urlencoded", []byte("name=soup&tag=hot&tag=quick"), `{"name":"soup","tag":["hot","quick"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := entity.DecodeBody(tt.contentType, tt.body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(decoded))
+		})
+	}
+}
+
+func TestDecodeBodyUnsupportedMediaType(t *testing.T) {
+	_, err := entity.DecodeBody("text/csv", []byte("a,b"))
+	assert.ErrorIs(t, err, domainerr.ErrUnsupportedMediaType)
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	registerCodecs()
+
+	encoded, err := entity.EncodeBody(codec.MessagePack{}, []byte(`{"name":"soup","servings":4}`))
+	require.NoError(t, err)
+
+	decoded, err := entity.DecodeBody("application/msgpack", encoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"soup","servings":4}`, string(decoded))
+}
+
+func TestFormRejectsNestedObjects(t *testing.T) {
+	_, err := entity.EncodeBody(codec.Form{}, []byte(`{"chef":{"name":"sam"}}`))
+	assert.ErrorIs(t, err, codec.ErrUnsupportedFormValue)
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	registerCodecs()
+
+	selected, err := entity.NegotiateCodec("text/html;q=0.9, application/yaml")
+	require.NoError(t, err)
+	assert.IsType(t, codec.YAML{}, selected)
+
+	selected, err = entity.NegotiateCodec("application/msgpack;q=0.5, application/json;q=0.8")
+	require.NoError(t, err)
+	assert.IsType(t, entity.JSONCodec{}, selected)
+
+	selected, err = entity.NegotiateCodec("*/*")
+	require.NoError(t, err)
+	assert.Nil(t, selected)
+
+	_, err = entity.NegotiateCodec("text/html")
+	assert.ErrorIs(t, err, domainerr.ErrNotAcceptable)
+}
+
+func TestRegisterCodecWhileNegotiating(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			registerCodecs()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, _ = entity.NegotiateCodec("application/yaml, application/json;q=0.5")
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// ErrUnsupportedFormValue is returned when a value cannot be represented in
+// a url-encoded form, such as a nested object.
+var ErrUnsupportedFormValue = errors.New("value cannot be encoded as a form field")
+
+// Form encodes and decodes application/x-www-form-urlencoded bodies. Fields
+// with a single value decode to strings and repeated fields to lists.
+type Form struct{}
+
+func (Form) MediaTypes() []string {
+	return []string{"application/x-www-form-urlencoded"}
+}
+
+func (Form) Unmarshal(data []byte) (any, error) {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any, len(values))
+	for key, vals := range values {
+		if len(vals) == 1 {
+			fields[key] = vals[0]
+			continue
+		}
+
+		list := make([]any, len(vals))
+		for i, val := range vals {
+			list[i] = val
+		}
+		fields[key] = list
+	}
+
+	return fields, nil
+}
+
+func (Form) Marshal(v any) ([]byte, error) {
+	fields, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: body must be an object", ErrUnsupportedFormValue)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, key := range keys {
+		switch val := fields[key].(type) {
+		case []any:
+			for _, item := range val {
+				s, err := formScalar(item)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", key, err)
+				}
+				values.Add(key, s)
+			}
+		default:
+			s, err := formScalar(val)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", key, err)
+			}
+			values.Add(key, s)
+		}
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+func formScalar(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	default:
+		return "", ErrUnsupportedFormValue
+	}
+}
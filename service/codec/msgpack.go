@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePack encodes and decodes MessagePack bodies.
+type MessagePack struct{}
+
+func (MessagePack) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (MessagePack) Unmarshal(data []byte) (any, error) {
+	var value any
+	if err := msgpack.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (MessagePack) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
@@ -0,0 +1,47 @@
+package codec
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// YAML encodes and decodes YAML bodies.
+type YAML struct{}
+
+func (YAML) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"}
+}
+
+func (YAML) Unmarshal(data []byte) (any, error) {
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return normalizeYAML(value), nil
+}
+
+func (YAML) Marshal(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by
+// yaml.v2 into map[string]any so that they can be marshaled as JSON.
+func normalizeYAML(value any) any {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]any, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYAML(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,161 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// aggregateID is the ID of the aggregate the documents of the tests belong to.
+const aggregateID = "1b4e28ba-2fa1-11d2-883f-0016d3cca427"
+
+func testKeyring(t *testing.T, primary string) *Keyring {
+	keyring, err := NewKeyring(primary, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	require.NoError(t, err)
+	return keyring
+}
+
+const personSchema = `{
+  "type": "object",
+  "properties": {
+    "name": {"type": "string"},
+    "ssn": {"type": "string", "x-hub-encrypted": true},
+    "address": {"$ref": "/person/address", "x-hub-encrypted": true},
+    "contacts": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "email": {"type": "string", "x-hub-encrypted": true},
+          "kind": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+func decode(t *testing.T, document string) map[string]any {
+	var m map[string]any
+	require.NoError(t, json.Unmarshal([]byte(document), &m))
+	return m
+}
+
+func TestEncryptedFields(t *testing.T) {
+	paths, err := EncryptedFields([]byte(personSchema))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"address", "contacts.*.email", "ssn"}, paths)
+}
+
+func TestFieldEncryptorRoundTrip(t *testing.T) {
+	paths, err := EncryptedFields([]byte(personSchema))
+	require.NoError(t, err)
+	encryptor := NewFieldEncryptor(testKeyring(t, "k1"), paths)
+
+	original := `{
+		"name": "Ada",
+		"ssn": "123-45-6789",
+		"address": {"locality": "London", "countryName": "UK"},
+		"contacts": [{"email": "ada@example.com", "kind": "work"}, {"kind": "none"}]
+	}`
+	document := decode(t, original)
+
+	require.NoError(t, encryptor.Encrypt(aggregateID, document))
+
+	encoded, err := json.Marshal(document)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "123-45-6789")
+	assert.NotContains(t, string(encoded), "London")
+	assert.NotContains(t, string(encoded), "ada@example.com")
+	assert.Contains(t, string(encoded), `"name":"Ada"`)
+	assert.True(t, strings.HasPrefix(document["ssn"].(string), "hubenc:v1:k1:"))
+
+	require.NoError(t, encryptor.Decrypt(aggregateID, document))
+	assert.Equal(t, decode(t, original), document)
+}
+
+func TestFieldEncryptorBindsPath(t *testing.T) {
+	encryptor := NewFieldEncryptor(testKeyring(t, "k1"), []string{"a", "b"})
+
+	document := map[string]any{"a": "secret", "b": "other"}
+	require.NoError(t, encryptor.Encrypt(aggregateID, document))
+
+	document["a"], document["b"] = document["b"], document["a"]
+	assert.ErrorIs(t, encryptor.Decrypt(aggregateID, document), ErrDecryptionFailed)
+}
+
+func TestFieldEncryptorBindsAggregate(t *testing.T) {
+	encryptor := NewFieldEncryptor(testKeyring(t, "k1"), []string{"ssn"})
+
+	document := map[string]any{"ssn": "123-45-6789"}
+	require.NoError(t, encryptor.Encrypt(aggregateID, document))
+
+	// an envelope copied into another aggregate does not decrypt there
+	assert.ErrorIs(t, encryptor.Decrypt("6f1c2a9e-0000-4000-8000-000000000000", document), ErrDecryptionFailed)
+}
+
+func TestFieldEncryptorRejectsEnvelopes(t *testing.T) {
+	encryptor := NewFieldEncryptor(testKeyring(t, "k1"), []string{"ssn"})
+
+	stored := map[string]any{"ssn": "123-45-6789"}
+	require.NoError(t, encryptor.Encrypt(aggregateID, stored))
+
+	submitted := map[string]any{"ssn": stored["ssn"]}
+	assert.ErrorIs(t, encryptor.Encrypt(aggregateID, submitted), ErrEnvelopeInput)
+	assert.ErrorIs(t, encryptor.Encrypt(aggregateID, map[string]any{"ssn": "hubenc:v1:forged"}), ErrEnvelopeInput)
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := NewFieldEncryptor(testKeyring(t, "k1"), []string{"ssn"})
+	rotated := NewFieldEncryptor(testKeyring(t, "k2"), []string{"ssn"})
+
+	document := map[string]any{"ssn": "123-45-6789"}
+	assert.True(t, old.NeedsReencryption(document), "plaintext needs encryption")
+
+	require.NoError(t, old.Encrypt(aggregateID, document))
+	assert.False(t, old.NeedsReencryption(document))
+	assert.True(t, rotated.NeedsReencryption(document))
+
+	require.NoError(t, rotated.Decrypt(aggregateID, document))
+	require.NoError(t, rotated.Encrypt(aggregateID, document))
+	assert.False(t, rotated.NeedsReencryption(document))
+
+	keyID, err := KeyID(document["ssn"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyID)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+primary: k2
+keys:
+  - id: k1
+    key: AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+  - id: k2
+    key: AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=
+`), 0o600))
+
+	keyring, err := LoadKeyring(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyring.PrimaryKeyID())
+
+	envelope, err := testKeyring(t, "k1").Encrypt([]byte("value"), nil)
+	require.NoError(t, err)
+	plaintext, err := keyring.Decrypt(envelope, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(plaintext))
+
+	_, err = NewKeyring("missing", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	assert.ErrorIs(t, err, ErrNoPrimaryKey)
+	_, err = NewKeyring("k1", map[string][]byte{"k1": []byte("short")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package encryption
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// EncryptedKeyword marks a schema property whose value is encrypted at rest.
+const EncryptedKeyword = "x-hub-encrypted"
+
+// EncryptedFields returns the paths of the properties of a JSON schema
+// marked with x-hub-encrypted: true. Properties defined through $ref are
+// not followed, so the keyword must be placed next to the $ref to encrypt a
+// referenced object as a whole.
+func EncryptedFields(schema []byte) ([]string, error) {
+	return entity.SchemaFieldsWithKeyword(schema, EncryptedKeyword)
+}
+
+// FieldEncryptor encrypts and decrypts the fields at the given paths of
+// decoded JSON documents. Each value is encoded as JSON before encryption,
+// so fields of any type, including whole objects, can be encrypted. The
+// aggregate ID and field path are bound to the ciphertext so values cannot
+// be swapped between fields or aggregates.
+type FieldEncryptor struct {
+	keyring *Keyring
+	paths   [][]string
+}
+
+// NewFieldEncryptor creates a FieldEncryptor for the given field paths.
+func NewFieldEncryptor(keyring *Keyring, paths []string) *FieldEncryptor {
+	e := &FieldEncryptor{keyring: keyring}
+	for _, path := range paths {
+		e.paths = append(e.paths, strings.Split(path, "."))
+	}
+	return e
+}
+
+// Encrypt replaces the plaintext values of the encrypted fields of the
+// document of an aggregate with encrypted envelopes. Values which look like
+// envelopes are rejected, since they would be stored as given and decrypted
+// on read as if the hub had encrypted them.
+func (e *FieldEncryptor) Encrypt(aggregateID string, document map[string]any) error {
+	return e.visit(document, func(path string, value any) (any, error) {
+		if s, ok := value.(string); ok && IsEncrypted(s) {
+			return nil, fmt.Errorf("%w: %s", ErrEnvelopeInput, path)
+		}
+
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return e.keyring.Encrypt(plaintext, associatedData(aggregateID, path))
+	})
+}
+
+// Decrypt replaces the encrypted envelopes in the document of an aggregate
+// with their values.
+func (e *FieldEncryptor) Decrypt(aggregateID string, document map[string]any) error {
+	return e.visit(document, func(path string, value any) (any, error) {
+		s, ok := value.(string)
+		if !ok || !IsEncrypted(s) {
+			return value, nil
+		}
+
+		plaintext, err := e.keyring.Decrypt(s, associatedData(aggregateID, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+
+		var decrypted any
+		if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+			return nil, err
+		}
+		return decrypted, nil
+	})
+}
+
+// NeedsReencryption reports whether any field of document is stored in
+// plaintext or encrypted with a key other than the primary key.
+func (e *FieldEncryptor) NeedsReencryption(document map[string]any) bool {
+	stale := false
+	e.visit(document, func(path string, value any) (any, error) {
+		s, ok := value.(string)
+		if !ok || !IsEncrypted(s) {
+			stale = true
+			return value, nil
+		}
+		if keyID, err := KeyID(s); err != nil || keyID != e.keyring.PrimaryKeyID() {
+			stale = true
+		}
+		return value, nil
+	})
+	return stale
+}
+
+// associatedData binds an envelope to the aggregate and field it is stored
+// in. Aggregate IDs are UUIDs, which cannot contain the NUL separator.
+func associatedData(aggregateID string, path string) []byte {
+	return []byte(aggregateID + "\x00" + path)
+}
+
+// visit calls fn for every present field matching one of the paths and
+// replaces the field with the value returned. The path given to fn is the
+// schema path, so array items share the path of their array.
+func (e *FieldEncryptor) visit(document map[string]any, fn func(path string, value any) (any, error)) error {
+	for _, path := range e.paths {
+		schemaPath := strings.Join(path, ".")
+		err := visitPath(document, path, func(value any) (any, error) {
+			return fn(schemaPath, value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func visitPath(node any, path []string, fn func(any) (any, error)) error {
+	if path[0] == "*" {
+		items, ok := node.([]any)
+		if !ok {
+			return nil
+		}
+		for i := range items {
+			if len(path) > 1 {
+				if err := visitPath(items[i], path[1:], fn); err != nil {
+					return err
+				}
+				continue
+			}
+
+			value, err := fn(items[i])
+			if err != nil {
+				return err
+			}
+			items[i] = value
+		}
+		return nil
+	}
+
+	object, ok := node.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	child, ok := object[path[0]]
+	if !ok || child == nil {
+		return nil
+	}
+
+	if len(path) > 1 {
+		return visitPath(child, path[1:], fn)
+	}
+
+	value, err := fn(child)
+	if err != nil {
+		return err
+	}
+	object[path[0]] = value
+
+	return nil
+}
+
+// ForSchema creates a FieldEncryptor for the fields marked as encrypted in a
+// registered schema.
+func ForSchema(keyring *Keyring, name string, version string) (*FieldEncryptor, error) {
+	schema, ok := entity.GetSchema(name, version)
+	if !ok {
+		return nil, fmt.Errorf("schema %s:%s is not registered", name, version)
+	}
+
+	paths, err := EncryptedFields(schema.Data)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s:%s: %w", name, version, err)
+	}
+
+	return NewFieldEncryptor(keyring, paths), nil
+}
@@ -0,0 +1,181 @@
+// Package encryption encrypts individual fields of aggregates at rest with
+// AES-GCM. Fields to encrypt are marked in JSON schemas with the
+// x-hub-encrypted keyword, and keys are read from a local keyring file in
+// which every key has an ID, so that keys can be rotated.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envelopePrefix starts every encrypted value. The full format is
+// hubenc:v1:<key id>:<base64url nonce and ciphertext>.
+const envelopePrefix = "hubenc:v1:"
+
+var (
+	ErrUnknownKey       = errors.New("unknown encryption key")
+	ErrInvalidEnvelope  = errors.New("invalid encrypted value")
+	ErrNoPrimaryKey     = errors.New("keyring has no primary key")
+	ErrDecryptionFailed = errors.New("decryption failed")
+	ErrEnvelopeInput    = errors.New("value to encrypt is already an encrypted envelope")
+)
+
+// KeyringFile is the format of a keyring file, in YAML or JSON. Keys are
+// base64 encoded 256 bit AES keys. New values are encrypted with the
+// primary key; the other keys are kept to decrypt older values.
+//
+//	primary: k2
+//	keys:
+//	  - id: k1
+//	    key: <base64>
+//	  - id: k2
+//	    key: <base64>
+type KeyringFile struct {
+	Primary string `yaml:"primary" json:"primary"`
+	Keys    []struct {
+		ID  string `yaml:"id" json:"id"`
+		Key string `yaml:"key" json:"key"`
+	} `yaml:"keys" json:"keys"`
+}
+
+// Keyring holds the keys used to encrypt and decrypt fields.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// LoadKeyring reads a keyring file.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring %s: %w", path, err)
+	}
+
+	// JSON is a subset of YAML, so both formats are handled by yaml.v2
+	var file KeyringFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for _, k := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s in keyring %s: %w", k.ID, path, err)
+		}
+		keys[k.ID] = key
+	}
+
+	return NewKeyring(file.Primary, keys)
+}
+
+// NewKeyring creates a keyring from raw keys, which must be 16, 24 or 32
+// bytes long.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	keyring := &Keyring{
+		primary: primary,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		keyring.keys[id] = aead
+	}
+
+	if _, ok := keyring.keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: %q is not in the keyring", ErrNoPrimaryKey, primary)
+	}
+
+	return keyring, nil
+}
+
+// PrimaryKeyID returns the ID of the key used for new values.
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Encrypt seals plaintext with the primary key. The associated data, such as
+// the field path, must be given again to decrypt the value.
+func (k *Keyring) Encrypt(plaintext []byte, associatedData []byte) (string, error) {
+	aead := k.keys[k.primary]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, associatedData)
+
+	return envelopePrefix + k.primary + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt.
+func (k *Keyring) Decrypt(envelope string, associatedData []byte) ([]byte, error) {
+	keyID, payload, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], associatedData)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether a value is an encrypted envelope.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// KeyID returns the ID of the key an envelope was encrypted with.
+func KeyID(envelope string) (string, error) {
+	keyID, _, err := parseEnvelope(envelope)
+	return keyID, err
+}
+
+func parseEnvelope(envelope string) (string, string, error) {
+	if !IsEncrypted(envelope) {
+		return "", "", ErrInvalidEnvelope
+	}
+
+	keyID, payload, ok := strings.Cut(strings.TrimPrefix(envelope, envelopePrefix), ":")
+	if !ok || keyID == "" || payload == "" {
+		return "", "", ErrInvalidEnvelope
+	}
+
+	return keyID, payload, nil
+}
@@ -0,0 +1,183 @@
+// Package idempotency replays the response to the first request made with
+// an Idempotency-Key when a client retries it, so that retried POST and
+// PATCH requests do not create duplicates. Responses are kept in Badger for
+// a configurable time.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// DefaultTTL is used when no TTL is configured.
+const DefaultTTL = 24 * time.Hour
+
+// MaxKeyLength is the longest Idempotency-Key accepted.
+const MaxKeyLength = 255
+
+// ReplayedHeader is set on responses replayed from an earlier request.
+const ReplayedHeader = "Idempotent-Replayed"
+
+var ErrInvalidKey = fmt.Errorf("%s must be between 1 and %d characters", entity.IdempotencyKeyHeader, MaxKeyLength)
+
+// Config describes how long responses are kept.
+type Config struct {
+	TTL time.Duration
+}
+
+// Idempotency implements entity.Idempotency.
+type Idempotency struct {
+	ttl   time.Duration
+	store Store
+
+	mu       sync.Mutex
+	inFlight map[string]*call
+}
+
+var _ entity.Idempotency = (*Idempotency)(nil)
+
+// call is a request in flight, which retries wait for.
+type call struct {
+	done chan struct{}
+}
+
+// New creates an Idempotency keeping responses in store.
+func New(config Config, store Store) *Idempotency {
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+
+	return &Idempotency{
+		ttl:      config.TTL,
+		store:    store,
+		inFlight: make(map[string]*call),
+	}
+}
+
+// Config returns the config of i, with defaults applied.
+func (i *Idempotency) Config() Config {
+	return Config{TTL: i.ttl}
+}
+
+// Store returns the store responses are kept in.
+func (i *Idempotency) Store() Store {
+	return i.store
+}
+
+// Do implements entity.Idempotency. Keys are scoped to the principal,
+// method and path, and a key reused with a different body is rejected with
+// ErrIdempotencyKeyReused. Errors and 5xx responses are not stored, so that
+// the request can be retried.
+func (i *Idempotency) Do(
+	ctx context.Context,
+	request entity.ServiceRequest,
+	next func(ctx context.Context) (entity.ServiceResponse, error)) (entity.ServiceResponse, error) {
+
+	key, err := storageKey(request)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := fingerprintOf(request.GetBody())
+
+	for {
+		stored, err := i.store.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			if stored.Fingerprint != fingerprint {
+				return nil, domainerr.NewHTTPError(http.StatusUnprocessableEntity, domainerr.ErrIdempotencyKeyReused)
+			}
+			return stored.response(), nil
+		}
+
+		i.mu.Lock()
+		if c, ok := i.inFlight[key]; ok {
+			i.mu.Unlock()
+
+			// wait for the first request, then look for its response again
+			select {
+			case <-c.done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		c := &call{done: make(chan struct{})}
+		i.inFlight[key] = c
+		i.mu.Unlock()
+
+		return i.run(ctx, key, fingerprint, c, next)
+	}
+}
+
+func (i *Idempotency) run(
+	ctx context.Context,
+	key string,
+	fingerprint string,
+	c *call,
+	next func(ctx context.Context) (entity.ServiceResponse, error)) (entity.ServiceResponse, error) {
+
+	defer func() {
+		i.mu.Lock()
+		delete(i.inFlight, key)
+		i.mu.Unlock()
+		close(c.done)
+	}()
+
+	response, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := newStoredResponse(fingerprint, response)
+	if stored.StatusCode >= 500 {
+		return response, nil
+	}
+
+	// the target has made its change, so failing the request would have
+	// the client retry it; the response is returned, though a retry will
+	// not be answered with it
+	if err := i.store.Put(key, stored, i.ttl); err != nil {
+		logging.GetLogger().Error().Err(err).Str("key", key).Msg("failed to store idempotent response")
+	}
+
+	return response, nil
+}
+
+// storageKey scopes a key to the principal, method and path of a request,
+// so that clients cannot replay each other's responses.
+func storageKey(request entity.ServiceRequest) (string, error) {
+	key := request.GetHeader().Get(entity.IdempotencyKeyHeader)
+	if key == "" || len(key) > MaxKeyLength {
+		return "", domainerr.NewHTTPError(http.StatusBadRequest, ErrInvalidKey)
+	}
+
+	sum := sha256.New()
+	for _, part := range []string{
+		request.GetClaims().Subject(),
+		request.GetMethod().String(),
+		request.GetInternalPath(),
+		key,
+	} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func fingerprintOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,167 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIdempotency(t *testing.T) *Idempotency {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+	return New(Config{TTL: time.Hour}, store)
+}
+
+func keyedRequest(key string, subject string, body string) *entity.HTTPServiceRequest {
+	return &entity.HTTPServiceRequest{
+		Method:       entity.HTTPMethodPOST,
+		InternalPath: "/recipeApp/recipe",
+		Header:       http.Header{entity.IdempotencyKeyHeader: {key}},
+		Body:         []byte(body),
+		Claims:       entity.Claims{"sub": subject},
+	}
+}
+
+// created counts calls and answers 201 with the call number.
+func created(calls *atomic.Int32) func(ctx context.Context) (entity.ServiceResponse, error) {
+	return func(ctx context.Context) (entity.ServiceResponse, error) {
+		id := strconv.Itoa(int(calls.Add(1)))
+		return &entity.HttpServiceResponse{
+			ResponseMeta: &entity.HttpResponseMeta{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Location": {"/recipeApp/recipe/" + id}},
+			},
+			Body: []byte(`{"id":"` + id + `"}`),
+		}, nil
+	}
+}
+
+func TestReplay(t *testing.T) {
+	i := newTestIdempotency(t)
+	var calls atomic.Int32
+	ctx := context.Background()
+
+	first, err := i.Do(ctx, keyedRequest("k1", "ada", `{"name":"soup"}`), created(&calls))
+	require.NoError(t, err)
+	assert.Empty(t, first.GetResponseMeta().GetHeader().Get(ReplayedHeader))
+
+	retry, err := i.Do(ctx, keyedRequest("k1", "ada", `{"name":"soup"}`), created(&calls))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, http.StatusCreated, retry.GetResponseMeta().GetStatusCode())
+	assert.Equal(t, "/recipeApp/recipe/1", retry.GetResponseMeta().GetHeader().Get("Location"))
+	assert.Equal(t, "true", retry.GetResponseMeta().GetHeader().Get(ReplayedHeader))
+	assert.JSONEq(t, `{"id":"1"}`, string(retry.GetBody()))
+
+	// keys are scoped to the principal
+	_, err = i.Do(ctx, keyedRequest("k1", "grace", `{"name":"soup"}`), created(&calls))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestKeyReusedWithDifferentBody(t *testing.T) {
+	i := newTestIdempotency(t)
+	var calls atomic.Int32
+	ctx := context.Background()
+
+	_, err := i.Do(ctx, keyedRequest("k1", "ada", `{"name":"soup"}`), created(&calls))
+	require.NoError(t, err)
+
+	_, err = i.Do(ctx, keyedRequest("k1", "ada", `{"name":"stew"}`), created(&calls))
+	var httpErr *domainerr.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, httpErr.StatusCode)
+	assert.ErrorIs(t, err, domainerr.ErrIdempotencyKeyReused)
+	assert.Equal(t, int32(1), calls.Load())
+
+	_, err = i.Do(ctx, keyedRequest(string(make([]byte, MaxKeyLength+1)), "ada", `{}`), created(&calls))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestFailuresAreNotStored(t *testing.T) {
+	i := newTestIdempotency(t)
+	ctx := context.Background()
+	var calls atomic.Int32
+
+	failing := func(ctx context.Context) (entity.ServiceResponse, error) {
+		calls.Add(1)
+		return &entity.HttpServiceResponse{
+			ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusServiceUnavailable},
+		}, nil
+	}
+
+	_, err := i.Do(ctx, keyedRequest("k1", "ada", `{}`), failing)
+	require.NoError(t, err)
+	_, err = i.Do(ctx, keyedRequest("k1", "ada", `{}`), func(ctx context.Context) (entity.ServiceResponse, error) {
+		calls.Add(1)
+		return nil, errors.New("target down")
+	})
+	assert.Error(t, err)
+	_, err = i.Do(ctx, keyedRequest("k1", "ada", `{}`), created(&calls))
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestConcurrentDuplicatesWait(t *testing.T) {
+	i := newTestIdempotency(t)
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	slow := func(ctx context.Context) (entity.ServiceResponse, error) {
+		<-release
+		return created(&calls)(ctx)
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]entity.ServiceResponse, 5)
+	for n := range responses {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			response, err := i.Do(context.Background(), keyedRequest("k1", "ada", `{}`), slow)
+			assert.NoError(t, err)
+			responses[n] = response
+		}(n)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, response := range responses {
+		assert.JSONEq(t, `{"id":"1"}`, string(response.GetBody()))
+	}
+}
+
+// unwritableStore finds no responses and fails to store them.
+type unwritableStore struct{}
+
+func (unwritableStore) Get(key string) (*StoredResponse, error) {
+	return nil, nil
+}
+
+func (unwritableStore) Put(key string, response *StoredResponse, ttl time.Duration) error {
+	return errors.New("disk full")
+}
+
+func TestStoreFailureReturnsResponse(t *testing.T) {
+	i := New(Config{TTL: time.Hour}, unwritableStore{})
+	var calls atomic.Int32
+
+	// the change is made, so the client is not told to retry it
+	response, err := i.Do(context.Background(), keyedRequest("k1", "ada", `{}`), created(&calls))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.GetResponseMeta().GetStatusCode())
+}
@@ -0,0 +1,146 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/shared"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// DefaultPath is the Badger directory used when none is configured.
+const DefaultPath = "./data/idempotency"
+
+const keyPrefix = "idempotency/"
+
+// StoredResponse is the response to the first request made with a key,
+// along with the fingerprint of that request's body.
+type StoredResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	StatusCode  int         `json:"statusCode"`
+	Status      string      `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+func newStoredResponse(fingerprint string, response entity.ServiceResponse) *StoredResponse {
+	stored := &StoredResponse{
+		Fingerprint: fingerprint,
+		StatusCode:  http.StatusOK,
+		Header:      make(http.Header),
+		Body:        response.GetBody(),
+	}
+
+	if meta := response.GetResponseMeta(); meta != nil {
+		if meta.GetStatusCode() != 0 {
+			stored.StatusCode = meta.GetStatusCode()
+		}
+		stored.Status = meta.GetStatus()
+		if meta.GetHeader() != nil {
+			stored.Header = meta.GetHeader().Clone()
+		}
+	}
+
+	return stored
+}
+
+// response builds a replayed response.
+func (s *StoredResponse) response() entity.ServiceResponse {
+	header := s.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(ReplayedHeader, "true")
+
+	body := make([]byte, len(s.Body))
+	copy(body, s.Body)
+
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{
+			StatusCode: s.StatusCode,
+			Status:     s.Status,
+			Header:     header,
+		},
+		Body: body,
+	}
+}
+
+// Store keeps stored responses until they expire.
+type Store interface {
+	// Get returns the response stored for key, or nil if there is none.
+	Get(key string) (*StoredResponse, error)
+	Put(key string, response *StoredResponse, ttl time.Duration) error
+}
+
+// BadgerStore keeps responses in a Badger database, using Badger TTLs for
+// expiry.
+type BadgerStore struct {
+	db   *badger.DB
+	path string
+}
+
+var badgerStores = shared.NewRegistry[*BadgerStore]()
+
+// OpenBadgerStore opens the Badger database at path. Stores are shared by
+// path, so that services may share one directory.
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	return badgerStores.Open(path, func() (*BadgerStore, error) {
+		db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open idempotency store %s: %w", path, err)
+		}
+
+		return &BadgerStore{db: db, path: path}, nil
+	})
+}
+
+// Path returns the directory of the database of the store.
+func (s *BadgerStore) Path() string {
+	return s.path
+}
+
+// Close closes the database of the store.
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// Get implements Store.
+func (s *BadgerStore) Get(key string) (*StoredResponse, error) {
+	var stored *StoredResponse
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(keyPrefix + key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(value []byte) error {
+			stored = &StoredResponse{}
+			return json.Unmarshal(value, stored)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// Put implements Store.
+func (s *BadgerStore) Put(key string, response *StoredResponse, ttl time.Duration) error {
+	value, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(keyPrefix+key), value).WithTTL(ttl))
+	})
+}
@@ -14,7 +14,7 @@ var (
 
 func GetLogger() *zerolog.Logger {
 	once.Do(func() {
-		instance = zerolog.New(os.Stdout).With().Timestamp().Logger()
+		instance = zerolog.New(redactingWriter{w: os.Stdout}).With().Timestamp().Logger()
 	})
 	return &instance
 }
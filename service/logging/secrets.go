@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// SecretMask replaces registered secrets in log output.
+const SecretMask = "[SECRET]"
+
+var (
+	secretsMu sync.RWMutex
+	secrets   = map[string]struct{}{}
+)
+
+// RegisterSecret masks value wherever it appears in the output of the
+// logger returned by GetLogger, and in errors passed through RedactError.
+func RegisterSecret(value string) {
+	if value == "" {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	secrets[value] = struct{}{}
+
+	// the logger writes JSON, in which the secret may appear escaped
+	if quoted, err := json.Marshal(value); err == nil {
+		if escaped := string(quoted[1 : len(quoted)-1]); escaped != value {
+			secrets[escaped] = struct{}{}
+		}
+	}
+}
+
+// Redact replaces every registered secret in s with SecretMask.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for secret := range secrets {
+		s = strings.ReplaceAll(s, secret, SecretMask)
+	}
+	return s
+}
+
+// RedactError wraps err so that its message has the registered secrets
+// masked. errors.Is and errors.As still see err.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{err: err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactingWriter masks registered secrets in everything written to w.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	secretsMu.RLock()
+	empty := len(secrets) == 0
+	secretsMu.RUnlock()
+
+	if empty {
+		return r.w.Write(p)
+	}
+
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,33 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	RegisterSecret(`pa"ss-word-123`)
+
+	var out bytes.Buffer
+	logger := zerolog.New(redactingWriter{w: &out})
+	logger.Info().Str("dsn", `postgres://app:pa"ss-word-123@db`).Msg("connecting")
+
+	assert.NotContains(t, out.String(), "ss-word-123")
+	assert.Contains(t, out.String(), SecretMask)
+}
+
+func TestRedactError(t *testing.T) {
+	RegisterSecret("top-secret-token")
+
+	base := errors.New("base")
+	err := RedactError(fmt.Errorf("bad token top-secret-token: %w", base))
+
+	assert.Equal(t, "bad token "+SecretMask+": base", err.Error())
+	assert.ErrorIs(t, err, base)
+	assert.Nil(t, RedactError(nil))
+}
@@ -0,0 +1,176 @@
+// Package ratelimit provides token bucket and sliding window rate limiters
+// backed by a pluggable counter store, so that limits can be kept in memory
+// or persisted in Badger and shared between hub restarts.
+package ratelimit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Algorithm names accepted in Config.
+const (
+	TokenBucket   = "tokenBucket"
+	SlidingWindow = "slidingWindow"
+)
+
+// Config describes a limit of Limit requests per Period. Burst is the
+// capacity of a token bucket and defaults to Limit.
+type Config struct {
+	Algorithm string
+	Limit     int
+	Period    time.Duration
+	Burst     int
+}
+
+// Decision is the outcome of a call to Allow.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter applies a Config to the keys it is given.
+type Limiter struct {
+	config Config
+	store  Store
+	now    func() time.Time
+}
+
+// New creates a Limiter which keeps its counters in the given store.
+func New(config Config, store Store) (*Limiter, error) {
+	if config.Limit <= 0 {
+		return nil, fmt.Errorf("rate limit must be positive, got %d", config.Limit)
+	}
+	if config.Period <= 0 {
+		return nil, fmt.Errorf("rate limit period must be positive, got %s", config.Period)
+	}
+
+	switch {
+	case config.Algorithm == "" || strings.EqualFold(config.Algorithm, TokenBucket):
+		config.Algorithm = TokenBucket
+	case strings.EqualFold(config.Algorithm, SlidingWindow):
+		config.Algorithm = SlidingWindow
+	default:
+		return nil, fmt.Errorf("unsupported rate limit algorithm %s", config.Algorithm)
+	}
+
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+
+	return &Limiter{
+		config: config,
+		store:  store,
+		now:    time.Now,
+	}, nil
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+func (l *Limiter) Allow(key string) (Decision, error) {
+	var decision Decision
+
+	key = fmt.Sprintf("%s/%d/%s/%s", l.config.Algorithm, l.config.Limit, l.config.Period, key)
+
+	err := l.store.Update(key, 2*l.config.Period, func(state []byte) []byte {
+		if l.config.Algorithm == SlidingWindow {
+			decision, state = l.slidingWindow(state)
+		} else {
+			decision, state = l.tokenBucket(state)
+		}
+		return state
+	})
+
+	return decision, err
+}
+
+// tokenBucket refills Limit tokens per Period up to Burst, and takes one
+// token per request. The state holds the token count and the refill time.
+func (l *Limiter) tokenBucket(state []byte) (Decision, []byte) {
+	now := l.now()
+	capacity := float64(l.config.Burst)
+	rate := float64(l.config.Limit) / float64(l.config.Period)
+
+	tokens, updated := capacity, now
+	if values, ok := decodeState(state); ok {
+		tokens = math.Float64frombits(values[0])
+		updated = time.Unix(0, int64(values[1]))
+		tokens = math.Min(capacity, tokens+float64(now.Sub(updated))*rate)
+	}
+
+	decision := Decision{Limit: l.config.Burst}
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - tokens) / rate)
+	}
+	decision.Remaining = int(tokens)
+
+	return decision, encodeState(math.Float64bits(tokens), uint64(now.UnixNano()))
+}
+
+// slidingWindow approximates a sliding window by weighting the count of the
+// previous fixed window by how much of it still overlaps the sliding window.
+// The state holds the current window start and the previous and current counts.
+func (l *Limiter) slidingWindow(state []byte) (Decision, []byte) {
+	now := l.now()
+	period := l.config.Period
+	windowStart := now.Truncate(period)
+
+	var previous, current uint64
+	if values, ok := decodeState(state); ok {
+		start := time.Unix(0, int64(values[0]))
+		switch {
+		case start.Equal(windowStart):
+			previous, current = values[1], values[2]
+		case start.Add(period).Equal(windowStart):
+			previous = values[2]
+		}
+	}
+
+	overlap := 1 - float64(now.Sub(windowStart))/float64(period)
+	estimate := float64(previous)*overlap + float64(current)
+
+	decision := Decision{Limit: l.config.Limit}
+	if estimate+1 <= float64(l.config.Limit) {
+		current++
+		estimate++
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = windowStart.Add(period).Sub(now)
+		if previous > 0 && current < uint64(l.config.Limit) {
+			// wait until enough of the previous window has slid out
+			needed := 1 - float64(uint64(l.config.Limit)-current-1)/float64(previous)
+			if wait := time.Duration(needed*float64(period)) - now.Sub(windowStart); wait > 0 {
+				decision.RetryAfter = wait
+			}
+		}
+	}
+	decision.Remaining = int(math.Max(0, float64(l.config.Limit)-estimate))
+
+	return decision, encodeState(uint64(windowStart.UnixNano()), previous, current)
+}
+
+func encodeState(values ...uint64) []byte {
+	state := make([]byte, 8*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint64(state[i*8:], value)
+	}
+	return state
+}
+
+func decodeState(state []byte) ([3]uint64, bool) {
+	var values [3]uint64
+	if len(state) < 16 || len(state)%8 != 0 {
+		return values, false
+	}
+	for i := 0; i < len(state)/8 && i < len(values); i++ {
+		values[i] = binary.BigEndian.Uint64(state[i*8:])
+	}
+	return values, true
+}
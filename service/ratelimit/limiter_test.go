@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestLimiter(t *testing.T, config Config, store Store) (*Limiter, *clock) {
+	l, err := New(config, store)
+	require.NoError(t, err)
+
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l.now = c.Now
+	return l, c
+}
+
+func allowN(t *testing.T, l *Limiter, key string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		decision, err := l.Allow(key)
+		require.NoError(t, err)
+		if decision.Allowed {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, c := newTestLimiter(t, Config{Limit: 10, Period: time.Second, Burst: 5}, NewMemoryStore())
+
+	assert.Equal(t, 5, allowN(t, l, "client", 8), "burst capacity")
+
+	decision, err := l.Allow("client")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 100*time.Millisecond, decision.RetryAfter)
+
+	c.now = c.now.Add(300 * time.Millisecond)
+	assert.Equal(t, 3, allowN(t, l, "client", 5), "refilled tokens")
+
+	assert.Equal(t, 5, allowN(t, l, "other", 5), "keys are independent")
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l, c := newTestLimiter(t, Config{Algorithm: "slidingWindow", Limit: 10, Period: time.Minute}, NewMemoryStore())
+
+	assert.Equal(t, 10, allowN(t, l, "client", 12))
+
+	// a quarter into the next window, three quarters of the previous count remain
+	c.now = c.now.Add(75 * time.Second)
+	assert.Equal(t, 2, allowN(t, l, "client", 5))
+
+	decision, err := l.Allow("client")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+	assert.Equal(t, 3*time.Second, decision.RetryAfter)
+
+	c.now = c.now.Add(decision.RetryAfter)
+	assert.Equal(t, 1, allowN(t, l, "client", 2))
+
+	// after two full periods nothing of the earlier windows remains
+	c.now = c.now.Add(2 * time.Minute)
+	assert.Equal(t, 10, allowN(t, l, "client", 10))
+}
+
+func TestBadgerStore(t *testing.T) {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+
+	l, _ := newTestLimiter(t, Config{Limit: 3, Period: time.Minute}, store)
+	assert.Equal(t, 3, allowN(t, l, "client", 5))
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Limit: 0, Period: time.Second},
+		{Limit: 1},
+		{Limit: 1, Period: time.Second, Algorithm: "leakyBucket"},
+	} {
+		_, err := New(config, NewMemoryStore())
+		assert.Error(t, err, "%+v", config)
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/service/shared"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Store holds limiter state. Update must apply fn atomically for a key,
+// passing the current state, or nil when there is none, and storing the
+// returned state for at least ttl.
+type Store interface {
+	Update(key string, ttl time.Duration, fn func(state []byte) []byte) error
+}
+
+// MemoryStore keeps limiter state in memory. Expired keys are dropped
+// periodically as new keys are added.
+type MemoryStore struct {
+	mu        sync.Mutex
+	items     map[string]memoryItem
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryItem struct {
+	state   []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]memoryItem),
+		now:   time.Now,
+	}
+}
+
+// Update implements Store.
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	var state []byte
+	if item, ok := s.items[key]; ok && now.Before(item.expires) {
+		state = item.state
+	}
+
+	s.items[key] = memoryItem{state: fn(state), expires: now.Add(ttl)}
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		for k, item := range s.items {
+			if !now.Before(item.expires) {
+				delete(s.items, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+// BadgerStore keeps limiter state in a Badger database, using Badger's
+// entry TTL to expire idle keys.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+var badgerStores = shared.NewRegistry[*BadgerStore]()
+
+// OpenBadgerStore opens the Badger database at path. Stores are shared by
+// path, since a Badger directory can only be opened once per process.
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	return badgerStores.Open(path, func() (*BadgerStore, error) {
+		db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open rate limit store %s: %w", path, err)
+		}
+
+		return &BadgerStore{db: db}, nil
+	})
+}
+
+// Close closes the database of the store.
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// Update implements Store, retrying when a concurrent update of the same
+// key conflicts.
+func (s *BadgerStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	for {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			var state []byte
+
+			item, err := txn.Get([]byte(key))
+			switch {
+			case err == nil:
+				if state, err = item.ValueCopy(nil); err != nil {
+					return err
+				}
+			case !errors.Is(err, badger.ErrKeyNotFound):
+				return err
+			}
+
+			return txn.SetEntry(badger.NewEntry([]byte(key), fn(state)).WithTTL(ttl))
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
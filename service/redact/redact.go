@@ -0,0 +1,245 @@
+// Package redact removes personal data and credentials from values before
+// they are logged. A Redactor masks denylisted headers, fields selected by
+// JSON paths or marked with the x-hub-pii schema keyword, and strings
+// matched by detectors such as email addresses and bearer tokens.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+)
+
+// PIIKeyword marks a schema property holding personal data.
+const PIIKeyword = "x-hub-pii"
+
+// DefaultMask replaces redacted values.
+const DefaultMask = "[REDACTED]"
+
+// DefaultHeaders are always redacted, whatever the configuration.
+var DefaultHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// detectors are the named patterns which may be enabled in Config.Detectors.
+var detectors = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"token": regexp.MustCompile(`(?i)\b(?:bearer|apikey)\s+[A-Za-z0-9._~+/=-]+` +
+		`|\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*` +
+		`|\bhub_[A-Za-z0-9]+\.[A-Za-z0-9_-]+`),
+}
+
+// Config selects what a Redactor masks.
+//
+//	redact:
+//	  headers: [X-Api-Key]
+//	  paths: [ssn, "contacts[*].email"]
+//	  schema: {name: Person, version: v0.0.1}
+//	  detectors: [email, token]
+//	  mask: "***"
+type Config struct {
+	// Headers are redacted in addition to DefaultHeaders.
+	Headers []string `config:"headers" description:"headers redacted in addition to the defaults"`
+	// Paths select JSON body fields, such as "$.user.email" or
+	// "contacts[*].phone". "*" selects every item of an array.
+	Paths []string `config:"paths" description:"JSON body fields, such as $.user.email or contacts[*].phone"`
+	// Schema redacts the fields marked with x-hub-pii in a registered schema.
+	Schema SchemaRef `config:"schema" description:"registered schema whose x-hub-pii fields are redacted"`
+	// Detectors names the patterns masked in every string: email and token.
+	Detectors []string `config:"detectors" description:"patterns masked in every string: email and token"`
+	// Patterns are additional regular expressions masked in every string.
+	Patterns []string `config:"patterns" description:"regular expressions masked in every string"`
+	Mask     string   `config:"mask" description:"text replacing redacted values"`
+}
+
+// SchemaRef names a registered schema.
+type SchemaRef struct {
+	Name    string `config:"name,required"`
+	Version string `config:"version"`
+}
+
+// Redactor masks sensitive values. It is safe for concurrent use once
+// created.
+type Redactor struct {
+	mask     string
+	headers  map[string]bool
+	paths    [][]string
+	patterns []*regexp.Regexp
+}
+
+// New creates a Redactor. When a schema is configured it must already be
+// registered.
+func New(config Config) (*Redactor, error) {
+	r := &Redactor{
+		mask:    config.Mask,
+		headers: make(map[string]bool),
+	}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+
+	for _, name := range append(DefaultHeaders, config.Headers...) {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+
+	for _, path := range config.Paths {
+		r.paths = append(r.paths, parsePath(path))
+	}
+
+	if config.Schema.Name != "" {
+		schema, ok := entity.GetSchema(config.Schema.Name, config.Schema.Version)
+		if !ok {
+			return nil, fmt.Errorf("schema %s:%s is not registered", config.Schema.Name, config.Schema.Version)
+		}
+
+		paths, err := entity.SchemaFieldsWithKeyword(schema.Data, PIIKeyword)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s:%s: %w", config.Schema.Name, config.Schema.Version, err)
+		}
+		for _, path := range paths {
+			r.paths = append(r.paths, strings.Split(path, "."))
+		}
+	}
+
+	for _, name := range config.Detectors {
+		pattern, ok := detectors[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+
+	for _, expr := range config.Patterns {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+
+	return r, nil
+}
+
+// parsePath turns "$.contacts[*].email" into [contacts * email].
+func parsePath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.ReplaceAll(path, "[*]", ".*")
+	path = strings.ReplaceAll(path, "[]", ".*")
+	return strings.Split(path, ".")
+}
+
+// Mask returns the string which replaces redacted values.
+func (r *Redactor) Mask() string {
+	return r.mask
+}
+
+// Headers flattens headers for logging, masking the denylisted ones.
+func (r *Redactor) Headers(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if r.headers[http.CanonicalHeaderKey(name)] {
+			headers[name] = r.mask
+			continue
+		}
+		headers[name] = r.String(strings.Join(values, ", "))
+	}
+	return headers
+}
+
+// Body decodes a body for logging. JSON bodies are returned decoded with
+// the selected fields masked; other bodies are returned as strings with
+// detected values masked.
+func (r *Redactor) Body(body []byte) interface{} {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return r.String(string(body))
+	}
+
+	for _, path := range r.paths {
+		maskPath(document, path, r.mask)
+	}
+
+	return r.value(document)
+}
+
+// BodyString redacts a body like Body and encodes it back to a string.
+func (r *Redactor) BodyString(body []byte) string {
+	redacted := r.Body(body)
+	if s, ok := redacted.(string); ok {
+		return s
+	}
+
+	encoded, err := json.Marshal(redacted)
+	if err != nil {
+		return r.mask
+	}
+	return string(encoded)
+}
+
+// String masks the values found by the detectors in s.
+func (r *Redactor) String(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
+
+// value applies the detectors to every string of a decoded JSON document.
+func (r *Redactor) value(node interface{}) interface{} {
+	if len(r.patterns) == 0 {
+		return node
+	}
+
+	switch v := node.(type) {
+	case string:
+		return r.String(v)
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = r.value(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.value(child)
+		}
+	}
+	return node
+}
+
+func maskPath(node interface{}, path []string, mask string) {
+	if len(path) == 0 || path[0] == "" {
+		return
+	}
+
+	if path[0] == "*" {
+		items, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		for i := range items {
+			if len(path) > 1 {
+				maskPath(items[i], path[1:], mask)
+				continue
+			}
+			items[i] = mask
+		}
+		return
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	child, ok := object[path[0]]
+	if !ok || child == nil {
+		return
+	}
+
+	if len(path) > 1 {
+		maskPath(child, path[1:], mask)
+		return
+	}
+	object[path[0]] = mask
+}
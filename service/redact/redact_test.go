@@ -0,0 +1,79 @@
+package redact
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadersRedactedByDefault(t *testing.T) {
+	redactor, err := New(Config{Headers: []string{"x-api-key"}})
+	require.NoError(t, err)
+
+	headers := redactor.Headers(http.Header{
+		"Authorization": {"Bearer abc"},
+		"Cookie":        {"session=1"},
+		"X-Api-Key":     {"secret"},
+		"Accept":        {"application/json"},
+	})
+
+	assert.Equal(t, map[string]string{
+		"Authorization": DefaultMask,
+		"Cookie":        DefaultMask,
+		"X-Api-Key":     DefaultMask,
+		"Accept":        "application/json",
+	}, headers)
+}
+
+func TestBodyPaths(t *testing.T) {
+	redactor, err := New(Config{
+		Paths: []string{"$.ssn", "contacts[*].email", "missing.field"},
+		Mask:  "***",
+	})
+	require.NoError(t, err)
+
+	body := redactor.BodyString([]byte(`{"name":"Ada","ssn":"123","contacts":[{"email":"a@b.co","kind":"work"}]}`))
+	assert.JSONEq(t, `{"name":"Ada","ssn":"***","contacts":[{"email":"***","kind":"work"}]}`, body)
+}
+
+func TestSchemaDrivenRedaction(t *testing.T) {
+	entity.RegisterSchema("RedactPerson", "v1", []byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"phone": {"type": "string", "x-hub-pii": true},
+			"addresses": {"type": "array", "items": {"type": "object", "x-hub-pii": true}}
+		}
+	}`))
+
+	redactor, err := New(Config{Schema: SchemaRef{Name: "RedactPerson", Version: "v1"}})
+	require.NoError(t, err)
+
+	body := redactor.BodyString([]byte(`{"name":"Ada","phone":"555","addresses":[{"locality":"London"}]}`))
+	assert.JSONEq(t, `{"name":"Ada","phone":"[REDACTED]","addresses":["[REDACTED]"]}`, body)
+
+	_, err = New(Config{Schema: SchemaRef{Name: "Unknown", Version: "v1"}})
+	assert.Error(t, err)
+}
+
+func TestDetectors(t *testing.T) {
+	redactor, err := New(Config{Detectors: []string{"email", "token"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "contact [REDACTED] now", redactor.String("contact ada@example.com now"))
+	assert.Equal(t, "auth: [REDACTED]", redactor.String("auth: Bearer abc.def-ghi"))
+	assert.Equal(t, "jwt [REDACTED]", redactor.String("jwt eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"))
+	assert.Equal(t, "key [REDACTED]", redactor.String("key hub_0a1b.Zm9vYmFy"))
+
+	body := redactor.BodyString([]byte(`{"note":"mail ada@example.com"}`))
+	assert.JSONEq(t, `{"note":"mail [REDACTED]"}`, body)
+	assert.Equal(t, "plain [REDACTED]", redactor.BodyString([]byte("plain ada@example.com")))
+
+	_, err = New(Config{Detectors: []string{"creditcard"}})
+	assert.Error(t, err)
+	_, err = New(Config{Patterns: []string{"("}})
+	assert.Error(t, err)
+}
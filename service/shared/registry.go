@@ -0,0 +1,81 @@
+// Package shared keeps the stores which may only be opened once per
+// process, such as Badger databases, so that every task, target and admin
+// API using the same path shares one store.
+package shared
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Registry keeps stores shared by path.
+type Registry[T io.Closer] struct {
+	mu     sync.Mutex
+	stores map[string]T
+}
+
+var (
+	registriesMu sync.Mutex
+	registries   []interface{ Close() error }
+)
+
+// NewRegistry creates a Registry, whose stores are closed by CloseAll.
+func NewRegistry[T io.Closer]() *Registry[T] {
+	registry := &Registry[T]{stores: map[string]T{}}
+
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	registries = append(registries, registry)
+
+	return registry
+}
+
+// Open returns the store opened at path, calling open to open it when
+// there is none.
+func (r *Registry[T]) Open(path string, open func() (T, error)) (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if store, ok := r.stores[path]; ok {
+		return store, nil
+	}
+
+	store, err := open()
+	if err != nil {
+		return store, err
+	}
+	r.stores[path] = store
+
+	return store, nil
+}
+
+// Close closes the stores of the registry. Stores opened afterwards are
+// opened again.
+func (r *Registry[T]) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for path, store := range r.stores {
+		if err := store.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(r.stores, path)
+	}
+	return errors.Join(errs...)
+}
+
+// CloseAll closes the stores of every registry.
+func CloseAll() error {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+
+	var errs []error
+	for _, registry := range registries {
+		if err := registry.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
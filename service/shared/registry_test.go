@@ -0,0 +1,47 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	closed bool
+}
+
+func (f *fakeStore) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRegistrySharesStoresByPath(t *testing.T) {
+	registry := NewRegistry[*fakeStore]()
+
+	opens := 0
+	open := func() (*fakeStore, error) {
+		opens++
+		return &fakeStore{}, nil
+	}
+
+	first, err := registry.Open("a", open)
+	require.NoError(t, err)
+	same, err := registry.Open("a", open)
+	require.NoError(t, err)
+	other, err := registry.Open("b", open)
+	require.NoError(t, err)
+
+	assert.Same(t, first, same)
+	assert.NotSame(t, first, other)
+	assert.Equal(t, 2, opens)
+
+	require.NoError(t, CloseAll())
+	assert.True(t, first.closed)
+	assert.True(t, other.closed)
+
+	// closed stores are opened again
+	reopened, err := registry.Open("a", open)
+	require.NoError(t, err)
+	assert.NotSame(t, first, reopened)
+}
@@ -1,70 +1,252 @@
+// Package keyvalue provides targets storing aggregates in key value stores.
 package keyvalue
 
-/*
-
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/QueerGlobal/hub-framework/core/adapter/target/keyvalue/badger"
+	"github.com/QueerGlobal/hub-framework/adapter/repository/target/keyvalue/badger"
 	"github.com/QueerGlobal/hub-framework/core/entity"
-	"github.com/dgraph-io/badger/v4"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/encryption"
+	"github.com/QueerGlobal/hub-framework/service/shared"
+	badgerdb "github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
 )
 
-type Badger[T any] struct {
-	repo *badger.Repository[T]
+// BadgerConfig is the config of the Badger target. When a keyring is given,
+// the fields of the schema marked x-hub-encrypted are encrypted at rest with
+// its primary key.
+type BadgerConfig struct {
+	Path          string `config:"path" default:"/tmp/badgerdb" description:"directory of the Badger database"`
+	KeyringFile   string `config:"keyringFile" description:"keyring encrypting the fields marked x-hub-encrypted in the schema"`
+	Schema        string `config:"schema" description:"name of the schema marking the encrypted fields"`
+	SchemaVersion string `config:"schemaVersion" description:"version of the schema marking the encrypted fields"`
+}
+
+// Badger is a target storing aggregates as JSON documents in a Badger
+// database, keyed by the UUID in the path of requests of the form
+// /{api}/{service}/{id}. POST requests create an aggregate with a new ID.
+type Badger struct {
+	repo *badger.Repository[map[string]any]
+}
+
+// sharedRepository is a database opened by a Badger target, along with the
+// config it was opened with.
+type sharedRepository struct {
+	repo   *badger.Repository[map[string]any]
+	config BadgerConfig
 }
 
-func NewBadger[T any](config map[string]interface{}) (entity.Target, error) {
-	repo, err := badger.NewRepository[T](config)
+func (s *sharedRepository) Close() error {
+	return s.repo.Close()
+}
+
+var repositories = shared.NewRegistry[*sharedRepository]()
+
+// NewBadger creates a Badger target. Databases are shared by path, since
+// Badger only lets a database be opened once, so that the targets of every
+// handler of an aggregate, and those rebuilt on reload, use the same one.
+func NewBadger(config BadgerConfig) (entity.Target, error) {
+	if config.KeyringFile != "" && (config.Schema == "" || config.SchemaVersion == "") {
+		return nil, fmt.Errorf("keyringFile requires the schema and schemaVersion marking the encrypted fields")
+	}
+
+	opened, err := repositories.Open(config.Path, func() (*sharedRepository, error) {
+		var opts []badger.Option
+		if config.KeyringFile != "" {
+			keyring, err := encryption.LoadKeyring(config.KeyringFile)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, badger.WithFieldEncryption(&schemaEncryptor{
+				keyring: keyring,
+				schema:  config.Schema,
+				version: config.SchemaVersion,
+			}))
+		}
+
+		repo, err := badger.NewRepository[map[string]any](&map[string]any{"path": config.Path}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedRepository{repo: repo, config: config}, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &Badger[T]{repo: repo}, nil
+	if opened.config != config {
+		return nil, fmt.Errorf("database %s is already used with another keyring or schema", config.Path)
+	}
 
+	return &Badger{repo: opened.repo}, nil
 }
 
-func (b *Badger[T]) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
-	var aggregate T
-	body := req.GetBody()
-	err := json.Unmarshal(body, &aggregate)
-	if err != nil {
-		return entity.ServiceResponse{}, fmt.Errorf("failed to unmarshal request body: %w", err)
+// Apply implements entity.Target.
+func (b *Badger) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	method := req.GetMethod()
+
+	if method == entity.HTTPMethodPOST {
+		return b.save(req, uuid.New(), http.StatusCreated)
 	}
-	req.SetAggregate(&aggregate)
 
-	switch req.GetMethod() {
-	case "POST":
+	id, err := aggregateID(req)
+	if err != nil {
+		return nil, err
+	}
 
-		err := b.repo.Create(req.GetAggregate())
-		if err != nil {
-			return entity.ServiceResponse{}, err
-		}
-		return entity.ServiceResponse{StatusCode: 201}, nil
-	case "PUT":
-		err := b.repo.Update(req.GetAggregate())
+	switch method {
+	case entity.HTTPMethodGET:
+		aggregate, err := b.repo.Read(id)
 		if err != nil {
-			return entity.ServiceResponse{}, err
+			return nil, repositoryError(err)
 		}
-		return entity.ServiceResponse{StatusCode: 200}, nil
-	case "DELETE":
-		err := b.repo.Delete(req.GetAggregate().GetID())
-		if err != nil {
-			return entity.ServiceResponse{}, err
+		document, _ := aggregate.Body.(map[string]any)
+		return respond(http.StatusOK, document)
+	case entity.HTTPMethodPUT:
+		return b.save(req, id, http.StatusOK)
+	case entity.HTTPMethodDELETE:
+		if err := b.repo.Delete(id); err != nil {
+			return nil, repositoryError(err)
 		}
-		return entity.ServiceResponse{StatusCode: 204}, nil
-	case "GET":
-		aggregate, err := b.repo.Get(req.Aggregate.ID)
-		if err != nil {
-			return entity.ServiceResponse{}, err
+		return respond(http.StatusNoContent, nil)
+	default:
+		return nil, domainerr.NewHTTPError(http.StatusMethodNotAllowed, domainerr.ErrUnsupportedHTTPMethod)
+	}
+}
+
+// save stores the body of a request as the aggregate with the given ID.
+func (b *Badger) save(req entity.ServiceRequest, id uuid.UUID, status int) (entity.ServiceResponse, error) {
+	var document map[string]any
+	if err := json.Unmarshal(req.GetBody(), &document); err != nil || document == nil {
+		return nil, domainerr.NewHTTPError(http.StatusBadRequest, domainerr.ErrMalformedBody)
+	}
+	document["id"] = id.String()
+
+	now := time.Now().UTC()
+	aggregate := &entity.Aggregate{
+		ID:            id,
+		AggregateName: req.GetServiceName(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Body:          document,
+	}
+
+	var err error
+	if status == http.StatusCreated {
+		err = b.repo.Create(aggregate)
+	} else {
+		if existing, readErr := b.repo.Read(id); readErr == nil {
+			aggregate.CreatedAt = existing.CreatedAt
 		}
-		return entity.ServiceResponse{
-			StatusCode: 200,
-			Aggregate:  aggregate,
-		}, nil
+		err = b.repo.Update(aggregate)
+	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return respond(status, document)
+}
+
+// Close closes the databases opened by Badger targets.
+func Close() error {
+	return repositories.Close()
+}
+
+// aggregateID returns the aggregate UUID from a request path of the form
+// /{api}/{service}/{id}.
+func aggregateID(req entity.ServiceRequest) (uuid.UUID, error) {
+	segments := strings.Split(strings.Trim(req.GetInternalPath(), "/"), "/")
+	if len(segments) < 3 {
+		return uuid.Nil, domainerr.NewHTTPError(http.StatusMethodNotAllowed, domainerr.ErrUnsupportedHTTPMethod)
+	}
+
+	id, err := uuid.Parse(segments[2])
+	if err != nil {
+		return uuid.Nil, domainerr.NewHTTPError(http.StatusNotFound, err)
+	}
+	return id, nil
+}
+
+// repositoryError maps the errors of the repository to HTTP errors.
+func repositoryError(err error) error {
+	switch {
+	case errors.Is(err, badgerdb.ErrKeyNotFound):
+		return domainerr.NewHTTPError(http.StatusNotFound, err)
+	case errors.Is(err, encryption.ErrEnvelopeInput):
+		return domainerr.NewHTTPError(http.StatusBadRequest, err)
 	default:
-		return entity.ServiceResponse{}, entity.ErrUnsupportedHTTPMethod
+		return err
+	}
+}
+
+func respond(status int, document map[string]any) (entity.ServiceResponse, error) {
+	header := http.Header{}
+	var body []byte
+	if document != nil {
+		var err error
+		if body, err = json.Marshal(document); err != nil {
+			return nil, err
+		}
+		header.Set("Content-Type", "application/json")
 	}
 
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Header:     header,
+		},
+		Body: body,
+	}, nil
+}
+
+// schemaEncryptor encrypts the fields marked in a registered schema. The
+// schema is looked up when first needed, since schemas are registered after
+// the targets of the services using them are built.
+type schemaEncryptor struct {
+	keyring *encryption.Keyring
+	schema  string
+	version string
+
+	once      sync.Once
+	encryptor *encryption.FieldEncryptor
+	err       error
+}
+
+var _ badger.FieldEncryptor = (*schemaEncryptor)(nil)
+
+func (e *schemaEncryptor) fields() (*encryption.FieldEncryptor, error) {
+	e.once.Do(func() {
+		e.encryptor, e.err = encryption.ForSchema(e.keyring, e.schema, e.version)
+	})
+	return e.encryptor, e.err
+}
+
+func (e *schemaEncryptor) Encrypt(aggregateID string, document map[string]any) error {
+	fields, err := e.fields()
+	if err != nil {
+		return err
+	}
+	return fields.Encrypt(aggregateID, document)
+}
+
+func (e *schemaEncryptor) Decrypt(aggregateID string, document map[string]any) error {
+	fields, err := e.fields()
+	if err != nil {
+		return err
+	}
+	return fields.Decrypt(aggregateID, document)
+}
+
+func (e *schemaEncryptor) NeedsReencryption(document map[string]any) bool {
+	fields, err := e.fields()
+	return err == nil && fields.NeedsReencryption(document)
 }
-*/
@@ -0,0 +1,135 @@
+package keyvalue
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/adapter/repository/target/keyvalue/badger"
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"ssn": {"type": "string", "x-hub-encrypted": true}
+	}
+}`
+
+func personRequest(method entity.HTTPMethod, path string, body string) *entity.HTTPServiceRequest {
+	return &entity.HTTPServiceRequest{
+		ApiName:      "app",
+		ServiceName:  "person",
+		Method:       method,
+		URL:          &url.URL{Path: path},
+		InternalPath: path,
+		Header:       http.Header{},
+		Body:         []byte(body),
+	}
+}
+
+func writeKeyring(t *testing.T) string {
+	t.Helper()
+
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	path := filepath.Join(t.TempDir(), "keyring.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("primary: k1\nkeys:\n  - id: k1\n    key: "+key+"\n"), 0o600))
+	return path
+}
+
+func statusOf(t *testing.T, err error) int {
+	t.Helper()
+
+	httpErr, ok := err.(*domainerr.HTTPError)
+	require.True(t, ok, "%v is not an HTTPError", err)
+	return httpErr.StatusCode
+}
+
+func TestBadgerStoresEncryptedAggregates(t *testing.T) {
+	entity.RegisterSchema("Person", "v1", []byte(personSchema))
+
+	config := BadgerConfig{
+		Path:          t.TempDir(),
+		KeyringFile:   writeKeyring(t),
+		Schema:        "Person",
+		SchemaVersion: "v1",
+	}
+	// closed before the temporary directory is removed
+	t.Cleanup(func() { require.NoError(t, Close()) })
+	target, err := NewBadger(config)
+	require.NoError(t, err)
+
+	response, err := target.Apply(context.Background(), personRequest(entity.HTTPMethodPOST, "/app/person", `{"name":"Ada","ssn":"123-45-6789"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.GetResponseMeta().GetStatusCode())
+
+	var created map[string]any
+	require.NoError(t, json.Unmarshal(response.GetBody(), &created))
+	id := created["id"].(string)
+
+	// the targets of the other handlers of the aggregate share the database
+	reader, err := NewBadger(config)
+	require.NoError(t, err)
+
+	response, err = reader.Apply(context.Background(), personRequest(entity.HTTPMethodGET, "/app/person/"+id, ""))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"`+id+`","name":"Ada","ssn":"123-45-6789"}`, string(response.GetBody()))
+
+	_, err = target.Apply(context.Background(), personRequest(entity.HTTPMethodPUT, "/app/person/"+id, `{"name":"Ada","ssn":"hubenc:v1:k1:forged"}`))
+	assert.Equal(t, http.StatusBadRequest, statusOf(t, err))
+
+	_, err = NewBadger(BadgerConfig{Path: config.Path})
+	assert.Error(t, err, "the database is already used with a keyring")
+
+	response, err = target.Apply(context.Background(), personRequest(entity.HTTPMethodDELETE, "/app/person/"+id, ""))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, response.GetResponseMeta().GetStatusCode())
+
+	_, err = target.Apply(context.Background(), personRequest(entity.HTTPMethodGET, "/app/person/"+id, ""))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, err))
+}
+
+func TestBadgerEncryptsAtRest(t *testing.T) {
+	entity.RegisterSchema("Person", "v1", []byte(personSchema))
+
+	config := BadgerConfig{
+		Path:          t.TempDir(),
+		KeyringFile:   writeKeyring(t),
+		Schema:        "Person",
+		SchemaVersion: "v1",
+	}
+	target, err := NewBadger(config)
+	require.NoError(t, err)
+
+	response, err := target.Apply(context.Background(), personRequest(entity.HTTPMethodPOST, "/app/person", `{"name":"Ada","ssn":"123-45-6789"}`))
+	require.NoError(t, err)
+	var created map[string]any
+	require.NoError(t, json.Unmarshal(response.GetBody(), &created))
+	require.NoError(t, Close())
+
+	raw, err := badger.NewRepository[map[string]any](&map[string]any{"path": config.Path})
+	require.NoError(t, err)
+	defer raw.Close()
+
+	stored, err := raw.Read(uuid.MustParse(created["id"].(string)))
+	require.NoError(t, err)
+	document := stored.Body.(map[string]any)
+	assert.Equal(t, "Ada", document["name"])
+	assert.True(t, strings.HasPrefix(document["ssn"].(string), "hubenc:v1:k1:"))
+}
+
+func TestNewBadgerRequiresSchemaForKeyring(t *testing.T) {
+	_, err := NewBadger(BadgerConfig{Path: t.TempDir(), KeyringFile: writeKeyring(t)})
+	assert.Error(t, err)
+}
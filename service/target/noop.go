@@ -9,16 +9,42 @@ import (
 // Noop is a target that does nothing
 type Noop struct{}
 
+// NoopConfig is the config of the Noop target type, which takes no settings.
+type NoopConfig struct{}
+
 // NewNoop creates a new Noop target
 func NewNoop(config map[string]interface{}) (entity.Target, error) {
+	var cfg NoopConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewNoopTarget(cfg)
+}
+
+// NewNoopTarget returns a Noop target.
+func NewNoopTarget(config NoopConfig) (entity.Target, error) {
 	return &Noop{}, nil
 }
 
 // Apply implements the Target interface but does nothing
 func (n *Noop) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	// The server may discard the unread request body once the response
+	// headers are written, so the body is buffered before it is echoed,
+	// spilling to disk rather than being held in memory when it is large
+	if buffered, ok := req.(interface{ BufferBody() error }); ok {
+		if err := buffered.BufferBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := req.GetBodyReader()
+	if err != nil {
+		return nil, err
+	}
+
 	// Return a concrete type that implements ServiceResponse
 	return &entity.HttpServiceResponse{
-		Body: req.GetBody(),
+		BodyStream: body,
 		ResponseMeta: &entity.HttpResponseMeta{
 			StatusCode:       200,
 			Proto:            req.GetRequestMeta().GetProto(),
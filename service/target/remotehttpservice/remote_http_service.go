@@ -2,6 +2,7 @@ package remotehttpservice
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -57,7 +58,7 @@ func (fs *ForwardingService) Apply(request entity.ServiceRequest) (entity.Servic
 	var serviceResponse entity.ServiceResponse
 	var err error
 
-	err = fs.backoff.ExecuteWithBackoff(func() error {
+	err = fs.backoff.ExecuteWithBackoff(context.Background(), func() error {
 		response, err := fs.forwardRequest(request)
 		if err != nil {
 			return err
@@ -0,0 +1,95 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/apikey"
+)
+
+// APIKeyAuthenticator is an inbound task which authenticates requests
+// carrying an "Authorization: ApiKey <token>" header against the keys
+// managed through the admin API. The key's scopes are placed on the request
+// as claims, with the subject "apikey:<id>".
+//
+// Configuration:
+//   - path: the Badger directory holding the keys, matching apiKeys.path in
+//     hub.yaml; defaults to apikey.DefaultPath
+//   - optional: pass requests without an ApiKey header on to later tasks
+type APIKeyAuthenticator struct {
+	name     string
+	optional bool
+	manager  *apikey.Manager
+}
+
+// APIKeyConfig is the config of the ApiKey task type.
+type APIKeyConfig struct {
+	Name     string `config:"name"`
+	Optional bool   `config:"optional" description:"pass requests without an ApiKey header on to later tasks"`
+	Path     string `config:"path" description:"the Badger directory holding the keys, matching apiKeys.path in hub.yaml"`
+}
+
+func NewAPIKeyTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg APIKeyConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewAPIKey(cfg)
+}
+
+// NewAPIKey returns an APIKeyAuthenticator task configured by config.
+func NewAPIKey(config APIKeyConfig) (entity.Task, error) {
+	path := config.Path
+	if path == "" {
+		path = apikey.DefaultPath
+	}
+
+	store, err := apikey.OpenBadgerStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeyAuthenticator{
+		name:     config.Name,
+		optional: config.Optional,
+		manager:  apikey.NewManager(store),
+	}, nil
+}
+
+func (a *APIKeyAuthenticator) Name() string {
+	return a.name
+}
+
+func (a *APIKeyAuthenticator) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	scheme, token, _ := strings.Cut(strings.TrimSpace(request.GetHeader().Get("Authorization")), " ")
+	if !strings.EqualFold(scheme, "ApiKey") {
+		if a.optional {
+			return nil
+		}
+		return apiKeyUnauthorized(errors.New("missing API key"))
+	}
+
+	key, err := a.manager.Authenticate(strings.TrimSpace(token))
+	if err != nil {
+		return apiKeyUnauthorized(err)
+	}
+
+	request.SetClaims(entity.Claims{
+		"sub":        "apikey:" + key.ID,
+		"apiKeyId":   key.ID,
+		"apiKeyName": key.Name,
+		"scope":      strings.Join(key.Scopes, " "),
+	})
+
+	return nil
+}
+
+func apiKeyUnauthorized(err error) error {
+	return domainerr.NewHTTPError(http.StatusUnauthorized, fmt.Errorf("%w: %v", domainerr.ErrUnauthorized, err)).
+		WithHeader("WWW-Authenticate", "ApiKey")
+}
@@ -0,0 +1,43 @@
+package builtin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/apikey"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyTask(t *testing.T) {
+	path := t.TempDir()
+
+	store, err := apikey.OpenBadgerStore(path)
+	require.NoError(t, err)
+	key, token, err := apikey.NewManager(store).Create("partner", []string{"recipes:read", "recipes:write"}, nil)
+	require.NoError(t, err)
+
+	task, err := NewAPIKeyTask(map[string]interface{}{"path": path})
+	require.NoError(t, err)
+
+	request := authorizeRequest(entity.HTTPMethodGET, nil, "/app/recipe", "")
+	request.Header.Set("Authorization", "ApiKey "+token)
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.Equal(t, "apikey:"+key.ID, request.GetClaims().Subject())
+	assert.Equal(t, []string{"recipes:read", "recipes:write"}, request.GetClaims().Scopes())
+
+	request = authorizeRequest(entity.HTTPMethodGET, nil, "/app/recipe", "")
+	request.Header.Set("Authorization", "ApiKey hub_"+key.ID+".wrong")
+	assert.Equal(t, http.StatusUnauthorized, statusOf(task.Apply(context.Background(), request)))
+
+	request = authorizeRequest(entity.HTTPMethodGET, nil, "/app/recipe", "")
+	assert.Equal(t, http.StatusUnauthorized, statusOf(task.Apply(context.Background(), request)))
+
+	optional, err := NewAPIKeyTask(map[string]interface{}{"path": path, "optional": true})
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer token")
+	assert.NoError(t, optional.Apply(context.Background(), request))
+	assert.Nil(t, request.GetClaims())
+}
@@ -0,0 +1,348 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+)
+
+// AuthorizationPolicy is the policy enforced by the Authorize task. Rules
+// are evaluated in order and a request is allowed by the first rule matching
+// its method whose requirements are all met. Requests matching no rule
+// receive the default decision, which is deny unless set to allow.
+type AuthorizationPolicy struct {
+	Default    string
+	RolesClaim string
+	Rules      []AuthorizationRule
+}
+
+// AuthorizationRule grants access to the listed methods, or to all methods
+// when none are listed. Any one of Roles, all of Scopes and all of the
+// Conditions are required. Anonymous rules also apply to requests without
+// claims.
+type AuthorizationRule struct {
+	Name       string                   `config:"name"`
+	Methods    []string                 `config:"methods"`
+	Anonymous  bool                     `config:"anonymous"`
+	Roles      []string                 `config:"roles" description:"roles, any one of which is required"`
+	Scopes     []string                 `config:"scopes" description:"scopes, all of which are required"`
+	Conditions []AuthorizationCondition `config:"conditions"`
+}
+
+// AuthorizationCondition compares a claim or a dotted field of the aggregate
+// either to a literal value, to one of a list of values, or, when both a
+// claim and a field are given, the claim to the field. For example
+// {claim: sub, field: chef} only allows the chef of the recipe. Fields are
+// read from the stored aggregate, through the GET target of the service,
+// and from the request body only for requests creating an aggregate.
+type AuthorizationCondition struct {
+	Claim  string        `config:"claim"`
+	Field  string        `config:"field" description:"dotted field of the aggregate"`
+	Equals interface{}   `config:"equals"`
+	In     []interface{} `config:"in"`
+}
+
+// AuthorizeConfig is the config of the Authorize task type.
+type AuthorizeConfig struct {
+	Name       string              `config:"name"`
+	Default    string              `config:"default" default:"deny" description:"decision for requests matching no rule: allow or deny"`
+	RolesClaim string              `config:"rolesClaim" default:"roles" description:"the claim listing the roles of the caller"`
+	Rules      []AuthorizationRule `config:"rules"`
+}
+
+// Authorize is an inbound task enforcing an AuthorizationPolicy against the
+// claims placed on the request by an authentication task such as ValidateJWT.
+// Every decision is logged for audit.
+type Authorize struct {
+	name   string
+	policy AuthorizationPolicy
+}
+
+func NewAuthorizeTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg AuthorizeConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewAuthorize(cfg)
+}
+
+// NewAuthorize returns an Authorize task configured by config.
+func NewAuthorize(config AuthorizeConfig) (entity.Task, error) {
+	a := &Authorize{
+		name: config.Name,
+		policy: AuthorizationPolicy{
+			Default:    config.Default,
+			RolesClaim: config.RolesClaim,
+			Rules:      config.Rules,
+		},
+	}
+
+	switch strings.ToLower(a.policy.Default) {
+	case "", "deny", "allow":
+	default:
+		return nil, fmt.Errorf("invalid default decision %q, must be allow or deny", a.policy.Default)
+	}
+
+	if a.policy.RolesClaim == "" {
+		a.policy.RolesClaim = "roles"
+	}
+
+	for i, rule := range a.policy.Rules {
+		for _, method := range rule.Methods {
+			if _, err := entity.StringToHTTPMethod(method); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+		}
+		for j, condition := range rule.Conditions {
+			if condition.Claim == "" && condition.Field == "" {
+				return nil, fmt.Errorf("rule %d condition %d: a claim or field is required", i, j)
+			}
+			if condition.Claim != "" && condition.Field != "" {
+				continue
+			}
+			if condition.Equals == nil && condition.In == nil {
+				return nil, fmt.Errorf("rule %d condition %d: equals or in is required", i, j)
+			}
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Authorize) Name() string {
+	return a.name
+}
+
+func (a *Authorize) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	claims := request.GetClaims()
+	aggregate := lazyAggregate{ctx: ctx, request: request}
+	denial := "no rule allows the request"
+
+	for i, rule := range a.policy.Rules {
+		if !rule.appliesTo(request.GetMethod()) {
+			continue
+		}
+		if claims == nil && !rule.Anonymous {
+			continue
+		}
+		if reason := a.check(rule, claims, &aggregate); reason != "" {
+			denial = ruleName(rule, i) + ": " + reason
+			continue
+		}
+
+		a.audit(request, "allow", ruleName(rule, i), "")
+		return nil
+	}
+
+	if strings.EqualFold(a.policy.Default, "allow") {
+		a.audit(request, "allow", "default", "")
+		return nil
+	}
+
+	if claims == nil {
+		a.audit(request, "deny", "default", "unauthenticated")
+		return domainerr.NewHTTPError(http.StatusUnauthorized, domainerr.ErrUnauthorized).
+			WithHeader("WWW-Authenticate", "Bearer")
+	}
+
+	a.audit(request, "deny", "default", denial)
+	return domainerr.NewHTTPError(http.StatusForbidden, domainerr.ErrForbidden)
+}
+
+// check returns the reason a rule is not satisfied, or an empty string.
+func (a *Authorize) check(rule AuthorizationRule, claims entity.Claims, aggregate *lazyAggregate) string {
+	if len(rule.Roles) > 0 && !containsAny(claims.Strings(a.policy.RolesClaim), rule.Roles) {
+		return "missing role"
+	}
+
+	if len(rule.Scopes) > 0 {
+		granted := claims.Scopes()
+		for _, scope := range rule.Scopes {
+			if !containsAny(granted, []string{scope}) {
+				return "missing scope " + scope
+			}
+		}
+	}
+
+	for _, condition := range rule.Conditions {
+		if !condition.holds(claims, aggregate) {
+			return "condition not met on " + condition.subject()
+		}
+	}
+
+	return ""
+}
+
+func (a *Authorize) audit(request entity.ServiceRequest, decision string, rule string, reason string) {
+	event := logging.GetLogger().Info().
+		Str("audit", "authorization").
+		Str("decision", decision).
+		Str("rule", rule).
+		Str("api", request.GetAPIName()).
+		Str("service", request.GetServiceName()).
+		Str("method", request.GetMethod().String()).
+		Str("requestId", request.GetID().String())
+
+	if claims := request.GetClaims(); claims != nil {
+		event = event.Str("subject", claims.Subject())
+	}
+	if reason != "" {
+		event = event.Str("reason", reason)
+	}
+
+	event.Msg("authorization decision")
+}
+
+func (r AuthorizationRule) appliesTo(method entity.HTTPMethod) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c AuthorizationCondition) holds(claims entity.Claims, aggregate *lazyAggregate) bool {
+	if c.Claim != "" && c.Field != "" {
+		claim, ok := claims[c.Claim]
+		if !ok {
+			return false
+		}
+		field, ok := aggregate.field(c.Field)
+		return ok && valuesEqual(claim, field)
+	}
+
+	var value interface{}
+	var ok bool
+	if c.Claim != "" {
+		value, ok = claims[c.Claim]
+	} else {
+		value, ok = aggregate.field(c.Field)
+	}
+	if !ok {
+		return false
+	}
+
+	if c.Equals != nil {
+		return valuesEqual(value, c.Equals)
+	}
+	for _, candidate := range c.In {
+		if valuesEqual(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c AuthorizationCondition) subject() string {
+	if c.Claim != "" {
+		return "claim " + c.Claim
+	}
+	return "field " + c.Field
+}
+
+// lazyAggregate decodes the JSON state of the aggregate a request refers to
+// the first time a field is needed. A request naming an aggregate is judged
+// on its stored state, so that the client cannot claim fields it does not
+// own, and a request creating one on its body.
+type lazyAggregate struct {
+	ctx     context.Context
+	request entity.ServiceRequest
+	decoded bool
+	value   interface{}
+}
+
+func (a *lazyAggregate) field(path string) (interface{}, bool) {
+	if !a.decoded {
+		a.decoded = true
+		if data := a.state(); len(data) > 0 {
+			_ = json.Unmarshal(data, &a.value)
+		}
+	}
+
+	current := a.value
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (a *lazyAggregate) state() []byte {
+	if stored, ok := entity.StoredAggregate(a.ctx, a.request); ok {
+		return stored
+	}
+	if a.request.GetMethod() == entity.HTTPMethodPOST && !namesAggregate(a.request) {
+		return a.request.GetBody()
+	}
+	return nil
+}
+
+// namesAggregate reports whether a request path is of the form
+// /{api}/{service}/{id}.
+func namesAggregate(request entity.ServiceRequest) bool {
+	return strings.Count(strings.Trim(request.GetInternalPath(), "/"), "/") >= 2
+}
+
+// valuesEqual compares values decoded from JSON, claims and YAML, which may
+// use different types for the same number. Values of different kinds, such
+// as the string "1" and the number 1, are never equal.
+func valuesEqual(a interface{}, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	if number, ok := value.(json.Number); ok {
+		f, err := number.Float64()
+		return f, err == nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ruleName(rule AuthorizationRule, index int) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("rule %d", index)
+}
@@ -0,0 +1,187 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recipePolicy mirrors the YAML produced config of an aggregate policy.
+var recipePolicy = map[string]interface{}{
+	"rules": []interface{}{
+		map[interface{}]interface{}{
+			"name":      "anyone can read",
+			"methods":   []interface{}{"GET"},
+			"anonymous": true,
+		},
+		map[interface{}]interface{}{
+			"methods": []interface{}{"POST"},
+			"roles":   []interface{}{"chef"},
+		},
+		map[interface{}]interface{}{
+			"methods": []interface{}{"PUT"},
+			"conditions": []interface{}{
+				map[interface{}]interface{}{"claim": "sub", "field": "chef"},
+			},
+		},
+		map[interface{}]interface{}{
+			"methods": []interface{}{"DELETE"},
+			"scopes":  []interface{}{"recipes:admin"},
+			"conditions": []interface{}{
+				map[interface{}]interface{}{"field": "status", "in": []interface{}{"draft", "archived"}},
+			},
+		},
+	},
+}
+
+func authorizeRequest(method entity.HTTPMethod, claims entity.Claims, path string, body string) *entity.HTTPServiceRequest {
+	return &entity.HTTPServiceRequest{
+		ApiName:      "app",
+		ServiceName:  "recipe",
+		Method:       method,
+		URL:          &url.URL{Path: path},
+		InternalPath: path,
+		Header:       http.Header{},
+		Body:         []byte(body),
+		Claims:       claims,
+	}
+}
+
+// storedRecipes is a target answering GET requests with the recipes it
+// holds, and every other request with 200.
+type storedRecipes map[string]string
+
+func (s storedRecipes) Apply(ctx context.Context, req entity.ServiceRequest) (entity.ServiceResponse, error) {
+	status, body := http.StatusOK, ""
+	if req.GetMethod() == entity.HTTPMethodGET {
+		var ok bool
+		if body, ok = s[req.GetInternalPath()]; !ok {
+			status = http.StatusNotFound
+		}
+	}
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: status, Header: http.Header{}},
+		Body:         []byte(body),
+	}, nil
+}
+
+// authorizedService returns a service enforcing a policy on every method.
+func authorizedService(t *testing.T, policy map[string]interface{}, recipes storedRecipes) *entity.Service {
+	task, err := NewAuthorizeTask(policy)
+	require.NoError(t, err)
+
+	service, _ := entity.NewService("app", "recipe", "Recipe", "1.0", true)
+	for _, method := range []entity.HTTPMethod{
+		entity.HTTPMethodGET, entity.HTTPMethodPOST, entity.HTTPMethodPUT, entity.HTTPMethodDELETE, entity.HTTPMethodPATCH,
+	} {
+		service.SetHandler(method, &entity.Handler{InboundWorkflow: task, Target: recipes})
+	}
+	return service
+}
+
+func statusOf(err error) int {
+	var httpErr *domainerr.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
+
+func TestAuthorizePolicy(t *testing.T) {
+	service := authorizedService(t, recipePolicy, storedRecipes{
+		"/app/recipe/soup":  `{"chef":"alice","status":"draft"}`,
+		"/app/recipe/stew":  `{"chef":"alice","status":"published"}`,
+		"/app/recipe/toast": `{"chef":"bob","status":"archived"}`,
+	})
+
+	alice := entity.Claims{"sub": "alice", "roles": []interface{}{"chef"}}
+	bob := entity.Claims{"sub": "bob", "scope": "recipes:read recipes:admin"}
+
+	tests := []struct {
+		name    string
+		request *entity.HTTPServiceRequest
+		status  int
+	}{
+		{"anonymous read", authorizeRequest(entity.HTTPMethodGET, nil, "/app/recipe/soup", ""), 0},
+		{"anonymous write", authorizeRequest(entity.HTTPMethodPOST, nil, "/app/recipe", `{}`), http.StatusUnauthorized},
+		{"chef creates", authorizeRequest(entity.HTTPMethodPOST, alice, "/app/recipe", `{}`), 0},
+		{"non chef creates", authorizeRequest(entity.HTTPMethodPOST, bob, "/app/recipe", `{}`), http.StatusForbidden},
+		{"own recipe update", authorizeRequest(entity.HTTPMethodPUT, alice, "/app/recipe/soup", `{"chef":"alice"}`), 0},
+		{"other recipe update", authorizeRequest(entity.HTTPMethodPUT, bob, "/app/recipe/soup", `{"chef":"alice"}`), http.StatusForbidden},
+		{"update claiming another recipe", authorizeRequest(entity.HTTPMethodPUT, bob, "/app/recipe/soup", `{"chef":"bob"}`), http.StatusForbidden},
+		{"update of missing recipe", authorizeRequest(entity.HTTPMethodPUT, alice, "/app/recipe/pie", `{"chef":"alice"}`), http.StatusForbidden},
+		{"admin deletes draft", authorizeRequest(entity.HTTPMethodDELETE, bob, "/app/recipe/soup", ""), 0},
+		{"admin deletes archived", authorizeRequest(entity.HTTPMethodDELETE, bob, "/app/recipe/toast", ""), 0},
+		{"admin deletes published", authorizeRequest(entity.HTTPMethodDELETE, bob, "/app/recipe/stew", `{"status":"draft"}`), http.StatusForbidden},
+		{"chef deletes draft", authorizeRequest(entity.HTTPMethodDELETE, alice, "/app/recipe/soup", ""), http.StatusForbidden},
+		{"unlisted method", authorizeRequest(entity.HTTPMethodPATCH, alice, "/app/recipe/soup", `{}`), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.DoRequest(context.Background(), tt.request)
+			if tt.status == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.status, statusOf(err))
+		})
+	}
+}
+
+func TestAuthorizeCreationIsJudgedOnTheBody(t *testing.T) {
+	service := authorizedService(t, map[string]interface{}{
+		"rules": []interface{}{
+			map[interface{}]interface{}{
+				"methods":    []interface{}{"POST"},
+				"conditions": []interface{}{map[interface{}]interface{}{"claim": "sub", "field": "chef"}},
+			},
+		},
+	}, storedRecipes{})
+
+	alice := entity.Claims{"sub": "alice"}
+	assert.NoError(t, service.DoRequest(context.Background(), authorizeRequest(entity.HTTPMethodPOST, alice, "/app/recipe", `{"chef":"alice"}`)))
+	assert.Equal(t, http.StatusForbidden, statusOf(service.DoRequest(context.Background(),
+		authorizeRequest(entity.HTTPMethodPOST, alice, "/app/recipe", `{"chef":"bob"}`))))
+}
+
+func TestValuesEqualComparesTypes(t *testing.T) {
+	assert.True(t, valuesEqual("alice", "alice"))
+	assert.True(t, valuesEqual(float64(42), 42))
+	assert.True(t, valuesEqual(json.Number("42"), uint8(42)))
+	assert.True(t, valuesEqual([]interface{}{"a"}, []interface{}{"a"}))
+	assert.False(t, valuesEqual("42", 42))
+	assert.False(t, valuesEqual(true, "true"))
+	assert.False(t, valuesEqual(nil, "<nil>"))
+	assert.False(t, valuesEqual([]interface{}{"a", "b"}, "[a b]"))
+}
+
+func TestAuthorizeDefaultAllow(t *testing.T) {
+	task, err := NewAuthorizeTask(map[string]interface{}{"default": "allow"})
+	require.NoError(t, err)
+
+	assert.NoError(t, task.Apply(context.Background(), authorizeRequest(entity.HTTPMethodPOST, nil, "/app/recipe", "")))
+}
+
+func TestNewAuthorizeTaskRejectsInvalidPolicies(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"default": "maybe"},
+		{"rules": []interface{}{map[interface{}]interface{}{"methods": []interface{}{"FETCH"}}}},
+		{"rules": []interface{}{map[interface{}]interface{}{
+			"conditions": []interface{}{map[interface{}]interface{}{"claim": "sub"}},
+		}}},
+	}
+
+	for _, config := range invalid {
+		_, err := NewAuthorizeTask(config)
+		assert.Error(t, err, "%v", config)
+	}
+}
@@ -0,0 +1,297 @@
+package builtin
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrKeyNotFound is returned when no verification key matches a token.
+var ErrKeyNotFound = errors.New("no matching verification key")
+
+// minJWKSRefreshInterval limits how often a remote key set is fetched, when
+// an unknown key ID asks for a refresh or a refresh has failed.
+const minJWKSRefreshInterval = time.Minute
+
+// keySource resolves the key used to verify a token signature.
+type keySource interface {
+	Key(ctx context.Context, keyID string, algorithm string) (any, error)
+}
+
+// staticKeys is a fixed set of keys loaded from configuration or local files.
+// Keys without an ID match any token.
+type staticKeys struct {
+	keys []verificationKey
+}
+
+type verificationKey struct {
+	id        string
+	algorithm string
+	key       any
+}
+
+func (s *staticKeys) Key(_ context.Context, keyID string, algorithm string) (any, error) {
+	return findKey(s.keys, keyID, algorithm)
+}
+
+// remoteKeySet fetches a JWKS document over HTTP and caches it. The set is
+// refreshed when it expires, and early when a token names an unknown key ID,
+// so that rotated signing keys are picked up. Concurrent refreshes share one
+// fetch, made without holding the lock, and no fetch is made within
+// minJWKSRefreshInterval of the last one, so that while the endpoint is down
+// requests are verified with the keys fetched before rather than waiting on
+// it.
+type remoteKeySet struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+	group           singleflight.Group
+
+	mu          sync.Mutex
+	keys        []verificationKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+}
+
+func newRemoteKeySet(url string, refreshInterval time.Duration) *remoteKeySet {
+	return &remoteKeySet{
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *remoteKeySet) Key(ctx context.Context, keyID string, algorithm string) (any, error) {
+	if keys, fetchedAt := r.cached(); keys == nil || time.Since(fetchedAt) > r.refreshInterval {
+		// stale keys are kept when the refresh fails
+		if err := r.refresh(ctx); err != nil && keys == nil {
+			return nil, err
+		}
+	}
+
+	keys, _ := r.cached()
+	key, err := findKey(keys, keyID, algorithm)
+	if err == nil {
+		return key, nil
+	}
+
+	// the signing key may have been rotated since the last fetch
+	if err := r.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	keys, _ = r.cached()
+	return findKey(keys, keyID, algorithm)
+}
+
+func (r *remoteKeySet) cached() ([]verificationKey, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.keys, r.fetchedAt
+}
+
+// refresh fetches the key set, unless it was fetched within
+// minJWKSRefreshInterval. It only fails when there are no keys to fall back
+// on, or when the fetch it made failed.
+func (r *remoteKeySet) refresh(ctx context.Context) error {
+	r.mu.Lock()
+	recent := time.Since(r.lastAttempt) < minJWKSRefreshInterval
+	keys, lastErr := r.keys, r.lastErr
+	r.mu.Unlock()
+
+	if recent {
+		if keys == nil {
+			return lastErr
+		}
+		return nil
+	}
+
+	// the fetch is shared, so it does not end with the request making it
+	_, err, _ := r.group.Do(r.url, func() (any, error) {
+		return nil, r.fetch(context.WithoutCancel(ctx))
+	})
+	return err
+}
+
+func (r *remoteKeySet) fetch(ctx context.Context) error {
+	keys, err := r.get(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastAttempt = time.Now()
+	r.lastErr = err
+	if err == nil {
+		r.keys = keys
+		r.fetchedAt = r.lastAttempt
+	}
+
+	return err
+}
+
+func (r *remoteKeySet) get(ctx context.Context) ([]verificationKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", r.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: status %d", r.url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJWKS(data)
+}
+
+func findKey(keys []verificationKey, keyID string, algorithm string) (any, error) {
+	for _, k := range keys {
+		if k.algorithm != "" && k.algorithm != algorithm {
+			continue
+		}
+		if keyID != "" && k.id != "" && k.id != keyID {
+			continue
+		}
+		return k.key, nil
+	}
+
+	return nil, fmt.Errorf("kid %q alg %s: %w", keyID, algorithm, ErrKeyNotFound)
+}
+
+// jwk is a single JSON Web Key as defined by RFC 7517.
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	K         string `json:"k"`
+}
+
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", k.KeyID, err)
+		}
+
+		keys = append(keys, verificationKey{id: k.KeyID, algorithm: k.Algorithm, key: key})
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.KeyType)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// parsePublicKeyPEM parses a PEM encoded public key or certificate.
+func parsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
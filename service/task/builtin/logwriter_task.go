@@ -8,45 +8,50 @@ import (
 	"strings"
 
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/redact"
 )
 
 type LogWriter struct {
 	name     string
 	LogLevel string
+	redactor *logRedactor
 	Fields   []LogField
 }
 
 type LogField struct {
-	Name  string
-	Value string
+	Name  string `config:"name,required"`
+	Value string `config:"value,required" description:"text to log, or a field of the request such as {{.Request.URL.Path}}"`
 }
 
-func NewLogWriterTask(config map[string]interface{}) (entity.Task, error) {
-	lw := &LogWriter{
-		name:     "",
-		LogLevel: "INFO",
-	}
-
-	if name, ok := config["name"].(string); ok {
-		lw.name = name
-	}
+// LogWriterConfig is the config of the LogWriter task type.
+type LogWriterConfig struct {
+	Name     string        `config:"name"`
+	LogLevel string        `config:"logLevel" default:"INFO"`
+	Redact   redact.Config `config:"redact" description:"schema and detectors of the values masked in the log"`
+	Fields   []LogField    `config:"fields"`
+}
 
-	if level, ok := config["logLevel"].(string); ok {
-		lw.LogLevel = level
+func NewLogWriterTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg LogWriterConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
 	}
+	return NewLogWriter(cfg)
+}
 
-	if fields, ok := config["fields"].([]interface{}); ok {
-		for _, field := range fields {
-			if fieldMap, ok := field.(map[string]interface{}); ok {
-				lw.Fields = append(lw.Fields, LogField{
-					Name:  fieldMap["name"].(string),
-					Value: fieldMap["value"].(string),
-				})
-			}
-		}
+// NewLogWriter returns a LogWriter task configured by config.
+func NewLogWriter(config LogWriterConfig) (entity.Task, error) {
+	redactor, err := newLogRedactor(config.Redact)
+	if err != nil {
+		return nil, err
 	}
 
-	return lw, nil
+	return &LogWriter{
+		name:     config.Name,
+		LogLevel: config.LogLevel,
+		redactor: redactor,
+		Fields:   config.Fields,
+	}, nil
 }
 
 func (lw *LogWriter) Name() string {
@@ -59,7 +64,7 @@ func (lw *LogWriter) Apply(ctx context.Context, request entity.ServiceRequest) e
 	for _, field := range lw.Fields {
 		value, err := lw.extractCommonFields(field.Value, request)
 		if err == nil {
-			logMessage[field.Name] = value
+			logMessage[field.Name] = lw.redactor.string(value)
 			continue
 		}
 
@@ -68,7 +73,7 @@ func (lw *LogWriter) Apply(ctx context.Context, request entity.ServiceRequest) e
 		if err != nil {
 			return fmt.Errorf("failed to extract value for field %s: %v", field.Name, err)
 		}
-		logMessage[field.Name] = value
+		logMessage[field.Name] = lw.redactor.string(value)
 	}
 
 	// Log the message
@@ -87,7 +92,7 @@ func (lw *LogWriter) extractCommonFields(pattern string, request entity.ServiceR
 
 	switch {
 	case len(parts) == 2 && parts[0] == "Request" && parts[1] == "Body":
-		return string(request.GetBody()), nil
+		return lw.redactor.bodyString(request.GetBody()), nil
 	case len(parts) == 2 && parts[0] == "Response" && parts[1] == "StatusCode":
 		return fmt.Sprintf("%d", request.GetResponse().GetResponseMeta().GetStatusCode()), nil
 	case len(parts) == 2 && parts[0] == "Request" && parts[1] == "Method":
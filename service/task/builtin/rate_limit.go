@@ -0,0 +1,168 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/QueerGlobal/hub-framework/service/ratelimit"
+)
+
+// RateLimit is an inbound task limiting the rate of requests per client.
+// Limits apply separately to each API, service and method. Requests over the
+// limit are rejected with 429 Too Many Requests and a Retry-After header.
+//
+// Configuration:
+//   - algorithm: tokenBucket (default) or slidingWindow
+//   - limit: requests allowed per period
+//   - period: e.g. "1s", "1m" or "24h" for daily quotas
+//   - burst: token bucket capacity; defaults to limit
+//   - key: remoteAddr (default), header:<name> or claim:<name>
+//   - store: memory (default) or badger, with path for the Badger directory
+type RateLimit struct {
+	name    string
+	key     string
+	limiter *ratelimit.Limiter
+}
+
+// RateLimitConfig is the config of the RateLimit task type.
+type RateLimitConfig struct {
+	Name      string        `config:"name"`
+	Algorithm string        `config:"algorithm" default:"tokenBucket" description:"tokenBucket or slidingWindow"`
+	Limit     int           `config:"limit,required" description:"requests allowed per period"`
+	Period    time.Duration `config:"period,required" description:"e.g. 1s, 1m or 24h for daily quotas"`
+	Burst     int           `config:"burst" description:"token bucket capacity; defaults to limit"`
+	Key       string        `config:"key" default:"remoteAddr" description:"remoteAddr, header:<name> or claim:<name>"`
+	Store     string        `config:"store" default:"memory" description:"memory or badger"`
+	Path      string        `config:"path" description:"directory of the badger store"`
+}
+
+func NewRateLimitTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg RateLimitConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewRateLimit(cfg)
+}
+
+// NewRateLimit returns a RateLimit task configured by config.
+func NewRateLimit(config RateLimitConfig) (entity.Task, error) {
+	r := &RateLimit{name: config.Name, key: "remoteAddr"}
+
+	if key := config.Key; key != "" {
+		source, name, _ := strings.Cut(key, ":")
+		switch {
+		case key == "remoteAddr":
+		case (source == "header" || source == "claim") && name != "":
+		default:
+			return nil, fmt.Errorf("invalid rate limit key %q, must be remoteAddr, header:<name> or claim:<name>", key)
+		}
+		r.key = key
+	}
+
+	limiterConfig := ratelimit.Config{
+		Algorithm: config.Algorithm,
+		Limit:     config.Limit,
+		Period:    config.Period,
+		Burst:     config.Burst,
+	}
+
+	var store ratelimit.Store
+	switch config.Store {
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	case "badger":
+		if config.Path == "" {
+			return nil, fmt.Errorf("the badger rate limit store requires a path")
+		}
+		badgerStore, err := ratelimit.OpenBadgerStore(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		store = badgerStore
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store %s", config.Store)
+	}
+
+	limiter, err := ratelimit.New(limiterConfig, store)
+	if err != nil {
+		return nil, err
+	}
+	r.limiter = limiter
+
+	return r, nil
+}
+
+func (r *RateLimit) Name() string {
+	return r.name
+}
+
+func (r *RateLimit) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	key := strings.Join([]string{
+		request.GetAPIName(),
+		request.GetServiceName(),
+		request.GetMethod().String(),
+		r.clientKey(request),
+	}, "/")
+
+	decision, err := r.limiter.Allow(key)
+	if err != nil {
+		// fail open rather than reject every request while the store is unavailable
+		logging.GetLogger().Err(err).Str("key", key).Msg("rate limit store error")
+		return nil
+	}
+
+	if decision.Allowed {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	return domainerr.NewHTTPError(http.StatusTooManyRequests, domainerr.ErrRateLimited).
+		WithHeader("Retry-After", strconv.Itoa(retryAfter)).
+		WithHeader("X-RateLimit-Limit", strconv.Itoa(decision.Limit)).
+		WithHeader("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+}
+
+// clientKey identifies the client of a request. Requests without the
+// configured header or claim fall back to the remote address.
+func (r *RateLimit) clientKey(request entity.ServiceRequest) string {
+	source, name, _ := strings.Cut(r.key, ":")
+
+	switch source {
+	case "header":
+		if value := request.GetHeader().Get(name); value != "" {
+			return "header:" + value
+		}
+	case "claim":
+		if values := request.GetClaims().Strings(name); len(values) > 0 {
+			return "claim:" + values[0]
+		}
+	}
+
+	return "addr:" + remoteHost(request)
+}
+
+func remoteHost(request entity.ServiceRequest) string {
+	meta := request.GetRequestMeta()
+	if meta == nil {
+		return ""
+	}
+
+	addr := meta.GetRemoteAddr()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,85 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func clientRequest(method entity.HTTPMethod, remoteAddr string, header http.Header, claims entity.Claims) *entity.HTTPServiceRequest {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &entity.HTTPServiceRequest{
+		ApiName:     "recipeApp",
+		ServiceName: "recipe",
+		Method:      method,
+		Header:      header,
+		Claims:      claims,
+		RequestMeta: entity.RequestMeta{RemoteAddr: remoteAddr},
+	}
+}
+
+func TestRateLimitByRemoteAddr(t *testing.T) {
+	task, err := NewRateLimitTask(map[string]interface{}{"limit": 2, "period": "1m"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, task.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.1:5000", nil, nil)))
+	require.NoError(t, task.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.1:5001", nil, nil)))
+
+	err = task.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.1:5002", nil, nil))
+	var httpErr *domainerr.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.ErrorIs(t, err, domainerr.ErrRateLimited)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.StatusCode)
+	assert.Equal(t, "30", httpErr.Header.Get("Retry-After"))
+
+	// other clients and methods have their own limits
+	assert.NoError(t, task.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.2:5000", nil, nil)))
+	assert.NoError(t, task.Apply(ctx, clientRequest(entity.HTTPMethodPOST, "10.0.0.1:5003", nil, nil)))
+}
+
+func TestRateLimitByHeaderAndClaim(t *testing.T) {
+	byHeader, err := NewRateLimitTask(map[string]interface{}{"limit": 1, "period": "1h", "key": "header:X-Api-Key"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	keyA := http.Header{"X-Api-Key": []string{"a"}}
+	keyB := http.Header{"X-Api-Key": []string{"b"}}
+	require.NoError(t, byHeader.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.1:1", keyA, nil)))
+	assert.Error(t, byHeader.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.2:1", keyA, nil)))
+	assert.NoError(t, byHeader.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.1:1", keyB, nil)))
+
+	byClaim, err := NewRateLimitTask(map[string]interface{}{
+		"algorithm": "slidingWindow",
+		"limit":     1,
+		"period":    "24h",
+		"key":       "claim:sub",
+	})
+	require.NoError(t, err)
+
+	alice := entity.Claims{"sub": "alice"}
+	require.NoError(t, byClaim.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.1:1", nil, alice)))
+	assert.Error(t, byClaim.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.9:1", nil, alice)))
+	assert.NoError(t, byClaim.Apply(ctx, clientRequest(entity.HTTPMethodGET, "10.0.0.9:1", nil, entity.Claims{"sub": "bob"})))
+}
+
+func TestNewRateLimitTaskRejectsInvalidConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"limit": 1},
+		{"limit": 1, "period": "soon"},
+		{"limit": 1, "period": "1s", "key": "cookie:session"},
+		{"limit": 1, "period": "1s", "store": "redis"},
+		{"limit": 1, "period": "1s", "store": "badger"},
+	} {
+		_, err := NewRateLimitTask(config)
+		assert.Error(t, err, "%v", config)
+	}
+}
@@ -0,0 +1,78 @@
+package builtin
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/QueerGlobal/hub-framework/service/redact"
+)
+
+// logRedactor builds the redactor of a logging task from its "redact"
+// config. Schemas are registered after tasks are created, so the redactor is
+// built on first use. If that fails, bodies are masked entirely rather than
+// logged unredacted.
+type logRedactor struct {
+	config   redact.Config
+	once     sync.Once
+	redactor *redact.Redactor
+	fallback *redact.Redactor
+}
+
+func newLogRedactor(config redact.Config) (*logRedactor, error) {
+	l := &logRedactor{config: config}
+
+	// validate everything but the schema now
+	withoutSchema := l.config
+	withoutSchema.Schema.Name = ""
+	fallback, err := redact.New(withoutSchema)
+	if err != nil {
+		return nil, err
+	}
+	l.fallback = fallback
+
+	return l, nil
+}
+
+func (l *logRedactor) get() (*redact.Redactor, bool) {
+	l.once.Do(func() {
+		redactor, err := redact.New(l.config)
+		if err != nil {
+			logger := logging.GetLogger()
+			logger.Error().Err(err).Msg("failed to build log redactor, bodies will not be logged")
+			return
+		}
+		l.redactor = redactor
+	})
+
+	if l.redactor == nil {
+		return l.fallback, false
+	}
+	return l.redactor, true
+}
+
+func (l *logRedactor) headers(header http.Header) map[string]string {
+	redactor, _ := l.get()
+	return redactor.Headers(header)
+}
+
+func (l *logRedactor) body(body []byte) interface{} {
+	redactor, ok := l.get()
+	if !ok {
+		return l.fallback.Mask()
+	}
+	return redactor.Body(body)
+}
+
+func (l *logRedactor) bodyString(body []byte) string {
+	redactor, ok := l.get()
+	if !ok {
+		return l.fallback.Mask()
+	}
+	return redactor.BodyString(body)
+}
+
+func (l *logRedactor) string(s string) string {
+	redactor, _ := l.get()
+	return redactor.String(s)
+}
@@ -0,0 +1,103 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRequestLoggerRedacts(t *testing.T) {
+	task, err := NewRequestLoggerTask(map[string]interface{}{
+		"redact": map[string]interface{}{
+			"paths":     []interface{}{"password"},
+			"detectors": []interface{}{"email"},
+		},
+	})
+	require.NoError(t, err)
+
+	request := clientRequest(entity.HTTPMethodPOST, "10.0.0.1:1", http.Header{
+		"Authorization": {"Bearer secret-token"},
+		"Cookie":        {"session=secret-cookie"},
+		"Content-Type":  {"application/json"},
+	}, nil)
+	request.URL = &url.URL{Path: "/recipeApp/recipe", RawQuery: "owner=ada@example.com"}
+	request.Body = []byte(`{"user":"ada","password":"hunter2","contact":"ada@example.com"}`)
+
+	output := captureLog(t)
+	require.NoError(t, task.Apply(context.Background(), request))
+
+	logged := output.String()
+	for _, secret := range []string{"secret-token", "secret-cookie", "hunter2", "ada@example.com"} {
+		assert.NotContains(t, logged, secret)
+	}
+	assert.Contains(t, logged, "application/json")
+	assert.Contains(t, logged, `"user": "ada"`)
+}
+
+func TestLogWriterRedactsBody(t *testing.T) {
+	task, err := NewLogWriterTask(map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"name": "body", "value": "{{Request.Body}}"},
+		},
+		"redact": map[string]interface{}{"paths": []interface{}{"$.card.number"}},
+	})
+	require.NoError(t, err)
+
+	request := clientRequest(entity.HTTPMethodPOST, "10.0.0.1:1", nil, nil)
+	request.Body = []byte(`{"card":{"number":"4111111111111111"}}`)
+
+	output := captureLog(t)
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.NotContains(t, output.String(), "4111111111111111")
+	assert.Contains(t, output.String(), "[REDACTED]")
+}
+
+func TestLogRedactorUnknownSchemaMasksBody(t *testing.T) {
+	task, err := NewResponseLoggerTask(map[string]interface{}{
+		"redact": map[string]interface{}{
+			"schema": map[string]interface{}{"name": "NotRegistered", "version": "v1"},
+		},
+	})
+	require.NoError(t, err)
+
+	request := clientRequest(entity.HTTPMethodGET, "10.0.0.1:1", nil, nil)
+	request.SetResponse(&entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK, Header: http.Header{"Set-Cookie": {"id=secret"}}},
+		Body:         []byte(`{"phone":"555-0100"}`),
+	})
+
+	output := captureLog(t)
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.NotContains(t, output.String(), "555-0100")
+	assert.NotContains(t, output.String(), "id=secret")
+
+	_, err = NewRequestLoggerTask(map[string]interface{}{
+		"redact": map[string]interface{}{"detectors": []interface{}{"unknown"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestLogRedactorRejectsUnknownKeys(t *testing.T) {
+	_, err := NewRequestLoggerTask(map[string]interface{}{
+		"redact": map[string]interface{}{"headrs": []interface{}{"X-Card"}},
+	})
+	assert.EqualError(t, err, "invalid config: redact.headrs: unknown key")
+
+	schema := entity.ConfigSchema(&RequestLoggerConfig{})["properties"].(map[string]any)["redact"].(map[string]any)
+	assert.Contains(t, schema["properties"], "paths")
+}
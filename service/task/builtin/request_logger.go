@@ -3,34 +3,47 @@ package builtin
 import (
 	"context"
 	"log"
-	"strings"
 
 	"encoding/json"
 	"net/http"
 
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/redact"
 )
 
 type RequestLogger struct {
 	name     string
 	LogLevel string
+	redactor *logRedactor
 }
 
-func NewRequestLoggerTask(config map[string]interface{}) (entity.Task, error) {
-	rl := &RequestLogger{
-		name:     "",
-		LogLevel: "INFO",
-	}
+// RequestLoggerConfig is the config of the RequestLogger task type.
+type RequestLoggerConfig struct {
+	Name     string        `config:"name"`
+	LogLevel string        `config:"logLevel" default:"INFO"`
+	Redact   redact.Config `config:"redact" description:"schema and detectors of the values masked in the log"`
+}
 
-	if name, ok := config["name"].(string); ok {
-		rl.name = name
+func NewRequestLoggerTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg RequestLoggerConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
 	}
+	return NewRequestLogger(cfg)
+}
 
-	if level, ok := config["logLevel"].(string); ok {
-		rl.LogLevel = level
+// NewRequestLogger returns a RequestLogger task configured by config.
+func NewRequestLogger(config RequestLoggerConfig) (entity.Task, error) {
+	redactor, err := newLogRedactor(config.Redact)
+	if err != nil {
+		return nil, err
 	}
 
-	return rl, nil
+	return &RequestLogger{
+		name:     config.Name,
+		LogLevel: config.LogLevel,
+		redactor: redactor,
+	}, nil
 }
 
 func (rl *RequestLogger) Name() string {
@@ -39,39 +52,19 @@ func (rl *RequestLogger) Name() string {
 
 func (rl *RequestLogger) Apply(ctx context.Context, request entity.ServiceRequest) error {
 	logMessage := map[string]interface{}{
-		"Method": request.GetMethod().String(),
-		"URL":    request.GetURL().String(),
-		"Headers": func() map[string]string {
-			headers := make(map[string]string)
-			for k, v := range request.GetHeader() {
-				headers[k] = strings.Join(v, ", ")
-			}
-			return headers
-		}(),
-	}
-
-	// Deserialize body
-	var body interface{}
-	err := json.Unmarshal(request.GetBody(), &body)
-	if err != nil {
-		logMessage["Body"] = string(request.GetBody()) // Fallback to string if not JSON
-	} else {
-		logMessage["Body"] = body
+		"Method":  request.GetMethod().String(),
+		"URL":     rl.redactor.string(request.GetURL().String()),
+		"Headers": rl.redactor.headers(request.GetHeader()),
+		"Body":    rl.redactor.body(request.GetBody()),
 	}
 
 	// Log response if available
 	if response := request.GetResponse(); response != nil {
-		var responseBodyUnmarshaled interface{}
-		err := json.Unmarshal(response.GetBody(), &responseBodyUnmarshaled)
-		if err != nil {
-			responseBodyUnmarshaled = string(response.GetBody())
-		}
-
 		logMessage["Response"] = map[string]interface{}{
 			"StatusCode": response.GetResponseMeta().GetStatusCode(),
 			"Status":     http.StatusText(response.GetResponseMeta().GetStatusCode()),
-			"Headers":    response.GetResponseMeta().GetHeader(),
-			"Body":       responseBodyUnmarshaled,
+			"Headers":    rl.redactor.headers(response.GetResponseMeta().GetHeader()),
+			"Body":       rl.redactor.body(response.GetBody()),
 		}
 	}
 
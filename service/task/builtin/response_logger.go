@@ -5,31 +5,44 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"strings"
 
 	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/redact"
 )
 
 type ResponseLogger struct {
 	name     string
 	LogLevel string
+	redactor *logRedactor
 }
 
-func NewResponseLoggerTask(config map[string]interface{}) (entity.Task, error) {
-	rl := &ResponseLogger{
-		name:     "",
-		LogLevel: "INFO",
-	}
+// ResponseLoggerConfig is the config of the ResponseLogger task type.
+type ResponseLoggerConfig struct {
+	Name     string        `config:"name"`
+	LogLevel string        `config:"logLevel" default:"INFO"`
+	Redact   redact.Config `config:"redact" description:"schema and detectors of the values masked in the log"`
+}
 
-	if name, ok := config["name"].(string); ok {
-		rl.name = name
+func NewResponseLoggerTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg ResponseLoggerConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
 	}
+	return NewResponseLogger(cfg)
+}
 
-	if level, ok := config["logLevel"].(string); ok {
-		rl.LogLevel = level
+// NewResponseLogger returns a ResponseLogger task configured by config.
+func NewResponseLogger(config ResponseLoggerConfig) (entity.Task, error) {
+	redactor, err := newLogRedactor(config.Redact)
+	if err != nil {
+		return nil, err
 	}
 
-	return rl, nil
+	return &ResponseLogger{
+		name:     config.Name,
+		LogLevel: config.LogLevel,
+		redactor: redactor,
+	}, nil
 }
 
 func (rl *ResponseLogger) Name() string {
@@ -46,22 +59,8 @@ func (rl *ResponseLogger) Apply(ctx context.Context, request entity.ServiceReque
 	logMessage := map[string]interface{}{
 		"StatusCode": response.GetResponseMeta().GetStatusCode(),
 		"Status":     http.StatusText(response.GetResponseMeta().GetStatusCode()),
-		"Headers": func() map[string]string {
-			headers := make(map[string]string)
-			for k, v := range response.GetResponseMeta().GetHeader() {
-				headers[k] = strings.Join(v, ", ")
-			}
-			return headers
-		}(),
-	}
-
-	// Deserialize body
-	var body interface{}
-	err := json.Unmarshal(response.GetBody(), &body)
-	if err != nil {
-		logMessage["Body"] = string(response.GetBody()) // Fallback to string if not JSON
-	} else {
-		logMessage["Body"] = body
+		"Headers":    rl.redactor.headers(response.GetResponseMeta().GetHeader()),
+		"Body":       rl.redactor.body(response.GetBody()),
 	}
 
 	logJSON, _ := json.MarshalIndent(logMessage, "", "  ")
@@ -1 +1,244 @@
 package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWKSRefreshInterval is how long keys fetched from a JWKS URL are
+// used before the set is fetched again.
+const DefaultJWKSRefreshInterval = 15 * time.Minute
+
+// ValidateJWT is an inbound task which authenticates requests by validating
+// a bearer token. The claims of a valid token are placed on the request for
+// use by later tasks; any other request is rejected with 401 Unauthorized.
+//
+// Configuration:
+//   - issuer: required "iss" claim, if set
+//   - audience: a string or list, one of which must be in the "aud" claim
+//   - algorithms: accepted signing algorithms (HS256, RS256, ES256); defaults to RS256
+//   - clockSkew: leeway applied to time based claims, e.g. "30s"
+//   - secret or secretFile: shared secret for HS256
+//   - publicKeyFile: PEM encoded public key or certificate
+//   - jwksFile or jwksURL: a JSON Web Key Set, with jwksRefreshInterval for URLs
+//   - header: the header carrying the token; defaults to Authorization
+type ValidateJWT struct {
+	name       string
+	header     string
+	issuer     string
+	audience   []string
+	algorithms []string
+	clockSkew  time.Duration
+	keys       keySource
+}
+
+var supportedAlgorithms = map[string]bool{
+	"HS256": true,
+	"RS256": true,
+	"ES256": true,
+}
+
+// ValidateJWTConfig is the config of the ValidateJWT task type.
+type ValidateJWTConfig struct {
+	Name                string        `config:"name"`
+	Header              string        `config:"header" default:"Authorization" description:"the header carrying the token"`
+	Issuer              string        `config:"issuer" description:"required iss claim"`
+	Audience            []string      `config:"audience,single" description:"audiences, one of which must be in the aud claim"`
+	Algorithms          []string      `config:"algorithms,single" default:"[\"RS256\"]" description:"accepted signing algorithms: HS256, RS256 or ES256"`
+	ClockSkew           time.Duration `config:"clockSkew" description:"leeway applied to time based claims"`
+	Secret              string        `config:"secret" description:"shared secret for HS256"`
+	SecretFile          string        `config:"secretFile" description:"file holding the shared secret for HS256"`
+	PublicKeyFile       string        `config:"publicKeyFile" description:"PEM encoded public key or certificate"`
+	JWKSFile            string        `config:"jwksFile" description:"file holding a JSON Web Key Set"`
+	JWKSURL             string        `config:"jwksURL" description:"URL of a JSON Web Key Set"`
+	JWKSRefreshInterval time.Duration `config:"jwksRefreshInterval" default:"15m" description:"how long keys fetched from jwksURL are used"`
+}
+
+func NewValidateJWTTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg ValidateJWTConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewValidateJWT(cfg)
+}
+
+// NewValidateJWT returns a ValidateJWT task configured by config.
+func NewValidateJWT(config ValidateJWTConfig) (entity.Task, error) {
+	v := &ValidateJWT{
+		name:       config.Name,
+		header:     config.Header,
+		issuer:     config.Issuer,
+		audience:   config.Audience,
+		algorithms: config.Algorithms,
+		clockSkew:  config.ClockSkew,
+	}
+
+	if v.header == "" {
+		v.header = "Authorization"
+	}
+
+	if len(v.algorithms) == 0 {
+		v.algorithms = []string{"RS256"}
+	}
+	for _, algorithm := range v.algorithms {
+		if !supportedAlgorithms[algorithm] {
+			return nil, fmt.Errorf("unsupported JWT algorithm %s", algorithm)
+		}
+	}
+
+	keys, err := newKeySource(config)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+
+	return v, nil
+}
+
+// newKeySource builds the key source described by the task config.
+func newKeySource(config ValidateJWTConfig) (keySource, error) {
+	if config.JWKSURL != "" {
+		interval := config.JWKSRefreshInterval
+		if interval <= 0 {
+			interval = DefaultJWKSRefreshInterval
+		}
+		return newRemoteKeySet(config.JWKSURL, interval), nil
+	}
+
+	static := &staticKeys{}
+
+	if config.Secret != "" {
+		static.keys = append(static.keys, verificationKey{algorithm: "HS256", key: []byte(config.Secret)})
+	}
+
+	if file := config.SecretFile; file != "" {
+		secret, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secretFile %s: %w", file, err)
+		}
+		static.keys = append(static.keys, verificationKey{
+			algorithm: "HS256",
+			key:       []byte(strings.TrimSpace(string(secret))),
+		})
+	}
+
+	if file := config.PublicKeyFile; file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read publicKeyFile %s: %w", file, err)
+		}
+		key, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid publicKeyFile %s: %w", file, err)
+		}
+		static.keys = append(static.keys, verificationKey{key: key})
+	}
+
+	if file := config.JWKSFile; file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwksFile %s: %w", file, err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwksFile %s: %w", file, err)
+		}
+		static.keys = append(static.keys, keys...)
+	}
+
+	if len(static.keys) == 0 {
+		return nil, errors.New("ValidateJWT requires one of secret, secretFile, publicKeyFile, jwksFile or jwksURL")
+	}
+
+	return static, nil
+}
+
+func (v *ValidateJWT) Name() string {
+	return v.name
+}
+
+func (v *ValidateJWT) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	token, err := v.tokenFrom(request.GetHeader())
+	if err != nil {
+		return unauthorized(err)
+	}
+
+	claims := jwt.MapClaims{}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(v.algorithms),
+		jwt.WithLeeway(v.clockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+
+	_, err = jwt.NewParser(options...).ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		keyID, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, keyID, t.Method.Alg())
+	})
+	if err != nil {
+		return unauthorized(err)
+	}
+
+	if !v.audienceAccepted(claims) {
+		return unauthorized(jwt.ErrTokenInvalidAudience)
+	}
+
+	request.SetClaims(entity.Claims(claims))
+
+	return nil
+}
+
+// tokenFrom extracts the token from the configured header, removing the
+// Bearer scheme when reading the Authorization header.
+func (v *ValidateJWT) tokenFrom(header http.Header) (string, error) {
+	value := strings.TrimSpace(header.Get(v.header))
+	if value == "" {
+		return "", errors.New("missing bearer token")
+	}
+
+	if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token), nil
+	}
+
+	if strings.EqualFold(v.header, "Authorization") {
+		return "", errors.New("authorization scheme must be Bearer")
+	}
+
+	return value, nil
+}
+
+func (v *ValidateJWT) audienceAccepted(claims jwt.MapClaims) bool {
+	if len(v.audience) == 0 {
+		return true
+	}
+
+	audience, err := claims.GetAudience()
+	if err != nil {
+		return false
+	}
+
+	for _, accepted := range v.audience {
+		for _, aud := range audience {
+			if aud == accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unauthorized(err error) error {
+	return domainerr.NewHTTPError(http.StatusUnauthorized, fmt.Errorf("%w: %v", domainerr.ErrUnauthorized, err)).
+		WithHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+}
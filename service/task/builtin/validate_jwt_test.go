@@ -0,0 +1,256 @@
+package builtin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	domainerr "github.com/QueerGlobal/hub-framework/core/entity/error"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bearerRequest(token string) *entity.HTTPServiceRequest {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return &entity.HTTPServiceRequest{Method: entity.HTTPMethodGET, Header: header}
+}
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func requireUnauthorized(t *testing.T, err error) {
+	var httpErr *domainerr.HTTPError
+	require.True(t, errors.As(err, &httpErr), "expected HTTPError, got %v", err)
+	assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	assert.ErrorIs(t, err, domainerr.ErrUnauthorized)
+	assert.NotEmpty(t, httpErr.Header.Get("WWW-Authenticate"))
+}
+
+func TestValidateJWTAcceptsValidToken(t *testing.T) {
+	task, err := NewValidateJWTTask(map[string]interface{}{
+		"secret":     "s3cret",
+		"algorithms": []interface{}{"HS256"},
+		"issuer":     "https://issuer.example",
+		"audience":   []interface{}{"hub", "other"},
+	})
+	require.NoError(t, err)
+
+	request := bearerRequest(signHS256(t, "s3cret", jwt.MapClaims{
+		"sub":   "alice",
+		"iss":   "https://issuer.example",
+		"aud":   "hub",
+		"scope": "read write",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}))
+
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.Equal(t, "alice", request.GetClaims().Subject())
+	assert.Equal(t, []string{"read", "write"}, request.GetClaims().Scopes())
+}
+
+func TestValidateJWTRejectsInvalidTokens(t *testing.T) {
+	task, err := NewValidateJWTTask(map[string]interface{}{
+		"secret":     "s3cret",
+		"algorithms": "HS256",
+		"issuer":     "https://issuer.example",
+		"audience":   "hub",
+		"clockSkew":  "30s",
+	})
+	require.NoError(t, err)
+
+	valid := jwt.MapClaims{"iss": "https://issuer.example", "aud": "hub"}
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := map[string]string{
+		"missing token":   "",
+		"wrong secret":    signHS256(t, "other", with("sub", "alice")),
+		"wrong issuer":    signHS256(t, "s3cret", with("iss", "https://evil.example")),
+		"wrong audience":  signHS256(t, "s3cret", with("aud", "elsewhere")),
+		"expired":         signHS256(t, "s3cret", with("exp", time.Now().Add(-time.Minute).Unix())),
+		"no expiry":       signHS256(t, "s3cret", with("exp", nil)),
+		"malformed token": "not-a-jwt",
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := bearerRequest(token)
+			requireUnauthorized(t, task.Apply(context.Background(), request))
+			assert.Nil(t, request.GetClaims())
+		})
+	}
+
+	// expired within the clock skew is still accepted
+	token := signHS256(t, "s3cret", with("exp", time.Now().Add(-10*time.Second).Unix()))
+	assert.NoError(t, task.Apply(context.Background(), bearerRequest(token)))
+}
+
+func TestValidateJWTRejectsUnlistedAlgorithm(t *testing.T) {
+	task, err := NewValidateJWTTask(map[string]interface{}{
+		"secret": "s3cret",
+	})
+	require.NoError(t, err)
+
+	// RS256 is the default, so HMAC signed tokens must be refused
+	token := signHS256(t, "s3cret", jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})
+	requireUnauthorized(t, task.Apply(context.Background(), bearerRequest(token)))
+}
+
+func ecJWKS(t *testing.T, keys map[string]*ecdsa.PrivateKey) []byte {
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, map[string]string{
+			"kty": "EC",
+			"kid": kid,
+			"alg": "ES256",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+func signES256(t *testing.T, kid string, key *ecdsa.PrivateKey) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"sub": "bob",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestValidateJWTWithJWKSFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(file, ecJWKS(t, map[string]*ecdsa.PrivateKey{"k1": key}), 0o600))
+
+	task, err := NewValidateJWTTask(map[string]interface{}{
+		"jwksFile":   file,
+		"algorithms": []interface{}{"ES256"},
+	})
+	require.NoError(t, err)
+
+	request := bearerRequest(signES256(t, "k1", key))
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.Equal(t, "bob", request.GetClaims().Subject())
+
+	requireUnauthorized(t, task.Apply(context.Background(), bearerRequest(signES256(t, "k2", key))))
+}
+
+func TestValidateJWTRefreshesRotatedJWKS(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var rotated atomic.Bool
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if rotated.Load() {
+			w.Write(ecJWKS(t, map[string]*ecdsa.PrivateKey{"new": newKey}))
+			return
+		}
+		w.Write(ecJWKS(t, map[string]*ecdsa.PrivateKey{"old": oldKey}))
+	}))
+	defer server.Close()
+
+	task, err := NewValidateJWTTask(map[string]interface{}{
+		"jwksURL":    server.URL,
+		"algorithms": []interface{}{"ES256"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, task.Apply(context.Background(), bearerRequest(signES256(t, "old", oldKey))))
+	require.NoError(t, task.Apply(context.Background(), bearerRequest(signES256(t, "old", oldKey))))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "keys should be cached")
+
+	rotated.Store(true)
+	// allow an unknown key ID to trigger a refresh
+	task.(*ValidateJWT).keys.(*remoteKeySet).lastAttempt = time.Time{}
+
+	require.NoError(t, task.Apply(context.Background(), bearerRequest(signES256(t, "new", newKey))))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestValidateJWTKeepsStaleJWKSWhileEndpointIsDown(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var down atomic.Bool
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(ecJWKS(t, map[string]*ecdsa.PrivateKey{"k1": key}))
+	}))
+	defer server.Close()
+
+	task, err := NewValidateJWTTask(map[string]interface{}{
+		"jwksURL":             server.URL,
+		"jwksRefreshInterval": "1ms",
+		"algorithms":          []interface{}{"ES256"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, task.Apply(context.Background(), bearerRequest(signES256(t, "k1", key))))
+
+	down.Store(true)
+	task.(*ValidateJWT).keys.(*remoteKeySet).lastAttempt = time.Time{}
+	time.Sleep(2 * time.Millisecond)
+
+	// the failed refresh is not retried by every request
+	for i := 0; i < 3; i++ {
+		require.NoError(t, task.Apply(context.Background(), bearerRequest(signES256(t, "k1", key))))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestNewValidateJWTTaskRequiresKeys(t *testing.T) {
+	_, err := NewValidateJWTTask(map[string]interface{}{"issuer": "https://issuer.example"})
+	assert.Error(t, err)
+
+	_, err = NewValidateJWTTask(map[string]interface{}{"secret": "s3cret", "algorithms": "none"})
+	assert.Error(t, err)
+}
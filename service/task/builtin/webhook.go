@@ -0,0 +1,182 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/webhook"
+	"github.com/QueerGlobal/hub-framework/util"
+)
+
+// Webhook notifies partner URLs of changes by POSTing the request body,
+// signed with HMAC-SHA256, to every configured URL. Deliveries are queued
+// and sent in the background, retried with backoff and stored as dead
+// letters once retries are exhausted, from where they can be replayed
+// through the webhook admin API. It is available both as a task and as a
+// target.
+//
+// Configuration:
+//   - name: identifies the webhook for replays; defaults to "webhook".
+//     Tasks and targets configuring the same webhook share it, and a
+//     webhook configured with other settings, as on a reload, replaces it
+//   - urls (or url): the URLs to notify
+//   - secret or secretFile: the HMAC signing secret
+//   - event: the X-Hub-Event header; defaults to <service>.<method>
+//   - signatureHeader, timestampHeader: default to X-Hub-Signature and X-Hub-Timestamp
+//   - timeout: per attempt, e.g. "10s"
+//   - retries: initialDelay, maxDelay, multiplier and maxRetries
+//   - queueSize: deliveries waiting to be sent, beyond which they are
+//     stored as dead letters; defaults to 1000
+//   - deadLetterPath: the Badger directory for dead letters; defaults to ./data/webhooks
+type Webhook struct {
+	name    string
+	event   string
+	webhook *webhook.Webhook
+}
+
+// WebhookConfig is the config of the Webhook task and target types.
+type WebhookConfig struct {
+	Name            string         `config:"name" description:"identifies the webhook for replays; defaults to webhook"`
+	Event           string         `config:"event" description:"the X-Hub-Event header; defaults to <service>.<method>"`
+	URLs            []string       `config:"urls,single" description:"the URLs to notify"`
+	URL             []string       `config:"url,single" description:"the URLs to notify, as for urls"`
+	Secret          string         `config:"secret" description:"the HMAC signing secret"`
+	SecretFile      string         `config:"secretFile" description:"file holding the HMAC signing secret"`
+	SignatureHeader string         `config:"signatureHeader" default:"X-Hub-Signature"`
+	TimestampHeader string         `config:"timestampHeader" default:"X-Hub-Timestamp"`
+	Timeout         time.Duration  `config:"timeout" default:"10s" description:"per delivery attempt"`
+	Retries         WebhookRetries `config:"retries"`
+	QueueSize       int            `config:"queueSize" description:"deliveries waiting to be sent, beyond which they are stored as dead letters"`
+	DeadLetterPath  string         `config:"deadLetterPath" description:"the Badger directory for dead letters"`
+}
+
+// WebhookRetries is the backoff between the delivery attempts of a Webhook.
+type WebhookRetries struct {
+	InitialDelay time.Duration `config:"initialDelay"`
+	MaxDelay     time.Duration `config:"maxDelay"`
+	Multiplier   float64       `config:"multiplier"`
+	MaxRetries   int           `config:"maxRetries"`
+}
+
+func NewWebhookTask(config map[string]interface{}) (entity.Task, error) {
+	var cfg WebhookConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewWebhook(cfg)
+}
+
+// NewWebhook returns a Webhook task configured by config.
+func NewWebhook(config WebhookConfig) (entity.Task, error) {
+	return newWebhook(config)
+}
+
+// NewWebhookTarget creates a Webhook target, which answers 202 Accepted once
+// the payload has been queued for delivery or stored as a dead letter.
+func NewWebhookTarget(config map[string]interface{}) (entity.Target, error) {
+	var cfg WebhookConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	return NewWebhookTargetFromConfig(cfg)
+}
+
+// NewWebhookTargetFromConfig returns a Webhook target configured by config.
+func NewWebhookTargetFromConfig(config WebhookConfig) (entity.Target, error) {
+	w, err := newWebhook(config)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookTarget{w}, nil
+}
+
+func newWebhook(config WebhookConfig) (*Webhook, error) {
+	w := &Webhook{name: config.Name, event: config.Event}
+
+	webhookConfig := webhook.Config{
+		Name:            config.Name,
+		URLs:            append(config.URLs, config.URL...),
+		Secret:          []byte(config.Secret),
+		SignatureHeader: config.SignatureHeader,
+		TimestampHeader: config.TimestampHeader,
+		Timeout:         config.Timeout,
+		Backoff: util.BackoffConfig{
+			InitialDelay: config.Retries.InitialDelay,
+			MaxDelay:     config.Retries.MaxDelay,
+			Multiplier:   config.Retries.Multiplier,
+			MaxRetries:   config.Retries.MaxRetries,
+		},
+		QueueSize: config.QueueSize,
+	}
+	if webhookConfig.Name == "" {
+		webhookConfig.Name = "webhook"
+	}
+
+	if file := config.SecretFile; file != "" {
+		secret, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secretFile %s: %w", file, err)
+		}
+		webhookConfig.Secret = []byte(strings.TrimSpace(string(secret)))
+	}
+
+	path := config.DeadLetterPath
+	if path == "" {
+		path = webhook.DefaultPath
+	}
+
+	store, err := webhook.OpenBadgerStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := webhook.Open(webhookConfig, store)
+	if err != nil {
+		return nil, err
+	}
+	w.webhook = delivery
+
+	return w, nil
+}
+
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+func (w *Webhook) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	event := w.event
+	if event == "" {
+		event = request.GetServiceName() + "." + strings.ToLower(request.GetMethod().String())
+	}
+
+	// handlers built before a reload deliver through the webhook which
+	// replaced theirs
+	delivery := w.webhook
+	if current, err := webhook.Lookup(delivery.Name()); err == nil {
+		delivery = current
+	}
+
+	return delivery.Deliver(ctx, webhook.Event{Type: event, Payload: request.GetBody()})
+}
+
+type webhookTarget struct {
+	*Webhook
+}
+
+func (t *webhookTarget) Apply(ctx context.Context, request entity.ServiceRequest) (entity.ServiceResponse, error) {
+	if err := t.Webhook.Apply(ctx, request); err != nil {
+		return nil, err
+	}
+
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{
+			StatusCode: http.StatusAccepted,
+			Header:     http.Header{},
+		},
+	}, nil
+}
@@ -0,0 +1,79 @@
+package builtin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/QueerGlobal/hub-framework/service/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookTarget(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, webhook.Sign([]byte("s3cret"), r.Header.Get("X-Partner-Timestamp"), body), r.Header.Get(webhook.DefaultSignatureHeader))
+		received <- r
+	}))
+	defer server.Close()
+
+	target, err := NewWebhookTarget(map[string]interface{}{
+		"name":            "partners",
+		"urls":            []interface{}{server.URL},
+		"secret":          "s3cret",
+		"timestampHeader": "X-Partner-Timestamp",
+		"deadLetterPath":  t.TempDir(),
+		"retries":         map[string]interface{}{"initialDelay": "1ms", "maxRetries": 2},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { webhook.CloseAll() })
+
+	request := clientRequest(entity.HTTPMethodPUT, "10.0.0.1:1", nil, nil)
+	request.Body = []byte(`{"id":"1"}`)
+
+	response, err := target.Apply(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, response.GetResponseMeta().GetStatusCode())
+	assert.Equal(t, "recipe.put", (<-received).Header.Get(webhook.EventHeader))
+
+	_, err = webhook.Lookup("partners")
+	assert.NoError(t, err)
+}
+
+func TestWebhookConfigErrors(t *testing.T) {
+	_, err := NewWebhookTask(map[string]interface{}{"secret": "s", "deadLetterPath": t.TempDir()})
+	assert.Error(t, err, "urls are required")
+
+	_, err = NewWebhookTask(map[string]interface{}{"url": "http://localhost", "deadLetterPath": t.TempDir()})
+	assert.Error(t, err, "a secret is required")
+
+	_, err = NewWebhookTask(map[string]interface{}{"url": "http://localhost", "secret": "s", "timeout": "soon"})
+	assert.Error(t, err)
+}
+
+func TestWebhooksWithOtherSettingsReplaceTheirName(t *testing.T) {
+	deadLetters := t.TempDir()
+	t.Cleanup(func() { webhook.CloseAll() })
+
+	_, err := NewWebhookTask(map[string]interface{}{"url": "http://localhost/a", "secret": "s", "deadLetterPath": deadLetters})
+	require.NoError(t, err)
+
+	_, err = NewWebhookTarget(map[string]interface{}{"url": "http://localhost/a", "secret": "s", "deadLetterPath": deadLetters})
+	assert.NoError(t, err, "the same webhook may be used by several handlers")
+	shared, err := webhook.Lookup("webhook")
+	require.NoError(t, err)
+
+	_, err = NewWebhookTask(map[string]interface{}{"url": "http://localhost/b", "secret": "s", "deadLetterPath": deadLetters})
+	require.NoError(t, err)
+	replaced, err := webhook.Lookup("webhook")
+	require.NoError(t, err)
+	assert.NotSame(t, shared, replaced)
+
+	_, err = NewWebhookTask(map[string]interface{}{"name": "b", "url": "http://localhost/b", "secret": "s", "deadLetterPath": deadLetters})
+	assert.NoError(t, err)
+}
@@ -3,12 +3,14 @@ package remote
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
-	"time"
 
 	"github.com/QueerGlobal/hub-framework/core/entity"
 	"github.com/QueerGlobal/hub-framework/util"
@@ -21,57 +23,100 @@ type ForwardingService struct {
 	PathPrefix string
 	name       string
 	backoff    *util.Backoff
+	client     *http.Client
 }
 
-func NewForwardingService(config map[string]interface{}) (entity.Task, error) {
-	svc := ForwardingService{}
+// ForwardingServiceConfig is the config of the HttpService task type.
+// Backoff delays are durations such as "500ms", or numbers of seconds.
+type ForwardingServiceConfig struct {
+	Host       string             `config:"host,required" description:"scheme and host requests are forwarded to"`
+	PathPrefix string             `config:"pathPrefix" description:"path prepended to the path of forwarded requests"`
+	Backoff    util.BackoffConfig `config:"backoff"`
+	TLS        *ClientTLSConfig   `config:"tls"`
+}
+
+// ClientTLSConfig configures TLS for calls to a remote service, presenting
+// a client certificate when the remote side requires mutual TLS.
+type ClientTLSConfig struct {
+	CertFile string `config:"certFile"`
+	KeyFile  string `config:"keyFile"`
+	CAFile   string `config:"caFile"`
+}
 
-	if host, ok := config["host"].(string); ok {
-		svc.Host = host
+func NewForwardingService(config map[string]interface{}) (entity.Task, error) {
+	var cfg ForwardingServiceConfig
+	if err := entity.DecodeConfig(config, &cfg); err != nil {
+		return nil, err
 	}
+	return NewForwardingServiceTask(cfg)
+}
 
-	if pathPrefix, ok := config["pathprefix"].(string); ok {
-		if len(pathPrefix) > 0 {
-			// Prepend "/" if it doesn't exist
-			if !strings.HasPrefix(pathPrefix, "/") {
-				pathPrefix = "/" + pathPrefix
-			}
-
-			// Append "/" if it doesn't exist
-			if !strings.HasSuffix(pathPrefix, "/") {
-				pathPrefix = pathPrefix + "/"
-			}
+// NewForwardingServiceTask returns a ForwardingService task configured by
+// config.
+func NewForwardingServiceTask(config ForwardingServiceConfig) (entity.Task, error) {
+	svc := ForwardingService{Host: config.Host}
+
+	if pathPrefix := config.PathPrefix; len(pathPrefix) > 0 {
+		// Prepend "/" if it doesn't exist
+		if !strings.HasPrefix(pathPrefix, "/") {
+			pathPrefix = "/" + pathPrefix
+		}
+
+		// Append "/" if it doesn't exist
+		if !strings.HasSuffix(pathPrefix, "/") {
+			pathPrefix = pathPrefix + "/"
 		}
 
 		svc.PathPrefix = pathPrefix
 	}
 
-	var backoffConfig util.BackoffConfig
-	if backoff, ok := config["backoff"].(map[string]interface{}); ok {
-		if initialDelay, ok := backoff["initialDelay"].(float64); ok {
-			backoffConfig.InitialDelay = time.Duration(initialDelay) * time.Second
+	svc.backoff = util.NewBackoff(config.Backoff)
+
+	svc.client = &http.Client{}
+	if config.TLS != nil {
+		clientTLS, err := newClientTLSConfig(*config.TLS)
+		if err != nil {
+			return nil, err
 		}
-		if maxDelay, ok := backoff["maxDelay"].(float64); ok {
-			backoffConfig.MaxDelay = time.Duration(maxDelay) * time.Second
+		svc.client.Transport = &http.Transport{TLSClientConfig: clientTLS}
+	}
+
+	return &svc, nil
+}
+
+// newClientTLSConfig builds the TLS config used to call the remote service,
+// presenting a client certificate when the remote side requires mutual TLS.
+func newClientTLSConfig(config ClientTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
 		}
-		if multiplier, ok := backoff["multiplier"].(float64); ok {
-			backoffConfig.Multiplier = multiplier
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if caFile := config.CAFile; caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", caFile, err)
 		}
-		if maxRetries, ok := backoff["maxRetries"].(float64); ok {
-			backoffConfig.MaxRetries = int(maxRetries)
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
 		}
+		tlsConfig.RootCAs = rootCAs
 	}
 
-	backoff := util.NewBackoff(backoffConfig)
-	svc.backoff = backoff
-
-	return &svc, nil
+	return tlsConfig, nil
 }
 
 func (fs *ForwardingService) Apply(ctx context.Context, request entity.ServiceRequest) error {
 	var serviceResponse entity.ServiceRequest
 
-	err := fs.backoff.ExecuteWithBackoff(func() error {
+	err := fs.backoff.ExecuteWithBackoff(ctx, func() error {
 		response, err := fs.forwardRequest(ctx, request)
 		if err != nil {
 			return err
@@ -131,8 +176,7 @@ func (fs *ForwardingService) forwardRequest(ctx context.Context, request entity.
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	// Perform the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := fs.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform HTTP request: %w", err)
 	}
@@ -0,0 +1,296 @@
+package remote
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DefaultSidecarTimeout bounds each call to a sidecar when no timeout is
+// configured.
+const DefaultSidecarTimeout = 10 * time.Second
+
+// maxExchangeSize bounds the documents accepted from a sidecar.
+const maxExchangeSize = 32 << 20
+
+// SidecarConfig locates a sidecar process running the task and target
+// types written in a language other than Go. A task type named name is
+// called at {Host}{PathPrefix}/tasks/{name}, and a target type at
+// {Host}{PathPrefix}/targets/{name}.
+type SidecarConfig struct {
+	Host       string
+	PathPrefix string
+	Timeout    time.Duration
+	CertFile   string // client certificate, for sidecars requiring mutual TLS
+	KeyFile    string
+	CAFile     string // CA verifying the sidecar, when it is not publicly trusted
+}
+
+// Exchange is the JSON document posted to a sidecar for each request. The
+// sidecar answers with the document, changed as the task or target sees
+// fit: tasks may change the body, header and response, and targets return
+// the response. Bodies are base64 encoded, so that binary bodies survive
+// the trip, and a task answering without a body, header or response leaves
+// that part of the request as it was.
+type Exchange struct {
+	ID          string            `json:"id"`
+	Method      string            `json:"method"`
+	APIName     string            `json:"apiName"`
+	ServiceName string            `json:"serviceName"`
+	Path        string            `json:"path"`
+	Header      http.Header       `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	Claims      entity.Claims     `json:"claims,omitempty"`
+	Config      map[string]any    `json:"config,omitempty"`
+	Response    *ExchangeResponse `json:"response,omitempty"`
+}
+
+// ExchangeResponse is the response part of an Exchange.
+type ExchangeResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// sidecarClient calls a single task or target type of a sidecar.
+type sidecarClient struct {
+	url    string
+	name   string
+	config map[string]any
+	client *http.Client
+}
+
+func newSidecarClient(sidecar SidecarConfig, kind, name string, config map[string]any) (*sidecarClient, error) {
+	if sidecar.Host == "" {
+		return nil, fmt.Errorf("sidecar for %s %s has no host", strings.TrimSuffix(kind, "s"), name)
+	}
+
+	timeout := sidecar.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSidecarTimeout
+	}
+
+	client := &http.Client{Timeout: timeout}
+	if sidecar.CertFile != "" || sidecar.KeyFile != "" || sidecar.CAFile != "" {
+		clientTLS, err := newClientTLSConfig(ClientTLSConfig{
+			CertFile: sidecar.CertFile,
+			KeyFile:  sidecar.KeyFile,
+			CAFile:   sidecar.CAFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &http.Transport{TLSClientConfig: clientTLS}
+	}
+
+	return &sidecarClient{
+		url:    strings.TrimSuffix(sidecar.Host, "/") + path.Join("/", sidecar.PathPrefix, kind, name),
+		name:   name,
+		config: config,
+		client: client,
+	}, nil
+}
+
+// call posts the exchange for request to the sidecar and returns its
+// answer.
+func (s *sidecarClient) call(ctx context.Context, request entity.ServiceRequest) (*Exchange, error) {
+	exchange := Exchange{
+		ID:          request.GetID().String(),
+		Method:      request.GetMethod().String(),
+		APIName:     request.GetAPIName(),
+		ServiceName: request.GetServiceName(),
+		Path:        request.GetInternalPath(),
+		Header:      request.GetHeader(),
+		Claims:      request.GetClaims(),
+		Config:      s.config,
+	}
+	if response := request.GetResponse(); response != nil && response.GetResponseMeta() != nil {
+		exchange.Response = &ExchangeResponse{
+			StatusCode: response.GetResponseMeta().GetStatusCode(),
+			Header:     response.GetResponseMeta().GetHeader(),
+			Body:       response.GetBody(),
+		}
+	}
+
+	// the body is streamed into the document, so that it is not held in
+	// memory along with its encoding
+	rest, err := json.Marshal(exchange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request for %s: %w", s.name, err)
+	}
+
+	body, err := bodyReader(request)
+	if err != nil {
+		return nil, err
+	}
+
+	document, writer := io.Pipe()
+	go func() {
+		defer body.Close()
+		writer.CloseWithError(writeExchange(writer, body, rest))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, document)
+	if err != nil {
+		document.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call sidecar for %s: %w", s.name, err)
+	}
+	defer resp.Body.Close()
+
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, maxExchangeSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidecar response for %s: %w", s.name, err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("sidecar failed to apply %s: status %d: %s", s.name, resp.StatusCode, strings.TrimSpace(string(answer)))
+	}
+
+	var result Exchange
+	if err := json.Unmarshal(answer, &result); err != nil {
+		return nil, fmt.Errorf("invalid sidecar response for %s: %w", s.name, err)
+	}
+
+	return &result, nil
+}
+
+// bodyReader returns a reader over the body of request. Streaming bodies
+// are buffered first, spilling to disk once they are large, so that the
+// body is still there for the tasks and target which follow when the
+// sidecar leaves it unchanged.
+func bodyReader(request entity.ServiceRequest) (io.ReadCloser, error) {
+	if buffered, ok := request.(interface{ BufferBody() error }); ok {
+		if err := buffered.BufferBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	return request.GetBodyReader()
+}
+
+// writeExchange writes the JSON document marshalled as rest, which has no
+// body, to w with body base64 encoded as its body.
+func writeExchange(w io.Writer, body io.Reader, rest []byte) error {
+	if _, err := io.WriteString(w, `{"body":"`); err != nil {
+		return err
+	}
+
+	encoder := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(encoder, body); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `",`); err != nil {
+		return err
+	}
+	_, err := w.Write(rest[1:])
+	return err
+}
+
+func toServiceResponse(response *ExchangeResponse) entity.ServiceResponse {
+	return &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{
+			StatusCode: response.StatusCode,
+			Header:     response.Header,
+		},
+		Body: response.Body,
+	}
+}
+
+// SidecarTask is a task type run by a sidecar.
+type SidecarTask struct {
+	sidecar *sidecarClient
+}
+
+// NewSidecarTaskConstructor returns the constructor of a task type named
+// name which is run by sidecar. The config of each task is sent to the
+// sidecar with every request.
+func NewSidecarTaskConstructor(sidecar SidecarConfig, name string) entity.TaskConstructorFunc {
+	return func(config map[string]any) (entity.Task, error) {
+		client, err := newSidecarClient(sidecar, "tasks", name, config)
+		if err != nil {
+			return nil, err
+		}
+		return &SidecarTask{sidecar: client}, nil
+	}
+}
+
+// Apply sends the request to the sidecar and applies the body, header and
+// response it answers with.
+func (t *SidecarTask) Apply(ctx context.Context, request entity.ServiceRequest) error {
+	result, err := t.sidecar.call(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if result.Body != nil {
+		request.SetBody(result.Body)
+	}
+	if result.Header != nil {
+		request.SetHeader(result.Header)
+	}
+	if result.Response != nil {
+		request.SetResponse(toServiceResponse(result.Response))
+	}
+
+	return nil
+}
+
+func (t *SidecarTask) Name() string {
+	return t.sidecar.name
+}
+
+// SidecarTarget is a target type run by a sidecar.
+type SidecarTarget struct {
+	sidecar *sidecarClient
+}
+
+// NewSidecarTargetConstructor returns the constructor of a target type
+// named name which is run by sidecar. The config of each target is sent to
+// the sidecar with every request.
+func NewSidecarTargetConstructor(sidecar SidecarConfig, name string) entity.TargetConstructorFunc {
+	return func(config map[string]any) (entity.Target, error) {
+		client, err := newSidecarClient(sidecar, "targets", name, config)
+		if err != nil {
+			return nil, err
+		}
+		return &SidecarTarget{sidecar: client}, nil
+	}
+}
+
+// Apply sends the request to the sidecar and returns the response it
+// answers with.
+func (t *SidecarTarget) Apply(ctx context.Context, request entity.ServiceRequest) (entity.ServiceResponse, error) {
+	result, err := t.sidecar.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if result.Response == nil {
+		return nil, fmt.Errorf("sidecar returned no response for %s", t.sidecar.name)
+	}
+
+	return toServiceResponse(result.Response), nil
+}
+
+func (t *SidecarTarget) Name() string {
+	return t.sidecar.name
+}
@@ -0,0 +1,100 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QueerGlobal/hub-framework/core/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// binaryBody is not valid UTF-8, and would be mangled as a JSON string.
+var binaryBody = []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe, 0x80}
+
+// sidecar stands in for a sidecar, answering each exchange it receives
+// with the one answer returns.
+func sidecar(t *testing.T, answer func(exchange Exchange) any) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var exchange Exchange
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&exchange))
+		require.NoError(t, json.NewEncoder(w).Encode(answer(exchange)))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func streamingRequest(body []byte) *entity.HTTPServiceRequest {
+	return &entity.HTTPServiceRequest{
+		Method:       entity.HTTPMethodPOST,
+		InternalPath: "/app/image",
+		Header:       http.Header{},
+		// spilled to disk rather than held in memory
+		BodyStream: entity.NewRequestBody(io.NopCloser(bytes.NewReader(body)), entity.BodyOptions{Streaming: true, SpillThreshold: 4}),
+	}
+}
+
+func TestSidecarTask_BinaryBodies(t *testing.T) {
+	server := sidecar(t, func(exchange Exchange) any {
+		assert.Equal(t, binaryBody, exchange.Body)
+		exchange.Body = bytes.Repeat(exchange.Body, 2)
+		return exchange
+	})
+
+	constructor := NewSidecarTaskConstructor(SidecarConfig{Host: server.URL}, "double")
+	task, err := constructor(nil)
+	require.NoError(t, err)
+
+	request := streamingRequest(binaryBody)
+	defer request.Close()
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.Equal(t, bytes.Repeat(binaryBody, 2), request.GetBody())
+}
+
+func TestSidecarTask_OmittedFieldsAreKept(t *testing.T) {
+	server := sidecar(t, func(exchange Exchange) any {
+		// only the header is returned
+		return map[string]any{"header": http.Header{"X-Checked": {"yes"}}}
+	})
+
+	constructor := NewSidecarTaskConstructor(SidecarConfig{Host: server.URL}, "check")
+	task, err := constructor(nil)
+	require.NoError(t, err)
+
+	request := streamingRequest(binaryBody)
+	defer request.Close()
+	response := &entity.HttpServiceResponse{
+		ResponseMeta: &entity.HttpResponseMeta{StatusCode: http.StatusOK, Header: http.Header{}},
+		Body:         []byte("ok"),
+	}
+	request.SetResponse(response)
+
+	require.NoError(t, task.Apply(context.Background(), request))
+	assert.Equal(t, binaryBody, request.GetBody())
+	assert.Equal(t, "yes", request.GetHeader().Get("X-Checked"))
+	assert.Same(t, response, request.GetResponse())
+}
+
+func TestSidecarTarget_BinaryResponse(t *testing.T) {
+	server := sidecar(t, func(exchange Exchange) any {
+		exchange.Response = &ExchangeResponse{StatusCode: http.StatusCreated, Body: exchange.Body}
+		return exchange
+	})
+
+	constructor := NewSidecarTargetConstructor(SidecarConfig{Host: server.URL}, "store")
+	target, err := constructor(nil)
+	require.NoError(t, err)
+
+	request := streamingRequest(binaryBody)
+	defer request.Close()
+	response, err := target.Apply(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.GetResponseMeta().GetStatusCode())
+	assert.Equal(t, binaryBody, response.GetBody())
+}
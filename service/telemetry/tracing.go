@@ -0,0 +1,172 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Supported values for TracingConfig.Exporter
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// ErrUnknownExporter is returned when the configured exporter is not supported.
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// TracingConfig describes how spans produced by the hub are sampled and exported.
+type TracingConfig struct {
+	Enabled            bool
+	ServiceName        string
+	ServiceVersion     string
+	SamplerRatio       float64
+	Exporter           string
+	Endpoint           string
+	Insecure           bool
+	Headers            map[string]string
+	FilePath           string
+	ResourceAttributes map[string]string
+}
+
+// ShutdownFunc flushes any pending spans and releases exporter resources.
+type ShutdownFunc func(ctx context.Context) error
+
+// RegisterPropagators installs W3C tracecontext and baggage propagation as
+// the global text map propagator.
+func RegisterPropagators() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// InitTracing builds a TracerProvider from the given config and installs it
+// as the global provider. When tracing is disabled the global no-op provider
+// is left in place and a no-op shutdown function is returned.
+func InitTracing(ctx context.Context, cfg TracingConfig) (ShutdownFunc, error) {
+	if !cfg.Enabled || strings.EqualFold(cfg.Exporter, ExporterNone) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	tp, closer, err := NewTracerProvider(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// NewTracerProvider creates a TracerProvider with the sampler, resource and
+// exporter described by cfg. The returned io.Closer is non-nil when the
+// exporter holds a resource (such as an open file) that must be closed after
+// the provider has been shut down.
+func NewTracerProvider(ctx context.Context, cfg TracingConfig) (*sdktrace.TracerProvider, io.Closer, error) {
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := newResource(cfg)
+	if err != nil {
+		return nil, closer, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SamplerRatio))),
+	)
+
+	return tp, closer, nil
+}
+
+func newExporter(ctx context.Context, cfg TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch strings.ToLower(cfg.Exporter) {
+	case ExporterOTLP, "":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		return exporter, nil, nil
+
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		return exporter, nil, nil
+
+	case ExporterFile:
+		if cfg.FilePath == "" {
+			return nil, nil, fmt.Errorf("file exporter requires a filePath")
+		}
+
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file %s: %w", cfg.FilePath, err)
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		return exporter, file, nil
+
+	default:
+		return nil, nil, fmt.Errorf("%s: %w", cfg.Exporter, ErrUnknownExporter)
+	}
+}
+
+func newResource(cfg TracingConfig) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(cfg.ServiceName),
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	for k, v := range cfg.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	return resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, attrs...),
+	)
+}
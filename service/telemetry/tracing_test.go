@@ -0,0 +1,57 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestInitTracingDisabled(t *testing.T) {
+	shutdown, err := InitTracing(context.Background(), TracingConfig{Enabled: false})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestInitTracingUnknownExporter(t *testing.T) {
+	_, err := InitTracing(context.Background(), TracingConfig{Enabled: true, Exporter: "carrier-pigeon"})
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func TestFileExporterWritesSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := InitTracing(context.Background(), TracingConfig{
+		Enabled:            true,
+		ServiceName:        "test-app",
+		ServiceVersion:     "v0.0.1",
+		SamplerRatio:       1.0,
+		Exporter:           ExporterFile,
+		FilePath:           path,
+		ResourceAttributes: map[string]string{"deployment.environment": "test"},
+	})
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "test-span")
+	assert.Contains(t, string(data), "test-app")
+	assert.Contains(t, string(data), "deployment.environment")
+}
+
+func TestRegisterPropagators(t *testing.T) {
+	RegisterPropagators()
+
+	fields := otel.GetTextMapPropagator().Fields()
+	assert.Contains(t, fields, "traceparent")
+	assert.Contains(t, fields, "baggage")
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AdminPath is the path under which the admin API is served.
+const AdminPath = "/admin/webhooks/deadletters"
+
+// AdminHandler serves the dead letter admin API. It performs no
+// authentication of its own and must only be exposed on the private port.
+//
+//	GET    /admin/webhooks/deadletters             list dead letters
+//	GET    /admin/webhooks/deadletters/{id}        get a dead letter
+//	POST   /admin/webhooks/deadletters/{id}/replay deliver a dead letter again
+//	DELETE /admin/webhooks/deadletters/{id}        discard a dead letter
+type AdminHandler struct {
+	store DeadLetterStore
+}
+
+// NewAdminHandler creates an AdminHandler for the given store.
+func NewAdminHandler(store DeadLetterStore) *AdminHandler {
+	return &AdminHandler{store: store}
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, AdminPath), "/")
+	segments := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		letters, err := h.store.List()
+		writeResult(w, http.StatusOK, letters, err)
+
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		letter, err := h.store.Get(segments[0])
+		writeResult(w, http.StatusOK, letter, err)
+
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		_, err := h.store.Get(segments[0])
+		if err == nil {
+			err = h.store.Delete(segments[0])
+		}
+		writeResult(w, http.StatusNoContent, nil, err)
+
+	case len(segments) == 2 && segments[1] == "replay" && r.Method == http.MethodPost:
+		h.replay(w, r, segments[0])
+
+	case len(segments) <= 2:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *AdminHandler) replay(w http.ResponseWriter, r *http.Request, id string) {
+	letter, err := h.store.Get(id)
+	if err != nil {
+		writeResult(w, http.StatusOK, nil, err)
+		return
+	}
+
+	webhook, err := Lookup(letter.Webhook)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err := webhook.Replay(r.Context(), letter); err != nil {
+		// the dead letter has been updated with the new error
+		writeResult(w, http.StatusBadGateway, letter, nil)
+		return
+	}
+
+	writeResult(w, http.StatusNoContent, nil, nil)
+}
+
+func writeResult(w http.ResponseWriter, status int, body any, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
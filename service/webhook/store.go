@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/service/shared"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// DefaultPath is the Badger directory used when none is configured.
+const DefaultPath = "./data/webhooks"
+
+const deadLetterPrefix = "webhook/deadletter/"
+
+var ErrNotFound = errors.New("dead letter not found")
+
+// DeadLetter records a delivery which failed after all retries.
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Webhook   string    `json:"webhook"`
+	URL       string    `json:"url"`
+	Event     string    `json:"event,omitempty"`
+	Payload   []byte    `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DeadLetterStore persists dead letters.
+type DeadLetterStore interface {
+	Get(id string) (*DeadLetter, error)
+	Put(letter *DeadLetter) error
+	List() ([]*DeadLetter, error)
+	Delete(id string) error
+}
+
+// BadgerStore keeps dead letters in a Badger database.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+var badgerStores = shared.NewRegistry[*BadgerStore]()
+
+// OpenBadgerStore opens the Badger database at path. Stores are shared by
+// path, so that the admin API and webhook tasks see the same dead letters.
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	return badgerStores.Open(path, func() (*BadgerStore, error) {
+		db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open webhook store %s: %w", path, err)
+		}
+
+		return &BadgerStore{db: db}, nil
+	})
+}
+
+// Close closes the database of the store.
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// Get implements DeadLetterStore.
+func (s *BadgerStore) Get(id string) (*DeadLetter, error) {
+	var letter *DeadLetter
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(storageKey(id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(value []byte) error {
+			letter, err = decodeDeadLetter(value)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return letter, nil
+}
+
+// Put implements DeadLetterStore.
+func (s *BadgerStore) Put(letter *DeadLetter) error {
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(storageKey(letter.ID), value)
+	})
+}
+
+// List implements DeadLetterStore. Dead letters are returned oldest first.
+func (s *BadgerStore) List() ([]*DeadLetter, error) {
+	letters := []*DeadLetter{}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(deadLetterPrefix)
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			err := it.Item().Value(func(value []byte) error {
+				letter, err := decodeDeadLetter(value)
+				if err != nil {
+					return err
+				}
+				letters = append(letters, letter)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].CreatedAt.Before(letters[j].CreatedAt)
+	})
+
+	return letters, nil
+}
+
+// Delete implements DeadLetterStore.
+func (s *BadgerStore) Delete(id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(storageKey(id))
+	})
+}
+
+func storageKey(id string) []byte {
+	return []byte(deadLetterPrefix + id)
+}
+
+func decodeDeadLetter(value []byte) (*DeadLetter, error) {
+	var letter DeadLetter
+	if err := json.Unmarshal(value, &letter); err != nil {
+		return nil, fmt.Errorf("invalid stored dead letter: %w", err)
+	}
+	return &letter, nil
+}
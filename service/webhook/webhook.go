@@ -0,0 +1,440 @@
+// Package webhook delivers signed event notifications to partner URLs.
+// Deliveries are queued and sent by a background worker per URL, so that
+// requests do not wait on partners, and a slow partner does not hold up
+// deliveries to the others. Every delivery is signed with HMAC-SHA256 over a
+// timestamp and the payload, retried with exponential backoff, and recorded
+// as a dead letter once retries are exhausted so that it can be replayed
+// later.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/QueerGlobal/hub-framework/util"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultSignatureHeader = "X-Hub-Signature"
+	DefaultTimestampHeader = "X-Hub-Timestamp"
+	EventHeader            = "X-Hub-Event"
+	DeliveryHeader         = "X-Hub-Delivery"
+
+	// signaturePrefix names the algorithm in the signature header value.
+	signaturePrefix = "sha256="
+)
+
+var (
+	ErrUnknownWebhook = errors.New("unknown webhook")
+
+	errQueueFull = errors.New("webhook delivery queue is full")
+	errClosed    = errors.New("webhook is closed")
+)
+
+// DefaultQueueSize is used when no queue size is configured.
+const DefaultQueueSize = 1000
+
+// DefaultBackoff is used for the retry settings which are not configured.
+var DefaultBackoff = util.BackoffConfig{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	MaxRetries:   5,
+}
+
+// Config describes a webhook.
+type Config struct {
+	// Name identifies the webhook, so that dead letters can be replayed.
+	Name            string
+	URLs            []string
+	Secret          []byte
+	SignatureHeader string
+	TimestampHeader string
+	Timeout         time.Duration
+	Backoff         util.BackoffConfig
+	// QueueSize bounds the deliveries waiting for the worker of each URL.
+	// Deliveries which do not fit are stored as dead letters.
+	QueueSize int
+}
+
+// Event is a notification sent to every URL of a webhook.
+type Event struct {
+	Type    string
+	Payload []byte
+}
+
+// Webhook signs and delivers events.
+type Webhook struct {
+	config Config
+	client *http.Client
+	store  DeadLetterStore
+	now    func() time.Time
+
+	queues  map[string]chan delivery // deliveries waiting, by URL
+	mu      sync.RWMutex             // guards closed against deliveries being queued
+	closed  bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// delivery is an event waiting to be sent to one URL.
+type delivery struct {
+	url   string
+	event Event
+}
+
+// New creates a Webhook storing failed deliveries in store, and starts the
+// workers of its URLs, which run until the Webhook is closed.
+func New(config Config, store DeadLetterStore) (*Webhook, error) {
+	config, err := withDefaults(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Webhook{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		store:  store,
+		now:    time.Now,
+		queues: make(map[string]chan delivery, len(config.URLs)),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, url := range config.URLs {
+		if _, ok := w.queues[url]; ok {
+			continue
+		}
+		queue := make(chan delivery, config.QueueSize)
+		w.queues[url] = queue
+		w.workers.Add(1)
+		go w.run(queue)
+	}
+
+	return w, nil
+}
+
+// withDefaults checks a config and sets the settings which are not
+// configured to their defaults.
+func withDefaults(config Config) (Config, error) {
+	if len(config.URLs) == 0 {
+		return config, errors.New("webhook requires at least one url")
+	}
+	if len(config.Secret) == 0 {
+		return config, errors.New("webhook requires a signing secret")
+	}
+
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = DefaultSignatureHeader
+	}
+	if config.TimestampHeader == "" {
+		config.TimestampHeader = DefaultTimestampHeader
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Backoff.InitialDelay == 0 {
+		config.Backoff.InitialDelay = DefaultBackoff.InitialDelay
+	}
+	if config.Backoff.MaxDelay == 0 {
+		config.Backoff.MaxDelay = DefaultBackoff.MaxDelay
+	}
+	if config.Backoff.Multiplier == 0 {
+		config.Backoff.Multiplier = DefaultBackoff.Multiplier
+	}
+	if config.Backoff.MaxRetries == 0 {
+		config.Backoff.MaxRetries = DefaultBackoff.MaxRetries
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+
+	return config, nil
+}
+
+// Name returns the name of the webhook.
+func (w *Webhook) Name() string {
+	return w.config.Name
+}
+
+// Deliver queues event for delivery to every URL of the webhook. Deliveries
+// which still fail after all retries, and those which cannot be queued
+// because the queue is full or the webhook is closed, are stored as dead
+// letters; an error is only returned when a dead letter cannot be stored.
+func (w *Webhook) Deliver(ctx context.Context, event Event) error {
+	// the payload may belong to a request which is released before the
+	// worker sends it
+	event.Payload = bytes.Clone(event.Payload)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var errs []error
+	for _, url := range w.config.URLs {
+		d := delivery{url: url, event: event}
+
+		reason := errClosed
+		if !w.closed {
+			select {
+			case w.queues[url] <- d:
+				continue
+			default:
+				reason = errQueueFull
+			}
+		}
+
+		if err := w.deadLetter(d, 0, reason); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// run sends the deliveries queued for one URL until the webhook is closed.
+func (w *Webhook) run(queue chan delivery) {
+	defer w.workers.Done()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case d := <-queue:
+			attempts, err := w.send(w.ctx, d.url, d.event)
+			if err == nil {
+				continue
+			}
+			if err := w.deadLetter(d, attempts, err); err != nil {
+				logging.GetLogger().Error().Err(err).Str("webhook", w.config.Name).Msg("webhook delivery lost")
+			}
+		}
+	}
+}
+
+// Close stops the workers. The deliveries in progress are abandoned and,
+// like the deliveries still queued, stored as dead letters to be replayed.
+func (w *Webhook) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	w.cancel()
+	w.workers.Wait()
+
+	var errs []error
+	for _, queue := range w.queues {
+		for drained := false; !drained; {
+			select {
+			case d := <-queue:
+				if err := w.deadLetter(d, 0, errClosed); err != nil {
+					errs = append(errs, err)
+				}
+			default:
+				drained = true
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deadLetter stores a failed delivery after the given number of attempts.
+func (w *Webhook) deadLetter(d delivery, attempts int, cause error) error {
+	logger := logging.GetLogger()
+	logger.Warn().Err(cause).Str("webhook", w.config.Name).Str("url", d.url).Int("attempts", attempts).Msg("webhook delivery failed, storing dead letter")
+
+	now := w.now().UTC()
+	letter := &DeadLetter{
+		ID:        uuid.NewString(),
+		Webhook:   w.config.Name,
+		URL:       d.url,
+		Event:     d.event.Type,
+		Payload:   d.event.Payload,
+		Attempts:  attempts,
+		LastError: cause.Error(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := w.store.Put(letter); err != nil {
+		return fmt.Errorf("failed to store dead letter for %s: %w", d.url, err)
+	}
+	return nil
+}
+
+// Replay retries a dead letter. It is deleted once delivered, and otherwise
+// updated with the new attempts and error.
+func (w *Webhook) Replay(ctx context.Context, letter *DeadLetter) error {
+	attempts, err := w.send(ctx, letter.URL, Event{Type: letter.Event, Payload: letter.Payload})
+	if err == nil {
+		return w.store.Delete(letter.ID)
+	}
+
+	letter.Attempts += attempts
+	letter.LastError = err.Error()
+	letter.UpdatedAt = w.now().UTC()
+	if putErr := w.store.Put(letter); putErr != nil {
+		return errors.Join(err, putErr)
+	}
+
+	return err
+}
+
+// send delivers an event to one URL, retrying with backoff until ctx is
+// done, and returns the number of attempts made. Every attempt is signed
+// with a fresh timestamp but keeps the same delivery ID, so that receivers
+// can discard duplicates.
+func (w *Webhook) send(ctx context.Context, url string, event Event) (int, error) {
+	deliveryID := uuid.NewString()
+
+	return util.NewBackoff(w.config.Backoff).Attempt(ctx, func() error {
+		timestamp := strconv.FormatInt(w.now().Unix(), 10)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(event.Payload))
+		if err != nil {
+			return fmt.Errorf("%s: %w", util.UnrecoverableErrorMsg, err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(w.config.TimestampHeader, timestamp)
+		req.Header.Set(w.config.SignatureHeader, Sign(w.config.Secret, timestamp, event.Payload))
+		req.Header.Set(DeliveryHeader, deliveryID)
+		if event.Type != "" {
+			req.Header.Set(EventHeader, event.Type)
+		}
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%s: %w", util.UnrecoverableErrorMsg, err)
+			}
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			return fmt.Errorf("webhook %s responded %s", url, resp.Status)
+		default:
+			// other client errors will not succeed on retry
+			return fmt.Errorf("webhook %s responded %s: %s", url, resp.Status, util.UnrecoverableErrorMsg)
+		}
+	})
+}
+
+// Sign returns the signature header value for a payload sent at timestamp:
+// the hex encoded HMAC-SHA256 of "<timestamp>.<payload>", prefixed with
+// "sha256=".
+func Sign(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign, and that timestamp is no
+// further than tolerance from now. Receivers may use it to authenticate
+// deliveries.
+func Verify(secret []byte, timestamp string, payload []byte, signature string, tolerance time.Duration, now time.Time) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, payload)))
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Webhook{}
+)
+
+// Open returns the registered webhook of the name of config, creating and
+// registering it if there is none, so that it is available to the admin API
+// for replays. Webhooks are shared by name, so that the tasks and targets of
+// every handler using a webhook, and those rebuilt on reload, deliver through
+// one queue. When a name comes back with another config or store, as when a
+// reload changes its settings, the registered webhook is closed, storing
+// its queued deliveries as dead letters, and replaced.
+func Open(config Config, store DeadLetterStore) (*Webhook, error) {
+	config, err := withDefaults(config)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if existing, ok := registry[config.Name]; ok {
+		if existing.store == store && reflect.DeepEqual(existing.config, config) {
+			return existing, nil
+		}
+		if err := existing.Close(); err != nil {
+			logging.GetLogger().Error().Err(err).Str("webhook", config.Name).Msg("webhook deliveries lost on replacement")
+		}
+		delete(registry, config.Name)
+	}
+
+	w, err := New(config, store)
+	if err != nil {
+		return nil, err
+	}
+	registry[config.Name] = w
+
+	return w, nil
+}
+
+// Lookup returns the registered webhook with the given name.
+func Lookup(name string) (*Webhook, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	w, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWebhook, name)
+	}
+	return w, nil
+}
+
+// CloseAll closes and unregisters every registered webhook.
+func CloseAll() error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var errs []error
+	for name, w := range registry {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(registry, name)
+	}
+	return errors.Join(errs...)
+}
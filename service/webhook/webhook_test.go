@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QueerGlobal/hub-framework/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastBackoff = util.BackoffConfig{
+	InitialDelay: time.Millisecond,
+	MaxDelay:     2 * time.Millisecond,
+	Multiplier:   2,
+	MaxRetries:   3,
+}
+
+func newTestWebhook(t *testing.T, name string, url string) (*Webhook, *BadgerStore) {
+	return newConfiguredWebhook(t, Config{Name: name, URLs: []string{url}, Secret: []byte("s3cret"), Backoff: fastBackoff})
+}
+
+func newConfiguredWebhook(t *testing.T, config Config) (*Webhook, *BadgerStore) {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+
+	w, err := New(config, store)
+	require.NoError(t, err)
+	t.Cleanup(func() { w.Close() })
+	return w, store
+}
+
+// deadLetters waits for the worker to store count dead letters.
+func deadLetters(t *testing.T, store *BadgerStore, count int) []*DeadLetter {
+	t.Helper()
+
+	var letters []*DeadLetter
+	require.Eventually(t, func() bool {
+		var err error
+		letters, err = store.List()
+		return err == nil && len(letters) == count
+	}, 5*time.Second, 5*time.Millisecond)
+	return letters
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(DefaultTimestampHeader)
+
+		assert.True(t, Verify([]byte("s3cret"), timestamp, body, r.Header.Get(DefaultSignatureHeader), time.Minute, time.Now()))
+		assert.False(t, Verify([]byte("other"), timestamp, body, r.Header.Get(DefaultSignatureHeader), time.Minute, time.Now()))
+		assert.Equal(t, "recipe.put", r.Header.Get(EventHeader))
+		assert.NotEmpty(t, r.Header.Get(DeliveryHeader))
+		assert.JSONEq(t, `{"id":"1"}`, string(body))
+
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	w, store := newTestWebhook(t, "signed", server.URL)
+	require.NoError(t, w.Deliver(context.Background(), Event{Type: "recipe.put", Payload: []byte(`{"id":"1"}`)}))
+
+	require.Eventually(t, func() bool { return received.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+	letters, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestVerifyRejectsStaleTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signature := Sign([]byte("k"), "1700000000", []byte("{}"))
+
+	assert.True(t, Verify([]byte("k"), "1700000000", []byte("{}"), signature, time.Minute, now))
+	assert.False(t, Verify([]byte("k"), "1700000000", []byte("{}"), signature, time.Minute, now.Add(2*time.Minute)))
+	assert.False(t, Verify([]byte("k"), "1700000000", []byte("{ }"), signature, time.Minute, now))
+}
+
+func TestDeadLetterAndReplay(t *testing.T) {
+	var healthy atomic.Bool
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+	w, err := Open(Config{Name: "replayed", URLs: []string{server.URL}, Secret: []byte("s3cret"), Backoff: fastBackoff}, store)
+	require.NoError(t, err)
+	t.Cleanup(func() { CloseAll() })
+
+	require.NoError(t, w.Deliver(context.Background(), Event{Type: "recipe.post", Payload: []byte(`{"id":"2"}`)}))
+
+	letters := deadLetters(t, store, 1)
+	assert.Equal(t, int32(3), attempts.Load(), "retried until MaxRetries")
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "replayed", letters[0].Webhook)
+	assert.Equal(t, server.URL, letters[0].URL)
+	assert.Contains(t, letters[0].LastError, "503")
+
+	admin := NewAdminHandler(store)
+
+	list := httptest.NewRecorder()
+	admin.ServeHTTP(list, httptest.NewRequest(http.MethodGet, AdminPath, nil))
+	assert.Equal(t, http.StatusOK, list.Code)
+	var listed []*DeadLetter
+	require.NoError(t, json.Unmarshal(list.Body.Bytes(), &listed))
+	assert.JSONEq(t, `{"id":"2"}`, string(listed[0].Payload))
+
+	replayPath := AdminPath + "/" + letters[0].ID + "/replay"
+	failed := httptest.NewRecorder()
+	admin.ServeHTTP(failed, httptest.NewRequest(http.MethodPost, replayPath, nil))
+	assert.Equal(t, http.StatusBadGateway, failed.Code)
+	updated, err := store.Get(letters[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, updated.Attempts)
+
+	healthy.Store(true)
+	replayed := httptest.NewRecorder()
+	admin.ServeHTTP(replayed, httptest.NewRequest(http.MethodPost, replayPath, nil))
+	assert.Equal(t, http.StatusNoContent, replayed.Code)
+
+	_, err = store.Get(letters[0].ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	missing := httptest.NewRecorder()
+	admin.ServeHTTP(missing, httptest.NewRequest(http.MethodPost, replayPath, nil))
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w, store := newTestWebhook(t, "rejected", server.URL)
+	require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{}`)}))
+
+	letters := deadLetters(t, store, 1)
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, 1, letters[0].Attempts)
+}
+
+func TestCloseAbandonsBackoff(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	slow := util.BackoffConfig{InitialDelay: time.Hour, MaxDelay: time.Hour, Multiplier: 2, MaxRetries: 5}
+	w, store := newConfiguredWebhook(t, Config{Name: "slow", URLs: []string{server.URL}, Secret: []byte("s3cret"), Backoff: slow})
+
+	start := time.Now()
+	require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{}`)}))
+	assert.Less(t, time.Since(start), time.Second, "deliveries do not wait on the partner")
+
+	require.Eventually(t, func() bool { return attempts.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, w.Close())
+
+	letters := deadLetters(t, store, 1)
+	assert.Equal(t, 1, letters[0].Attempts)
+
+	// deliveries after closing are kept as dead letters
+	require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{}`)}))
+	deadLetters(t, store, 2)
+}
+
+func TestFullQueueStoresDeadLetters(t *testing.T) {
+	release := make(chan struct{})
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	w, store := newConfiguredWebhook(t, Config{Name: "busy", URLs: []string{server.URL}, Secret: []byte("s3cret"), Backoff: fastBackoff, QueueSize: 1})
+
+	require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{"n":1}`)}))
+	require.Eventually(t, func() bool { return attempts.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{"n":2}`)}))
+	require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{"n":3}`)}))
+
+	letters := deadLetters(t, store, 1)
+	assert.JSONEq(t, `{"n":3}`, string(letters[0].Payload))
+	assert.Equal(t, 0, letters[0].Attempts)
+}
+
+func TestOpenSharesWebhooksByName(t *testing.T) {
+	store, err := OpenBadgerStore(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { CloseAll() })
+
+	config := Config{Name: "shared", URLs: []string{"http://localhost/a"}, Secret: []byte("s3cret")}
+	first, err := Open(config, store)
+	require.NoError(t, err)
+	second, err := Open(config, store)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// new settings, as on a reload, replace the webhook
+	require.NoError(t, first.Deliver(context.Background(), Event{Payload: []byte(`{}`)}))
+
+	other := config
+	other.URLs = []string{"http://localhost/b"}
+	replaced, err := Open(other, store)
+	require.NoError(t, err)
+	assert.NotSame(t, first, replaced)
+
+	registered, err := Lookup("shared")
+	require.NoError(t, err)
+	assert.Same(t, replaced, registered)
+
+	// the deliveries of the replaced webhook are kept as dead letters
+	require.NoError(t, first.Deliver(context.Background(), Event{Payload: []byte(`{}`)}))
+	letters := deadLetters(t, store, 2)
+	for _, letter := range letters {
+		assert.Equal(t, "http://localhost/a", letter.URL)
+	}
+}
+
+func TestSlowURLDoesNotHoldUpOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	var received atomic.Int32
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer fast.Close()
+
+	w, _ := newConfiguredWebhook(t, Config{
+		Name:    "fanout",
+		URLs:    []string{slow.URL, fast.URL},
+		Secret:  []byte("s3cret"),
+		Backoff: fastBackoff,
+	})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, w.Deliver(context.Background(), Event{Payload: []byte(`{}`)}))
+	}
+	require.Eventually(t, func() bool { return received.Load() == 3 }, 5*time.Second, 5*time.Millisecond)
+}
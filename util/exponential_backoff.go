@@ -1,11 +1,13 @@
 package util
 
 import (
-	"fmt"
-	"github.com/pkg/errors"
+	"context"
 	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/QueerGlobal/hub-framework/service/logging"
+	"github.com/pkg/errors"
 )
 
 var UnrecoverableErrorMsg = "error is unrecoverable - backoff cancelled"
@@ -25,32 +27,58 @@ func NewBackoff(config BackoffConfig) *Backoff {
 	return &Backoff{config: config}
 }
 
-func (b *Backoff) ExecuteWithBackoff(operation func() error) error {
+// ExecuteWithBackoff runs operation until it succeeds, returns an
+// unrecoverable error or has been attempted MaxRetries times, waiting with
+// exponential backoff between attempts. Waiting stops when ctx is done.
+func (b *Backoff) ExecuteWithBackoff(ctx context.Context, operation func() error) error {
+	_, err := b.Attempt(ctx, operation)
+	return err
+}
+
+// Attempt runs operation as ExecuteWithBackoff does, and also returns the
+// number of attempts made.
+func (b *Backoff) Attempt(ctx context.Context, operation func() error) (int, error) {
+	logger := logging.GetLogger()
 	delay := b.config.InitialDelay
 
 	var err error
-	for i := 0; i < b.config.MaxRetries; i++ {
+	attempts := 0
+	for attempts < b.config.MaxRetries {
+		attempts++
+
 		err = operation()
 		if err == nil {
-			return nil
+			return attempts, nil
 		}
 
 		if strings.Contains(err.Error(), UnrecoverableErrorMsg) {
-			fmt.Println("Unrecoverable error encountered, canceling backoff:", err) // TODO - replace this with proper logging
-			return err
+			logger.Debug().Err(err).Int("attempt", attempts).Msg("unrecoverable error encountered, canceling backoff")
+			return attempts, err
 		}
 
-		fmt.Printf("Attempt %d failed: %v. Retrying in %v...\n", i+1, err, delay) // TODO - replace this with proper logging
-		time.Sleep(delay)
+		if attempts == b.config.MaxRetries {
+			break
+		}
+
+		logger.Debug().Err(err).Int("attempt", attempts).Dur("delay", delay).Msg("attempt failed, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, errors.Wrapf(err, "retries cancelled: %v", ctx.Err())
+		case <-timer.C:
+		}
 
 		// Calculate next delay with jitter
 		delay = time.Duration(float64(delay) * b.config.Multiplier)
 		if delay > b.config.MaxDelay {
 			delay = b.config.MaxDelay
 		}
-		jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-		delay += jitter
+		if half := int64(delay / 2); half > 0 {
+			delay += time.Duration(rand.Int63n(half))
+		}
 	}
 
-	return errors.Wrap(err, "all retry attempts failed")
+	return attempts, errors.Wrap(err, "all retry attempts failed")
 }